@@ -57,6 +57,7 @@ func (ct *CellType) fallbackTo(cellData string, fallback CellType) CellType {
 type Cell struct {
 	Row            *Row
 	Value          string
+	richText       RichText
 	formula        string
 	style          *Style
 	NumFmt         string
@@ -68,6 +69,7 @@ type Cell struct {
 	cellType       CellType
 	DataValidation *xlsxDataValidation
 	Hyperlink      Hyperlink
+	Comment        Comment
 }
 
 type Hyperlink struct {
@@ -101,6 +103,7 @@ func (c *Cell) Type() CellType {
 // SetString sets the value of a cell to a string.
 func (c *Cell) SetString(s string) {
 	c.Value = s
+	c.richText = nil
 	c.formula = ""
 	c.cellType = CellTypeString
 }
@@ -401,10 +404,20 @@ func (c *Cell) getNumberFormat() *parsedNumberFormat {
 // FormattedValue returns a value, and possibly an error condition
 // from a Cell.  If it is possible to apply a format to the cell
 // value, it will do so, if not then an error will be returned, along
-// with the raw value of the Cell.
+// with the raw value of the Cell.  As there is no cell width to fill,
+// the repeated character of a *x in the format is left out, while the
+// _x that leaves room for the width of a character is shown as a space.
 func (c *Cell) FormattedValue() (string, error) {
+	return c.FormattedValueWithLocale(nil)
+}
+
+// FormattedValueWithLocale is like FormattedValue, but shows the
+// value with the separators and names of a locale. A format that
+// names a registered locale, as in [$€-407]#,##0.00, is shown in that
+// locale instead. A nil locale is the same as LocaleEnUS.
+func (c *Cell) FormattedValueWithLocale(locale *Locale) (string, error) {
 	fullFormat := c.getNumberFormat()
-	returnVal, err := fullFormat.FormatValue(c)
+	returnVal, err := fullFormat.formatValue(c, locale)
 	if fullFormat.parseEncounteredError != nil {
 		return returnVal, *fullFormat.parseEncounteredError
 	}
@@ -412,34 +412,30 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	cell.NumFmt = "0"
 	fvc.Equals(cell, "37948")
 
-	cell.NumFmt = "#,##0" // For the time being we're not doing
-	// this comma formatting, so it'll fall back to the related
-	// non-comma form.
-	fvc.Equals(cell, "37948")
+	cell.NumFmt = "#,##0"
+	fvc.Equals(cell, "37,948")
 
 	cell.NumFmt = "#,##0.00;(#,##0.00)"
-	fvc.Equals(cell, "37947.75")
+	fvc.Equals(cell, "37,947.75")
 
 	cell.NumFmt = "0.00"
 	fvc.Equals(cell, "37947.75")
 
-	cell.NumFmt = "#,##0.00" // For the time being we're not doing
-	// this comma formatting, so it'll fall back to the related
-	// non-comma form.
-	fvc.Equals(cell, "37947.75")
+	cell.NumFmt = "#,##0.00"
+	fvc.Equals(cell, "37,947.75")
 
 	cell.NumFmt = "#,##0 ;(#,##0)"
-	fvc.Equals(cell, "37948")
+	fvc.Equals(cell, "37,948 ")
 	negativeCell.NumFmt = "#,##0 ;(#,##0)"
-	fvc.Equals(negativeCell, "(37948)")
+	fvc.Equals(negativeCell, "(37,948)")
 
 	cell.NumFmt = "#,##0 ;[red](#,##0)"
-	fvc.Equals(cell, "37948")
+	fvc.Equals(cell, "37,948 ")
 	negativeCell.NumFmt = "#,##0 ;[red](#,##0)"
-	fvc.Equals(negativeCell, "(37948)")
+	fvc.Equals(negativeCell, "(37,948)")
 
 	negativeCell.NumFmt = "#,##0.00;(#,##0.00)"
-	fvc.Equals(negativeCell, "(37947.75)")
+	fvc.Equals(negativeCell, "(37,947.75)")
 
 	cell.NumFmt = "0%"
 	fvc.Equals(cell, "3794775%")
@@ -448,10 +444,10 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	fvc.Equals(cell, "3794775.00%")
 
 	cell.NumFmt = "0.00e+00"
-	fvc.Equals(cell, "3.794775e+04")
+	fvc.Equals(cell, "3.79e+04")
 
-	cell.NumFmt = "##0.0e+0" // This is wrong, but we'll use it for now.
-	fvc.Equals(cell, "3.794775e+04")
+	cell.NumFmt = "##0.0e+0"
+	fvc.Equals(cell, "37.9e+3")
 
 	cell.NumFmt = "mm-dd-yy"
 	fvc.Equals(cell, "11-22-03")
@@ -479,12 +475,12 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	cell.NumFmt = "hh:mm:ss"
 	fvc.Equals(cell, "18:00:00")
 	smallCell.NumFmt = "h:mm:ss am/pm"
-	fvc.Equals(smallCell, "12:10:04 am")
+	fvc.Equals(smallCell, "12:10:05 am")
 
 	cell.NumFmt = "h:mm"
 	fvc.Equals(cell, "18:00")
 	smallCell.NumFmt = "h:mm"
-	fvc.Equals(smallCell, "00:10")
+	fvc.Equals(smallCell, "0:10")
 	smallCell.NumFmt = "hh:mm"
 	fvc.Equals(smallCell, "00:10")
 
@@ -493,53 +489,58 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	cell.NumFmt = "hh:mm:ss"
 	fvc.Equals(cell, "18:00:00")
 
+	// Excel rounds to the second, so 10:04.8 is shown as 10:05.
 	smallCell.NumFmt = "hh:mm:ss"
-	fvc.Equals(smallCell, "00:10:04")
+	fvc.Equals(smallCell, "00:10:05")
 	smallCell.NumFmt = "h:mm:ss"
-	fvc.Equals(smallCell, "00:10:04")
+	fvc.Equals(smallCell, "0:10:05")
 
 	cell.NumFmt = "m/d/yy h:mm"
 	fvc.Equals(cell, "11/22/03 18:00")
 	cell.NumFmt = "m/d/yy hh:mm"
 	fvc.Equals(cell, "11/22/03 18:00")
 	smallCell.NumFmt = "m/d/yy h:mm"
-	fvc.Equals(smallCell, "12/30/99 00:10")
+	fvc.Equals(smallCell, "12/30/99 0:10")
 	smallCell.NumFmt = "m/d/yy hh:mm"
 	fvc.Equals(smallCell, "12/30/99 00:10")
 	earlyCell.NumFmt = "m/d/yy hh:mm"
 	fvc.Equals(earlyCell, "1/1/00 02:24")
 	earlyCell.NumFmt = "m/d/yy h:mm"
-	fvc.Equals(earlyCell, "1/1/00 02:24")
+	fvc.Equals(earlyCell, "1/1/00 2:24")
 
 	cell.NumFmt = "mm:ss"
 	fvc.Equals(cell, "00:00")
 	smallCell.NumFmt = "mm:ss"
-	fvc.Equals(smallCell, "10:04")
+	fvc.Equals(smallCell, "10:05")
 
+	// Elapsed hours count every hour since the start of the
+	// calendar.
 	cell.NumFmt = "[hh]:mm:ss"
-	fvc.Equals(cell, "18:00:00")
+	fvc.Equals(cell, "910746:00:00")
 	cell.NumFmt = "[h]:mm:ss"
-	fvc.Equals(cell, "18:00:00")
+	fvc.Equals(cell, "910746:00:00")
 	smallCell.NumFmt = "[h]:mm:ss"
-	fvc.Equals(smallCell, "10:04")
-
-	const (
-		expect1 = "0000.0086"
-		expect2 = "1004.8000"
-		format  = "mmss.0000"
-		tlen    = len(format)
-	)
-
-	for i := 0; i < 3; i++ {
-		tfmt := format[0 : tlen-i]
-		cell.NumFmt = tfmt
-		fvc.Equals(cell, expect1[0:tlen-i])
-		smallCell.NumFmt = tfmt
-		fvc.Equals(smallCell, expect2[0:tlen-i])
+	fvc.Equals(smallCell, "0:10:05")
+	smallCell.NumFmt = "[mm]:ss"
+	fvc.Equals(smallCell, "10:05")
+	smallCell.NumFmt = "[ss]"
+	fvc.Equals(smallCell, "605")
+
+	// Fractions of a second are rounded to the digits shown.
+	for format, expected := range map[string][2]string{
+		"mmss.0000": {"0000.0086", "1004.8000"},
+		"mmss.000":  {"0000.009", "1004.800"},
+		"mmss.00":   {"0000.01", "1004.80"},
+		"mmss.0":    {"0000.0", "1004.8"},
+	} {
+		cell.NumFmt = format
+		fvc.Equals(cell, expected[0])
+		smallCell.NumFmt = format
+		fvc.Equals(smallCell, expected[1])
 	}
 
 	cell.NumFmt = "yyyy\\-mm\\-dd"
-	fvc.Equals(cell, "2003\\-11\\-22")
+	fvc.Equals(cell, "2003-11-22")
 
 	cell.NumFmt = "dd/mm/yyyy hh:mm:ss"
 	fvc.Equals(cell, "22/11/2003 18:00:00")
@@ -552,10 +553,10 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	cell.NumFmt = "hh:mm:ss"
 	fvc.Equals(cell, "18:00:00")
 	smallCell.NumFmt = "hh:mm:ss"
-	fvc.Equals(smallCell, "00:10:04")
+	fvc.Equals(smallCell, "00:10:05")
 
 	cell.NumFmt = "dd/mm/yy\\ hh:mm"
-	fvc.Equals(cell, "22/11/03\\ 18:00")
+	fvc.Equals(cell, "22/11/03 18:00")
 
 	cell.NumFmt = "yyyy/mm/dd"
 	fvc.Equals(cell, "2003/11/22")
@@ -592,12 +593,12 @@ func (l *CellSuite) TestFormattedValue(c *C) {
 	cell.NumFmt = "mm/dd/yyyy hh:mm:ss"
 	fvc.Equals(cell, "11/22/2003 18:00:00")
 	smallCell.NumFmt = "mm/dd/yyyy hh:mm:ss"
-	fvc.Equals(smallCell, "12/30/1899 00:10:04")
+	fvc.Equals(smallCell, "12/30/1899 00:10:05")
 
 	cell.NumFmt = "yyyy-mm-dd hh:mm:ss"
 	fvc.Equals(cell, "2003-11-22 18:00:00")
 	smallCell.NumFmt = "yyyy-mm-dd hh:mm:ss"
-	fvc.Equals(smallCell, "1899-12-30 00:10:04")
+	fvc.Equals(smallCell, "1899-12-30 00:10:05")
 
 	cell.NumFmt = "mmmm d, yyyy"
 	fvc.Equals(cell, "November 22, 2003")
@@ -0,0 +1,570 @@
+package xlsx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Encrypted XLSX files are not zip files but Compound File Binary
+// files, as described by [MS-CFB], that hold the encrypted zip in a
+// stream.  Just enough of the format is implemented here to read and
+// write the streams of such a file.
+
+const (
+	cfbSignature        = "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"
+	cfbHeaderSize       = 512
+	cfbDirEntrySize     = 128
+	cfbMiniSectorSize   = 64
+	cfbMiniStreamCutoff = 4096
+	cfbHeaderDIFATCount = 109
+	cfbMaxNameLength    = 31
+
+	cfbMaxRegSect  = 0xFFFFFFFA
+	cfbDIFSect     = 0xFFFFFFFC
+	cfbFATSect     = 0xFFFFFFFD
+	cfbEndOfChain  = 0xFFFFFFFE
+	cfbFreeSect    = 0xFFFFFFFF
+	cfbNoStream    = 0xFFFFFFFF
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+	cfbColorBlack  = 1
+)
+
+// isCFB returns true if data starts like a Compound File Binary file.
+func isCFB(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(cfbSignature))
+}
+
+type cfbDirEntry struct {
+	name  string
+	typ   byte
+	left  uint32
+	right uint32
+	child uint32
+	start uint32
+	size  uint64
+}
+
+type cfbReader struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []cfbDirEntry
+}
+
+// readCFB returns the streams of a Compound File Binary file, keyed
+// by their path from the root storage, such as "EncryptionInfo" or
+// "\x06DataSpaces/Version".
+func readCFB(data []byte) (map[string][]byte, error) {
+	if !isCFB(data) || len(data) < cfbHeaderSize {
+		return nil, errors.New("not a compound file")
+	}
+	le := binary.LittleEndian
+	r := &cfbReader{data: data}
+	major := le.Uint16(data[26:])
+	sectorShift := le.Uint16(data[30:])
+	if !(major == 3 && sectorShift == 9) && !(major == 4 && sectorShift == 12) {
+		return nil, fmt.Errorf("unsupported compound file version %d with sector shift %d", major, sectorShift)
+	}
+	if miniSectorShift := le.Uint16(data[32:]); miniSectorShift != 6 {
+		return nil, fmt.Errorf("unsupported compound file mini sector shift %d", miniSectorShift)
+	}
+	r.sectorSize = 1 << sectorShift
+	fatCount := int(le.Uint32(data[44:]))
+	firstDirSector := le.Uint32(data[48:])
+	firstMiniFATSector := le.Uint32(data[60:])
+	firstDIFATSector := le.Uint32(data[68:])
+
+	var fatSectors []uint32
+	for i := 0; i < cfbHeaderDIFATCount && len(fatSectors) < fatCount; i++ {
+		fatSectors = append(fatSectors, le.Uint32(data[76+4*i:]))
+	}
+	entriesPerSector := r.sectorSize / 4
+	for next, n := firstDIFATSector, 0; next <= cfbMaxRegSect && len(fatSectors) < fatCount; n++ {
+		if n > fatCount {
+			return nil, errors.New("compound file DIFAT chain is corrupt")
+		}
+		sector, err := r.sector(next)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < entriesPerSector-1 && len(fatSectors) < fatCount; i++ {
+			fatSectors = append(fatSectors, le.Uint32(sector[4*i:]))
+		}
+		next = le.Uint32(sector[r.sectorSize-4:])
+	}
+	for _, s := range fatSectors {
+		sector, err := r.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < entriesPerSector; i++ {
+			r.fat = append(r.fat, le.Uint32(sector[4*i:]))
+		}
+	}
+
+	dir, err := r.chain(firstDirSector, r.fat, r.sector)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+cfbDirEntrySize <= len(dir); i += cfbDirEntrySize {
+		r.entries = append(r.entries, readCFBDirEntry(dir[i:i+cfbDirEntrySize], major))
+	}
+	if len(r.entries) == 0 || r.entries[0].typ != cfbTypeRoot {
+		return nil, errors.New("compound file has no root entry")
+	}
+
+	if firstMiniFATSector <= cfbMaxRegSect {
+		miniFAT, err := r.chain(firstMiniFATSector, r.fat, r.sector)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+4 <= len(miniFAT); i += 4 {
+			r.miniFAT = append(r.miniFAT, le.Uint32(miniFAT[i:]))
+		}
+	}
+	root := r.entries[0]
+	if root.start <= cfbMaxRegSect {
+		r.miniStream, err = r.chain(root.start, r.fat, r.sector)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(r.miniStream)) > root.size {
+			r.miniStream = r.miniStream[:root.size]
+		}
+	}
+
+	streams := make(map[string][]byte)
+	visited := make(map[uint32]bool)
+	var walk func(id uint32, dir string) error
+	walk = func(id uint32, dir string) error {
+		if id == cfbNoStream {
+			return nil
+		}
+		if int(id) >= len(r.entries) || visited[id] {
+			return errors.New("compound file directory is corrupt")
+		}
+		visited[id] = true
+		entry := r.entries[id]
+		if err := walk(entry.left, dir); err != nil {
+			return err
+		}
+		if err := walk(entry.right, dir); err != nil {
+			return err
+		}
+		switch entry.typ {
+		case cfbTypeStorage:
+			return walk(entry.child, dir+entry.name+"/")
+		case cfbTypeStream:
+			stream, err := r.stream(entry)
+			if err != nil {
+				return err
+			}
+			streams[dir+entry.name] = stream
+		}
+		return nil
+	}
+	if err := walk(root.child, ""); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+func readCFBDirEntry(b []byte, major uint16) cfbDirEntry {
+	le := binary.LittleEndian
+	nameLength := int(le.Uint16(b[64:]))/2 - 1
+	if nameLength < 0 {
+		nameLength = 0
+	} else if nameLength > cfbMaxNameLength {
+		nameLength = cfbMaxNameLength
+	}
+	name := make([]uint16, nameLength)
+	for i := range name {
+		name[i] = le.Uint16(b[2*i:])
+	}
+	size := le.Uint64(b[120:])
+	if major == 3 {
+		// Version 3 files may have garbage in the high bits.
+		size &= 0xFFFFFFFF
+	}
+	return cfbDirEntry{
+		name:  string(utf16.Decode(name)),
+		typ:   b[66],
+		left:  le.Uint32(b[68:]),
+		right: le.Uint32(b[72:]),
+		child: le.Uint32(b[76:]),
+		start: le.Uint32(b[116:]),
+		size:  size,
+	}
+}
+
+// sector returns the contents of a sector of the file.  The last
+// sector may be cut short by some writers, so it is padded.
+func (r *cfbReader) sector(n uint32) ([]byte, error) {
+	offset := (int64(n) + 1) * int64(r.sectorSize)
+	if n > cfbMaxRegSect || offset >= int64(len(r.data)) {
+		return nil, fmt.Errorf("compound file sector %d is out of range", n)
+	}
+	end := offset + int64(r.sectorSize)
+	if end <= int64(len(r.data)) {
+		return r.data[offset:end], nil
+	}
+	sector := make([]byte, r.sectorSize)
+	copy(sector, r.data[offset:])
+	return sector, nil
+}
+
+// miniSector returns the contents of a sector of the mini stream,
+// which is padded in the same way.
+func (r *cfbReader) miniSector(n uint32) ([]byte, error) {
+	offset := int64(n) * cfbMiniSectorSize
+	if n > cfbMaxRegSect || offset >= int64(len(r.miniStream)) {
+		return nil, fmt.Errorf("compound file mini sector %d is out of range", n)
+	}
+	end := offset + cfbMiniSectorSize
+	if end <= int64(len(r.miniStream)) {
+		return r.miniStream[offset:end], nil
+	}
+	sector := make([]byte, cfbMiniSectorSize)
+	copy(sector, r.miniStream[offset:])
+	return sector, nil
+}
+
+// chain returns the contents of the chain of sectors that starts
+// with start in the given allocation table.
+func (r *cfbReader) chain(start uint32, table []uint32, sector func(uint32) ([]byte, error)) ([]byte, error) {
+	var result []byte
+	for n, next := 0, start; next != cfbEndOfChain; n++ {
+		if n > len(table) || int(next) >= len(table) {
+			return nil, errors.New("compound file sector chain is corrupt")
+		}
+		s, err := sector(next)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s...)
+		next = table[next]
+	}
+	return result, nil
+}
+
+func (r *cfbReader) stream(entry cfbDirEntry) ([]byte, error) {
+	if entry.size == 0 {
+		return []byte{}, nil
+	}
+	var stream []byte
+	var err error
+	if entry.size < cfbMiniStreamCutoff {
+		stream, err = r.chain(entry.start, r.miniFAT, r.miniSector)
+	} else {
+		stream, err = r.chain(entry.start, r.fat, r.sector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(stream)) < entry.size {
+		return nil, fmt.Errorf("compound file stream %q is truncated", entry.name)
+	}
+	return stream[:entry.size], nil
+}
+
+// cfbNode is a storage or stream that is to be written.
+type cfbNode struct {
+	name     string
+	typ      byte
+	data     []byte
+	children []*cfbNode
+	id       uint32
+	left     uint32
+	right    uint32
+	child    uint32
+	start    uint32
+}
+
+// cfbNameLess orders the entries of a storage the way the format
+// requires: shorter names first, and then by their upper case.
+func cfbNameLess(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	if len(ua) != len(ub) {
+		return len(ua) < len(ub)
+	}
+	for i := range ua {
+		ca, cb := unicode.ToUpper(rune(ua[i])), unicode.ToUpper(rune(ub[i]))
+		if ca != cb {
+			return ca < cb
+		}
+	}
+	return false
+}
+
+// writeCFB returns a Compound File Binary file of the given version,
+// 3 or 4, that holds the streams, keyed by their path from the root
+// storage.
+func writeCFB(streams map[string][]byte, version uint16) ([]byte, error) {
+	sectorSize := 512
+	if version == 4 {
+		sectorSize = 4096
+	} else if version != 3 {
+		return nil, fmt.Errorf("unsupported compound file version %d", version)
+	}
+
+	// Build the tree of storages and streams.
+	root := &cfbNode{name: "Root Entry", typ: cfbTypeRoot}
+	storages := map[string]*cfbNode{"": root}
+	paths := make([]string, 0, len(streams))
+	for path := range streams {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		names := strings.Split(path, "/")
+		parent := root
+		for i, name := range names {
+			if name == "" || len(utf16.Encode([]rune(name))) > cfbMaxNameLength {
+				return nil, fmt.Errorf("invalid compound file stream name %q", path)
+			}
+			key := strings.Join(names[:i+1], "/")
+			if i == len(names)-1 {
+				if storages[key] != nil {
+					return nil, fmt.Errorf("compound file stream %q is also a storage", path)
+				}
+				parent.children = append(parent.children, &cfbNode{name: name, typ: cfbTypeStream, data: streams[path]})
+				break
+			}
+			storage, ok := storages[key]
+			if !ok {
+				if _, ok := streams[key]; ok {
+					return nil, fmt.Errorf("compound file stream %q is also a storage", key)
+				}
+				storage = &cfbNode{name: name, typ: cfbTypeStorage}
+				storages[key] = storage
+				parent.children = append(parent.children, storage)
+			}
+			parent = storage
+		}
+	}
+
+	// Number the entries, and make the children of each storage a
+	// balanced binary tree.  All the entries are black, which keeps
+	// it a valid red-black tree for readers that care.
+	nodes := []*cfbNode{root}
+	for i := 0; i < len(nodes); i++ {
+		node := nodes[i]
+		node.left, node.right, node.child = cfbNoStream, cfbNoStream, cfbNoStream
+		children := node.children
+		sort.Slice(children, func(a, b int) bool { return cfbNameLess(children[a].name, children[b].name) })
+		for _, child := range children {
+			child.id = uint32(len(nodes))
+			nodes = append(nodes, child)
+		}
+	}
+	var balance func(children []*cfbNode) uint32
+	balance = func(children []*cfbNode) uint32 {
+		if len(children) == 0 {
+			return cfbNoStream
+		}
+		mid := len(children) / 2
+		children[mid].left = balance(children[:mid])
+		children[mid].right = balance(children[mid+1:])
+		return children[mid].id
+	}
+	for _, node := range nodes {
+		node.child = balance(node.children)
+	}
+
+	// Lay out the sectors: the large streams, the mini stream, the
+	// mini FAT, the directory, the FAT and the DIFAT.
+	var fat []uint32
+	allocate := func(count int, next func(i int) uint32) uint32 {
+		if count == 0 {
+			return cfbEndOfChain
+		}
+		start := uint32(len(fat))
+		for i := 0; i < count; i++ {
+			fat = append(fat, next(i))
+		}
+		return start
+	}
+	chained := func(count int) func(i int) uint32 {
+		return func(i int) uint32 {
+			if i == count-1 {
+				return cfbEndOfChain
+			}
+			return uint32(len(fat) + 1)
+		}
+	}
+	sectors := func(size, sectorSize int) int {
+		return (size + sectorSize - 1) / sectorSize
+	}
+
+	var body bytes.Buffer
+	pad := func(buf *bytes.Buffer, size int) {
+		if rem := buf.Len() % size; rem != 0 {
+			buf.Write(make([]byte, size-rem))
+		}
+	}
+	var miniStream bytes.Buffer
+	var miniFAT []uint32
+	for _, node := range nodes {
+		if node.typ != cfbTypeStream {
+			continue
+		}
+		size := len(node.data)
+		switch {
+		case size == 0:
+			node.start = cfbEndOfChain
+		case size < cfbMiniStreamCutoff:
+			count := sectors(size, cfbMiniSectorSize)
+			node.start = uint32(len(miniFAT))
+			for i := 0; i < count; i++ {
+				if i == count-1 {
+					miniFAT = append(miniFAT, cfbEndOfChain)
+				} else {
+					miniFAT = append(miniFAT, uint32(len(miniFAT)+1))
+				}
+			}
+			miniStream.Write(node.data)
+			pad(&miniStream, cfbMiniSectorSize)
+		default:
+			count := sectors(size, sectorSize)
+			node.start = allocate(count, chained(count))
+			body.Write(node.data)
+			pad(&body, sectorSize)
+		}
+	}
+	count := sectors(miniStream.Len(), sectorSize)
+	root.start = allocate(count, chained(count))
+	root.data = miniStream.Bytes()
+	body.Write(root.data)
+	pad(&body, sectorSize)
+
+	count = sectors(4*len(miniFAT), sectorSize)
+	firstMiniFATSector := allocate(count, chained(count))
+	for _, next := range miniFAT {
+		binary.Write(&body, binary.LittleEndian, next)
+	}
+	for i := len(miniFAT); i < count*sectorSize/4; i++ {
+		binary.Write(&body, binary.LittleEndian, uint32(cfbFreeSect))
+	}
+
+	entriesPerSector := sectorSize / cfbDirEntrySize
+	dirSectorCount := sectors(len(nodes), entriesPerSector)
+	firstDirSector := allocate(dirSectorCount, chained(dirSectorCount))
+	for _, node := range nodes {
+		body.Write(node.dirEntry())
+	}
+	for i := len(nodes); i < dirSectorCount*entriesPerSector; i++ {
+		body.Write(emptyCFBDirEntry())
+	}
+
+	// The FAT has to cover its own sectors and those of the DIFAT.
+	fatEntriesPerSector := sectorSize / 4
+	difatEntriesPerSector := fatEntriesPerSector - 1
+	fatCount, difatCount := 0, 0
+	for {
+		total := len(fat) + fatCount + difatCount
+		f := sectors(total, fatEntriesPerSector)
+		d := 0
+		if f > cfbHeaderDIFATCount {
+			d = sectors(f-cfbHeaderDIFATCount, difatEntriesPerSector)
+		}
+		if f == fatCount && d == difatCount {
+			break
+		}
+		fatCount, difatCount = f, d
+	}
+	firstFATSector := allocate(fatCount, func(int) uint32 { return cfbFATSect })
+	firstDIFATSector := allocate(difatCount, func(int) uint32 { return cfbDIFSect })
+	for len(fat) < fatCount*fatEntriesPerSector {
+		fat = append(fat, cfbFreeSect)
+	}
+	for _, next := range fat {
+		binary.Write(&body, binary.LittleEndian, next)
+	}
+	fatSectors := make([]uint32, fatCount)
+	for i := range fatSectors {
+		fatSectors[i] = firstFATSector + uint32(i)
+	}
+	for i := 0; i < difatCount; i++ {
+		for j := 0; j < difatEntriesPerSector; j++ {
+			k := cfbHeaderDIFATCount + i*difatEntriesPerSector + j
+			if k < len(fatSectors) {
+				binary.Write(&body, binary.LittleEndian, fatSectors[k])
+			} else {
+				binary.Write(&body, binary.LittleEndian, uint32(cfbFreeSect))
+			}
+		}
+		next := uint32(cfbEndOfChain)
+		if i < difatCount-1 {
+			next = firstDIFATSector + uint32(i) + 1
+		}
+		binary.Write(&body, binary.LittleEndian, next)
+	}
+
+	header := make([]byte, sectorSize)
+	le := binary.LittleEndian
+	copy(header, cfbSignature)
+	le.PutUint16(header[24:], 0x3E)
+	le.PutUint16(header[26:], version)
+	le.PutUint16(header[28:], 0xFFFE)
+	if version == 4 {
+		le.PutUint16(header[30:], 12)
+		le.PutUint32(header[40:], uint32(dirSectorCount))
+	} else {
+		le.PutUint16(header[30:], 9)
+	}
+	le.PutUint16(header[32:], 6)
+	le.PutUint32(header[44:], uint32(fatCount))
+	le.PutUint32(header[48:], firstDirSector)
+	le.PutUint32(header[56:], cfbMiniStreamCutoff)
+	le.PutUint32(header[60:], firstMiniFATSector)
+	le.PutUint32(header[64:], uint32(sectors(4*len(miniFAT), sectorSize)))
+	le.PutUint32(header[68:], firstDIFATSector)
+	le.PutUint32(header[72:], uint32(difatCount))
+	for i := 0; i < cfbHeaderDIFATCount; i++ {
+		next := uint32(cfbFreeSect)
+		if i < len(fatSectors) {
+			next = fatSectors[i]
+		}
+		le.PutUint32(header[76+4*i:], next)
+	}
+	return append(header, body.Bytes()...), nil
+}
+
+func (node *cfbNode) dirEntry() []byte {
+	b := make([]byte, cfbDirEntrySize)
+	le := binary.LittleEndian
+	name := utf16.Encode([]rune(node.name))
+	for i, c := range name {
+		le.PutUint16(b[2*i:], c)
+	}
+	le.PutUint16(b[64:], uint16(2*(len(name)+1)))
+	b[66] = node.typ
+	b[67] = cfbColorBlack
+	le.PutUint32(b[68:], node.left)
+	le.PutUint32(b[72:], node.right)
+	le.PutUint32(b[76:], node.child)
+	if node.typ != cfbTypeStorage {
+		le.PutUint32(b[116:], node.start)
+		le.PutUint64(b[120:], uint64(len(node.data)))
+	}
+	return b
+}
+
+func emptyCFBDirEntry() []byte {
+	b := make([]byte, cfbDirEntrySize)
+	le := binary.LittleEndian
+	le.PutUint32(b[68:], cfbNoStream)
+	le.PutUint32(b[72:], cfbNoStream)
+	le.PutUint32(b[76:], cfbNoStream)
+	return b
+}
@@ -0,0 +1,123 @@
+package xlsx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCFB(t *testing.T) {
+	c := qt.New(t)
+
+	pattern := func(size int) []byte {
+		b := make([]byte, size)
+		for i := range b {
+			b[i] = byte(i*7 + size)
+		}
+		return b
+	}
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		streams := map[string][]byte{
+			"Empty":                       {},
+			"Small":                       pattern(100),
+			"JustUnderCutoff":             pattern(cfbMiniStreamCutoff - 1),
+			"Cutoff":                      pattern(cfbMiniStreamCutoff),
+			"Large":                       pattern(10000),
+			"\x06DataSpaces/Version":      pattern(76),
+			"\x06DataSpaces/Info/Primary": pattern(200),
+			"\x06DataSpaces/Info/Other":   pattern(5000),
+		}
+		// Enough streams for several directory sectors.
+		for i := 0; i < 20; i++ {
+			streams["Many/Stream"+strings.Repeat("x", i)] = pattern(i * 3)
+		}
+		for _, version := range []uint16{3, 4} {
+			data, err := writeCFB(streams, version)
+			c.Assert(err, qt.IsNil)
+			c.Assert(isCFB(data), qt.Equals, true)
+			c.Assert(binary.LittleEndian.Uint16(data[26:]), qt.Equals, version)
+			got, err := readCFB(data)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got, qt.DeepEquals, streams, qt.Commentf("version %d", version))
+		}
+	})
+
+	c.Run("DIFAT", func(c *qt.C) {
+		// More than 109 FAT sectors need DIFAT sectors.
+		streams := map[string][]byte{"Large": pattern(cfbHeaderDIFATCount * 128 * 512)}
+		data, err := writeCFB(streams, 3)
+		c.Assert(err, qt.IsNil)
+		c.Assert(binary.LittleEndian.Uint32(data[72:]), qt.Equals, uint32(1))
+		got, err := readCFB(data)
+		c.Assert(err, qt.IsNil)
+		c.Assert(bytes.Equal(got["Large"], streams["Large"]), qt.Equals, true)
+	})
+
+	c.Run("DirectoryTree", func(c *qt.C) {
+		names := []string{"b", "A", "aa", "B1", "c", "DD", "e", "EncryptionInfo", "EncryptedPackage"}
+		streams := make(map[string][]byte)
+		for _, name := range names {
+			streams[name] = []byte(name)
+		}
+		data, err := writeCFB(streams, 3)
+		c.Assert(err, qt.IsNil)
+		dirStart := binary.LittleEndian.Uint32(data[48:])
+		dir := data[(dirStart+1)*512:]
+		var entries []cfbDirEntry
+		for i := 0; i <= len(names); i++ {
+			entries = append(entries, readCFBDirEntry(dir[i*cfbDirEntrySize:], 3))
+		}
+		c.Assert(entries[0].name, qt.Equals, "Root Entry")
+		// An in order walk of the tree gives the names in the order
+		// of the format.
+		var walked []string
+		var walk func(id uint32)
+		walk = func(id uint32) {
+			if id == cfbNoStream {
+				return
+			}
+			walk(entries[id].left)
+			walked = append(walked, entries[id].name)
+			walk(entries[id].right)
+		}
+		walk(entries[0].child)
+		c.Assert(walked, qt.DeepEquals, []string{"A", "b", "c", "e", "aa", "B1", "DD", "EncryptionInfo", "EncryptedPackage"})
+		c.Assert(sort.SliceIsSorted(walked, func(i, j int) bool { return cfbNameLess(walked[i], walked[j]) }), qt.Equals, true)
+	})
+
+	c.Run("WriteErrors", func(c *qt.C) {
+		_, err := writeCFB(map[string][]byte{"a": nil}, 5)
+		c.Assert(err, qt.ErrorMatches, `unsupported compound file version 5`)
+		_, err = writeCFB(map[string][]byte{strings.Repeat("x", 32): nil}, 3)
+		c.Assert(err, qt.ErrorMatches, `invalid compound file stream name "x+"`)
+		_, err = writeCFB(map[string][]byte{"a": nil, "a/b": nil}, 3)
+		c.Assert(err, qt.ErrorMatches, `compound file stream "a" is also a storage`)
+	})
+
+	c.Run("ReadErrors", func(c *qt.C) {
+		_, err := readCFB([]byte("PK\x03\x04"))
+		c.Assert(err, qt.ErrorMatches, `not a compound file`)
+
+		data, err := writeCFB(map[string][]byte{"Large": pattern(10000)}, 3)
+		c.Assert(err, qt.IsNil)
+		_, err = readCFB(data[:2048])
+		c.Assert(err, qt.ErrorMatches, `compound file sector \d+ is out of range`)
+
+		corrupt := append([]byte{}, data...)
+		binary.LittleEndian.PutUint16(corrupt[30:], 10)
+		_, err = readCFB(corrupt)
+		c.Assert(err, qt.ErrorMatches, `unsupported compound file version 3 with sector shift 10`)
+
+		// A FAT that chains a sector to itself.
+		corrupt = append([]byte{}, data...)
+		fatSector := binary.LittleEndian.Uint32(corrupt[76:])
+		binary.LittleEndian.PutUint32(corrupt[(fatSector+1)*512:], 0)
+		_, err = readCFB(corrupt)
+		c.Assert(err, qt.ErrorMatches, `compound file sector chain is corrupt`)
+	})
+}
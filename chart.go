@@ -0,0 +1,466 @@
+package xlsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChartType is the kind of chart that a Chart draws.
+type ChartType int
+
+const (
+	// ChartTypeColumn draws each value as a vertical bar.
+	ChartTypeColumn ChartType = iota
+	// ChartTypeBar draws each value as a horizontal bar.
+	ChartTypeBar
+	// ChartTypeLine joins the values of each series with a line.
+	ChartTypeLine
+	// ChartTypeArea fills the area beneath the line of each series.
+	ChartTypeArea
+	// ChartTypePie draws the values of the first series as slices
+	// of a pie.
+	ChartTypePie
+	// ChartTypeScatter plots each pair of x and y values as a
+	// point.
+	ChartTypeScatter
+)
+
+// ChartLegendPosition determines where the legend of a chart is shown.
+type ChartLegendPosition int
+
+const (
+	ChartLegendRight ChartLegendPosition = iota
+	ChartLegendBottom
+	ChartLegendTop
+	ChartLegendLeft
+	// ChartLegendNone hides the legend.
+	ChartLegendNone
+)
+
+// The legendPos values of the positions, in the same order.
+var chartLegendPositions = []string{"r", "b", "t", "l"}
+
+// The default size of a chart on a worksheet, in pixels, which is
+// the size Excel gives new charts.
+const (
+	defaultChartWidth  = 480
+	defaultChartHeight = 288
+)
+
+// The ids that tie the axes of a chart to its plot.
+const (
+	chartXAxisID = 1
+	chartYAxisID = 2
+)
+
+// ChartSeries is a series of values that a chart draws.  Categories
+// and Values are references to ranges of cells, including the name of
+// their sheet, for example "Sheet1!$B$2:$B$5".
+type ChartSeries struct {
+	// Name is shown in the legend.
+	Name string
+	// Categories are the labels of the values, or for scatter
+	// charts the x values.  It may be empty, in which case the
+	// values are numbered.
+	Categories string
+	// Values are the values to draw, or for scatter charts the y
+	// values.
+	Values string
+}
+
+// ChartAxis describes an axis of a chart.
+type ChartAxis struct {
+	Title string
+	// NumFormat is the number format of the axis' labels.  If it
+	// is empty the format of the cells is used.
+	NumFormat string
+	// Min and Max fix the ends of the axis.  If they are nil they
+	// are chosen to fit the values.
+	Min *float64
+	Max *float64
+	// MajorGridlines draws lines across the plot at each label.
+	MajorGridlines bool
+	// Hidden hides the axis and its labels.
+	Hidden bool
+}
+
+// Chart is a chart that draws the values of cells, either placed on a
+// worksheet with Sheet.AddChart, or shown on a sheet of its own with
+// File.AddChartSheet.
+type Chart struct {
+	Type   ChartType
+	Title  string
+	Series []ChartSeries
+	// XAxis is the axis of the categories, or for scatter charts
+	// of the x values, and YAxis is the axis of the values.  Bar
+	// charts have their categories running up the side.  Pie
+	// charts have no axes.
+	XAxis  ChartAxis
+	YAxis  ChartAxis
+	Legend ChartLegendPosition
+	// Cell and Options place a chart on a worksheet, in the same
+	// way as they do for a Picture.  They are set by AddChart.
+	Cell    string
+	Options PictureOptions
+}
+
+// ChartSheet is a sheet that shows nothing but a chart.
+type ChartSheet struct {
+	Name  string
+	Chart *Chart
+}
+
+// AddChart places chart on the sheet, with its top left corner in
+// anchorCell, for example "B2".  opts may be nil, in which case the
+// chart is shown at the size Excel gives new charts, with a two cell
+// anchor.
+func (s *Sheet) AddChart(anchorCell string, chart *Chart, opts *PictureOptions) error {
+	s.load()
+	if _, _, err := GetCoordsFromCellIDString(anchorCell); err != nil {
+		return err
+	}
+	if err := chart.validate(); err != nil {
+		return fmt.Errorf("AddChart: %s", err)
+	}
+	var options PictureOptions
+	if opts != nil {
+		options = *opts
+	}
+	if options.Width == 0 {
+		options.Width = defaultChartWidth
+	}
+	if options.Height == 0 {
+		options.Height = defaultChartHeight
+	}
+	if options.Width < 0 || options.Height < 0 {
+		return fmt.Errorf("AddChart: invalid chart size %dx%d", options.Width, options.Height)
+	}
+	chart.Cell = anchorCell
+	chart.Options = options
+	s.Charts = append(s.Charts, chart)
+	return nil
+}
+
+// AddChartSheet adds a sheet called name that shows chart.  Chart
+// sheets follow all of the worksheets in the workbook.
+func (f *File) AddChartSheet(name string, chart *Chart) (*ChartSheet, error) {
+	if err := f.checkSheetName(name); err != nil {
+		return nil, err
+	}
+	if err := chart.validate(); err != nil {
+		return nil, fmt.Errorf("AddChartSheet: %s", err)
+	}
+	chartSheet := &ChartSheet{Name: name, Chart: chart}
+	f.ChartSheets = append(f.ChartSheets, chartSheet)
+	return chartSheet, nil
+}
+
+// validate checks that the chart can be written.
+func (c *Chart) validate() error {
+	if c.Type < ChartTypeColumn || c.Type > ChartTypeScatter {
+		return fmt.Errorf("unknown chart type %d", c.Type)
+	}
+	if c.Legend < ChartLegendRight || c.Legend > ChartLegendNone {
+		return fmt.Errorf("unknown legend position %d", c.Legend)
+	}
+	if len(c.Series) == 0 {
+		return fmt.Errorf("chart has no series")
+	}
+	for _, series := range c.Series {
+		if series.Values == "" {
+			return fmt.Errorf("series %q has no values", series.Name)
+		}
+		for _, ref := range []string{series.Categories, series.Values} {
+			if ref == "" {
+				continue
+			}
+			_, cells, err := splitChartRef(ref)
+			if err == nil {
+				_, err = parseFormulaRef("", cells)
+			}
+			if err != nil {
+				return fmt.Errorf("invalid reference %q: %s", ref, err)
+			}
+		}
+	}
+	return nil
+}
+
+// splitChartRef splits a reference to a range of cells, such as
+// "'My Sheet'!$A$1:$A$5", into the name of the sheet and the range.
+func splitChartRef(ref string) (sheetName, cells string, err error) {
+	i := strings.LastIndex(ref, "!")
+	if i <= 0 {
+		return "", "", fmt.Errorf("no sheet name")
+	}
+	sheetName = ref[:i]
+	if len(sheetName) > 1 && strings.HasPrefix(sheetName, "'") && strings.HasSuffix(sheetName, "'") {
+		sheetName = strings.Replace(sheetName[1:len(sheetName)-1], "''", "'", -1)
+	}
+	return sheetName, ref[i+1:], nil
+}
+
+// chartCells returns the cells that ref refers to, or nil if they
+// aren't in the file.  Cells that don't exist are nil.
+func (f *File) chartCells(ref string) []*Cell {
+	sheetName, cells, err := splitChartRef(ref)
+	if err != nil {
+		return nil
+	}
+	sheet, ok := f.Sheet[sheetName]
+	if !ok {
+		return nil
+	}
+	node, err := parseFormulaRef(sheetName, cells)
+	if err != nil {
+		return nil
+	}
+	// Whole rows and columns stop at the edge of the sheet's
+	// cells.
+	if node.lastRow == formulaMaxRow {
+		node.lastRow = len(sheet.Rows) - 1
+	}
+	if node.lastCol == formulaMaxCol {
+		node.lastCol = sheet.MaxCol - 1
+	}
+	var result []*Cell
+	for row := node.firstRow; row <= node.lastRow; row++ {
+		for col := node.firstCol; col <= node.lastCol; col++ {
+			var cell *Cell
+			if row < len(sheet.Rows) && sheet.Rows[row] != nil && col < len(sheet.Rows[row].Cells) {
+				cell = sheet.Rows[row].Cells[col]
+			}
+			result = append(result, cell)
+		}
+	}
+	return result
+}
+
+// writeChartRef writes a reference to chart data, along with the
+// current values of the cells it refers to, which are shown until the
+// chart is next recalculated.
+func (f *File) writeChartRef(b *strings.Builder, ref string, numeric bool) {
+	element, cache := "c:strRef", "c:strCache"
+	if numeric {
+		element, cache = "c:numRef", "c:numCache"
+	}
+	fmt.Fprintf(b, `<%s><c:f>%s</c:f>`, element, escapeXMLAttr(ref))
+	if cells := f.chartCells(ref); cells != nil {
+		fmt.Fprintf(b, `<%s>`, cache)
+		if numeric {
+			b.WriteString(`<c:formatCode>General</c:formatCode>`)
+		}
+		fmt.Fprintf(b, `<c:ptCount val="%d"/>`, len(cells))
+		for i, cell := range cells {
+			if cell == nil || cell.Value == "" {
+				continue
+			}
+			value := cell.Value
+			if numeric {
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					continue
+				}
+			} else if formatted, err := cell.FormattedValue(); err == nil {
+				value = formatted
+			}
+			fmt.Fprintf(b, `<c:pt idx="%d"><c:v>%s</c:v></c:pt>`, i, escapeXMLAttr(value))
+		}
+		fmt.Fprintf(b, `</%s>`, cache)
+	}
+	fmt.Fprintf(b, `</%s>`, element)
+}
+
+// writeChartTitle writes a title element holding text.
+func writeChartTitle(b *strings.Builder, text string) {
+	fmt.Fprintf(b, `<c:title><c:tx><c:rich><a:bodyPr/><a:lstStyle/><a:p><a:pPr><a:defRPr/></a:pPr><a:r><a:t>%s</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`,
+		escapeXMLAttr(text))
+}
+
+// writeChartAxis writes an axis element, catAx or valAx, of a chart.
+// tail holds the elements that are particular to the kind of axis.
+func writeChartAxis(b *strings.Builder, element string, id, crossID int, position string, axis ChartAxis, tail string) {
+	fmt.Fprintf(b, `<c:%s><c:axId val="%d"/><c:scaling><c:orientation val="minMax"/>`, element, id)
+	if axis.Max != nil {
+		fmt.Fprintf(b, `<c:max val="%s"/>`, strconv.FormatFloat(*axis.Max, 'f', -1, 64))
+	}
+	if axis.Min != nil {
+		fmt.Fprintf(b, `<c:min val="%s"/>`, strconv.FormatFloat(*axis.Min, 'f', -1, 64))
+	}
+	b.WriteString(`</c:scaling>`)
+	if axis.Hidden {
+		b.WriteString(`<c:delete val="1"/>`)
+	} else {
+		b.WriteString(`<c:delete val="0"/>`)
+	}
+	fmt.Fprintf(b, `<c:axPos val="%s"/>`, position)
+	if axis.MajorGridlines {
+		b.WriteString(`<c:majorGridlines/>`)
+	}
+	if axis.Title != "" {
+		writeChartTitle(b, axis.Title)
+	}
+	if axis.NumFormat == "" {
+		b.WriteString(`<c:numFmt formatCode="General" sourceLinked="1"/>`)
+	} else {
+		fmt.Fprintf(b, `<c:numFmt formatCode="%s" sourceLinked="0"/>`, escapeXMLAttr(axis.NumFormat))
+	}
+	fmt.Fprintf(b, `<c:majorTickMark val="out"/><c:minorTickMark val="none"/><c:tickLblPos val="nextTo"/><c:crossAx val="%d"/><c:crosses val="autoZero"/>%s</c:%s>`,
+		crossID, tail, element)
+}
+
+// marshal returns the chart part for the chart, with the current
+// values of the cells it refers to in f.
+func (c *Chart) marshal(f *File) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	b.WriteString(`<c:roundedCorners val="0"/><c:chart>`)
+	if c.Title != "" {
+		writeChartTitle(&b, c.Title)
+	} else {
+		// Otherwise Excel titles charts with a single series
+		// with the name of the series.
+		b.WriteString(`<c:autoTitleDeleted val="1"/>`)
+	}
+	b.WriteString(`<c:plotArea><c:layout/>`)
+
+	var element, head, tail string
+	axes := fmt.Sprintf(`<c:axId val="%d"/><c:axId val="%d"/>`, chartXAxisID, chartYAxisID)
+	switch c.Type {
+	case ChartTypeColumn:
+		element = "barChart"
+		head = `<c:barDir val="col"/><c:grouping val="clustered"/><c:varyColors val="0"/>`
+		tail = `<c:gapWidth val="150"/>` + axes
+	case ChartTypeBar:
+		element = "barChart"
+		head = `<c:barDir val="bar"/><c:grouping val="clustered"/><c:varyColors val="0"/>`
+		tail = `<c:gapWidth val="150"/>` + axes
+	case ChartTypeLine:
+		element = "lineChart"
+		head = `<c:grouping val="standard"/><c:varyColors val="0"/>`
+		tail = `<c:marker val="1"/>` + axes
+	case ChartTypeArea:
+		element = "areaChart"
+		head = `<c:grouping val="standard"/><c:varyColors val="0"/>`
+		tail = axes
+	case ChartTypePie:
+		element = "pieChart"
+		head = `<c:varyColors val="1"/>`
+		tail = `<c:firstSliceAng val="0"/>`
+	case ChartTypeScatter:
+		element = "scatterChart"
+		head = `<c:scatterStyle val="lineMarker"/><c:varyColors val="0"/>`
+		tail = axes
+	}
+	fmt.Fprintf(&b, `<c:%s>%s`, element, head)
+	for i, series := range c.Series {
+		fmt.Fprintf(&b, `<c:ser><c:idx val="%d"/><c:order val="%d"/>`, i, i)
+		if series.Name != "" {
+			fmt.Fprintf(&b, `<c:tx><c:v>%s</c:v></c:tx>`, escapeXMLAttr(series.Name))
+		}
+		if c.Type == ChartTypeScatter {
+			// Just the markers, without lines between them.
+			b.WriteString(`<c:spPr><a:ln w="19050"><a:noFill/></a:ln></c:spPr>`)
+			if series.Categories != "" {
+				b.WriteString(`<c:xVal>`)
+				f.writeChartRef(&b, series.Categories, true)
+				b.WriteString(`</c:xVal>`)
+			}
+			b.WriteString(`<c:yVal>`)
+			f.writeChartRef(&b, series.Values, true)
+			b.WriteString(`</c:yVal><c:smooth val="0"/></c:ser>`)
+			continue
+		}
+		if series.Categories != "" {
+			b.WriteString(`<c:cat>`)
+			f.writeChartRef(&b, series.Categories, false)
+			b.WriteString(`</c:cat>`)
+		}
+		b.WriteString(`<c:val>`)
+		f.writeChartRef(&b, series.Values, true)
+		b.WriteString(`</c:val>`)
+		if c.Type == ChartTypeLine {
+			b.WriteString(`<c:smooth val="0"/>`)
+		}
+		b.WriteString(`</c:ser>`)
+	}
+	fmt.Fprintf(&b, `%s</c:%s>`, tail, element)
+
+	categoryTail := `<c:auto val="1"/><c:lblAlgn val="ctr"/><c:lblOffset val="100"/><c:noMultiLvlLbl val="0"/>`
+	switch c.Type {
+	case ChartTypePie:
+	case ChartTypeScatter:
+		writeChartAxis(&b, "valAx", chartXAxisID, chartYAxisID, "b", c.XAxis, `<c:crossBetween val="midCat"/>`)
+		writeChartAxis(&b, "valAx", chartYAxisID, chartXAxisID, "l", c.YAxis, `<c:crossBetween val="midCat"/>`)
+	case ChartTypeBar:
+		writeChartAxis(&b, "catAx", chartXAxisID, chartYAxisID, "l", c.XAxis, categoryTail)
+		writeChartAxis(&b, "valAx", chartYAxisID, chartXAxisID, "b", c.YAxis, `<c:crossBetween val="between"/>`)
+	default:
+		writeChartAxis(&b, "catAx", chartXAxisID, chartYAxisID, "b", c.XAxis, categoryTail)
+		writeChartAxis(&b, "valAx", chartYAxisID, chartXAxisID, "l", c.YAxis, `<c:crossBetween val="between"/>`)
+	}
+	b.WriteString(`</c:plotArea>`)
+	if c.Legend != ChartLegendNone {
+		fmt.Fprintf(&b, `<c:legend><c:legendPos val="%s"/><c:overlay val="0"/></c:legend>`, chartLegendPositions[c.Legend])
+	}
+	b.WriteString(`<c:plotVisOnly val="1"/><c:dispBlanksAs val="gap"/></c:chart></c:chartSpace>`)
+	return b.String()
+}
+
+// chartGraphicFrame returns the graphicFrame that shows a chart in a
+// drawing.
+func chartGraphicFrame(id int, name, description, relId string) string {
+	return fmt.Sprintf(`<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="%d" name="%s" descr="%s"/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>`+
+		`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm>`+
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" r:id="%s"/></a:graphicData></a:graphic></xdr:graphicFrame>`,
+		id, escapeXMLAttr(name), escapeXMLAttr(description), relId)
+}
+
+// chartParts numbers the charts of a workbook as they are written.
+type chartParts struct {
+	file      *File
+	names     *partNames
+	partNames []string
+	parts     []string
+}
+
+// add adds the chart part for chart, and returns its name.
+func (c *chartParts) add(chart *Chart) string {
+	name := c.names.pick("xl/charts/chart%d.xml", len(c.parts)+1)
+	c.partNames = append(c.partNames, name)
+	c.parts = append(c.parts, chart.marshal(c.file))
+	return name
+}
+
+// write adds the charts to parts, and their content types to types.
+func (c *chartParts) write(parts map[string]string, types *xlsxTypes) {
+	for i, chart := range c.parts {
+		name := c.partNames[i]
+		parts[name] = chart
+		types.Overrides = append(
+			types.Overrides,
+			xlsxOverride{
+				PartName:    "/" + name,
+				ContentType: "application/vnd.openxmlformats-officedocument.drawingml.chart+xml"})
+	}
+}
+
+// The chart sheet part of a ChartSheet, which refers to the drawing
+// that holds its chart.
+const chartSheetXML = `<?xml version="1.0" encoding="UTF-8"?>
+<chartsheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheetViews><sheetView zoomToFit="1" workbookViewId="0"/></sheetViews><pageMargins left="0.7" right="0.7" top="0.75" bottom="0.75" header="0.3" footer="0.3"/><drawing r:id="rId1"/></chartsheet>`
+
+// makeDrawingPart returns the drawing part that fills the chart sheet
+// with its chart, adding the chart to charts.
+func (cs *ChartSheet) makeDrawingPart(charts *chartParts) *drawingPart {
+	drawing := &drawingPart{}
+	target := "../" + strings.TrimPrefix(charts.add(cs.Chart), "xl/")
+	relId := drawing.addRelation(RelationshipTypeChart, target, "")
+	id := drawing.nextShapeID()
+	// The size of the page that Excel gives a new chart sheet,
+	// which it stretches to fit the window.
+	drawing.anchors += fmt.Sprintf(`<xdr:absoluteAnchor><xdr:pos x="0" y="0"/><xdr:ext cx="8670925" cy="6291263"/>%s<xdr:clientData/></xdr:absoluteAnchor>`,
+		chartGraphicFrame(id, fmt.Sprintf("Chart %d", id-1), "", relId))
+	return drawing
+}
@@ -0,0 +1,202 @@
+package xlsx
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestChart(t *testing.T) {
+	c := qt.New(t)
+
+	makeSalesFile := func(c *qt.C) *File {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sales Q1")
+		c.Assert(err, qt.IsNil)
+		for _, line := range [][]interface{}{
+			{"Month", "Apples", "Pears"},
+			{"Jan", 10, 4},
+			{"Feb", 12.5, "n/a"},
+			{"Mar", 7, 9},
+		} {
+			sheet.AddRow().WriteSlice(&line, -1)
+		}
+		return file
+	}
+	sales := []ChartSeries{
+		{Name: "Apples", Categories: "'Sales Q1'!$A$2:$A$4", Values: "'Sales Q1'!$B$2:$B$4"},
+		{Name: "Pears & co", Categories: "'Sales Q1'!$A$2:$A$4", Values: "'Sales Q1'!$C$2:$C$4"},
+	}
+
+	c.Run("AddChart", func(c *qt.C) {
+		sheet := makeSalesFile(c).Sheet["Sales Q1"]
+		chart := &Chart{Series: sales}
+		c.Assert(sheet.AddChart("E2", chart, nil), qt.IsNil)
+		c.Assert(chart.Cell, qt.Equals, "E2")
+		c.Assert(chart.Options, qt.Equals, PictureOptions{Width: 480, Height: 288})
+		c.Assert(sheet.Charts, qt.HasLen, 1)
+
+		chart = &Chart{Series: sales}
+		c.Assert(sheet.AddChart("E20", chart, &PictureOptions{Width: 200, Anchor: PictureAnchorOneCell}), qt.IsNil)
+		c.Assert(chart.Options, qt.Equals, PictureOptions{Width: 200, Height: 288, Anchor: PictureAnchorOneCell})
+
+		err := sheet.AddChart("E2", &Chart{}, nil)
+		c.Assert(err, qt.ErrorMatches, "AddChart: chart has no series")
+		err = sheet.AddChart("E2", &Chart{Type: ChartType(42), Series: sales}, nil)
+		c.Assert(err, qt.ErrorMatches, "AddChart: unknown chart type 42")
+		err = sheet.AddChart("E2", &Chart{Series: []ChartSeries{{Name: "Apples"}}}, nil)
+		c.Assert(err, qt.ErrorMatches, `AddChart: series "Apples" has no values`)
+		err = sheet.AddChart("E2", &Chart{Series: []ChartSeries{{Values: "B2:B4"}}}, nil)
+		c.Assert(err, qt.ErrorMatches, `AddChart: invalid reference "B2:B4": no sheet name`)
+		err = sheet.AddChart("E2", &Chart{Series: []ChartSeries{{Values: "Sheet1!B2:B4", Categories: "Sheet1!A2:"}}}, nil)
+		c.Assert(err, qt.ErrorMatches, `AddChart: invalid reference "Sheet1!A2:": .*`)
+		err = sheet.AddChart("E2", &Chart{Series: sales}, &PictureOptions{Height: -1})
+		c.Assert(err, qt.ErrorMatches, `AddChart: invalid chart size 480x-1`)
+		c.Assert(sheet.Charts, qt.HasLen, 2)
+	})
+
+	c.Run("AddChartSheet", func(c *qt.C) {
+		file := makeSalesFile(c)
+		chartSheet, err := file.AddChartSheet("Sales Chart", &Chart{Type: ChartTypePie, Series: sales[:1]})
+		c.Assert(err, qt.IsNil)
+		c.Assert(chartSheet.Name, qt.Equals, "Sales Chart")
+		c.Assert(file.ChartSheets, qt.HasLen, 1)
+
+		_, err = file.AddChartSheet("Sales Q1", &Chart{Series: sales})
+		c.Assert(err, qt.ErrorMatches, "duplicate sheet name 'Sales Q1'.")
+		_, err = file.AddSheet("Sales Chart")
+		c.Assert(err, qt.ErrorMatches, "duplicate sheet name 'Sales Chart'.")
+		_, err = file.AddChartSheet("Sales/Chart", &Chart{Series: sales})
+		c.Assert(err, qt.Not(qt.IsNil))
+		_, err = file.AddChartSheet("Empty", &Chart{})
+		c.Assert(err, qt.ErrorMatches, "AddChartSheet: chart has no series")
+		c.Assert(file.ChartSheets, qt.HasLen, 1)
+	})
+
+	c.Run("Marshal", func(c *qt.C) {
+		file := makeSalesFile(c)
+		max := 20.0
+		chart := &Chart{
+			Title:  "Fruit <sold>",
+			Series: sales,
+			XAxis:  ChartAxis{Title: "Month"},
+			YAxis:  ChartAxis{Title: "Crates", NumFormat: "0.0", Max: &max, MajorGridlines: true},
+			Legend: ChartLegendBottom,
+		}
+		c.Assert(chart.marshal(file), qt.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`+
+			`<c:roundedCorners val="0"/><c:chart>`+
+			`<c:title><c:tx><c:rich><a:bodyPr/><a:lstStyle/><a:p><a:pPr><a:defRPr/></a:pPr><a:r><a:t>Fruit &lt;sold&gt;</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`+
+			`<c:plotArea><c:layout/>`+
+			`<c:barChart><c:barDir val="col"/><c:grouping val="clustered"/><c:varyColors val="0"/>`+
+			`<c:ser><c:idx val="0"/><c:order val="0"/><c:tx><c:v>Apples</c:v></c:tx>`+
+			`<c:cat><c:strRef><c:f>&#39;Sales Q1&#39;!$A$2:$A$4</c:f><c:strCache><c:ptCount val="3"/><c:pt idx="0"><c:v>Jan</c:v></c:pt><c:pt idx="1"><c:v>Feb</c:v></c:pt><c:pt idx="2"><c:v>Mar</c:v></c:pt></c:strCache></c:strRef></c:cat>`+
+			`<c:val><c:numRef><c:f>&#39;Sales Q1&#39;!$B$2:$B$4</c:f><c:numCache><c:formatCode>General</c:formatCode><c:ptCount val="3"/><c:pt idx="0"><c:v>10</c:v></c:pt><c:pt idx="1"><c:v>12.5</c:v></c:pt><c:pt idx="2"><c:v>7</c:v></c:pt></c:numCache></c:numRef></c:val></c:ser>`+
+			`<c:ser><c:idx val="1"/><c:order val="1"/><c:tx><c:v>Pears &amp; co</c:v></c:tx>`+
+			`<c:cat><c:strRef><c:f>&#39;Sales Q1&#39;!$A$2:$A$4</c:f><c:strCache><c:ptCount val="3"/><c:pt idx="0"><c:v>Jan</c:v></c:pt><c:pt idx="1"><c:v>Feb</c:v></c:pt><c:pt idx="2"><c:v>Mar</c:v></c:pt></c:strCache></c:strRef></c:cat>`+
+			`<c:val><c:numRef><c:f>&#39;Sales Q1&#39;!$C$2:$C$4</c:f><c:numCache><c:formatCode>General</c:formatCode><c:ptCount val="3"/><c:pt idx="0"><c:v>4</c:v></c:pt><c:pt idx="2"><c:v>9</c:v></c:pt></c:numCache></c:numRef></c:val></c:ser>`+
+			`<c:gapWidth val="150"/><c:axId val="1"/><c:axId val="2"/></c:barChart>`+
+			`<c:catAx><c:axId val="1"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="b"/>`+
+			`<c:title><c:tx><c:rich><a:bodyPr/><a:lstStyle/><a:p><a:pPr><a:defRPr/></a:pPr><a:r><a:t>Month</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`+
+			`<c:numFmt formatCode="General" sourceLinked="1"/><c:majorTickMark val="out"/><c:minorTickMark val="none"/><c:tickLblPos val="nextTo"/><c:crossAx val="2"/><c:crosses val="autoZero"/>`+
+			`<c:auto val="1"/><c:lblAlgn val="ctr"/><c:lblOffset val="100"/><c:noMultiLvlLbl val="0"/></c:catAx>`+
+			`<c:valAx><c:axId val="2"/><c:scaling><c:orientation val="minMax"/><c:max val="20"/></c:scaling><c:delete val="0"/><c:axPos val="l"/><c:majorGridlines/>`+
+			`<c:title><c:tx><c:rich><a:bodyPr/><a:lstStyle/><a:p><a:pPr><a:defRPr/></a:pPr><a:r><a:t>Crates</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`+
+			`<c:numFmt formatCode="0.0" sourceLinked="0"/><c:majorTickMark val="out"/><c:minorTickMark val="none"/><c:tickLblPos val="nextTo"/><c:crossAx val="1"/><c:crosses val="autoZero"/>`+
+			`<c:crossBetween val="between"/></c:valAx>`+
+			`</c:plotArea>`+
+			`<c:legend><c:legendPos val="b"/><c:overlay val="0"/></c:legend>`+
+			`<c:plotVisOnly val="1"/><c:dispBlanksAs val="gap"/></c:chart></c:chartSpace>`)
+	})
+
+	c.Run("MarshalTypes", func(c *qt.C) {
+		file := makeSalesFile(c)
+		series := []ChartSeries{{Values: "Elsewhere!$B$2:$B$4"}}
+
+		xml := (&Chart{Type: ChartTypeBar, Series: series}).marshal(file)
+		c.Assert(xml, qt.Contains, `<c:autoTitleDeleted val="1"/>`)
+		c.Assert(xml, qt.Contains, `<c:barDir val="bar"/>`)
+		c.Assert(xml, qt.Contains, `<c:ser><c:idx val="0"/><c:order val="0"/><c:val><c:numRef><c:f>Elsewhere!$B$2:$B$4</c:f></c:numRef></c:val></c:ser>`)
+		c.Assert(xml, qt.Contains, `<c:catAx><c:axId val="1"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="l"/>`)
+		c.Assert(xml, qt.Contains, `<c:valAx><c:axId val="2"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="b"/>`)
+
+		xml = (&Chart{Type: ChartTypeLine, Series: series, Legend: ChartLegendNone}).marshal(file)
+		c.Assert(xml, qt.Contains, `<c:lineChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+		c.Assert(xml, qt.Contains, `</c:val><c:smooth val="0"/></c:ser><c:marker val="1"/><c:axId val="1"/><c:axId val="2"/></c:lineChart>`)
+		c.Assert(xml, qt.Not(qt.Contains), `<c:legend>`)
+
+		xml = (&Chart{Type: ChartTypeArea, Series: series}).marshal(file)
+		c.Assert(xml, qt.Contains, `<c:areaChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+
+		xml = (&Chart{Type: ChartTypePie, Series: series}).marshal(file)
+		c.Assert(xml, qt.Contains, `<c:pieChart><c:varyColors val="1"/><c:ser>`)
+		c.Assert(xml, qt.Contains, `<c:firstSliceAng val="0"/></c:pieChart></c:plotArea>`)
+
+		min := -1.5
+		xml = (&Chart{
+			Type:   ChartTypeScatter,
+			Series: []ChartSeries{{Categories: "'Sales Q1'!B2:B4", Values: "'Sales Q1'!C:C"}},
+			XAxis:  ChartAxis{Min: &min, Hidden: true},
+		}).marshal(file)
+		c.Assert(xml, qt.Contains, `<c:scatterChart><c:scatterStyle val="lineMarker"/><c:varyColors val="0"/>`+
+			`<c:ser><c:idx val="0"/><c:order val="0"/><c:spPr><a:ln w="19050"><a:noFill/></a:ln></c:spPr>`+
+			`<c:xVal><c:numRef><c:f>&#39;Sales Q1&#39;!B2:B4</c:f><c:numCache><c:formatCode>General</c:formatCode><c:ptCount val="3"/><c:pt idx="0"><c:v>10</c:v></c:pt><c:pt idx="1"><c:v>12.5</c:v></c:pt><c:pt idx="2"><c:v>7</c:v></c:pt></c:numCache></c:numRef></c:xVal>`+
+			// The whole column stops at the last row.
+			`<c:yVal><c:numRef><c:f>&#39;Sales Q1&#39;!C:C</c:f><c:numCache><c:formatCode>General</c:formatCode><c:ptCount val="4"/><c:pt idx="1"><c:v>4</c:v></c:pt><c:pt idx="3"><c:v>9</c:v></c:pt></c:numCache></c:numRef></c:yVal>`+
+			`<c:smooth val="0"/></c:ser><c:axId val="1"/><c:axId val="2"/></c:scatterChart>`)
+		c.Assert(xml, qt.Contains, `<c:valAx><c:axId val="1"/><c:scaling><c:orientation val="minMax"/><c:min val="-1.5"/></c:scaling><c:delete val="1"/><c:axPos val="b"/>`)
+		c.Assert(xml, qt.Contains, `<c:crossBetween val="midCat"/></c:valAx>`)
+	})
+
+	c.Run("Write", func(c *qt.C) {
+		file := makeSalesFile(c)
+		sheet := file.Sheet["Sales Q1"]
+		_, err := sheet.AddPicture("A10", makeTestImage(c, 10, 10, encodePNG), nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(sheet.AddChart("E2", &Chart{Title: "Column", Series: sales}, &PictureOptions{Name: "Sales"}), qt.IsNil)
+		c.Assert(sheet.AddChart("E20", &Chart{Title: "Line", Type: ChartTypeLine, Series: sales}, nil), qt.IsNil)
+		_, err = file.AddChartSheet("Pie", &Chart{Title: "Pie", Type: ChartTypePie, Series: sales[:1]})
+		c.Assert(err, qt.IsNil)
+
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/charts/chart1.xml"], qt.Contains, `<a:t>Column</a:t>`)
+		c.Assert(parts["xl/charts/chart2.xml"], qt.Contains, `<a:t>Line</a:t>`)
+		c.Assert(parts["xl/charts/chart3.xml"], qt.Contains, `<a:t>Pie</a:t>`)
+
+		drawing := parts["xl/drawings/drawing1.xml"]
+		c.Assert(drawing, qt.Contains, `<xdr:pic>`)
+		c.Assert(drawing, qt.Contains, `<xdr:twoCellAnchor><xdr:from><xdr:col>4</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>`+
+			`<xdr:to><xdr:col>11</xdr:col><xdr:colOff>304800</xdr:colOff><xdr:row>17</xdr:row><xdr:rowOff>152400</xdr:rowOff></xdr:to>`+
+			`<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="3" name="Sales" descr=""/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>`+
+			`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm>`+
+			`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" r:id="rId2"/></a:graphicData></a:graphic></xdr:graphicFrame>`+
+			`<xdr:clientData/></xdr:twoCellAnchor>`)
+		c.Assert(drawing, qt.Contains, `<xdr:cNvPr id="4" name="Chart 3" descr=""/>`)
+		c.Assert(parts["xl/drawings/_rels/drawing1.xml.rels"], qt.Contains,
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart" Target="../charts/chart1.xml"></Relationship>`+
+				`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart" Target="../charts/chart2.xml"></Relationship>`)
+
+		// The chart sheet follows the worksheet.
+		c.Assert(parts["xl/workbook.xml"], qt.Contains, `<sheet name="Pie" sheetId="2" r:id="rId2" state="visible"></sheet>`)
+		c.Assert(parts["xl/_rels/workbook.xml.rels"], qt.Contains,
+			`<Relationship Id="rId2" Target="chartsheets/sheet1.xml" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chartsheet"></Relationship>`)
+		c.Assert(parts["xl/chartsheets/sheet1.xml"], qt.Contains, `<drawing r:id="rId1"/></chartsheet>`)
+		c.Assert(parts["xl/chartsheets/_rels/sheet1.xml.rels"], qt.Contains,
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing" Target="../drawings/drawing2.xml"></Relationship>`)
+		c.Assert(parts["xl/drawings/drawing2.xml"], qt.Contains, `<xdr:absoluteAnchor><xdr:pos x="0" y="0"/><xdr:ext cx="8670925" cy="6291263"/><xdr:graphicFrame macro="">`)
+		c.Assert(parts["xl/drawings/_rels/drawing2.xml.rels"], qt.Contains, `Target="../charts/chart3.xml"`)
+
+		contentTypes := parts["[Content_Types].xml"]
+		c.Assert(contentTypes, qt.Contains, `<Override PartName="/xl/chartsheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.chartsheet+xml"></Override>`)
+		c.Assert(contentTypes, qt.Contains, `<Override PartName="/xl/drawings/drawing2.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"></Override>`)
+		c.Assert(contentTypes, qt.Contains, `<Override PartName="/xl/charts/chart3.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"></Override>`)
+
+		// Chart sheets aren't read, but the worksheets and their
+		// pictures still are.
+		file, err = OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 1)
+		c.Assert(file.Sheets[0].Pictures, qt.HasLen, 1)
+	})
+}
@@ -0,0 +1,173 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// The legacy VML drawing that goes with a comments part.  Excel
+// won't show the comments of a sheet without it, as it describes the
+// boxes the comments are displayed in.
+const vmlDrawingHeader = `<xml xmlns:v="urn:schemas-microsoft-com:vml" xmlns:o="urn:schemas-microsoft-com:office:office" xmlns:x="urn:schemas-microsoft-com:office:excel">
+ <o:shapelayout v:ext="edit"><o:idmap v:ext="edit" data="%d"/></o:shapelayout>
+ <v:shapetype id="_x0000_t202" coordsize="21600,21600" o:spt="202" path="m,l,21600r21600,l21600,xe">
+  <v:stroke joinstyle="miter"/>
+  <v:path gradientshapeok="t" o:connecttype="rect"/>
+ </v:shapetype>
+`
+
+const vmlDrawingShape = ` <v:shape id="_x0000_s%d" type="#_x0000_t202" style="position:absolute;margin-left:59.25pt;margin-top:1.5pt;width:108pt;height:59.25pt;z-index:%d;visibility:hidden" fillcolor="#ffffe1" o:insetmode="auto">
+  <v:fill color2="#ffffe1"/>
+  <v:shadow on="t" color="black" obscured="t"/>
+  <v:path o:connecttype="none"/>
+  <v:textbox style="mso-direction-alt:auto"><div style="text-align:left"></div></v:textbox>
+  <x:ClientData ObjectType="Note">
+   <x:MoveWithCells/>
+   <x:SizeWithCells/>
+   <x:Anchor>%d, 15, %d, 2, %d, 15, %d, 16</x:Anchor>
+   <x:AutoFill>False</x:AutoFill>
+   <x:Row>%d</x:Row>
+   <x:Column>%d</x:Column>
+  </x:ClientData>
+ </v:shape>
+`
+
+const vmlDrawingFooter = `</xml>`
+
+// Comment is a note attached to a Cell, Excel shows it when the mouse
+// hovers over the cell.
+type Comment struct {
+	Author string
+	Text   string
+}
+
+// SetComment attaches a comment by author to the cell, replacing any
+// comment it already has.
+func (c *Cell) SetComment(author, text string) {
+	c.Comment = Comment{Author: author, Text: text}
+}
+
+// eachComment calls fn for every cell of the sheet that has a comment,
+// in row order.
+func (s *Sheet) eachComment(fn func(row, col int, comment Comment)) {
+	for r, row := range s.Rows {
+		if row == nil {
+			continue
+		}
+		for c, cell := range row.Cells {
+			if cell != nil && cell.Comment != (Comment{}) {
+				fn(r, c, cell.Comment)
+			}
+		}
+	}
+}
+
+// makeXLSXComments returns the comments part of the sheet, or nil if
+// none of its cells have a comment.
+func (s *Sheet) makeXLSXComments() *xlsxComments {
+	var comments *xlsxComments
+	authors := make(map[string]int)
+	s.eachComment(func(row, col int, comment Comment) {
+		if comments == nil {
+			comments = &xlsxComments{}
+		}
+		authorId, ok := authors[comment.Author]
+		if !ok {
+			authorId = len(comments.Authors)
+			authors[comment.Author] = authorId
+			comments.Authors = append(comments.Authors, comment.Author)
+		}
+		comments.CommentList = append(comments.CommentList, xlsxCommentItem{
+			Ref:      GetCellIDStringFromCoords(col, row),
+			AuthorId: authorId,
+			Text:     xlsxSI{T: comment.Text},
+		})
+	})
+	return comments
+}
+
+// makeVMLDrawing returns the legacy drawing with a comment box for
+// every comment of the sheet.  sheetIndex makes the ids of the shapes
+// unique within the workbook.
+func (s *Sheet) makeVMLDrawing(sheetIndex int) string {
+	vml := fmt.Sprintf(vmlDrawingHeader, sheetIndex)
+	shape := 0
+	s.eachComment(func(row, col int, comment Comment) {
+		shape++
+		vml += fmt.Sprintf(vmlDrawingShape, sheetIndex*1024+shape, shape,
+			col+1, row, col+3, row+4, row, col)
+	})
+	return vml + vmlDrawingFooter
+}
+
+// setCommentRelations replaces the sheet's relations to a comments
+// part and legacy drawing with ones to the parts with the given names,
+// or just removes them if the sheet has no comments and the names are
+// empty.
+func (s *Sheet) setCommentRelations(commentsPartName, vmlDrawingPartName string) {
+	s.removeRelations(RelationshipTypeComments, RelationshipTypeVMLDrawing)
+	if commentsPartName != "" {
+		s.addRelation(RelationshipTypeComments, relativePartTarget("xl/worksheets", commentsPartName), "")
+		s.addRelation(RelationshipTypeVMLDrawing, relativePartTarget("xl/worksheets", vmlDrawingPartName), "")
+	}
+}
+
+// makeLegacyDrawing points the worksheet at its VML drawing, if it
+// has one.
+func (s *Sheet) makeLegacyDrawing(worksheet *xlsxWorksheet, relations *xlsxWorksheetRels) {
+	if relations == nil {
+		return
+	}
+	for _, rel := range relations.Relationships {
+		if rel.Type == RelationshipTypeVMLDrawing {
+			worksheet.LegacyDrawing = &xlsxLegacyDrawing{RelationshipId: rel.Id}
+			return
+		}
+	}
+}
+
+// readCommentsFromZipFile reads the comments part that the worksheet
+// relations refer to, if there is one, and attaches the comments to
+// the cells of sheet.  Comments on cells beyond the row and column
+// limits of the options are ignored.
+func readCommentsFromZipFile(sheet *Sheet, worksheetRels *xlsxWorksheetRels, parts map[string]*zip.File, options ReadOptions) error {
+	if worksheetRels == nil {
+		return nil
+	}
+	for _, rel := range worksheetRels.Relationships {
+		if rel.Type != RelationshipTypeComments {
+			continue
+		}
+		f, ok := parts[relationshipTargetPartName("xl/worksheets", rel.Target)]
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return &ReadError{Part: f.Name, Err: err}
+		}
+		xComments := new(xlsxComments)
+		err = xml.NewDecoder(rc).Decode(xComments)
+		rc.Close()
+		if err != nil {
+			return &ReadError{Part: f.Name, Err: err}
+		}
+		for _, item := range xComments.CommentList {
+			x, y, err := GetCoordsFromCellIDString(item.Ref)
+			if err != nil {
+				return &ReadError{Part: f.Name, Cell: item.Ref, Err: errors.New("invalid comment cell reference")}
+			}
+			if !options.readsCell(x, y) {
+				continue
+			}
+			comment := Comment{Text: item.text()}
+			if item.AuthorId >= 0 && item.AuthorId < len(xComments.Authors) {
+				comment.Author = xComments.Authors[item.AuthorId]
+			}
+			sheet.Cell(y, x).Comment = comment
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package xlsx
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestComments(t *testing.T) {
+	c := qt.New(t)
+
+	makeCommentsFile := func(c *qt.C) *File {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetString("no comment")
+		sheet, err = file.AddSheet("Sheet2")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetHyperlink("http://example.com", "", "")
+		sheet.Cell(1, 1).SetInt(42)
+		sheet.Cell(1, 1).SetComment("Reviewer", "Where does this come from?")
+		sheet.Cell(3, 2).SetComment("Author", "From the 2019 report")
+		sheet.Cell(4, 0).SetComment("Reviewer", "Thanks")
+		return file
+	}
+
+	c.Run("Write", func(c *qt.C) {
+		parts, err := makeCommentsFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+
+		_, ok := parts["xl/comments1.xml"]
+		c.Assert(ok, qt.Equals, false)
+		_, ok = parts["xl/worksheets/_rels/sheet1.xml.rels"]
+		c.Assert(ok, qt.Equals, false)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Not(qt.Contains), "legacyDrawing")
+
+		c.Assert(parts["xl/comments2.xml"], qt.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<comments xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+
+			`<authors><author>Reviewer</author><author>Author</author></authors><commentList>`+
+			`<comment ref="B2" authorId="0"><text><t>Where does this come from?</t></text></comment>`+
+			`<comment ref="C4" authorId="1"><text><t>From the 2019 report</t></text></comment>`+
+			`<comment ref="A5" authorId="0"><text><t>Thanks</t></text></comment>`+
+			`</commentList></comments>`)
+
+		vml := parts["xl/drawings/vmlDrawing2.vml"]
+		c.Assert(vml, qt.Contains, `<o:idmap v:ext="edit" data="2"/>`)
+		c.Assert(vml, qt.Contains, `<v:shape id="_x0000_s2049"`)
+		c.Assert(vml, qt.Contains, `<x:Anchor>2, 15, 1, 2, 4, 15, 5, 16</x:Anchor>`)
+		c.Assert(vml, qt.Contains, "<x:Row>3</x:Row>\n   <x:Column>2</x:Column>")
+		c.Assert(vml, qt.Contains, `<v:shape id="_x0000_s2051"`)
+
+		c.Assert(parts["xl/worksheets/_rels/sheet2.xml.rels"], qt.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="http://example.com" TargetMode="External"></Relationship>`+
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="../comments2.xml"></Relationship>`+
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing" Target="../drawings/vmlDrawing2.vml"></Relationship>`+
+			`</Relationships>`)
+		c.Assert(parts["xl/worksheets/sheet2.xml"], qt.Contains, `</headerFooter><legacyDrawing r:id="rId3"></legacyDrawing></worksheet>`)
+
+		contentTypes := parts["[Content_Types].xml"]
+		c.Assert(contentTypes, qt.Contains, `<Override PartName="/xl/comments2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.comments+xml"></Override>`)
+		c.Assert(contentTypes, qt.Contains, `<Default Extension="vml" ContentType="application/vnd.openxmlformats-officedocument.vmlDrawing"></Default>`)
+	})
+
+	c.Run("WriteTwice", func(c *qt.C) {
+		file := makeCommentsFile(c)
+		_, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		// Moving the comments to the other sheet replaces the
+		// relations rather than adding to them.
+		file.Sheets[0], file.Sheets[1] = file.Sheets[1], file.Sheets[0]
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets[0].Relations, qt.HasLen, 3)
+		c.Assert(file.Sheets[0].Relations[1].Target, qt.Equals, "../comments1.xml")
+		_, ok := parts["xl/comments1.xml"]
+		c.Assert(ok, qt.Equals, true)
+		_, ok = parts["xl/comments2.xml"]
+		c.Assert(ok, qt.Equals, false)
+
+		file.Sheets[0].Cell(1, 1).Comment = Comment{}
+		file.Sheets[0].Cell(3, 2).Comment = Comment{}
+		file.Sheets[0].Cell(4, 0).Comment = Comment{}
+		parts, err = file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets[0].Relations, qt.HasLen, 1)
+		c.Assert(parts["[Content_Types].xml"], qt.Not(qt.Contains), "vml")
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		parts, err := makeCommentsFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+
+		sheet := file.Sheet["Sheet2"]
+		c.Assert(sheet.Cell(1, 1).Comment, qt.Equals, Comment{Author: "Reviewer", Text: "Where does this come from?"})
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "42")
+		c.Assert(sheet.Cell(3, 2).Comment, qt.Equals, Comment{Author: "Author", Text: "From the 2019 report"})
+		c.Assert(sheet.Cell(4, 0).Comment, qt.Equals, Comment{Author: "Reviewer", Text: "Thanks"})
+		c.Assert(sheet.Cell(0, 0).Comment, qt.Equals, Comment{})
+		c.Assert(sheet.Cell(0, 0).Hyperlink.Link, qt.Equals, "http://example.com")
+		c.Assert(file.Sheet["Sheet1"].Cell(0, 0).Comment, qt.Equals, Comment{})
+	})
+
+	c.Run("ReadRichText", func(c *qt.C) {
+		// Excel writes the author's name at the start of a note
+		// as a separate bold run.
+		file, err := OpenBinary(zipParts(c, map[string]string{
+			"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Notes" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/notes.xml"/></Relationships>`,
+			"xl/worksheets/notes.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheetData/><legacyDrawing r:id="rId1"/></worksheet>`,
+			"xl/worksheets/_rels/notes.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing" Target="../drawings/vmlDrawing1.vml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="/xl/comments7.xml"/></Relationships>`,
+			"xl/comments7.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<comments xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><authors><author>Jane</author></authors><commentList><comment ref="D3" authorId="0"><text><r><rPr><b/></rPr><t>Jane:</t></r><r><t xml:space="preserve">
+Check this</t></r></text></comment></commentList></comments>`,
+		}))
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Notes"].Cell(2, 3).Comment, qt.Equals, Comment{Author: "Jane", Text: "Jane:\nCheck this"})
+	})
+}
@@ -0,0 +1,582 @@
+package xlsx
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ConditionalFormatType is the type of a conditional formatting rule,
+// it determines which of the fields of a ConditionalFormatRule are
+// used.
+type ConditionalFormatType string
+
+// Conditional formatting rule types.  Other types that Excel
+// supports, such as "containsText" or "duplicateValues", are
+// preserved when a file is read and saved again.
+const (
+	// ConditionalFormatTypeCellIs compares the value of each cell
+	// against one or two formulas using the rule's Operator.
+	ConditionalFormatTypeCellIs ConditionalFormatType = "cellIs"
+	// ConditionalFormatTypeExpression applies the rule's Style
+	// wherever its formula is true.
+	ConditionalFormatTypeExpression ConditionalFormatType = "expression"
+	// ConditionalFormatTypeColorScale shades the cells on a
+	// gradient of colors.
+	ConditionalFormatTypeColorScale ConditionalFormatType = "colorScale"
+	// ConditionalFormatTypeDataBar draws a bar in each cell in
+	// proportion to its value.
+	ConditionalFormatTypeDataBar ConditionalFormatType = "dataBar"
+	// ConditionalFormatTypeIconSet shows an icon in each cell
+	// depending on its value.
+	ConditionalFormatTypeIconSet ConditionalFormatType = "iconSet"
+	// ConditionalFormatTypeTop10 applies the rule's Style to the top
+	// or bottom N values, or N percent of the values.
+	ConditionalFormatTypeTop10 ConditionalFormatType = "top10"
+)
+
+// ConditionalFormatOperator is the comparison used by a
+// ConditionalFormatTypeCellIs rule.
+type ConditionalFormatOperator string
+
+// Conditional formatting operators
+const (
+	ConditionalFormatOperatorBetween            ConditionalFormatOperator = "between"
+	ConditionalFormatOperatorNotBetween         ConditionalFormatOperator = "notBetween"
+	ConditionalFormatOperatorEqual              ConditionalFormatOperator = "equal"
+	ConditionalFormatOperatorNotEqual           ConditionalFormatOperator = "notEqual"
+	ConditionalFormatOperatorGreaterThan        ConditionalFormatOperator = "greaterThan"
+	ConditionalFormatOperatorGreaterThanOrEqual ConditionalFormatOperator = "greaterThanOrEqual"
+	ConditionalFormatOperatorLessThan           ConditionalFormatOperator = "lessThan"
+	ConditionalFormatOperatorLessThanOrEqual    ConditionalFormatOperator = "lessThanOrEqual"
+)
+
+// ConditionalFormatValueType determines how the Value of a
+// ConditionalFormatValue is interpreted.
+type ConditionalFormatValueType string
+
+// Conditional formatting value types
+const (
+	ConditionalFormatValueMin        ConditionalFormatValueType = "min"
+	ConditionalFormatValueMax        ConditionalFormatValueType = "max"
+	ConditionalFormatValueNumber     ConditionalFormatValueType = "num"
+	ConditionalFormatValuePercent    ConditionalFormatValueType = "percent"
+	ConditionalFormatValuePercentile ConditionalFormatValueType = "percentile"
+	ConditionalFormatValueFormula    ConditionalFormatValueType = "formula"
+)
+
+// ConditionalFormat applies a list of conditional formatting rules to
+// a range of cells in a Sheet.
+type ConditionalFormat struct {
+	// Ref is the range of cells the rules apply to, for example
+	// "A1:D10".  Several ranges can be given separated by spaces.
+	Ref   string
+	Rules []*ConditionalFormatRule
+}
+
+// ConditionalFormatRule is a single conditional formatting rule.
+// Which fields are used depends on the Type of the rule, the New...Rule
+// functions create rules of each of the common types.
+type ConditionalFormatRule struct {
+	Type ConditionalFormatType
+	// Priority orders the rules of a sheet, 1 being the highest
+	// priority.  Rules with a Priority of 0 are given the lowest
+	// priorities, in the order they were added, when the sheet is
+	// saved.
+	Priority   int
+	StopIfTrue bool
+	// Operator and Formulas are used by cellIs rules, which take
+	// two formulas for the between and notBetween operators and
+	// one otherwise.  Expression rules take a single formula.
+	// Formulas are written without the leading "=".
+	Operator ConditionalFormatOperator
+	Formulas []string
+	// Rank, Percent and Bottom are used by top10 rules.
+	Rank    int
+	Percent bool
+	Bottom  bool
+	// The following are only used by rule types that have no
+	// dedicated constructor, and are kept so such rules survive
+	// being read and saved.
+	Text         string
+	TimePeriod   string
+	AboveAverage *bool
+	EqualAverage bool
+	StdDev       int
+	// Style is applied to the cells matched by cellIs, expression
+	// and top10 rules.
+	Style      *DifferentialStyle
+	ColorScale *ColorScale
+	DataBar    *DataBar
+	IconSet    *IconSet
+}
+
+// DifferentialStyle is the formatting that a conditional formatting
+// rule applies to a cell.  Unlike a Style it only describes the
+// properties that are to change, fields that are left empty keep
+// the cell's own formatting.  Note that, as in Excel, the color of
+// a solid fill is given by its BgColor.
+type DifferentialStyle struct {
+	Font   *Font
+	Fill   *Fill
+	Border *Border
+	NumFmt string
+}
+
+// ConditionalFormatValue is one of the thresholds of a color scale,
+// data bar or icon set.
+type ConditionalFormatValue struct {
+	Type  ConditionalFormatValueType
+	Value string
+	// GreaterThan makes an icon set threshold exclusive, it is
+	// inclusive by default.
+	GreaterThan bool
+}
+
+// ColorScale shades cells on a gradient between two or three colors,
+// Colors holds the ARGB color for each of the Values.
+type ColorScale struct {
+	Values []ConditionalFormatValue
+	Colors []string
+}
+
+// DataBar draws a bar of Color in each cell, the length of which is
+// relative to the Min and Max values.
+type DataBar struct {
+	Min       ConditionalFormatValue
+	Max       ConditionalFormatValue
+	Color     string
+	HideValue bool
+	// MinLength and MaxLength are percentages of the cell width,
+	// Excel uses 10 and 90 when they are zero.
+	MinLength int
+	MaxLength int
+}
+
+// IconSet shows one of a set of icons in each cell.  Name is one of
+// Excel's icon set names, for example "3TrafficLights1", "3Arrows",
+// "4Rating" or "5Quarters".  Values holds the threshold for each
+// icon, the first of which is always the minimum.
+type IconSet struct {
+	Name      string
+	Values    []ConditionalFormatValue
+	Reverse   bool
+	HideValue bool
+}
+
+// NewCellIsRule creates a rule that applies style to cells whose value
+// compares to the formulas with operator.  The between and notBetween
+// operators require two formulas, all others one.
+func NewCellIsRule(operator ConditionalFormatOperator, style *DifferentialStyle, formulas ...string) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type:     ConditionalFormatTypeCellIs,
+		Operator: operator,
+		Formulas: formulas,
+		Style:    style,
+	}
+}
+
+// NewExpressionRule creates a rule that applies style wherever formula
+// is true.  Relative references in formula are relative to the top
+// left cell of the range the rule is added to.
+func NewExpressionRule(formula string, style *DifferentialStyle) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type:     ConditionalFormatTypeExpression,
+		Formulas: []string{formula},
+		Style:    style,
+	}
+}
+
+// NewColorScaleRule creates a two color scale from minColor for the
+// lowest value to maxColor for the highest.
+func NewColorScaleRule(minColor, maxColor string) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type: ConditionalFormatTypeColorScale,
+		ColorScale: &ColorScale{
+			Values: []ConditionalFormatValue{
+				{Type: ConditionalFormatValueMin},
+				{Type: ConditionalFormatValueMax},
+			},
+			Colors: []string{minColor, maxColor},
+		},
+	}
+}
+
+// NewThreeColorScaleRule creates a three color scale, with midColor
+// used for the median value.
+func NewThreeColorScaleRule(minColor, midColor, maxColor string) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type: ConditionalFormatTypeColorScale,
+		ColorScale: &ColorScale{
+			Values: []ConditionalFormatValue{
+				{Type: ConditionalFormatValueMin},
+				{Type: ConditionalFormatValuePercentile, Value: "50"},
+				{Type: ConditionalFormatValueMax},
+			},
+			Colors: []string{minColor, midColor, maxColor},
+		},
+	}
+}
+
+// NewDataBarRule creates a rule that draws bars of the given color,
+// scaled from the lowest to the highest value.
+func NewDataBarRule(color string) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type: ConditionalFormatTypeDataBar,
+		DataBar: &DataBar{
+			Min:   ConditionalFormatValue{Type: ConditionalFormatValueMin},
+			Max:   ConditionalFormatValue{Type: ConditionalFormatValueMax},
+			Color: color,
+		},
+	}
+}
+
+// NewIconSetRule creates a rule that shows the named icon set, with
+// the icons spread evenly over the range of values in the same way as
+// Excel's defaults.
+func NewIconSetRule(name string) *ConditionalFormatRule {
+	icons, err := strconv.Atoi(name[:1])
+	if err != nil || icons < 3 {
+		icons = 3
+	}
+	iconSet := &IconSet{Name: name}
+	for i := 0; i < icons; i++ {
+		iconSet.Values = append(iconSet.Values, ConditionalFormatValue{
+			Type:  ConditionalFormatValuePercent,
+			Value: strconv.Itoa((i*100 + icons/2) / icons),
+		})
+	}
+	return &ConditionalFormatRule{
+		Type:    ConditionalFormatTypeIconSet,
+		IconSet: iconSet,
+	}
+}
+
+// NewTopRule creates a rule that applies style to the rank highest
+// values, or to the rank percent highest values.
+func NewTopRule(rank int, percent bool, style *DifferentialStyle) *ConditionalFormatRule {
+	return &ConditionalFormatRule{
+		Type:    ConditionalFormatTypeTop10,
+		Rank:    rank,
+		Percent: percent,
+		Style:   style,
+	}
+}
+
+// NewBottomRule creates a rule that applies style to the rank lowest
+// values, or to the rank percent lowest values.
+func NewBottomRule(rank int, percent bool, style *DifferentialStyle) *ConditionalFormatRule {
+	rule := NewTopRule(rank, percent, style)
+	rule.Bottom = true
+	return rule
+}
+
+// AddConditionalFormat applies the given rules to the cells in ref,
+// for example "B2:B20", and returns the resulting ConditionalFormat.
+func (s *Sheet) AddConditionalFormat(ref string, rules ...*ConditionalFormatRule) *ConditionalFormat {
+	s.load()
+	cf := &ConditionalFormat{Ref: ref, Rules: rules}
+	s.ConditionalFormats = append(s.ConditionalFormats, cf)
+	return cf
+}
+
+//
+// Conversion to and from the XML representation
+//
+
+func (v ConditionalFormatValue) makeXLSXCfvo() xlsxCfvo {
+	cfvo := xlsxCfvo{Type: string(v.Type), Val: v.Value}
+	if v.GreaterThan {
+		gte := false
+		cfvo.Gte = &gte
+	}
+	return cfvo
+}
+
+func readConditionalFormatValue(cfvo xlsxCfvo) ConditionalFormatValue {
+	return ConditionalFormatValue{
+		Type:        ConditionalFormatValueType(cfvo.Type),
+		Value:       cfvo.Val,
+		GreaterThan: cfvo.Gte != nil && !*cfvo.Gte,
+	}
+}
+
+// makeXLSXDXF converts a DifferentialStyle to its XML representation,
+// registering any number format it uses with styles.
+func (ds *DifferentialStyle) makeXLSXDXF(styles *xlsxStyleSheet) *xlsxDXF {
+	dxf := &xlsxDXF{}
+	if font := ds.Font; font != nil {
+		xFont := &xlsxFont{}
+		if font.Size > 0 {
+			xFont.Sz.Val = strconv.Itoa(font.Size)
+		}
+		xFont.Name.Val = font.Name
+		if font.Family > 0 {
+			xFont.Family.Val = strconv.Itoa(font.Family)
+		}
+		if font.Charset > 0 {
+			xFont.Charset.Val = strconv.Itoa(font.Charset)
+		}
+		xFont.Color.RGB = font.Color
+		if font.Bold {
+			xFont.B = &xlsxVal{}
+		}
+		if font.Italic {
+			xFont.I = &xlsxVal{}
+		}
+		if font.Underline {
+			xFont.U = &xlsxVal{}
+		}
+		dxf.Font = xFont
+	}
+	if ds.NumFmt != "" {
+		numFmt := styles.newNumFmt(ds.NumFmt)
+		dxf.NumFmt = &numFmt
+	}
+	if fill := ds.Fill; fill != nil {
+		patternType := fill.PatternType
+		if patternType == "" {
+			patternType = Solid_Cell_Fill
+		}
+		dxf.Fill = &xlsxFill{PatternFill: xlsxPatternFill{
+			PatternType: patternType,
+			FgColor:     xlsxColor{RGB: fill.FgColor},
+			BgColor:     xlsxColor{RGB: fill.BgColor},
+		}}
+	}
+	if border := ds.Border; border != nil {
+		dxf.Border = &xlsxBorder{
+			Left:   xlsxLine{Style: border.Left, Color: xlsxColor{RGB: border.LeftColor}},
+			Right:  xlsxLine{Style: border.Right, Color: xlsxColor{RGB: border.RightColor}},
+			Top:    xlsxLine{Style: border.Top, Color: xlsxColor{RGB: border.TopColor}},
+			Bottom: xlsxLine{Style: border.Bottom, Color: xlsxColor{RGB: border.BottomColor}},
+		}
+	}
+	return dxf
+}
+
+// getDifferentialStyle returns the DifferentialStyle described by the
+// dxf at the given index, or nil if there is no such dxf.
+func (styles *xlsxStyleSheet) getDifferentialStyle(dxfID int) *DifferentialStyle {
+	if dxfID < 0 || dxfID >= len(styles.DXfs.Dxf) {
+		return nil
+	}
+	dxf := styles.DXfs.Dxf[dxfID]
+	ds := &DifferentialStyle{}
+	if xFont := dxf.Font; xFont != nil {
+		font := &Font{}
+		font.Size, _ = strconv.Atoi(xFont.Sz.Val)
+		font.Name = xFont.Name.Val
+		font.Family, _ = strconv.Atoi(xFont.Family.Val)
+		font.Charset, _ = strconv.Atoi(xFont.Charset.Val)
+		font.Color = styles.argbValue(xFont.Color)
+		font.Bold = xFont.B != nil && xFont.B.Val != "0"
+		font.Italic = xFont.I != nil && xFont.I.Val != "0"
+		font.Underline = xFont.U != nil && xFont.U.Val != "0"
+		ds.Font = font
+	}
+	if dxf.NumFmt != nil {
+		ds.NumFmt = dxf.NumFmt.FormatCode
+		if ds.NumFmt == "" {
+			ds.NumFmt = getBuiltinNumberFormat(dxf.NumFmt.NumFmtId)
+		}
+	}
+	if xFill := dxf.Fill; xFill != nil {
+		ds.Fill = &Fill{
+			PatternType: xFill.PatternFill.PatternType,
+			FgColor:     styles.argbValue(xFill.PatternFill.FgColor),
+			BgColor:     styles.argbValue(xFill.PatternFill.BgColor),
+		}
+	}
+	if xBorder := dxf.Border; xBorder != nil {
+		ds.Border = &Border{
+			Left:        xBorder.Left.Style,
+			LeftColor:   styles.argbValue(xBorder.Left.Color),
+			Right:       xBorder.Right.Style,
+			RightColor:  styles.argbValue(xBorder.Right.Color),
+			Top:         xBorder.Top.Style,
+			TopColor:    styles.argbValue(xBorder.Top.Color),
+			Bottom:      xBorder.Bottom.Style,
+			BottomColor: styles.argbValue(xBorder.Bottom.Color),
+		}
+	}
+	return ds
+}
+
+// makeXLSXCfRule converts a ConditionalFormatRule to its XML
+// representation.
+func (r *ConditionalFormatRule) makeXLSXCfRule(styles *xlsxStyleSheet) *xlsxCfRule {
+	rule := &xlsxCfRule{
+		Type:         string(r.Type),
+		Priority:     r.Priority,
+		StopIfTrue:   r.StopIfTrue,
+		AboveAverage: r.AboveAverage,
+		Percent:      r.Percent,
+		Bottom:       r.Bottom,
+		Operator:     string(r.Operator),
+		Text:         r.Text,
+		TimePeriod:   r.TimePeriod,
+		Rank:         r.Rank,
+		StdDev:       r.StdDev,
+		EqualAverage: r.EqualAverage,
+	}
+	for _, formula := range r.Formulas {
+		rule.Formula = append(rule.Formula, strings.TrimPrefix(formula, "="))
+	}
+	if r.Style != nil {
+		dxfID := styles.addDXF(r.Style.makeXLSXDXF(styles))
+		rule.DxfID = &dxfID
+	}
+	if cs := r.ColorScale; cs != nil {
+		rule.ColorScale = &xlsxColorScale{}
+		for _, v := range cs.Values {
+			rule.ColorScale.Cfvo = append(rule.ColorScale.Cfvo, v.makeXLSXCfvo())
+		}
+		for _, color := range cs.Colors {
+			rule.ColorScale.Color = append(rule.ColorScale.Color, xlsxColor{RGB: color})
+		}
+	}
+	if db := r.DataBar; db != nil {
+		rule.DataBar = &xlsxDataBar{
+			Cfvo:  []xlsxCfvo{db.Min.makeXLSXCfvo(), db.Max.makeXLSXCfvo()},
+			Color: []xlsxColor{{RGB: db.Color}},
+		}
+		if db.MinLength > 0 {
+			minLength := db.MinLength
+			rule.DataBar.MinLength = &minLength
+		}
+		if db.MaxLength > 0 {
+			maxLength := db.MaxLength
+			rule.DataBar.MaxLength = &maxLength
+		}
+		if db.HideValue {
+			showValue := false
+			rule.DataBar.ShowValue = &showValue
+		}
+	}
+	if is := r.IconSet; is != nil {
+		rule.IconSet = &xlsxIconSet{IconSet: is.Name, Reverse: is.Reverse}
+		for _, v := range is.Values {
+			rule.IconSet.Cfvo = append(rule.IconSet.Cfvo, v.makeXLSXCfvo())
+		}
+		if is.HideValue {
+			showValue := false
+			rule.IconSet.ShowValue = &showValue
+		}
+	}
+	return rule
+}
+
+// readConditionalFormatRule converts the XML representation of a
+// rule to a ConditionalFormatRule.  styles may be nil if the file has
+// no style sheet.
+func readConditionalFormatRule(rule *xlsxCfRule, styles *xlsxStyleSheet) *ConditionalFormatRule {
+	r := &ConditionalFormatRule{
+		Type:         ConditionalFormatType(rule.Type),
+		Priority:     rule.Priority,
+		StopIfTrue:   rule.StopIfTrue,
+		Operator:     ConditionalFormatOperator(rule.Operator),
+		Formulas:     rule.Formula,
+		Rank:         rule.Rank,
+		Percent:      rule.Percent,
+		Bottom:       rule.Bottom,
+		Text:         rule.Text,
+		TimePeriod:   rule.TimePeriod,
+		AboveAverage: rule.AboveAverage,
+		EqualAverage: rule.EqualAverage,
+		StdDev:       rule.StdDev,
+	}
+	if rule.DxfID != nil && styles != nil {
+		r.Style = styles.getDifferentialStyle(*rule.DxfID)
+	}
+	argb := func(color xlsxColor) string {
+		if styles == nil {
+			return color.RGB
+		}
+		return styles.argbValue(color)
+	}
+	if cs := rule.ColorScale; cs != nil {
+		r.ColorScale = &ColorScale{}
+		for _, cfvo := range cs.Cfvo {
+			r.ColorScale.Values = append(r.ColorScale.Values, readConditionalFormatValue(cfvo))
+		}
+		for _, color := range cs.Color {
+			r.ColorScale.Colors = append(r.ColorScale.Colors, argb(color))
+		}
+	}
+	if db := rule.DataBar; db != nil {
+		r.DataBar = &DataBar{HideValue: db.ShowValue != nil && !*db.ShowValue}
+		if len(db.Cfvo) > 0 {
+			r.DataBar.Min = readConditionalFormatValue(db.Cfvo[0])
+		}
+		if len(db.Cfvo) > 1 {
+			r.DataBar.Max = readConditionalFormatValue(db.Cfvo[1])
+		}
+		if len(db.Color) > 0 {
+			r.DataBar.Color = argb(db.Color[0])
+		}
+		if db.MinLength != nil {
+			r.DataBar.MinLength = *db.MinLength
+		}
+		if db.MaxLength != nil {
+			r.DataBar.MaxLength = *db.MaxLength
+		}
+	}
+	if is := rule.IconSet; is != nil {
+		r.IconSet = &IconSet{
+			Name:      is.IconSet,
+			Reverse:   is.Reverse,
+			HideValue: is.ShowValue != nil && !*is.ShowValue,
+		}
+		if r.IconSet.Name == "" {
+			// The default icon set
+			r.IconSet.Name = "3TrafficLights1"
+		}
+		for _, cfvo := range is.Cfvo {
+			r.IconSet.Values = append(r.IconSet.Values, readConditionalFormatValue(cfvo))
+		}
+	}
+	return r
+}
+
+// readConditionalFormatsFromSheet converts the conditionalFormatting
+// elements of a worksheet to ConditionalFormats.
+func readConditionalFormatsFromSheet(worksheet *xlsxWorksheet, styles *xlsxStyleSheet) []*ConditionalFormat {
+	var formats []*ConditionalFormat
+	for _, xcf := range worksheet.ConditionalFormatting {
+		cf := &ConditionalFormat{Ref: xcf.SQRef}
+		for _, rule := range xcf.CfRule {
+			cf.Rules = append(cf.Rules, readConditionalFormatRule(rule, styles))
+		}
+		formats = append(formats, cf)
+	}
+	return formats
+}
+
+// makeConditionalFormatting adds the sheet's conditional formats to
+// the worksheet.  Rules without a priority are given priorities below
+// those of the other rules, in the order they were added.
+func (s *Sheet) makeConditionalFormatting(worksheet *xlsxWorksheet, styles *xlsxStyleSheet) {
+	nextPriority := 1
+	for _, cf := range s.ConditionalFormats {
+		for _, rule := range cf.Rules {
+			if rule.Priority >= nextPriority {
+				nextPriority = rule.Priority + 1
+			}
+		}
+	}
+	for _, cf := range s.ConditionalFormats {
+		if len(cf.Rules) == 0 {
+			continue
+		}
+		xcf := &xlsxConditionalFormatting{SQRef: cf.Ref}
+		for _, rule := range cf.Rules {
+			xrule := rule.makeXLSXCfRule(styles)
+			if xrule.Priority == 0 {
+				xrule.Priority = nextPriority
+				nextPriority++
+			}
+			xcf.CfRule = append(xcf.CfRule, xrule)
+		}
+		worksheet.ConditionalFormatting = append(worksheet.ConditionalFormatting, xcf)
+	}
+}
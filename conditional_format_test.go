@@ -0,0 +1,159 @@
+package xlsx
+
+import (
+	"reflect"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestConditionalFormat(t *testing.T) {
+	c := qt.New(t)
+
+	redText := &DifferentialStyle{
+		Font: &Font{Color: RGB_Dark_Red},
+		Fill: &Fill{BgColor: RGB_Light_Red},
+	}
+
+	makeConditionalFormatFile := func(c *qt.C) *File {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < 10; i++ {
+			sheet.Cell(i, 0).SetInt(i * 10)
+		}
+		sheet.AddConditionalFormat("A1:A10",
+			NewCellIsRule(ConditionalFormatOperatorGreaterThan, redText, "50"),
+			NewCellIsRule(ConditionalFormatOperatorBetween, &DifferentialStyle{
+				Font:   &Font{Bold: true},
+				NumFmt: "0.000",
+			}, "10", "=20"),
+		)
+		sheet.AddConditionalFormat("B1:B10",
+			NewExpressionRule("MOD(ROW(),2)=0", redText))
+		sheet.AddConditionalFormat("C1:C10", NewThreeColorScaleRule(RGB_Light_Red, "FFFFEB84", RGB_Light_Green))
+		sheet.AddConditionalFormat("D1:D10", NewDataBarRule("FF638EC6"))
+		sheet.AddConditionalFormat("E1:E10", NewIconSetRule("3Arrows"))
+		sheet.AddConditionalFormat("F1:F10", NewTopRule(3, false, redText))
+		sheet.AddConditionalFormat("G1:G10", NewBottomRule(10, true, &DifferentialStyle{
+			Border: &Border{Bottom: "thin", BottomColor: RGB_Dark_Green},
+		}))
+		return file
+	}
+
+	c.Run("IconSetThresholds", func(c *qt.C) {
+		values := func(name string) []string {
+			var result []string
+			for _, v := range NewIconSetRule(name).IconSet.Values {
+				c.Assert(v.Type, qt.Equals, ConditionalFormatValuePercent)
+				result = append(result, v.Value)
+			}
+			return result
+		}
+		c.Assert(values("3TrafficLights1"), qt.DeepEquals, []string{"0", "33", "67"})
+		c.Assert(values("4Rating"), qt.DeepEquals, []string{"0", "25", "50", "75"})
+		c.Assert(values("5Quarters"), qt.DeepEquals, []string{"0", "20", "40", "60", "80"})
+	})
+
+	c.Run("Write", func(c *qt.C) {
+		parts, err := makeConditionalFormatFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+
+		sheet := parts["xl/worksheets/sheet1.xml"]
+		c.Assert(sheet, qt.Contains, `</sheetData><conditionalFormatting sqref="A1:A10">`+
+			`<cfRule type="cellIs" dxfId="0" priority="1" operator="greaterThan"><formula>50</formula></cfRule>`+
+			`<cfRule type="cellIs" dxfId="1" priority="2" operator="between"><formula>10</formula><formula>20</formula></cfRule>`+
+			`</conditionalFormatting>`)
+		c.Assert(sheet, qt.Contains, `<conditionalFormatting sqref="B1:B10">`+
+			`<cfRule type="expression" dxfId="0" priority="3"><formula>MOD(ROW(),2)=0</formula></cfRule>`+
+			`</conditionalFormatting>`)
+		c.Assert(sheet, qt.Contains, `<conditionalFormatting sqref="C1:C10"><cfRule type="colorScale" priority="4"><colorScale>`+
+			`<cfvo type="min"></cfvo><cfvo type="percentile" val="50"></cfvo><cfvo type="max"></cfvo>`+
+			`<color rgb="FFFFC7CE"></color><color rgb="FFFFEB84"></color><color rgb="FFC6EFCE"></color>`+
+			`</colorScale></cfRule></conditionalFormatting>`)
+		c.Assert(sheet, qt.Contains, `<conditionalFormatting sqref="D1:D10"><cfRule type="dataBar" priority="5"><dataBar>`+
+			`<cfvo type="min"></cfvo><cfvo type="max"></cfvo><color rgb="FF638EC6"></color>`+
+			`</dataBar></cfRule></conditionalFormatting>`)
+		c.Assert(sheet, qt.Contains, `<conditionalFormatting sqref="E1:E10"><cfRule type="iconSet" priority="6"><iconSet iconSet="3Arrows">`+
+			`<cfvo type="percent" val="0"></cfvo><cfvo type="percent" val="33"></cfvo><cfvo type="percent" val="67"></cfvo>`+
+			`</iconSet></cfRule></conditionalFormatting>`)
+		c.Assert(sheet, qt.Contains, `<cfRule type="top10" dxfId="0" priority="7" rank="3"></cfRule>`)
+		c.Assert(sheet, qt.Contains, `<cfRule type="top10" dxfId="2" priority="8" percent="true" bottom="true" rank="10"></cfRule>`)
+		c.Assert(sheet, qt.Contains, `</conditionalFormatting><printOptions`)
+
+		styles := parts["xl/styles.xml"]
+		c.Assert(styles, qt.Contains, `<dxfs count="3">`+
+			`<dxf><font><color rgb="FF9C0006"/></font><fill><patternFill patternType="solid"><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf>`+
+			`<dxf><font><b/></font><numFmt numFmtId="164" formatCode="0.000"/></dxf>`+
+			`<dxf><border><left/><right/><top/><bottom style="thin"><color rgb="FF006100"/></bottom></border></dxf>`+
+			`</dxfs>`)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		expected := makeConditionalFormatFile(c)
+		parts, err := expected.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+
+		formats := file.Sheet["Sheet1"].ConditionalFormats
+		c.Assert(formats, qt.HasLen, 7)
+		// The rules read back have the priorities they were
+		// given on saving, and formulas without the "=".
+		priority := 1
+		for _, cf := range expected.Sheet["Sheet1"].ConditionalFormats {
+			for _, rule := range cf.Rules {
+				rule.Priority = priority
+				priority++
+			}
+		}
+		expected.Sheet["Sheet1"].ConditionalFormats[0].Rules[1].Formulas[1] = "20"
+		// Fills are always given a pattern type.
+		expected.Sheet["Sheet1"].ConditionalFormats[0].Rules[0].Style.Fill.PatternType = Solid_Cell_Fill
+
+		for i, cf := range formats {
+			expectedCF := expected.Sheet["Sheet1"].ConditionalFormats[i]
+			c.Assert(cf.Ref, qt.Equals, expectedCF.Ref)
+			c.Assert(cf.Rules, qt.HasLen, len(expectedCF.Rules))
+			for j, rule := range cf.Rules {
+				c.Assert(reflect.DeepEqual(rule, expectedCF.Rules[j]), qt.Equals, true,
+					qt.Commentf("%s rule %d: got %#v, want %#v", cf.Ref, j, rule, expectedCF.Rules[j]))
+			}
+		}
+	})
+
+	c.Run("ReadOtherRules", func(c *qt.C) {
+		file, err := OpenBinary(zipParts(c, map[string]string{
+			"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`,
+			"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`,
+			"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><dxfs count="1"><dxf><font><b/><color rgb="FFFF0000"/></font></dxf></dxfs></styleSheet>`,
+			"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/>` +
+				`<conditionalFormatting sqref="A1:A5 C1"><cfRule type="containsText" dxfId="0" priority="1" operator="containsText" text="x"><formula>NOT(ISERROR(SEARCH("x",A1)))</formula></cfRule></conditionalFormatting>` +
+				`<conditionalFormatting sqref="B1:B5"><cfRule type="dataBar" priority="2"><dataBar showValue="0"><cfvo type="num" val="0"/><cfvo type="max"/><color rgb="FF4F81BD"/></dataBar></cfRule></conditionalFormatting>` +
+				`</worksheet>`,
+		}))
+		c.Assert(err, qt.IsNil)
+
+		formats := file.Sheet["Sheet1"].ConditionalFormats
+		c.Assert(formats, qt.HasLen, 2)
+		c.Assert(formats[0].Ref, qt.Equals, "A1:A5 C1")
+		rule := formats[0].Rules[0]
+		c.Assert(rule.Type, qt.Equals, ConditionalFormatType("containsText"))
+		c.Assert(rule.Text, qt.Equals, "x")
+		c.Assert(rule.Formulas, qt.DeepEquals, []string{`NOT(ISERROR(SEARCH("x",A1)))`})
+		c.Assert(rule.Style.Font.Bold, qt.Equals, true)
+		c.Assert(rule.Style.Font.Color, qt.Equals, "FFFF0000")
+
+		dataBar := formats[1].Rules[0].DataBar
+		c.Assert(dataBar.Min, qt.Equals, ConditionalFormatValue{Type: ConditionalFormatValueNumber, Value: "0"})
+		c.Assert(dataBar.Max, qt.Equals, ConditionalFormatValue{Type: ConditionalFormatValueMax})
+		c.Assert(dataBar.HideValue, qt.Equals, true)
+		c.Assert(dataBar.Color, qt.Equals, "FF4F81BD")
+	})
+}
@@ -0,0 +1,377 @@
+package xlsx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// date1904Offset is the number of days between the starts of the 1900
+// and 1904 date systems.
+const date1904Offset = 1462
+
+// CloneSheet adds a copy of the sheet src, called newName, to the
+// file.  src may belong to this file or to another one, so a workbook
+// can be assembled from the sheets of several.  The copy has its own
+// cells, styles, column settings, merged cells, data validations,
+// conditional formats, hyperlinks, comments, pictures, charts, tables
+// and autofilter.  Styles are added to the file's style sheet when it
+// is saved, and dates are moved if the files use different date
+// systems.  Charts that show cells of src show those of the copy, and
+// copied tables are renamed if their names are taken.  Parts of src
+// that the library doesn't model aren't copied.
+//
+// Formulas are copied unchanged.  When src belongs to another file,
+// references in them to other sheets of that file aren't resolved:
+// they refer to the sheets of the same names in this file, if it has
+// any, so they should be checked or replaced by values.
+func (f *File) CloneSheet(src *Sheet, newName string) (*Sheet, error) {
+	if err := src.Load(); err != nil {
+		return nil, err
+	}
+	sheet, err := f.AddSheet(newName)
+	if err != nil {
+		return nil, err
+	}
+	c := newSheetCopier(src, sheet)
+	sheet.Hidden = src.Hidden
+	sheet.SheetFormat = src.SheetFormat
+	sheet.MaxRow, sheet.MaxCol = src.extent()
+	sheet.Rows = make([]*Row, len(src.Rows))
+	for y, row := range src.Rows {
+		if row == nil {
+			continue
+		}
+		rowCopy := *row
+		rowCopy.Sheet = sheet
+		rowCopy.Cells = make([]*Cell, len(row.Cells))
+		for x, cell := range row.Cells {
+			if cell != nil {
+				rowCopy.Cells[x] = c.cell(cell, &rowCopy, 0, 0)
+			}
+		}
+		sheet.Rows[y] = &rowCopy
+	}
+	if src.Cols != nil {
+		src.Cols.ForEach(func(_ int, col *Col) {
+			colCopy := *col
+			colCopy.style = c.style(col.style)
+			sheet.Cols.Add(&colCopy)
+		})
+	}
+	for _, view := range src.SheetViews {
+		if view.Pane != nil {
+			pane := *view.Pane
+			view.Pane = &pane
+		}
+		sheet.SheetViews = append(sheet.SheetViews, view)
+	}
+	if src.AutoFilter != nil {
+		autoFilter := *src.AutoFilter
+		sheet.AutoFilter = &autoFilter
+	}
+	if src.Protection != nil {
+		protection := *src.Protection
+		sheet.Protection = &protection
+	}
+	for _, dv := range src.DataValidations {
+		sheet.DataValidations = append(sheet.DataValidations, c.dataValidation(dv, 0, 0))
+	}
+	for _, cf := range src.ConditionalFormats {
+		sheet.ConditionalFormats = append(sheet.ConditionalFormats, c.conditionalFormat(cf, cf.Ref, 0, 0))
+	}
+	for _, picture := range src.Pictures {
+		pictureCopy := *picture
+		sheet.Pictures = append(sheet.Pictures, &pictureCopy)
+	}
+	for _, chart := range src.Charts {
+		chartCopy := *chart
+		chartCopy.Series = make([]ChartSeries, len(chart.Series))
+		for i, series := range chart.Series {
+			series.Categories = renameFormulaSheet(series.Categories, src.Name, newName)
+			series.Values = renameFormulaSheet(series.Values, src.Name, newName)
+			chartCopy.Series[i] = series
+		}
+		sheet.Charts = append(sheet.Charts, &chartCopy)
+	}
+	for _, table := range src.Tables {
+		tableCopy := *table
+		tableCopy.Sheet = sheet
+		tableCopy.Columns = append([]TableColumn(nil), table.Columns...)
+		tableCopy.original = nil
+		for n := 2; f.Table(tableCopy.Name) != nil; n++ {
+			tableCopy.Name = table.Name + "_" + strconv.Itoa(n)
+		}
+		sheet.Tables = append(sheet.Tables, &tableCopy)
+	}
+	return sheet, nil
+}
+
+// CopyRange copies the cells of the range srcRange of the sheet, for
+// example "A1:C10", to the sheet dst, which may be this sheet or one of
+// another file, with the top left cell at dstCell.  The cells of dst
+// are replaced, along with their values, formulas, styles, merges,
+// data validations, hyperlinks and comments, and the data validations
+// and conditional formats of the sheet that apply to the range are
+// added to dst for the cells they apply to there.  As in Excel,
+// references in formulas that aren't fixed with a $ move by the same
+// number of rows and columns as the cells do, and merges are cut at
+// the edges of the range.  Column settings, tables and pictures aren't
+// copied, CloneSheet copies those.
+func (s *Sheet) CopyRange(srcRange string, dst *Sheet, dstCell string) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+	if err := dst.Load(); err != nil {
+		return err
+	}
+	x1, y1, x2, y2, err := parseCellRange(strings.Replace(srcRange, fixedCellRefChar, "", -1))
+	if err != nil {
+		return fmt.Errorf("CopyRange: %s", err)
+	}
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	toX, toY, err := GetCoordsFromCellIDString(dstCell)
+	if err != nil {
+		return fmt.Errorf("CopyRange: %s", err)
+	}
+	dx, dy := toX-x1, toY-y1
+	if x2+dx > formulaMaxCol || y2+dy > formulaMaxRow {
+		return fmt.Errorf("CopyRange: %s doesn't fit on the sheet at %s", srcRange, dstCell)
+	}
+
+	// The copies are all made before any are placed, as the ranges
+	// may overlap.
+	c := newSheetCopier(s, dst)
+	copies := make([][]*Cell, y2-y1+1)
+	for y := y1; y <= y2; y++ {
+		copies[y-y1] = make([]*Cell, x2-x1+1)
+		if y >= len(s.Rows) || s.Rows[y] == nil {
+			continue
+		}
+		row := s.Rows[y]
+		for x := x1; x <= x2 && x < len(row.Cells); x++ {
+			cell := row.Cells[x]
+			if cell == nil {
+				continue
+			}
+			cellCopy := c.cell(cell, nil, dx, dy)
+			if x+cellCopy.HMerge > x2 {
+				cellCopy.HMerge = x2 - x
+			}
+			if y+cellCopy.VMerge > y2 {
+				cellCopy.VMerge = y2 - y
+			}
+			copies[y-y1][x-x1] = cellCopy
+		}
+	}
+	var validations []*xlsxDataValidation
+	for _, dv := range s.DataValidations {
+		if ref := moveRangeRefs(dv.Sqref, x1, y1, x2, y2, dx, dy); ref != "" {
+			dvCopy := c.dataValidation(dv, dx, dy)
+			dvCopy.Sqref = ref
+			validations = append(validations, dvCopy)
+		}
+	}
+	var formats []*ConditionalFormat
+	for _, cf := range s.ConditionalFormats {
+		if ref := moveRangeRefs(cf.Ref, x1, y1, x2, y2, dx, dy); ref != "" {
+			formats = append(formats, c.conditionalFormat(cf, ref, dx, dy))
+		}
+	}
+
+	for y, cells := range copies {
+		for x, cell := range cells {
+			if toY+y < len(dst.Rows) && dst.Rows[toY+y] == nil {
+				dst.Rows[toY+y] = &Row{Sheet: dst}
+			}
+			dst.Cell(toY+y, toX+x)
+			row := dst.Rows[toY+y]
+			if cell == nil {
+				cell = NewCell(row)
+			}
+			cell.Row = row
+			row.Cells[toX+x] = cell
+		}
+	}
+	dst.DataValidations = append(dst.DataValidations, validations...)
+	dst.ConditionalFormats = append(dst.ConditionalFormats, formats...)
+	dst.MaxRow, dst.MaxCol = dst.extent()
+	return nil
+}
+
+// sheetCopier copies the parts of a sheet to another sheet, which may
+// belong to another file.
+type sheetCopier struct {
+	src, dst *Sheet
+	// styles maps the styles of src to their copies, so that cells
+	// that share a style still do.
+	styles map[*Style]*Style
+	// otherFile is set if dst belongs to another file than src, and
+	// dateOffset is then the number of days that dates move by.
+	otherFile  bool
+	dateOffset float64
+}
+
+func newSheetCopier(src, dst *Sheet) *sheetCopier {
+	c := &sheetCopier{src: src, dst: dst, styles: make(map[*Style]*Style)}
+	c.otherFile = src.File != dst.File
+	if src.File != nil && dst.File != nil && src.File.Date1904 != dst.File.Date1904 {
+		c.dateOffset = date1904Offset
+		if dst.File.Date1904 {
+			c.dateOffset = -date1904Offset
+		}
+	}
+	return c
+}
+
+// style returns a copy of the style.  The index of the named style
+// that a style is based on only means something in its own file, so it
+// isn't copied to another one.
+func (c *sheetCopier) style(style *Style) *Style {
+	if style == nil {
+		return nil
+	}
+	if styleCopy, ok := c.styles[style]; ok {
+		return styleCopy
+	}
+	styleCopy := *style
+	if c.otherFile {
+		styleCopy.NamedStyleIndex = nil
+	}
+	c.styles[style] = &styleCopy
+	return &styleCopy
+}
+
+// cell returns a copy of the cell in row, whose formula is moved by dx
+// columns and dy rows.
+func (c *sheetCopier) cell(cell *Cell, row *Row, dx, dy int) *Cell {
+	cellCopy := *cell
+	cellCopy.Row = row
+	cellCopy.style = c.style(cell.style)
+	cellCopy.richText = append(RichText(nil), cell.richText...)
+	cellCopy.formula = moveFormula(cell.formula, dx, dy)
+	if cell.DataValidation != nil {
+		cellCopy.DataValidation = c.dataValidation(cell.DataValidation, dx, dy)
+	}
+	if c.dst.File != nil {
+		cellCopy.date1904 = c.dst.File.Date1904
+	}
+	if c.dateOffset != 0 && cell.Type() == CellTypeNumeric && cell.formula == "" && cell.IsTime() {
+		if value, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+			cellCopy.Value = strconv.FormatFloat(value+c.dateOffset, 'f', -1, 64)
+		}
+	}
+	if link := cell.Hyperlink.Link; link != "" {
+		mode := RelationshipTargetModeExternal
+		for _, rel := range c.src.Relations {
+			if rel.Type == RelationshipTypeHyperlink && rel.Target == link {
+				mode = rel.TargetMode
+			}
+		}
+		c.dst.addRelation(RelationshipTypeHyperlink, link, mode)
+	}
+	return &cellCopy
+}
+
+// dataValidation returns a copy of the data validation, whose formulas
+// are moved by dx columns and dy rows.
+func (c *sheetCopier) dataValidation(dv *xlsxDataValidation, dx, dy int) *xlsxDataValidation {
+	dvCopy := *dv
+	dvCopy.Formula1 = moveFormula(dv.Formula1, dx, dy)
+	dvCopy.Formula2 = moveFormula(dv.Formula2, dx, dy)
+	return &dvCopy
+}
+
+// conditionalFormat returns a copy of the conditional format that
+// applies to ref, whose formulas are moved by dx columns and dy rows.
+func (c *sheetCopier) conditionalFormat(cf *ConditionalFormat, ref string, dx, dy int) *ConditionalFormat {
+	cfCopy := &ConditionalFormat{Ref: ref}
+	for _, rule := range cf.Rules {
+		ruleCopy := *rule
+		ruleCopy.Formulas = make([]string, len(rule.Formulas))
+		for i, formula := range rule.Formulas {
+			ruleCopy.Formulas[i] = moveFormula(formula, dx, dy)
+		}
+		if rule.Style != nil {
+			style := *rule.Style
+			ruleCopy.Style = &style
+		}
+		cfCopy.Rules = append(cfCopy.Rules, &ruleCopy)
+	}
+	return cfCopy
+}
+
+// moveRangeRefs returns the parts of the space separated list of
+// references refs that are within the range from x1, y1 to x2, y2,
+// moved by dx columns and dy rows, or an empty string if there are
+// none.
+func moveRangeRefs(refs string, x1, y1, x2, y2, dx, dy int) string {
+	var moved []string
+	for _, ref := range strings.Fields(refs) {
+		rx1, ry1, rx2, ry2, err := parseCellRange(strings.Replace(ref, fixedCellRefChar, "", -1))
+		if err != nil {
+			continue
+		}
+		if rx1 < x1 {
+			rx1 = x1
+		}
+		if ry1 < y1 {
+			ry1 = y1
+		}
+		rx2, ry2 = minInt(rx2, x2), minInt(ry2, y2)
+		if rx1 <= rx2 && ry1 <= ry2 {
+			moved = append(moved, cellRangeRef(rx1+dx, ry1+dy, rx2+dx, ry2+dy))
+		}
+	}
+	return strings.Join(moved, " ")
+}
+
+// moveFormula returns the formula with the references in it that
+// aren't fixed with a $ moved by dx columns and dy rows.  References
+// that would move off the sheet become #REF!.
+func moveFormula(formula string, dx, dy int) string {
+	if dx == 0 && dy == 0 {
+		return formula
+	}
+	return rewriteFormulaRefs(formula, func(prefix, _, ref string) string {
+		corners := strings.Split(ref, cellRangeChar)
+		for i, corner := range corners {
+			m := refCornerRegexp.FindStringSubmatch(corner)
+			if m == nil {
+				return prefix + ref
+			}
+			if m[2] != "" && m[1] == "" {
+				x := refIndex(m[2], true) + dx
+				if x < 0 || x > formulaMaxCol {
+					return prefix + formulaErrorRef
+				}
+				m[2] = refIndexString(x, true)
+			}
+			if m[4] != "" && m[3] == "" {
+				y := refIndex(m[4], false) + dy
+				if y < 0 || y > formulaMaxRow {
+					return prefix + formulaErrorRef
+				}
+				m[4] = refIndexString(y, false)
+			}
+			corners[i] = m[1] + strings.ToUpper(m[2]) + m[3] + m[4]
+		}
+		return prefix + strings.Join(corners, cellRangeChar)
+	})
+}
+
+// renameFormulaSheet returns the formula with the references to the
+// sheet called from changed to refer to the sheet called to.
+func renameFormulaSheet(formula, from, to string) string {
+	return rewriteFormulaRefs(formula, func(prefix, sheet, ref string) string {
+		if sheet == "" || !strings.EqualFold(sheet, from) {
+			return prefix + ref
+		}
+		return "'" + strings.Replace(to, "'", "''", -1) + "'" + externalSheetBangChar + ref
+	})
+}
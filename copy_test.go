@@ -0,0 +1,179 @@
+package xlsx
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCopy(t *testing.T) {
+	c := qt.New(t)
+
+	// reportSheet returns a sheet with styled values, a formula, a date,
+	// a merged cell, a hyperlink, a comment, validations, a conditional
+	// format, column settings, a table and a chart.
+	reportSheet := func(c *qt.C, file *File) *Sheet {
+		sheet, err := file.AddSheet("Report")
+		c.Assert(err, qt.IsNil)
+		bold := NewStyle()
+		bold.Font.Bold = true
+		for x, header := range []string{"Month", "Sales", "Due"} {
+			sheet.Cell(0, x).SetString(header)
+			sheet.Cell(0, x).SetStyle(bold)
+		}
+		for y := 1; y <= 3; y++ {
+			sheet.Cell(y, 0).SetString(time.Month(y).String())
+			sheet.Cell(y, 1).SetInt(y * 100)
+			sheet.Cell(y, 2).SetDate(time.Date(2019, time.Month(y), 1, 0, 0, 0, 0, time.UTC))
+		}
+		sheet.Cell(4, 0).SetString("Total")
+		sheet.Cell(4, 0).Merge(0, 1)
+		sheet.Cell(4, 1).SetFormula("SUM(B2:B4)*$E$1")
+		sheet.Cell(4, 2).SetHyperlink("https://example.com/", "Details", "")
+		sheet.Cell(4, 2).SetComment("Ann", "Check")
+		sheet.Cell(1, 1).DataValidation = NewDataValidation(1, 1, 1, 1, false)
+		sheet.AddDataValidation(NewDataValidation(1, 0, 3, 0, false))
+		sheet.AddConditionalFormat("B2:B4", NewExpressionRule("B2>$E$1", nil))
+		sheet.SetColWidth(2, 3, 15)
+		_, err = sheet.AddTable("Sales", "A1:C4", []string{"Month", "Sales", "Due"}, "", false)
+		c.Assert(err, qt.IsNil)
+		chart := &Chart{Type: ChartTypeBar, Series: []ChartSeries{{Categories: "Report!$A$2:$A$4", Values: "'Report'!$B$2:$B$4"}}}
+		c.Assert(sheet.AddChart("E2", chart, nil), qt.IsNil)
+		return sheet
+	}
+
+	c.Run("CloneSheet", func(c *qt.C) {
+		file := NewFile()
+		src := reportSheet(c, file)
+		sheet, err := file.CloneSheet(src, "Report 2")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Report 2"], qt.Equals, sheet)
+		c.Assert(sheet.Selected, qt.Equals, false)
+		c.Assert(sheet.MaxRow, qt.Equals, 5)
+		c.Assert(sheet.Cell(2, 0).Value, qt.Equals, "February")
+		c.Assert(sheet.Cell(2, 0).Row.Sheet, qt.Equals, sheet)
+		c.Assert(sheet.Cell(4, 1).Formula(), qt.Equals, "SUM(B2:B4)*$E$1")
+		c.Assert(sheet.Cell(4, 0).VMerge, qt.Equals, 1)
+		c.Assert(sheet.Cell(4, 2).Hyperlink.Link, qt.Equals, "https://example.com/")
+		c.Assert(sheet.Cell(4, 2).Comment, qt.Equals, Comment{Author: "Ann", Text: "Check"})
+		c.Assert(sheet.Col(1).Width, qt.Equals, 15.0)
+		c.Assert(sheet.DataValidations[0].Sqref, qt.Equals, src.DataValidations[0].Sqref)
+		c.Assert(sheet.ConditionalFormats[0].Ref, qt.Equals, "B2:B4")
+		c.Assert(sheet.Tables[0].Name, qt.Equals, "Sales_2")
+		c.Assert(sheet.Tables[0].Sheet, qt.Equals, sheet)
+		c.Assert(sheet.Charts[0].Series[0].Categories, qt.Equals, "'Report 2'!$A$2:$A$4")
+		c.Assert(sheet.Charts[0].Series[0].Values, qt.Equals, "'Report 2'!$B$2:$B$4")
+		c.Assert(src.Charts[0].Series[0].Categories, qt.Equals, "Report!$A$2:$A$4")
+
+		// The copy doesn't share anything that can be changed with
+		// the source.
+		c.Assert(sheet.Cell(0, 0).GetStyle(), qt.Not(qt.Equals), src.Cell(0, 0).GetStyle())
+		c.Assert(sheet.Cell(0, 0).GetStyle(), qt.Equals, sheet.Cell(0, 1).GetStyle())
+		sheet.Cell(0, 0).GetStyle().Font.Italic = true
+		c.Assert(src.Cell(0, 0).GetStyle().Font.Italic, qt.Equals, false)
+		c.Assert(sheet.Cell(1, 1).DataValidation, qt.Not(qt.Equals), src.Cell(1, 1).DataValidation)
+		sheet.Cell(1, 0).SetString("Changed")
+		c.Assert(src.Cell(1, 0).Value, qt.Equals, "January")
+		sheet.Col(1).Width = 30
+		c.Assert(src.Col(1).Width, qt.Equals, 15.0)
+
+		_, err = file.CloneSheet(src, "Report")
+		c.Assert(err, qt.ErrorMatches, `duplicate sheet name 'Report'.`)
+	})
+
+	c.Run("OtherFile", func(c *qt.C) {
+		src := reportSheet(c, NewFile())
+		src.Cell(5, 1).SetFormula("Rates!A1*B2")
+		index := 0
+		src.Cell(0, 0).GetStyle().NamedStyleIndex = &index
+		file := NewFile()
+		file.Date1904 = true
+		sheet, err := file.CloneSheet(src, "March")
+		c.Assert(err, qt.IsNil)
+		c.Assert(sheet.File, qt.Equals, file)
+		c.Assert(sheet.Selected, qt.Equals, true)
+		c.Assert(sheet.Tables[0].Name, qt.Equals, "Sales")
+		c.Assert(sheet.Cell(0, 0).GetStyle().NamedStyleIndex, qt.IsNil)
+		due, err := sheet.Cell(1, 2).GetTime(true)
+		c.Assert(err, qt.IsNil)
+		c.Assert(due, qt.Equals, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		// References to the sheets of the other file are kept as they
+		// are.
+		c.Assert(sheet.Cell(4, 1).Formula(), qt.Equals, "SUM(B2:B4)*$E$1")
+		c.Assert(sheet.Cell(5, 1).Formula(), qt.Equals, "Rates!A1*B2")
+
+		// The copy is written with its own styles and shared strings.
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		read, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		readSheet := read.Sheet["March"]
+		c.Assert(readSheet.Cell(0, 1).Value, qt.Equals, "Sales")
+		c.Assert(readSheet.Cell(0, 1).GetStyle().Font.Bold, qt.Equals, true)
+		c.Assert(readSheet.Cell(4, 0).VMerge, qt.Equals, 1)
+		c.Assert(readSheet.Cell(4, 2).Hyperlink.Link, qt.Equals, "https://example.com/")
+		c.Assert(readSheet.Col(2).Width, qt.Equals, 15.0)
+		c.Assert(readSheet.Tables[0].Ref, qt.Equals, "A1:C4")
+		due, err = readSheet.Cell(1, 2).GetTime(read.Date1904)
+		c.Assert(err, qt.IsNil)
+		c.Assert(due, qt.Equals, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	c.Run("CopyRange", func(c *qt.C) {
+		src := reportSheet(c, NewFile())
+		file := NewFile()
+		dst, err := file.AddSheet("Summary")
+		c.Assert(err, qt.IsNil)
+		dst.Cell(3, 3).SetString("Replaced")
+		c.Assert(src.CopyRange("A2:C5", dst, "C3"), qt.IsNil)
+		c.Assert(dst.MaxRow, qt.Equals, 6)
+		c.Assert(dst.MaxCol, qt.Equals, 5)
+		c.Assert(dst.Cell(2, 2).Value, qt.Equals, "January")
+		c.Assert(dst.Cell(3, 3).Value, qt.Equals, "200")
+		c.Assert(dst.Cell(3, 3).Row, qt.Equals, dst.Rows[3])
+		c.Assert(dst.Cell(1, 1).DataValidation, qt.IsNil)
+		c.Assert(dst.Cell(2, 3).DataValidation, qt.Not(qt.IsNil))
+		c.Assert(dst.Cell(5, 3).Formula(), qt.Equals, "SUM(D3:D5)*$E$1")
+
+		// Merges are cut at the edge of the range.
+		c.Assert(dst.Cell(5, 2).VMerge, qt.Equals, 0)
+		c.Assert(dst.Cell(5, 4).Hyperlink.Link, qt.Equals, "https://example.com/")
+		c.Assert(dst.Relations, qt.HasLen, 1)
+
+		c.Assert(dst.DataValidations, qt.HasLen, 1)
+		c.Assert(dst.DataValidations[0].Sqref, qt.Equals, "C3:C5")
+		c.Assert(dst.ConditionalFormats, qt.HasLen, 1)
+		c.Assert(dst.ConditionalFormats[0].Ref, qt.Equals, "D3:D5")
+		c.Assert(dst.ConditionalFormats[0].Rules[0].Formulas, qt.DeepEquals, []string{"D3>$E$1"})
+
+		// Ranges of a sheet can overlap.
+		c.Assert(src.CopyRange("B2:B4", src, "B3"), qt.IsNil)
+		values := []string{}
+		for y := 1; y <= 4; y++ {
+			values = append(values, src.Cell(y, 1).Value)
+		}
+		c.Assert(values, qt.DeepEquals, []string{"100", "100", "200", "300"})
+		c.Assert(src.Cell(4, 1).Formula(), qt.Equals, "")
+
+		c.Assert(src.CopyRange("B1", dst, "A1048576"), qt.IsNil)
+		c.Assert(src.CopyRange("B1:B2", dst, "A1048576"), qt.ErrorMatches, `CopyRange: B1:B2 doesn't fit on the sheet at A1048576`)
+		c.Assert(src.CopyRange("A1:", dst, "A1"), qt.ErrorMatches, `CopyRange: .*`)
+	})
+
+	c.Run("MoveFormula", func(c *qt.C) {
+		for _, test := range []struct {
+			formula string
+			dx, dy  int
+			want    string
+		}{
+			{"A1+$B$2+C$3+$D4", 1, 2, "B3+$B$2+D$3+$D6"},
+			{"SUM(Other!A1:B2,'My Sheet'!A:A,1:1)", 1, 1, "SUM(Other!B2:C3,'My Sheet'!B:B,2:2)"},
+			{`A1&"A1"`, -1, 0, `#REF!&"A1"`},
+		} {
+			c.Assert(moveFormula(test.formula, test.dx, test.dy), qt.Equals, test.want, qt.Commentf("%+v", test))
+		}
+		c.Assert(renameFormulaSheet("Report!A1+report!B2+A3+Other!A1", "Report", "It's"), qt.Equals, "'It''s'!A1+'It''s'!B2+A3+Other!A1")
+	})
+}
@@ -0,0 +1,357 @@
+package xlsx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVOptions control how sheets are written as CSV, and how CSV is
+// read into sheets.  The zero value writes and reads comma separated
+// values as they are shown in a spreadsheet program.
+type CSVOptions struct {
+	// Comma is the field delimiter, such as '\t' for TSV.  If it is
+	// zero, a comma is used.
+	Comma rune
+	// RawValues writes numbers and dates as they are stored, rather
+	// than as they are shown with their number formats.  Booleans
+	// are written as TRUE and FALSE either way.
+	RawValues bool
+	// DateLayout is the layout, as for time.Format, in which cells
+	// that hold dates are written, instead of with their number
+	// formats.  When CSV is read, values in this layout are read as
+	// dates, as well as those in ISO 8601 layouts.
+	DateLayout string
+	// FillMerged writes the value of a merged cell in each of the
+	// cells that it covers, rather than only in the first.
+	FillMerged bool
+	// SkipHidden leaves out the hidden rows and columns of a sheet.
+	SkipHidden bool
+	// StringsOnly reads every value as text, rather than inferring
+	// numbers, booleans and dates.
+	StringsOnly bool
+}
+
+func (o CSVOptions) writer(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if o.Comma != 0 {
+		cw.Comma = o.Comma
+	}
+	return cw
+}
+
+func (o CSVOptions) reader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if o.Comma != 0 {
+		cr.Comma = o.Comma
+	}
+	// Rows of different lengths are common in CSV that has been
+	// written by hand.
+	cr.FieldsPerRecord = -1
+	return cr
+}
+
+// WriteCSV writes the rows of the sheet to w as CSV, one record per
+// row.  Each record has a field for each column of the sheet, up to
+// the last column that is used.
+func (s *Sheet) WriteCSV(w io.Writer, opts CSVOptions) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+	var merged map[[2]int]*Cell
+	if opts.FillMerged {
+		merged = make(map[[2]int]*Cell)
+		for y, row := range s.Rows {
+			if row == nil {
+				continue
+			}
+			for x, cell := range row.Cells {
+				if cell == nil || (cell.HMerge == 0 && cell.VMerge == 0) {
+					continue
+				}
+				for dy := 0; dy <= cell.VMerge; dy++ {
+					for dx := 0; dx <= cell.HMerge; dx++ {
+						merged[[2]int{y + dy, x + dx}] = cell
+					}
+				}
+			}
+		}
+	}
+	maxRow, maxCol := s.extent()
+	cw := opts.writer(w)
+	for y := 0; y < maxRow; y++ {
+		var row *Row
+		if y < len(s.Rows) {
+			row = s.Rows[y]
+		}
+		if opts.SkipHidden && row != nil && row.Hidden {
+			continue
+		}
+		record := []string{}
+		for x := 0; x < maxCol; x++ {
+			if opts.SkipHidden && colHidden(s.Cols, x) {
+				continue
+			}
+			cell := merged[[2]int{y, x}]
+			if cell == nil && row != nil && x < len(row.Cells) {
+				cell = row.Cells[x]
+			}
+			value, err := csvValue(cell, opts)
+			if err != nil {
+				return fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, y), err)
+			}
+			record = append(record, value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteCSV writes the rows of the current sheet that haven't been read
+// yet to w as CSV, one record per row.  As each row is written as soon
+// as it is read, a record has a field for each of the cells that are
+// stored in its row, and merged cells are not filled.
+func (sr *StreamReader) WriteCSV(w io.Writer, opts CSVOptions) error {
+	sheet := sr.Sheet()
+	if sheet == nil {
+		return NoCurrentSheetError
+	}
+	cw := opts.writer(w)
+	for {
+		row, err := sr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if opts.SkipHidden && row.Hidden {
+			continue
+		}
+		record := []string{}
+		for x, cell := range row.Cells {
+			if opts.SkipHidden && colHidden(sheet.Cols, x) {
+				continue
+			}
+			value, err := csvValue(cell, opts)
+			if err != nil {
+				return fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, sr.currentSheet.rowCount-1), err)
+			}
+			record = append(record, value)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// colHidden returns true if the column with the zero based index x is
+// hidden.
+func colHidden(cols *ColStore, x int) bool {
+	if cols == nil {
+		return false
+	}
+	col := cols.FindColByIndex(x + 1)
+	return col != nil && col.Hidden
+}
+
+// csvValue returns the text of a cell in CSV.  cell may be nil.
+func csvValue(cell *Cell, opts CSVOptions) (string, error) {
+	if cell == nil {
+		return "", nil
+	}
+	if cell.Type() == CellTypeNumeric && cell.Value != "" {
+		if opts.DateLayout != "" && cell.IsTime() {
+			t, err := cell.GetTime(cell.date1904)
+			if err != nil {
+				return cell.Value, err
+			}
+			return t.Format(opts.DateLayout), nil
+		}
+		if opts.RawValues {
+			return cell.Value, nil
+		}
+	}
+	value, err := cell.FormattedValue()
+	if err != nil {
+		// As in ToSlice, a numeric cell without a value is empty.
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Num == "" {
+			return "", nil
+		}
+		return value, err
+	}
+	return value, nil
+}
+
+// ImportCSV reads the records of r into a new sheet called sheetName,
+// one row per record.  Unless opts.StringsOnly is set, the type of
+// each value is inferred: numbers become numeric cells with the
+// General format, TRUE and FALSE become booleans, and dates in ISO 8601
+// layouts, or in opts.DateLayout, become dates with the default date
+// or date and time format.  Numbers with leading zeros or a plus sign,
+// such as codes and phone numbers, and numbers with more digits than a
+// float64 holds are kept as text.
+func (f *File) ImportCSV(r io.Reader, sheetName string, opts CSVOptions) (*Sheet, error) {
+	sheet, err := f.AddSheet(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	cr := opts.reader(r)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sheet, err
+		}
+		row := sheet.AddRow()
+		for _, value := range record {
+			cell := row.AddCell()
+			if value == "" {
+				continue
+			}
+			switch kind, t := inferCSVType(value, opts); kind {
+			case csvNumber:
+				cell.SetNumeric(value)
+			case csvBool:
+				cell.SetBool(strings.EqualFold(value, "true"))
+			case csvDate:
+				cell.SetDate(t)
+			case csvDateTime:
+				cell.SetDateTime(t)
+			default:
+				cell.SetString(value)
+			}
+		}
+	}
+	return sheet, nil
+}
+
+// ImportCSV reads the records of r and writes them as rows of the
+// current sheet, with the types that File.ImportCSV would infer.  As
+// with WriteS, every row has to have the same number of cells: records
+// that are shorter than the first are padded with empty cells.  Dates
+// are only inferred if StreamStyleDefaultDate and
+// StreamStyleDefaultDateTime have been added to the StreamFileBuilder,
+// and are otherwise written as text.
+func (sf *StreamFile) ImportCSV(r io.Reader, opts CSVOptions) error {
+	if sf.currentSheet == nil {
+		return NoCurrentSheetError
+	}
+	_, dates := sf.styleIdMap[StreamStyleDefaultDate]
+	_, dateTimes := sf.styleIdMap[StreamStyleDefaultDateTime]
+	cr := opts.reader(r)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cells := make([]StreamCell, 0, len(record))
+		for _, value := range record {
+			kind, t := inferCSVType(value, opts)
+			switch {
+			case kind == csvNumber:
+				cells = append(cells, NewStreamCell(value, StreamStyle{}, CellTypeNumeric))
+			case kind == csvBool && strings.EqualFold(value, "true"):
+				cells = append(cells, NewStreamCell("1", StreamStyle{}, CellTypeBool))
+			case kind == csvBool:
+				cells = append(cells, NewStreamCell("0", StreamStyle{}, CellTypeBool))
+			case kind == csvDate && dates:
+				cells = append(cells, NewStreamCell(excelTimeString(t), StreamStyleDefaultDate, CellTypeNumeric))
+			case kind == csvDateTime && dateTimes:
+				cells = append(cells, NewStreamCell(excelTimeString(t), StreamStyleDefaultDateTime, CellTypeNumeric))
+			default:
+				cells = append(cells, NewStreamCell(value, StreamStyle{}, CellTypeString))
+			}
+		}
+		for len(cells) < sf.currentSheet.columnCount {
+			cells = append(cells, NewStreamCell("", StreamStyle{}, CellTypeString))
+		}
+		if err := sf.WriteS(cells); err != nil {
+			return err
+		}
+	}
+}
+
+// excelTimeString returns the value of a cell that holds the time t.
+func excelTimeString(t time.Time) string {
+	return strconv.FormatFloat(TimeToExcelTime(t, false), 'f', -1, 64)
+}
+
+type csvType int
+
+const (
+	csvString csvType = iota
+	csvNumber
+	csvBool
+	csvDate
+	csvDateTime
+)
+
+// numberRegexp matches numbers in the syntax of JSON, which is also the
+// syntax of the numbers that ImportCSV reads.
+var numberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// csvDateLayouts are the layouts of the values that ImportCSV reads as
+// dates.
+var csvDateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC3339Nano,
+}
+
+// inferCSVType returns the type of a value read from CSV, and for
+// dates, the time it holds.
+func inferCSVType(value string, opts CSVOptions) (csvType, time.Time) {
+	if opts.StringsOnly || value == "" {
+		return csvString, time.Time{}
+	}
+	if numberRegexp.MatchString(value) {
+		mantissa := strings.TrimPrefix(strings.FieldsFunc(value, func(r rune) bool { return r == 'e' || r == 'E' })[0], "-")
+		if len(strings.TrimLeft(strings.Replace(mantissa, ".", "", 1), "0")) <= 15 {
+			return csvNumber, time.Time{}
+		}
+		return csvString, time.Time{}
+	}
+	if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+		return csvBool, time.Time{}
+	}
+	return inferDate(value, opts.DateLayout)
+}
+
+// inferDate returns csvDate or csvDateTime, and the time it holds, for
+// a value in one of csvDateLayouts or in layout, and otherwise
+// csvString.  The time is the wall clock time of the value, in UTC, as
+// spreadsheets have no time zones.
+func inferDate(value, layout string) (csvType, time.Time) {
+	layouts := csvDateLayouts
+	if layout != "" {
+		layouts = append([]string{layout}, layouts...)
+	}
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+			return csvDate, t
+		}
+		return csvDateTime, t
+	}
+	return csvString, time.Time{}
+}
@@ -0,0 +1,168 @@
+package xlsx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCSV(t *testing.T) {
+	c := qt.New(t)
+
+	// csvSheet returns a sheet with text, numbers, a date, a boolean,
+	// a merged cell and a hidden row and column.
+	csvSheet := func(c *qt.C) *Sheet {
+		sheet, err := NewFile().AddSheet("Report")
+		c.Assert(err, qt.IsNil)
+		for x, header := range []string{"Name", "Secret", "Amount", "Due", "Paid"} {
+			sheet.Cell(0, x).SetString(header)
+		}
+		sheet.Cell(1, 0).SetString("Widget, large")
+		sheet.Cell(1, 1).SetString("x")
+		sheet.Cell(1, 2).SetFloatWithFormat(1234.5, "#,##0.00")
+		sheet.Cell(1, 3).SetDate(time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+		sheet.Cell(1, 4).SetBool(true)
+		sheet.Cell(2, 0).SetString("Hidden")
+		sheet.Row(2).Hidden = true
+		sheet.Cell(3, 0).SetString("Total")
+		sheet.Cell(3, 0).Merge(1, 0)
+		sheet.Cell(3, 2).SetFloatWithFormat(1234.5, "#,##0.00")
+		sheet.Cell(3, 4).SetBool(false)
+		sheet.SetColParameters(&Col{Min: 2, Max: 2, Hidden: true})
+		return sheet
+	}
+	writeCSV := func(c *qt.C, sheet *Sheet, opts CSVOptions) string {
+		var buf bytes.Buffer
+		c.Assert(sheet.WriteCSV(&buf, opts), qt.IsNil)
+		return buf.String()
+	}
+
+	c.Run("Formatted", func(c *qt.C) {
+		c.Assert(writeCSV(c, csvSheet(c), CSVOptions{}), qt.Equals, ""+
+			"Name,Secret,Amount,Due,Paid\n"+
+			"\"Widget, large\",x,\"1,234.50\",11-04-19,TRUE\n"+
+			"Hidden,,,,\n"+
+			"Total,,\"1,234.50\",,FALSE\n")
+	})
+
+	c.Run("Options", func(c *qt.C) {
+		opts := CSVOptions{
+			Comma:      '\t',
+			RawValues:  true,
+			DateLayout: "2006-01-02",
+			FillMerged: true,
+			SkipHidden: true,
+		}
+		c.Assert(writeCSV(c, csvSheet(c), opts), qt.Equals, ""+
+			"Name\tAmount\tDue\tPaid\n"+
+			"Widget, large\t1234.5\t2019-11-04\tTRUE\n"+
+			"Total\t1234.5\t\tFALSE\n")
+
+		// A merged cell fills the cells that it covers.
+		sheet := csvSheet(c)
+		sheet.Cell(3, 0).Merge(2, 0)
+		opts.SkipHidden = false
+		c.Assert(strings.Split(writeCSV(c, sheet, opts), "\n")[3], qt.Equals, "Total\tTotal\tTotal\t\tFALSE")
+	})
+
+	c.Run("Import", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.ImportCSV(strings.NewReader(""+
+			"Name;Amount;Count;Paid;Due;At;Zip;Phone;Id\n"+
+			"\"Widget; large\";1234.5;-3;true;2019-11-04;2019-11-04T10:30:00Z;01234;+4930123;12345678901234567\n"+
+			"Gadget;1e3\n"), "Imported", CSVOptions{Comma: ';'})
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Imported"], qt.Equals, sheet)
+		c.Assert(sheet.MaxRow, qt.Equals, 3)
+
+		types := []CellType{}
+		for _, cell := range sheet.Rows[1].Cells {
+			types = append(types, cell.Type())
+		}
+		c.Assert(types, qt.DeepEquals, []CellType{
+			CellTypeString, CellTypeNumeric, CellTypeNumeric, CellTypeBool, CellTypeNumeric,
+			CellTypeNumeric, CellTypeString, CellTypeString, CellTypeString,
+		})
+		c.Assert(sheet.Cell(1, 0).Value, qt.Equals, "Widget; large")
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "1234.5")
+		c.Assert(sheet.Cell(1, 1).NumFmt, qt.Equals, "general")
+		c.Assert(sheet.Cell(1, 3).Bool(), qt.Equals, true)
+		due, err := sheet.Cell(1, 4).GetTime(false)
+		c.Assert(err, qt.IsNil)
+		c.Assert(due, qt.Equals, time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+		c.Assert(sheet.Cell(1, 4).NumFmt, qt.Equals, DefaultDateFormat)
+		at, err := sheet.Cell(1, 5).GetTime(false)
+		c.Assert(err, qt.IsNil)
+		c.Assert(at, qt.Equals, time.Date(2019, 11, 4, 10, 30, 0, 0, time.UTC))
+		c.Assert(sheet.Cell(1, 5).NumFmt, qt.Equals, DefaultDateTimeFormat)
+		c.Assert(sheet.Cell(1, 6).Value, qt.Equals, "01234")
+		c.Assert(sheet.Cell(2, 1).Value, qt.Equals, "1e3")
+		c.Assert(sheet.Cell(2, 1).Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(2, 2).Value, qt.Equals, "")
+
+		// The sheet is written back as it was read.
+		c.Assert(writeCSV(c, sheet, CSVOptions{Comma: ';', RawValues: true, DateLayout: "2006-01-02"}), qt.Equals, ""+
+			"Name;Amount;Count;Paid;Due;At;Zip;Phone;Id\n"+
+			"\"Widget; large\";1234.5;-3;TRUE;2019-11-04;2019-11-04;01234;+4930123;12345678901234567\n"+
+			"Gadget;1e3;;;;;;;\n")
+
+		sheet, err = file.ImportCSV(strings.NewReader("1,TRUE,2019-11-04,04.11.2019\n"), "Strings", CSVOptions{StringsOnly: true})
+		c.Assert(err, qt.IsNil)
+		for _, cell := range sheet.Rows[0].Cells {
+			c.Assert(cell.Type(), qt.Equals, CellTypeString)
+		}
+
+		sheet, err = file.ImportCSV(strings.NewReader("04.11.2019\n"), "Layout", CSVOptions{DateLayout: "02.01.2006"})
+		c.Assert(err, qt.IsNil)
+		due, err = sheet.Cell(0, 0).GetTime(false)
+		c.Assert(err, qt.IsNil)
+		c.Assert(due, qt.Equals, time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+
+		_, err = file.ImportCSV(strings.NewReader("a,\"b\n"), "Broken", CSVOptions{})
+		c.Assert(err, qt.ErrorMatches, `.*parse error on line .*: extraneous or missing " in quoted-field`)
+		_, err = file.ImportCSV(strings.NewReader("a\n"), "Imported", CSVOptions{})
+		c.Assert(err, qt.ErrorMatches, `duplicate sheet name 'Imported'.`)
+	})
+
+	c.Run("Stream", func(c *qt.C) {
+		var buf bytes.Buffer
+		builder := NewStreamFileBuilder(&buf)
+		c.Assert(builder.AddStreamStyleList([]StreamStyle{StreamStyleDefaultDate, StreamStyleDefaultDateTime}), qt.IsNil)
+		c.Assert(builder.AddSheetS("Imported", nil), qt.IsNil)
+		sf, err := builder.Build()
+		c.Assert(err, qt.IsNil)
+		c.Assert(sf.ImportCSV(strings.NewReader(""+
+			"Name\tAmount\tPaid\tDue\tAt\n"+
+			"Widget\t1234.5\tFALSE\t2019-11-04\t2019-11-04 10:30:00\n"+
+			"Gadget\t007\n"), CSVOptions{Comma: '\t'}), qt.IsNil)
+		c.Assert(sf.Close(), qt.IsNil)
+
+		sr, err := NewStreamReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		c.Assert(err, qt.IsNil)
+		defer sr.Close()
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		var out bytes.Buffer
+		c.Assert(sr.WriteCSV(&out, CSVOptions{DateLayout: "2006-01-02 15:04"}), qt.IsNil)
+		c.Assert(out.String(), qt.Equals, ""+
+			"Name,Amount,Paid,Due,At\n"+
+			"Widget,1234.5,FALSE,2019-11-04 00:00,2019-11-04 10:30\n"+
+			"Gadget,007,,,\n")
+
+		// Without the date styles, dates are written as text.
+		buf.Reset()
+		builder = NewStreamFileBuilder(&buf)
+		c.Assert(builder.AddSheet("Imported", nil), qt.IsNil)
+		sf, err = builder.Build()
+		c.Assert(err, qt.IsNil)
+		c.Assert(sf.ImportCSV(strings.NewReader("2019-11-04,1\n"), CSVOptions{}), qt.IsNil)
+		c.Assert(sf.Close(), qt.IsNil)
+		file, err := OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets[0].Cell(0, 0).Type(), qt.Equals, CellTypeInline)
+		c.Assert(file.Sheets[0].Cell(0, 0).Value, qt.Equals, "2019-11-04")
+		c.Assert(file.Sheets[0].Cell(0, 1).Type(), qt.Equals, CellTypeNumeric)
+	})
+}
@@ -0,0 +1,649 @@
+package xlsx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"unicode/utf16"
+)
+
+// Files saved with a password to open them are encrypted as [MS-OFFCRYPTO]
+// describes: the zip package is encrypted into the EncryptedPackage
+// stream of a compound file, and the EncryptionInfo stream says how.
+// Agile Encryption is written and read, and Standard Encryption,
+// which older versions of Excel write, is read.
+
+var (
+	// ErrEncrypted is returned when opening an encrypted file
+	// without a password.  Use OpenFileWithPassword instead.
+	ErrEncrypted = errors.New("the file is encrypted and needs a password to open it")
+	// ErrIncorrectPassword is returned when the password given to
+	// open an encrypted file is wrong.
+	ErrIncorrectPassword = errors.New("the password is incorrect")
+)
+
+const (
+	encryptionInfoStream   = "EncryptionInfo"
+	encryptedPackageStream = "EncryptedPackage"
+
+	agileSegmentSize          = 4096
+	agileSpinCount            = 100000
+	agileKeyEncryptorPassword = "http://schemas.microsoft.com/office/2006/keyEncryptor/password"
+
+	standardSpinCount = 50000
+)
+
+var (
+	agileVerifierHashInputBlockKey = []byte{0xfe, 0xa7, 0xd2, 0x76, 0x3b, 0x4b, 0x9e, 0x79}
+	agileVerifierHashValueBlockKey = []byte{0xd7, 0xaa, 0x0f, 0x6d, 0x30, 0x61, 0x34, 0x4e}
+	agileKeyValueBlockKey          = []byte{0x14, 0x6e, 0x0b, 0xe7, 0xab, 0xac, 0xd0, 0xd6}
+	agileHmacKeyBlockKey           = []byte{0x5f, 0xb2, 0xad, 0x01, 0x0c, 0xb9, 0xe1, 0xf6}
+	agileHmacValueBlockKey         = []byte{0xa0, 0x67, 0x7f, 0x02, 0xb2, 0x2c, 0x84, 0x33}
+)
+
+var agileHashes = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA384": sha512.New384,
+	"SHA512": sha512.New,
+}
+
+// OpenFileWithPassword opens an XLSX file that was saved with a
+// password.  Files that aren't encrypted are opened as OpenFile
+// would.
+func OpenFileWithPassword(fileName, password string) (*File, error) {
+	bs, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBinaryWithPassword(bs, password)
+}
+
+// OpenBinaryWithPassword takes the bytes of an XLSX file that was
+// saved with a password and returns a populated xlsx.File struct for
+// it.  Files that aren't encrypted are opened as OpenBinary would.
+func OpenBinaryWithPassword(bs []byte, password string) (*File, error) {
+	if !isCFB(bs) {
+		return OpenBinary(bs)
+	}
+	pkg, err := decryptPackage(bs, password)
+	if err != nil {
+		return nil, err
+	}
+	return OpenBinary(pkg)
+}
+
+// SaveWithPassword saves the File to an xlsx file at the provided
+// path, encrypted so that the password is needed to open it.
+func (f *File) SaveWithPassword(path, password string) (err error) {
+	target, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = f.WriteWithPassword(target, password)
+	if err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// WriteWithPassword writes the File to io.Writer as an xlsx file,
+// encrypted so that the password is needed to open it.
+func (f *File) WriteWithPassword(writer io.Writer, password string) error {
+	if password == "" {
+		return errors.New("WriteWithPassword: the password is empty")
+	}
+	var pkg bytes.Buffer
+	if err := f.Write(&pkg); err != nil {
+		return err
+	}
+	encrypted, err := encryptPackage(pkg.Bytes(), password)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(encrypted)
+	return err
+}
+
+// isEncryptedFile returns true if the start of a file that couldn't
+// be read as a zip says that it is encrypted.
+func isEncryptedFile(r io.ReaderAt) bool {
+	signature := make([]byte, len(cfbSignature))
+	if _, err := r.ReadAt(signature, 0); err != nil {
+		return false
+	}
+	return isCFB(signature)
+}
+
+// decryptPackage returns the zip package held by an encrypted file.
+func decryptPackage(data []byte, password string) ([]byte, error) {
+	streams, err := readCFB(data)
+	if err != nil {
+		return nil, err
+	}
+	info, ok := streams[encryptionInfoStream]
+	if !ok {
+		return nil, errors.New("the compound file is not an encrypted package")
+	}
+	pkg, ok := streams[encryptedPackageStream]
+	if !ok || len(pkg) < 8 {
+		return nil, errors.New("the compound file is not an encrypted package")
+	}
+	if len(info) < 8 {
+		return nil, errors.New("the encryption info is truncated")
+	}
+	major := binary.LittleEndian.Uint16(info)
+	minor := binary.LittleEndian.Uint16(info[2:])
+	switch {
+	case major == 4 && minor == 4:
+		return decryptAgilePackage(info[8:], pkg, password)
+	case (major == 2 || major == 3 || major == 4) && minor == 2:
+		return decryptStandardPackage(info[8:], pkg, password)
+	}
+	return nil, fmt.Errorf("unsupported encryption version %d.%d", major, minor)
+}
+
+// agileEncryption maps the XML of the EncryptionInfo stream of a
+// package that uses Agile Encryption.
+type agileEncryption struct {
+	XMLName       xml.Name            `xml:"http://schemas.microsoft.com/office/2006/encryption encryption"`
+	KeyData       agileKeyData        `xml:"keyData"`
+	DataIntegrity *agileDataIntegrity `xml:"dataIntegrity"`
+	KeyEncryptors []agileKeyEncryptor `xml:"keyEncryptors>keyEncryptor"`
+}
+
+type agileKeyData struct {
+	SaltSize        int         `xml:"saltSize,attr"`
+	BlockSize       int         `xml:"blockSize,attr"`
+	KeyBits         int         `xml:"keyBits,attr"`
+	HashSize        int         `xml:"hashSize,attr"`
+	CipherAlgorithm string      `xml:"cipherAlgorithm,attr"`
+	CipherChaining  string      `xml:"cipherChaining,attr"`
+	HashAlgorithm   string      `xml:"hashAlgorithm,attr"`
+	SaltValue       base64Value `xml:"saltValue,attr"`
+}
+
+type agileDataIntegrity struct {
+	EncryptedHmacKey   base64Value `xml:"encryptedHmacKey,attr"`
+	EncryptedHmacValue base64Value `xml:"encryptedHmacValue,attr"`
+}
+
+type agileKeyEncryptor struct {
+	URI          string             `xml:"uri,attr"`
+	EncryptedKey *agileEncryptedKey `xml:"http://schemas.microsoft.com/office/2006/keyEncryptor/password encryptedKey"`
+}
+
+type agileEncryptedKey struct {
+	agileKeyData
+	SpinCount                  int         `xml:"spinCount,attr"`
+	EncryptedVerifierHashInput base64Value `xml:"encryptedVerifierHashInput,attr"`
+	EncryptedVerifierHashValue base64Value `xml:"encryptedVerifierHashValue,attr"`
+	EncryptedKeyValue          base64Value `xml:"encryptedKeyValue,attr"`
+}
+
+// base64Value is an attribute that holds base64 encoded bytes.
+type base64Value []byte
+
+func (v *base64Value) UnmarshalXMLAttr(attr xml.Attr) error {
+	b, err := base64.StdEncoding.DecodeString(attr.Value)
+	if err != nil {
+		return fmt.Errorf("cannot decode %s: %v", attr.Name.Local, err)
+	}
+	*v = b
+	return nil
+}
+
+// newHash returns the hash, and checks that the cipher is one that
+// can be used.
+func (k *agileKeyData) newHash() (func() hash.Hash, error) {
+	newHash, ok := agileHashes[k.HashAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encryption hash algorithm %q", k.HashAlgorithm)
+	}
+	if k.CipherAlgorithm != "AES" {
+		return nil, fmt.Errorf("unsupported encryption cipher %q", k.CipherAlgorithm)
+	}
+	if k.CipherChaining != "ChainingModeCBC" {
+		return nil, fmt.Errorf("unsupported encryption cipher chaining %q", k.CipherChaining)
+	}
+	switch k.KeyBits {
+	case 128, 192, 256:
+	default:
+		return nil, fmt.Errorf("unsupported encryption key size %d", k.KeyBits)
+	}
+	if k.BlockSize != aes.BlockSize || k.HashSize != newHash().Size() {
+		return nil, errors.New("the encryption info is inconsistent")
+	}
+	return newHash, nil
+}
+
+// agilePasswordHash returns the hash of the salt and password that
+// the keys of the key encryptor are derived from.
+func agilePasswordHash(newHash func() hash.Hash, salt []byte, password string, spinCount int) []byte {
+	h := newHash()
+	h.Write(salt)
+	h.Write(utf16LE(password))
+	sum := h.Sum(nil)
+	iterator := make([]byte, 4)
+	for i := 0; i < spinCount; i++ {
+		binary.LittleEndian.PutUint32(iterator, uint32(i))
+		h.Reset()
+		h.Write(iterator)
+		h.Write(sum)
+		sum = h.Sum(sum[:0])
+	}
+	return sum
+}
+
+// agileDerive hashes data and block, and fits the result to size
+// bytes, padding it with 0x36 if it is too short.
+func agileDerive(newHash func() hash.Hash, data, block []byte, size int) []byte {
+	h := newHash()
+	h.Write(data)
+	h.Write(block)
+	return fitAgileBytes(h.Sum(nil), size)
+}
+
+func fitAgileBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b[:size]
+	}
+	return append(b, bytes.Repeat([]byte{0x36}, size-len(b))...)
+}
+
+func aesCBC(key, iv, data []byte, encrypt bool) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		if !encrypt {
+			return nil, errors.New("the encrypted data is not a whole number of blocks")
+		}
+		data = append(append([]byte{}, data...), make([]byte, aes.BlockSize-len(data)%aes.BlockSize)...)
+	}
+	out := make([]byte, len(data))
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	}
+	return out, nil
+}
+
+func decryptAgilePackage(info, pkg []byte, password string) ([]byte, error) {
+	var encryption agileEncryption
+	if err := xml.Unmarshal(info, &encryption); err != nil {
+		return nil, fmt.Errorf("cannot read the encryption info: %v", err)
+	}
+	var key *agileEncryptedKey
+	for _, encryptor := range encryption.KeyEncryptors {
+		if encryptor.URI == agileKeyEncryptorPassword && encryptor.EncryptedKey != nil {
+			key = encryptor.EncryptedKey
+		}
+	}
+	if key == nil {
+		return nil, errors.New("the file is not encrypted with a password")
+	}
+	keyHash, err := key.newHash()
+	if err != nil {
+		return nil, err
+	}
+	dataHash, err := encryption.KeyData.newHash()
+	if err != nil {
+		return nil, err
+	}
+
+	hn := agilePasswordHash(keyHash, key.SaltValue, password, key.SpinCount)
+	iv := fitAgileBytes(append([]byte{}, key.SaltValue...), key.BlockSize)
+	decrypt := func(blockKey, data []byte) ([]byte, error) {
+		return aesCBC(agileDerive(keyHash, hn, blockKey, key.KeyBits/8), iv, data, false)
+	}
+	verifier, err := decrypt(agileVerifierHashInputBlockKey, key.EncryptedVerifierHashInput)
+	if err != nil {
+		return nil, err
+	}
+	verifierHash, err := decrypt(agileVerifierHashValueBlockKey, key.EncryptedVerifierHashValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(verifier) < key.SaltSize || len(verifierHash) < key.HashSize {
+		return nil, errors.New("the encryption info is inconsistent")
+	}
+	h := keyHash()
+	h.Write(verifier[:key.SaltSize])
+	if subtle.ConstantTimeCompare(h.Sum(nil), verifierHash[:key.HashSize]) != 1 {
+		return nil, ErrIncorrectPassword
+	}
+	secretKey, err := decrypt(agileKeyValueBlockKey, key.EncryptedKeyValue)
+	if err != nil {
+		return nil, err
+	}
+	keyData := encryption.KeyData
+	if len(secretKey) < keyData.KeyBits/8 {
+		return nil, errors.New("the encryption info is inconsistent")
+	}
+	secretKey = secretKey[:keyData.KeyBits/8]
+
+	if integrity := encryption.DataIntegrity; integrity != nil {
+		hmacKey, err := aesCBC(secretKey, agileDerive(dataHash, keyData.SaltValue, agileHmacKeyBlockKey, keyData.BlockSize), integrity.EncryptedHmacKey, false)
+		if err != nil {
+			return nil, err
+		}
+		hmacValue, err := aesCBC(secretKey, agileDerive(dataHash, keyData.SaltValue, agileHmacValueBlockKey, keyData.BlockSize), integrity.EncryptedHmacValue, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(hmacKey) < keyData.HashSize || len(hmacValue) < keyData.HashSize {
+			return nil, errors.New("the encryption info is inconsistent")
+		}
+		mac := hmac.New(dataHash, hmacKey[:keyData.HashSize])
+		mac.Write(pkg)
+		if !hmac.Equal(mac.Sum(nil), hmacValue[:keyData.HashSize]) {
+			return nil, errors.New("the encrypted package has been changed or is corrupt")
+		}
+	}
+
+	size := binary.LittleEndian.Uint64(pkg)
+	encrypted := pkg[8:]
+	if size > uint64(len(encrypted)) {
+		return nil, errors.New("the encrypted package is truncated")
+	}
+	out := make([]byte, 0, len(encrypted))
+	segment := make([]byte, 4)
+	for i := 0; uint64(len(out)) < size; i++ {
+		start, end := i*agileSegmentSize, (i+1)*agileSegmentSize
+		if end > len(encrypted) {
+			end = len(encrypted) - len(encrypted)%keyData.BlockSize
+		}
+		if end <= start {
+			return nil, errors.New("the encrypted package is truncated")
+		}
+		binary.LittleEndian.PutUint32(segment, uint32(i))
+		plain, err := aesCBC(secretKey, agileDerive(dataHash, keyData.SaltValue, segment, keyData.BlockSize), encrypted[start:end], false)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, plain...)
+	}
+	return out[:size], nil
+}
+
+// encryptPackage returns a compound file that holds the zip package
+// encrypted with Agile Encryption, using AES-256 and SHA-512.
+func encryptPackage(pkg []byte, password string) ([]byte, error) {
+	random := func(size int) ([]byte, error) {
+		b := make([]byte, size)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("cannot make encryption keys: %v", err)
+		}
+		return b, nil
+	}
+	keyData := agileKeyData{
+		SaltSize:        16,
+		BlockSize:       aes.BlockSize,
+		KeyBits:         256,
+		HashSize:        sha512.Size,
+		CipherAlgorithm: "AES",
+		CipherChaining:  "ChainingModeCBC",
+		HashAlgorithm:   "SHA512",
+	}
+	var err error
+	if keyData.SaltValue, err = random(keyData.SaltSize); err != nil {
+		return nil, err
+	}
+	key := agileEncryptedKey{agileKeyData: keyData, SpinCount: agileSpinCount}
+	if key.SaltValue, err = random(key.SaltSize); err != nil {
+		return nil, err
+	}
+	secretKey, err := random(keyData.KeyBits / 8)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := random(key.SaltSize)
+	if err != nil {
+		return nil, err
+	}
+	hmacKey, err := random(keyData.HashSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hn := agilePasswordHash(sha512.New, key.SaltValue, password, key.SpinCount)
+	encrypt := func(blockKey, data []byte) ([]byte, error) {
+		return aesCBC(agileDerive(sha512.New, hn, blockKey, key.KeyBits/8), key.SaltValue, data, true)
+	}
+	if key.EncryptedVerifierHashInput, err = encrypt(agileVerifierHashInputBlockKey, verifier); err != nil {
+		return nil, err
+	}
+	verifierHash := sha512.Sum512(verifier)
+	if key.EncryptedVerifierHashValue, err = encrypt(agileVerifierHashValueBlockKey, verifierHash[:]); err != nil {
+		return nil, err
+	}
+	if key.EncryptedKeyValue, err = encrypt(agileKeyValueBlockKey, secretKey); err != nil {
+		return nil, err
+	}
+
+	encrypted := make([]byte, 8, 8+len(pkg)+aes.BlockSize)
+	binary.LittleEndian.PutUint64(encrypted, uint64(len(pkg)))
+	segment := make([]byte, 4)
+	for i := 0; i*agileSegmentSize < len(pkg); i++ {
+		end := (i + 1) * agileSegmentSize
+		if end > len(pkg) {
+			end = len(pkg)
+		}
+		binary.LittleEndian.PutUint32(segment, uint32(i))
+		cipherText, err := aesCBC(secretKey, agileDerive(sha512.New, keyData.SaltValue, segment, keyData.BlockSize), pkg[i*agileSegmentSize:end], true)
+		if err != nil {
+			return nil, err
+		}
+		encrypted = append(encrypted, cipherText...)
+	}
+
+	mac := hmac.New(sha512.New, hmacKey)
+	mac.Write(encrypted)
+	var integrity agileDataIntegrity
+	if integrity.EncryptedHmacKey, err = aesCBC(secretKey, agileDerive(sha512.New, keyData.SaltValue, agileHmacKeyBlockKey, keyData.BlockSize), hmacKey, true); err != nil {
+		return nil, err
+	}
+	if integrity.EncryptedHmacValue, err = aesCBC(secretKey, agileDerive(sha512.New, keyData.SaltValue, agileHmacValueBlockKey, keyData.BlockSize), mac.Sum(nil), true); err != nil {
+		return nil, err
+	}
+
+	info := []byte{4, 0, 4, 0, 0x40, 0, 0, 0}
+	info = append(info, marshalAgileEncryption(keyData, integrity, key)...)
+	streams := dataSpacesStreams()
+	streams[encryptionInfoStream] = info
+	streams[encryptedPackageStream] = encrypted
+	return writeCFB(streams, 3)
+}
+
+// marshalAgileEncryption returns the XML of the EncryptionInfo
+// stream.  It is written by hand because Excel expects the namespace
+// prefixes that it uses itself.
+func marshalAgileEncryption(keyData agileKeyData, integrity agileDataIntegrity, key agileEncryptedKey) string {
+	b64 := base64.StdEncoding.EncodeToString
+	keyDataAttrs := func(k agileKeyData) string {
+		return fmt.Sprintf(`saltSize="%d" blockSize="%d" keyBits="%d" hashSize="%d" cipherAlgorithm="%s" cipherChaining="%s" hashAlgorithm="%s" saltValue="%s"`,
+			k.SaltSize, k.BlockSize, k.KeyBits, k.HashSize, k.CipherAlgorithm, k.CipherChaining, k.HashAlgorithm, b64(k.SaltValue))
+	}
+	return "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"yes\"?>\r\n" +
+		`<encryption xmlns="http://schemas.microsoft.com/office/2006/encryption" xmlns:p="` + agileKeyEncryptorPassword + `" xmlns:c="http://schemas.microsoft.com/office/2006/keyEncryptor/certificate">` +
+		`<keyData ` + keyDataAttrs(keyData) + `/>` +
+		`<dataIntegrity encryptedHmacKey="` + b64(integrity.EncryptedHmacKey) + `" encryptedHmacValue="` + b64(integrity.EncryptedHmacValue) + `"/>` +
+		`<keyEncryptors><keyEncryptor uri="` + agileKeyEncryptorPassword + `">` +
+		fmt.Sprintf(`<p:encryptedKey spinCount="%d" `, key.SpinCount) + keyDataAttrs(key.agileKeyData) +
+		` encryptedVerifierHashInput="` + b64(key.EncryptedVerifierHashInput) +
+		`" encryptedVerifierHashValue="` + b64(key.EncryptedVerifierHashValue) +
+		`" encryptedKeyValue="` + b64(key.EncryptedKeyValue) + `"/>` +
+		`</keyEncryptor></keyEncryptors></encryption>`
+}
+
+// dataSpacesStreams returns the \x06DataSpaces storage that says the
+// EncryptedPackage stream is encrypted.
+func dataSpacesStreams() map[string][]byte {
+	le := binary.LittleEndian
+	uint32s := func(values ...uint32) []byte {
+		b := make([]byte, 4*len(values))
+		for i, v := range values {
+			le.PutUint32(b[4*i:], v)
+		}
+		return b
+	}
+	// lengthPrefixed returns a UNICODE-LP-P4 string.
+	lengthPrefixed := func(s string) []byte {
+		b := utf16LE(s)
+		b = append(uint32s(uint32(len(b))), b...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	// Version 1.0 for the reader, updater and writer.
+	versions := uint32s(1, 1, 1)
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	const (
+		dataSpaceName = "StrongEncryptionDataSpace"
+		transformName = "StrongEncryptionTransform"
+		transformID   = "{FF9A3F03-56EF-4613-BDD5-5A41C1D07246}"
+	)
+	mapEntry := join(uint32s(1, 0), lengthPrefixed(encryptedPackageStream), lengthPrefixed(dataSpaceName))
+	mapEntry = append(uint32s(uint32(4+len(mapEntry))), mapEntry...)
+	id := lengthPrefixed(transformID)
+	return map[string][]byte{
+		"\x06DataSpaces/Version":                        join(lengthPrefixed("Microsoft.Container.DataSpaces"), versions),
+		"\x06DataSpaces/DataSpaceMap":                   join(uint32s(8, 1), mapEntry),
+		"\x06DataSpaces/DataSpaceInfo/" + dataSpaceName: join(uint32s(8, 1), lengthPrefixed(transformName)),
+		"\x06DataSpaces/TransformInfo/" + transformName + "/\x06Primary": join(
+			uint32s(uint32(8+len(id)), 1), id,
+			lengthPrefixed("Microsoft.Container.EncryptionTransform"), versions,
+			// An empty encryption name, and the block size,
+			// cipher mode and reserved fields.
+			uint32s(0, 0, 0, 4)),
+	}
+}
+
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// standardEncryptionHeader maps the EncryptionHeader of a package
+// that uses Standard Encryption.
+type standardEncryptionHeader struct {
+	Flags        uint32
+	SizeExtra    uint32
+	AlgID        uint32
+	AlgIDHash    uint32
+	KeySize      uint32
+	ProviderType uint32
+	Reserved1    uint32
+	Reserved2    uint32
+}
+
+const (
+	standardFlagAES   = 0x20
+	standardAlgSHA1   = 0x8004
+	standardAlgAES128 = 0x660E
+	standardAlgAES192 = 0x660F
+	standardAlgAES256 = 0x6610
+)
+
+func decryptStandardPackage(info, pkg []byte, password string) ([]byte, error) {
+	le := binary.LittleEndian
+	if len(info) < 4 {
+		return nil, errors.New("the encryption info is truncated")
+	}
+	headerSize := int(le.Uint32(info))
+	if headerSize < 32 || len(info) < 4+headerSize+4 {
+		return nil, errors.New("the encryption info is truncated")
+	}
+	var header standardEncryptionHeader
+	binary.Read(bytes.NewReader(info[4:]), le, &header)
+	if header.Flags&standardFlagAES == 0 {
+		return nil, errors.New("unsupported encryption: only AES Standard Encryption can be read")
+	}
+	keySizes := map[uint32]uint32{standardAlgAES128: 128, standardAlgAES192: 192, standardAlgAES256: 256}
+	if keySizes[header.AlgID] == 0 || (header.KeySize != 0 && header.KeySize != keySizes[header.AlgID]) {
+		return nil, fmt.Errorf("unsupported encryption algorithm 0x%04X with key size %d", header.AlgID, header.KeySize)
+	}
+	if header.AlgIDHash != 0 && header.AlgIDHash != standardAlgSHA1 {
+		return nil, fmt.Errorf("unsupported encryption hash algorithm 0x%04X", header.AlgIDHash)
+	}
+
+	verifier := info[4+headerSize:]
+	saltSize := int(le.Uint32(verifier))
+	if saltSize != 16 || len(verifier) < 4+16+16+4+32 {
+		return nil, errors.New("the encryption verifier is truncated")
+	}
+	salt := verifier[4:20]
+	encryptedVerifier := verifier[20:36]
+	verifierHashSize := int(le.Uint32(verifier[36:]))
+	encryptedVerifierHash := verifier[40:72]
+
+	key := standardKey(salt, password, int(keySizes[header.AlgID]/8))
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	decryptECB := func(data []byte) []byte {
+		out := make([]byte, len(data)-len(data)%aes.BlockSize)
+		for i := 0; i < len(out); i += aes.BlockSize {
+			block.Decrypt(out[i:], data[i:])
+		}
+		return out
+	}
+	hashed := sha1.Sum(decryptECB(encryptedVerifier))
+	if verifierHashSize > len(hashed) || subtle.ConstantTimeCompare(hashed[:verifierHashSize], decryptECB(encryptedVerifierHash)[:verifierHashSize]) != 1 {
+		return nil, ErrIncorrectPassword
+	}
+
+	size := le.Uint64(pkg)
+	out := decryptECB(pkg[8:])
+	if size > uint64(len(out)) {
+		return nil, errors.New("the encrypted package is truncated")
+	}
+	return out[:size], nil
+}
+
+// standardKey derives the key of Standard Encryption from the salt
+// and password.
+func standardKey(salt []byte, password string, size int) []byte {
+	hn := agilePasswordHash(sha1.New, salt, password, standardSpinCount)
+	h := sha1.New()
+	h.Write(hn)
+	h.Write([]byte{0, 0, 0, 0})
+	hfinal := h.Sum(nil)
+	derive := func(fill byte) []byte {
+		buf := bytes.Repeat([]byte{fill}, 64)
+		for i, b := range hfinal {
+			buf[i] ^= b
+		}
+		sum := sha1.Sum(buf)
+		return sum[:]
+	}
+	return append(derive(0x36), derive(0x5c)...)[:size]
+}
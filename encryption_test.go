@@ -0,0 +1,237 @@
+package xlsx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestEncryption(t *testing.T) {
+	c := qt.New(t)
+
+	newTestFile := func(c *qt.C) *File {
+		file := NewFile()
+		sheet, err := file.AddSheet("Salaries")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetString("Name")
+		sheet.Cell(0, 1).SetString("Salary")
+		// Enough rows for several segments of the package.
+		for i := 1; i < 500; i++ {
+			sheet.Cell(i, 0).SetString("Employee " + RowIndexToString(i))
+			sheet.Cell(i, 1).SetInt(30000 + i)
+		}
+		return file
+	}
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		var buf bytes.Buffer
+		c.Assert(newTestFile(c).WriteWithPassword(&buf, "pässwörd"), qt.IsNil)
+		c.Assert(isCFB(buf.Bytes()), qt.Equals, true)
+
+		file, err := OpenBinaryWithPassword(buf.Bytes(), "pässwörd")
+		c.Assert(err, qt.IsNil)
+		sheet := file.Sheet["Salaries"]
+		c.Assert(sheet, qt.Not(qt.IsNil))
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "Salary")
+		c.Assert(sheet.Cell(499, 0).Value, qt.Equals, "Employee 500")
+		c.Assert(sheet.Cell(499, 1).Value, qt.Equals, "30499")
+
+		_, err = OpenBinaryWithPassword(buf.Bytes(), "password")
+		c.Assert(err, qt.Equals, ErrIncorrectPassword)
+		_, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.Equals, ErrEncrypted)
+	})
+
+	c.Run("SaveWithPassword", func(c *qt.C) {
+		dir, err := ioutil.TempDir("", "xlsx-encryption")
+		c.Assert(err, qt.IsNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "encrypted.xlsx")
+		c.Assert(newTestFile(c).SaveWithPassword(path, "secret"), qt.IsNil)
+
+		_, err = OpenFile(path)
+		c.Assert(err, qt.Equals, ErrEncrypted)
+		file, err := OpenFileWithPassword(path, "secret")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Salaries"].Cell(1, 1).Value, qt.Equals, "30001")
+
+		c.Assert(newTestFile(c).SaveWithPassword(path, ""), qt.ErrorMatches, `WriteWithPassword: the password is empty`)
+	})
+
+	c.Run("NotEncrypted", func(c *qt.C) {
+		file, err := OpenFileWithPassword("./testdocs/testfile.xlsx", "ignored")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 3)
+	})
+
+	c.Run("EncryptionInfo", func(c *qt.C) {
+		var buf bytes.Buffer
+		c.Assert(newTestFile(c).WriteWithPassword(&buf, "secret"), qt.IsNil)
+		streams, err := readCFB(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+
+		info := streams[encryptionInfoStream]
+		c.Assert(info[:8], qt.DeepEquals, []byte{4, 0, 4, 0, 0x40, 0, 0, 0})
+		var encryption agileEncryption
+		c.Assert(xml.Unmarshal(info[8:], &encryption), qt.IsNil)
+		c.Assert(encryption.KeyData.CipherAlgorithm, qt.Equals, "AES")
+		c.Assert(encryption.KeyData.KeyBits, qt.Equals, 256)
+		c.Assert(encryption.KeyData.HashAlgorithm, qt.Equals, "SHA512")
+		c.Assert(encryption.KeyData.SaltValue, qt.HasLen, 16)
+		c.Assert(encryption.DataIntegrity, qt.Not(qt.IsNil))
+		c.Assert(encryption.DataIntegrity.EncryptedHmacKey, qt.HasLen, 64)
+		c.Assert(encryption.KeyEncryptors, qt.HasLen, 1)
+		key := encryption.KeyEncryptors[0].EncryptedKey
+		c.Assert(key, qt.Not(qt.IsNil))
+		c.Assert(key.SpinCount, qt.Equals, 100000)
+		c.Assert(key.EncryptedKeyValue, qt.HasLen, 32)
+		c.Assert(string(info[8:]), qt.Contains, `<p:encryptedKey spinCount="100000" saltSize="16" blockSize="16" keyBits="256" hashSize="64" cipherAlgorithm="AES" cipherChaining="ChainingModeCBC" hashAlgorithm="SHA512" saltValue="`)
+
+		pkg := streams[encryptedPackageStream]
+		size := binary.LittleEndian.Uint64(pkg)
+		c.Assert(len(pkg)-8, qt.Equals, int(size+15)/16*16)
+
+		primary := streams["\x06DataSpaces/TransformInfo/StrongEncryptionTransform/\x06Primary"]
+		c.Assert(primary[:12], qt.DeepEquals, []byte{0x58, 0, 0, 0, 1, 0, 0, 0, 0x4c, 0, 0, 0})
+		c.Assert(primary[12:88], qt.DeepEquals, utf16LE("{FF9A3F03-56EF-4613-BDD5-5A41C1D07246}"))
+		c.Assert(streams["\x06DataSpaces/DataSpaceInfo/StrongEncryptionDataSpace"], qt.DeepEquals,
+			append([]byte{8, 0, 0, 0, 1, 0, 0, 0, 50, 0, 0, 0}, append(utf16LE("StrongEncryptionTransform"), 0, 0)...))
+		dataSpaceMap := streams["\x06DataSpaces/DataSpaceMap"]
+		c.Assert(binary.LittleEndian.Uint32(dataSpaceMap[8:]), qt.Equals, uint32(len(dataSpaceMap)-8))
+		c.Assert(streams["\x06DataSpaces/Version"][:4], qt.DeepEquals, []byte{60, 0, 0, 0})
+	})
+
+	c.Run("Tampered", func(c *qt.C) {
+		var buf bytes.Buffer
+		c.Assert(newTestFile(c).WriteWithPassword(&buf, "secret"), qt.IsNil)
+		streams, err := readCFB(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		streams[encryptedPackageStream][100] ^= 1
+		data, err := writeCFB(streams, 3)
+		c.Assert(err, qt.IsNil)
+		_, err = OpenBinaryWithPassword(data, "secret")
+		c.Assert(err, qt.ErrorMatches, `the encrypted package has been changed or is corrupt`)
+	})
+
+	c.Run("Standard", func(c *qt.C) {
+		var pkg bytes.Buffer
+		c.Assert(newTestFile(c).Write(&pkg), qt.IsNil)
+		data := encryptStandardPackage(c, pkg.Bytes(), "secret")
+
+		file, err := OpenBinaryWithPassword(data, "secret")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Salaries"].Cell(2, 1).Value, qt.Equals, "30002")
+
+		_, err = OpenBinaryWithPassword(data, "Secret")
+		c.Assert(err, qt.Equals, ErrIncorrectPassword)
+	})
+
+	c.Run("ExcelFiles", func(c *qt.C) {
+		// Files encrypted by Excel rather than by this package, with
+		// Agile Encryption using SHA-1 and AES-128, and with Standard
+		// Encryption.  They are taken from the tests of excelize.
+		for _, test := range []struct {
+			name    string
+			version []byte
+		}{
+			{"encrypted_agile.xlsx", []byte{4, 0, 4, 0}},
+			{"encrypted_standard.xlsx", []byte{3, 0, 2, 0}},
+		} {
+			path := filepath.Join("testdocs", test.name)
+			data, err := ioutil.ReadFile(path)
+			c.Assert(err, qt.IsNil)
+			streams, err := readCFB(data)
+			c.Assert(err, qt.IsNil)
+			c.Assert(streams[encryptionInfoStream][:4], qt.DeepEquals, test.version)
+
+			file, err := OpenFileWithPassword(path, "password")
+			c.Assert(err, qt.IsNil, qt.Commentf(test.name))
+			c.Assert(file.Sheets, qt.HasLen, 1)
+			c.Assert(file.Sheets[0].Name, qt.Equals, "Sheet1")
+			c.Assert(file.Sheets[0].Cell(0, 0).Value, qt.Equals, "SECRET")
+
+			_, err = OpenFileWithPassword(path, "passwd")
+			c.Assert(err, qt.Equals, ErrIncorrectPassword)
+			_, err = OpenFile(path)
+			c.Assert(err, qt.Equals, ErrEncrypted)
+		}
+	})
+
+	c.Run("Unsupported", func(c *qt.C) {
+		data, err := writeCFB(map[string][]byte{
+			encryptionInfoStream:   {3, 0, 3, 0, 0, 0, 0, 0},
+			encryptedPackageStream: make([]byte, 16),
+		}, 3)
+		c.Assert(err, qt.IsNil)
+		_, err = OpenBinaryWithPassword(data, "secret")
+		c.Assert(err, qt.ErrorMatches, `unsupported encryption version 3.3`)
+
+		data, err = writeCFB(map[string][]byte{"WordDocument": make([]byte, 16)}, 3)
+		c.Assert(err, qt.IsNil)
+		_, err = OpenBinaryWithPassword(data, "secret")
+		c.Assert(err, qt.ErrorMatches, `the compound file is not an encrypted package`)
+	})
+}
+
+// encryptStandardPackage encrypts a package with Standard Encryption
+// and AES-128, as Excel 2007 does.
+func encryptStandardPackage(c *qt.C, pkg []byte, password string) []byte {
+	le := binary.LittleEndian
+	salt := make([]byte, 16)
+	verifier := make([]byte, 16)
+	_, err := rand.Read(salt)
+	c.Assert(err, qt.IsNil)
+	_, err = rand.Read(verifier)
+	c.Assert(err, qt.IsNil)
+
+	block, err := aes.NewCipher(standardKey(salt, password, 16))
+	c.Assert(err, qt.IsNil)
+	encryptECB := func(data []byte) []byte {
+		data = append(append([]byte{}, data...), make([]byte, (aes.BlockSize-len(data)%aes.BlockSize)%aes.BlockSize)...)
+		out := make([]byte, len(data))
+		for i := 0; i < len(data); i += aes.BlockSize {
+			block.Encrypt(out[i:], data[i:])
+		}
+		return out
+	}
+	verifierHash := sha1.Sum(verifier)
+
+	var header bytes.Buffer
+	binary.Write(&header, le, standardEncryptionHeader{
+		Flags:        0x24,
+		AlgID:        standardAlgAES128,
+		AlgIDHash:    standardAlgSHA1,
+		KeySize:      128,
+		ProviderType: 0x18,
+	})
+	header.Write(utf16LE("Microsoft Enhanced RSA and AES Cryptographic Provider\x00"))
+
+	var info bytes.Buffer
+	binary.Write(&info, le, []uint16{3, 2})
+	binary.Write(&info, le, []uint32{0x24, uint32(header.Len())})
+	info.Write(header.Bytes())
+	binary.Write(&info, le, uint32(16))
+	info.Write(salt)
+	info.Write(encryptECB(verifier))
+	binary.Write(&info, le, uint32(sha1.Size))
+	info.Write(encryptECB(verifierHash[:]))
+
+	encrypted := make([]byte, 8)
+	le.PutUint64(encrypted, uint64(len(pkg)))
+	encrypted = append(encrypted, encryptECB(pkg)...)
+	data, err := writeCFB(map[string][]byte{
+		encryptionInfoStream:   info.Bytes(),
+		encryptedPackageStream: encrypted,
+	}, 3)
+	c.Assert(err, qt.IsNil)
+	return data
+}
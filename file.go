@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -18,6 +19,7 @@ import (
 type File struct {
 	worksheets     map[string]*zip.File
 	worksheetRels  map[string]*zip.File
+	parts          map[string]*zip.File
 	referenceTable *RefTable
 	Date1904       bool
 	styles         *xlsxStyleSheet
@@ -25,6 +27,21 @@ type File struct {
 	Sheet          map[string]*Sheet
 	theme          *theme
 	DefinedNames   []*xlsxDefinedName
+	ChartSheets    []*ChartSheet
+	Protection     *WorkbookProtection
+	// PassThrough is set when a File is read, so that the parts and
+	// elements of the file that aren't modelled are written back as
+	// they were.  Clear it to write just what is modelled.
+	PassThrough bool
+	// CalculateOnSave is set to evaluate every formula when the file
+	// is written, so that the values saved with them are up to date.
+	// Formulas that can't be evaluated keep the value they had.  It
+	// is off by default, as it costs an evaluation of each formula on
+	// every save; Calculate can be called instead.
+	CalculateOnSave bool
+	original        *originalWorkbook
+	// readWarnings is set while a file is read in lenient mode.
+	readWarnings *readWarnings
 }
 
 const NoRowLimit int = -1
@@ -39,49 +56,112 @@ func NewFile() *File {
 }
 
 // OpenFile() take the name of an XLSX file and returns a populated
-// xlsx.File struct for it.
-func OpenFile(fileName string) (file *File, err error) {
-	return OpenFileWithRowLimit(fileName, NoRowLimit)
+// xlsx.File struct for it.  Options such as RowLimit and OnlySheets
+// limit what is read.
+func OpenFile(fileName string, options ...FileOption) (file *File, err error) {
+	file, _, err = OpenFileWithOptions(fileName, readOptions(options))
+	return file, err
 }
 
 // OpenFileWithRowLimit() will open the file, but will only read the specified number of rows.
 // If you save this file, it will be truncated to the number of rows specified.
 func OpenFileWithRowLimit(fileName string, rowLimit int) (file *File, err error) {
-	var z *zip.ReadCloser
-	z, err = zip.OpenReader(fileName)
+	return OpenFile(fileName, RowLimit(rowLimit))
+}
+
+// OpenFileWithOptions opens an XLSX file and reads it as the options
+// say.  In lenient mode the problems that were worked around are
+// returned as warnings, along with the File.
+func OpenFileWithOptions(fileName string, options ReadOptions) (*File, []*ReadError, error) {
+	if options.Lazy {
+		// The sheets are read after the file is closed.
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return OpenBinaryWithOptions(data, options)
+	}
+	z, err := openZipFile(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer z.Close()
+	return ReadZipReaderWithOptions(&z.Reader, options)
+}
+
+// openZipFile opens the zip file of an XLSX file, and returns
+// ErrEncrypted for a file that is encrypted instead.
+func openZipFile(fileName string) (*zip.ReadCloser, error) {
+	z, err := zip.OpenReader(fileName)
 	if err != nil {
+		if err == zip.ErrFormat {
+			if f, openErr := os.Open(fileName); openErr == nil {
+				defer f.Close()
+				if isEncryptedFile(f) {
+					return nil, ErrEncrypted
+				}
+			}
+		}
 		return nil, err
 	}
-	return ReadZipWithRowLimit(z, rowLimit)
+	return z, nil
 }
 
 // OpenBinary() take bytes of an XLSX file and returns a populated
 // xlsx.File struct for it.
-func OpenBinary(bs []byte) (*File, error) {
-	return OpenBinaryWithRowLimit(bs, NoRowLimit)
+func OpenBinary(bs []byte, options ...FileOption) (*File, error) {
+	file, _, err := OpenBinaryWithOptions(bs, readOptions(options))
+	return file, err
 }
 
 // OpenBinaryWithRowLimit() take bytes of an XLSX file and returns a populated
 // xlsx.File struct for it.
 func OpenBinaryWithRowLimit(bs []byte, rowLimit int) (*File, error) {
+	return OpenBinary(bs, RowLimit(rowLimit))
+}
+
+// OpenBinaryWithOptions is like OpenFileWithOptions, for the bytes of
+// an XLSX file.
+func OpenBinaryWithOptions(bs []byte, options ReadOptions) (*File, []*ReadError, error) {
 	r := bytes.NewReader(bs)
-	return OpenReaderAtWithRowLimit(r, int64(r.Len()), rowLimit)
+	return OpenReaderAtWithOptions(r, int64(r.Len()), options)
 }
 
 // OpenReaderAt() take io.ReaderAt of an XLSX file and returns a populated
 // xlsx.File struct for it.
-func OpenReaderAt(r io.ReaderAt, size int64) (*File, error) {
-	return OpenReaderAtWithRowLimit(r, size, NoRowLimit)
+func OpenReaderAt(r io.ReaderAt, size int64, options ...FileOption) (*File, error) {
+	file, _, err := OpenReaderAtWithOptions(r, size, readOptions(options))
+	return file, err
 }
 
 // OpenReaderAtWithRowLimit() take io.ReaderAt of an XLSX file and returns a populated
 // xlsx.File struct for it.
 func OpenReaderAtWithRowLimit(r io.ReaderAt, size int64, rowLimit int) (*File, error) {
+	return OpenReaderAt(r, size, RowLimit(rowLimit))
+}
+
+// OpenReaderAtWithOptions is like OpenFileWithOptions, for an
+// io.ReaderAt of an XLSX file.
+func OpenReaderAtWithOptions(r io.ReaderAt, size int64, options ReadOptions) (*File, []*ReadError, error) {
+	file, err := openZipReaderAt(r, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ReadZipReaderWithOptions(file, options)
+}
+
+// openZipReaderAt opens the zip file of an XLSX file in an
+// io.ReaderAt, and returns ErrEncrypted for a file that is encrypted
+// instead.
+func openZipReaderAt(r io.ReaderAt, size int64) (*zip.Reader, error) {
 	file, err := zip.NewReader(r, size)
 	if err != nil {
+		if err == zip.ErrFormat && isEncryptedFile(r) {
+			return nil, ErrEncrypted
+		}
 		return nil, err
 	}
-	return ReadZipReaderWithRowLimit(file, rowLimit)
+	return file, nil
 }
 
 // A convenient wrapper around File.ToSlice, FileToSlice will
@@ -156,32 +236,48 @@ func (f *File) Write(writer io.Writer) (err error) {
 // The maximum sheet name length is 31 characters. If the sheet name length is exceeded an error is thrown.
 // These special characters are also not allowed: : \ / ? * [ ]
 func (f *File) AddSheet(sheetName string) (*Sheet, error) {
+	if err := f.checkSheetName(sheetName); err != nil {
+		return nil, err
+	}
+	sheet := &Sheet{
+		Name:     sheetName,
+		File:     f,
+		Selected: len(f.Sheets) == 0,
+		Cols:     &ColStore{},
+	}
+	f.Sheet[sheetName] = sheet
+	f.Sheets = append(f.Sheets, sheet)
+	return sheet, nil
+}
+
+// checkSheetName returns an error if sheetName can't be used for a new
+// sheet.
+func (f *File) checkSheetName(sheetName string) error {
 	if _, exists := f.Sheet[sheetName]; exists {
-		return nil, fmt.Errorf("duplicate sheet name '%s'.", sheetName)
+		return fmt.Errorf("duplicate sheet name '%s'.", sheetName)
+	}
+	for _, chartSheet := range f.ChartSheets {
+		if chartSheet.Name == sheetName {
+			return fmt.Errorf("duplicate sheet name '%s'.", sheetName)
+		}
 	}
 	runeLength := utf8.RuneCountInString(sheetName)
 	if runeLength > 31 || runeLength == 0 {
-		return nil, fmt.Errorf("sheet name must be 31 or fewer characters long.  It is currently '%d' characters long", runeLength)
+		return fmt.Errorf("sheet name must be 31 or fewer characters long.  It is currently '%d' characters long", runeLength)
 	}
 	// Iterate over the runes
 	for _, r := range sheetName {
 		// Excel forbids : \ / ? * [ ]
 		if r == ':' || r == '\\' || r == '/' || r == '?' || r == '*' || r == '[' || r == ']' {
-			return nil, fmt.Errorf("sheet name must not contain any restricted characters : \\ / ? * [ ] but contains '%s'", string(r))
+			return fmt.Errorf("sheet name must not contain any restricted characters : \\ / ? * [ ] but contains '%s'", string(r))
 		}
 	}
-	sheet := &Sheet{
-		Name:     sheetName,
-		File:     f,
-		Selected: len(f.Sheets) == 0,
-		Cols:     &ColStore{},
-	}
-	f.Sheet[sheetName] = sheet
-	f.Sheets = append(f.Sheets, sheet)
-	return sheet, nil
+	return nil
 }
 
-// Appends an existing Sheet, with the provided name, to a File
+// Appends an existing Sheet, with the provided name, to a File.  The
+// Sheet struct is copied, but its rows, cells and styles are shared with
+// the original; CloneSheet makes a copy of its own.
 func (f *File) AppendSheet(sheet Sheet, sheetName string) (*Sheet, error) {
 	if _, exists := f.Sheet[sheetName]; exists {
 		return nil, fmt.Errorf("duplicate sheet name '%s'.", sheetName)
@@ -197,7 +293,7 @@ func (f *File) AppendSheet(sheet Sheet, sheetName string) (*Sheet, error) {
 func (f *File) makeWorkbook() xlsxWorkbook {
 	return xlsxWorkbook{
 		FileVersion: xlsxFileVersion{AppName: "Go XLSX"},
-		WorkbookPr:  xlsxWorkbookPr{ShowObjects: "all"},
+		WorkbookPr:  xlsxWorkbookPr{ShowObjects: "all", Date1904: f.Date1904},
 		BookViews: xlsxBookViews{
 			WorkBookView: []xlsxWorkBookView{
 				{
@@ -212,7 +308,8 @@ func (f *File) makeWorkbook() xlsxWorkbook {
 				},
 			},
 		},
-		Sheets: xlsxSheets{Sheet: make([]xlsxSheet, len(f.Sheets))},
+		WorkbookProtection: f.makeWorkbookProtection(),
+		Sheets:             xlsxSheets{Sheet: make([]xlsxSheet, len(f.Sheets))},
 		CalcPr: xlsxCalcPr{
 			IterateCount: 100,
 			RefMode:      "A1",
@@ -248,6 +345,18 @@ func addRelationshipNameSpaceToWorksheet(worksheetMarshal string) string {
 	oldHyperlink := `<hyperlink id=`
 	newHyperlink := `<hyperlink r:id=`
 	newSheetMarshall = strings.Replace(newSheetMarshall, oldHyperlink, newHyperlink, -1)
+
+	oldDrawing := `<drawing id=`
+	newDrawing := `<drawing r:id=`
+	newSheetMarshall = strings.Replace(newSheetMarshall, oldDrawing, newDrawing, 1)
+
+	oldLegacyDrawing := `<legacyDrawing id=`
+	newLegacyDrawing := `<legacyDrawing r:id=`
+	newSheetMarshall = strings.Replace(newSheetMarshall, oldLegacyDrawing, newLegacyDrawing, 1)
+
+	oldTablePart := `<tablePart id=`
+	newTablePart := `<tablePart r:id=`
+	newSheetMarshall = strings.Replace(newSheetMarshall, oldTablePart, newTablePart, -1)
 	return newSheetMarshall
 }
 
@@ -274,17 +383,94 @@ func (f *File) MarshallParts() (map[string]string, error) {
 	workbook = f.makeWorkbook()
 	sheetIndex := 1
 
-	if f.styles == nil {
-		f.styles = newXlsxStyleSheet(f.theme)
-	}
-	f.styles.reset()
 	if len(f.Sheets) == 0 {
 		err := errors.New("Workbook must contains atleast one worksheet")
 		return nil, err
 	}
+	// The sheets of a lazily read file that can't be written as they
+	// were have to be read first.
+	for _, sheet := range f.Sheets {
+		if sheet.lazy != nil && !sheet.writesOriginal() {
+			if err := sheet.Load(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if f.styles != nil && f.readLazily() {
+		f.styles.recount()
+		refTable = f.referenceTable.writeCopy()
+	} else {
+		if f.styles == nil {
+			f.styles = newXlsxStyleSheet(f.theme)
+		}
+		f.styles.reset()
+	}
+	// Bring the cached values of formulas up to date, if asked to.
+	// Formulas that can't be evaluated, for example because they use
+	// a function we don't support, keep the value they had, so the
+	// error is deliberately ignored.  The sheets that formulas read
+	// are only read, so those that hadn't been read are still
+	// written as they were.
+	if f.CalculateOnSave {
+		unread := f.unreadSheets()
+		f.Calculate()
+		for _, sheet := range unread {
+			sheet.Unload()
+		}
+	}
+	hasComments := false
+	tableCount := 0
+	// The parts kept from the file that was read, which the parts
+	// that are written mustn't take the names of.
+	keep := f.originalPartsToKeep()
+	if f.passThrough() {
+		tableCount = f.maxOriginalTableID(keep)
+	}
+	names := newPartNames(keep)
+	media := newMediaParts(names)
+	charts := &chartParts{file: f, names: names}
+	writeDrawing := func(drawing *drawingPart, drawingPartName string) error {
+		parts[drawingPartName] = drawing.marshal()
+		parts[relsPartName(drawingPartName)], err = marshal(drawing.makeXLSXRels())
+		if err != nil {
+			return err
+		}
+		types.Overrides = append(
+			types.Overrides,
+			xlsxOverride{
+				PartName:    "/" + drawingPartName,
+				ContentType: "application/vnd.openxmlformats-officedocument.drawing+xml"})
+		return nil
+	}
 	for _, sheet := range f.Sheets {
+		if sheet.writesOriginal() {
+			if err := f.writeOriginalSheet(sheet, sheetIndex, parts, &types, workbookRels, &workbook); err != nil {
+				return parts, err
+			}
+			sheetIndex++
+			continue
+		}
+		xComments := sheet.makeXLSXComments()
+		var commentsPartName, vmlDrawingPartName string
+		if xComments != nil {
+			commentsPartName = names.pick("xl/comments%d.xml", sheetIndex)
+			vmlDrawingPartName = names.pick("xl/drawings/vmlDrawing%d.vml", sheetIndex)
+		}
+		sheet.setCommentRelations(commentsPartName, vmlDrawingPartName)
+		drawing := sheet.makeDrawingPart(media, charts)
+		var drawingPartName string
+		if drawing != nil {
+			drawingPartName = names.pick("xl/drawings/drawing%d.xml", sheetIndex)
+		}
+		sheet.setDrawingRelation(drawingPartName)
+		var tablePartNames []string
+		for i := range sheet.Tables {
+			tablePartNames = append(tablePartNames, names.pick("xl/tables/table%d.xml", tableCount+i+1))
+		}
+		sheet.setTableRelations(tablePartNames)
 		xSheetRels := sheet.makeXLSXSheetRelations()
 		xSheet := sheet.makeXLSXSheet(refTable, f.styles, xSheetRels)
+		xSheetRels, originalIDs := sheet.addOriginalRelations(xSheetRels)
 		rId := fmt.Sprintf("rId%d", sheetIndex)
 		sheetId := strconv.Itoa(sheetIndex)
 		sheetPath := fmt.Sprintf("worksheets/sheet%d.xml", sheetIndex)
@@ -307,6 +493,10 @@ func (f *File) MarshallParts() (map[string]string, error) {
 			return parts, err
 		}
 		worksheetMarshal = addRelationshipNameSpaceToWorksheet(worksheetMarshal)
+		worksheetMarshal, err = sheet.mergeOriginalXML(worksheetMarshal, originalIDs)
+		if err != nil {
+			return parts, err
+		}
 		parts[partName] = worksheetMarshal
 		if xSheetRels != nil {
 			parts[relPartName], err = marshal(xSheetRels)
@@ -314,14 +504,93 @@ func (f *File) MarshallParts() (map[string]string, error) {
 				return parts, err
 			}
 		}
+		if xComments != nil {
+			parts[commentsPartName], err = marshal(xComments)
+			if err != nil {
+				return parts, err
+			}
+			parts[vmlDrawingPartName] = sheet.makeVMLDrawing(sheetIndex)
+			types.Overrides = append(
+				types.Overrides,
+				xlsxOverride{
+					PartName:    "/" + commentsPartName,
+					ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.comments+xml"})
+			hasComments = true
+		}
+		if drawing != nil {
+			if err := writeDrawing(drawing, drawingPartName); err != nil {
+				return parts, err
+			}
+		}
+		for i, table := range sheet.Tables {
+			tableCount++
+			if err := table.write(parts, &types, tablePartNames[i], tableCount); err != nil {
+				return parts, err
+			}
+		}
+		sheetIndex++
+	}
+	for i, chartSheet := range f.ChartSheets {
+		rId := fmt.Sprintf("rId%d", sheetIndex)
+		partName := names.pick("xl/chartsheets/sheet%d.xml", i+1)
+		sheetPath := strings.TrimPrefix(partName, "xl/")
+		drawingPartName := names.pick("xl/drawings/drawing%d.xml", sheetIndex)
+		types.Overrides = append(
+			types.Overrides,
+			xlsxOverride{
+				PartName:    "/" + partName,
+				ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.chartsheet+xml"})
+		workbookRels[rId] = sheetPath
+		workbook.Sheets.Sheet = append(workbook.Sheets.Sheet, xlsxSheet{
+			Name:    chartSheet.Name,
+			SheetId: strconv.Itoa(sheetIndex),
+			Id:      rId,
+			State:   "visible"})
+
+		parts[partName] = chartSheetXML
+		parts[relsPartName(partName)], err = marshal(&xlsxWorksheetRels{
+			Relationships: []xlsxWorksheetRelation{{
+				Id:     "rId1",
+				Type:   RelationshipTypeDrawing,
+				Target: relativePartTarget("xl/chartsheets", drawingPartName)}}})
+		if err != nil {
+			return parts, err
+		}
+		if err := writeDrawing(chartSheet.makeDrawingPart(charts), drawingPartName); err != nil {
+			return parts, err
+		}
 		sheetIndex++
 	}
+	media.write(parts, &types)
+	charts.write(parts, &types)
+	if hasComments {
+		types.Defaults = append(
+			types.Defaults,
+			xlsxDefault{
+				Extension:   "vml",
+				ContentType: "application/vnd.openxmlformats-officedocument.vmlDrawing"})
+	}
+
+	// The relationships of the workbook kept from the file that was
+	// read follow the sheets and the shared strings, theme and styles.
+	var originalRels []xlsxWorkbookRelation
+	var originalIDs map[string]string
+	if f.passThrough() {
+		originalRels, originalIDs = f.original.relations(len(workbookRels) + 4)
+		f.original.addSheets(&workbook, originalIDs, sheetIndex)
+	}
 
 	workbookMarshal, err := marshal(workbook)
 	if err != nil {
 		return parts, err
 	}
 	workbookMarshal = replaceRelationshipsNameSpace(workbookMarshal)
+	if f.passThrough() {
+		workbookMarshal, err = workbookElementPolicy.merge(workbookMarshal, f.original.doc, originalIDs)
+		if err != nil {
+			return parts, err
+		}
+	}
 	parts["xl/workbook.xml"] = workbookMarshal
 	if err != nil {
 		return parts, err
@@ -340,12 +609,18 @@ func (f *File) MarshallParts() (map[string]string, error) {
 	}
 
 	xWRel := workbookRels.MakeXLSXWorkbookRels()
+	xWRel.Relationships = append(xWRel.Relationships, originalRels...)
 
 	parts["xl/_rels/workbook.xml.rels"], err = marshal(xWRel)
 	if err != nil {
 		return parts, err
 	}
 
+	err = f.writeOriginalParts(parts, &types, keep)
+	if err != nil {
+		return parts, err
+	}
+
 	parts["[Content_Types].xml"], err = marshal(types)
 	if err != nil {
 		return parts, err
@@ -375,6 +650,9 @@ func (f *File) MarshallParts() (map[string]string, error) {
 func (f *File) ToSlice() (output [][][]string, err error) {
 	output = [][][]string{}
 	for _, sheet := range f.Sheets {
+		if err := sheet.Load(); err != nil {
+			return output, err
+		}
 		s := [][]string{}
 		for _, row := range sheet.Rows {
 			if row == nil {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 	. "gopkg.in/check.v1"
@@ -301,6 +302,29 @@ func TestGetStyleFromZipFile(t *testing.T) {
 	c.Assert(cellBar.GetStyle().Fill.BgColor, qt.Equals, "")
 }
 
+// The date system of a workbook is written, so that its dates are read
+// back as they were.
+func TestDate1904RoundTrip(t *testing.T) {
+	c := qt.New(t)
+	file := NewFile()
+	file.Date1904 = true
+	sheet, err := file.AddSheet("Sheet1")
+	c.Assert(err, qt.IsNil)
+	sheet.Cell(0, 0).SetDateTimeWithFormat(1, DefaultDateFormat)
+
+	parts, err := file.MarshallParts()
+	c.Assert(err, qt.IsNil)
+	c.Assert(parts["xl/workbook.xml"], qt.Contains, `date1904="true"`)
+	read, err := OpenBinary(zipParts(c, parts))
+	c.Assert(err, qt.IsNil)
+	c.Assert(read.Date1904, qt.Equals, true)
+	cell := read.Sheet["Sheet1"].Cell(0, 0)
+	c.Assert(cell.date1904, qt.Equals, true)
+	date, err := cell.GetTime(read.Date1904)
+	c.Assert(err, qt.IsNil)
+	c.Assert(date, qt.Equals, time.Date(1904, 1, 2, 0, 0, 0, 0, time.UTC))
+}
+
 // Test we can create a File object from scratch
 func (l *FileSuite) TestCreateFile(c *C) {
 	var xlsxFile *File
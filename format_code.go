@@ -6,6 +6,8 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Do not edit these attributes once this struct is created. This struct should only be created by
@@ -15,6 +17,7 @@ type parsedNumberFormat struct {
 	numFmt                        string
 	isTimeFormat                  bool
 	negativeFormatExpectsPositive bool
+	hasConditions                 bool
 	positiveFormat                *formatOptions
 	negativeFormat                *formatOptions
 	zeroFormat                    *formatOptions
@@ -22,13 +25,88 @@ type parsedNumberFormat struct {
 	parseEncounteredError         *error
 }
 
+// formatOptions is a single section of a number format, broken up
+// into tokens by parseNumberFormatSection.
 type formatOptions struct {
-	isTimeFormat        bool
-	showPercent         bool
-	fullFormatString    string
-	reducedFormatString string
-	prefix              string
-	suffix              string
+	isTimeFormat     bool
+	isGeneral        bool
+	fullFormatString string
+	tokens           []formatToken
+	condition        *formatCondition
+	// lcid is the locale named by the section, as in [$-407]. The
+	// system date and time formats [$-F800] and [$-F400] are shown in
+	// the long date and time formats of the locale.
+	lcid       int
+	systemDate bool
+	systemTime bool
+
+	// The layout of a number section, found by analyseNumberTokens.
+	// The indexes are positions in tokens, and are -1 when the
+	// section doesn't have that part.
+	percent          int
+	scale            int
+	grouping         bool
+	point            int
+	exponent         int
+	fraction         int
+	integer          []int
+	decimals         []int
+	exponentDigits   []int
+	numerator        []int
+	denominator      []int
+	fixedDenominator int
+}
+
+type formatTokenType int
+
+const (
+	formatTokenLiteral formatTokenType = iota
+	formatTokenDigit                   // 0, # or ?
+	formatTokenDecimalPoint
+	formatTokenThousands
+	formatTokenPercent
+	formatTokenExponent    // E+, E-, e+ or e-
+	formatTokenFraction    // The slash of a fraction such as # ?/?
+	formatTokenDenominator // A fixed denominator such as the 8 in # ?/8
+	formatTokenText        // @
+	formatTokenGeneral     // General, as part of a section such as General" kg"
+	formatTokenDate        // y, m, d, h, s, e or g, repeated, or aaa or aaaa
+	formatTokenMinute      // m or mm following hours or preceding seconds
+	formatTokenElapsed     // [h], [mm], [ss] and so on
+	formatTokenSubsecond   // .0, .00 or .000 following seconds
+	formatTokenAMPM        // AM/PM or A/P, in the case it is written in
+)
+
+type formatToken struct {
+	typ  formatTokenType
+	text string
+}
+
+// formatCondition is a condition such as [>100] at the start of a
+// format section.
+type formatCondition struct {
+	operator string
+	value    float64
+}
+
+func (c *formatCondition) matches(value float64) bool {
+	if c == nil {
+		return false
+	}
+	switch c.operator {
+	case "<":
+		return value < c.value
+	case "<=":
+		return value <= c.value
+	case ">":
+		return value > c.value
+	case ">=":
+		return value >= c.value
+	case "<>":
+		return value != c.value
+	default:
+		return value == c.value
+	}
 }
 
 // FormatValue returns a value, and possibly an error condition
@@ -67,6 +145,13 @@ type formatOptions struct {
 // does not support adjusting the precision while not padding with zeros, while also not switching to scientific
 // notation too early.
 func (fullFormat *parsedNumberFormat) FormatValue(cell *Cell) (string, error) {
+	return fullFormat.formatValue(cell, nil)
+}
+
+// formatValue formats a cell in a locale. Sections of the format
+// that name a locale of their own use that one instead, and a nil
+// locale stands for LocaleEnUS.
+func (fullFormat *parsedNumberFormat) formatValue(cell *Cell, locale *Locale) (string, error) {
 	switch cell.cellType {
 	case CellTypeError:
 		// The error type is what XLSX uses in error cases such as when formulas are invalid.
@@ -85,113 +170,95 @@ func (fullFormat *parsedNumberFormat) FormatValue(cell *Cell) (string, error) {
 	case CellTypeInline:
 		fallthrough
 	case CellTypeStringFormula:
-		textFormat := cell.parsedNumFmt.textFormat
-		// This switch statement is only for String formats
-		switch textFormat.reducedFormatString {
-		case builtInNumFmt[builtInNumFmtIndex_GENERAL]: // General is literally "general"
+		textFormat := fullFormat.textFormat
+		if textFormat.isGeneral {
 			return cell.Value, nil
-		case builtInNumFmt[builtInNumFmtIndex_STRING]: // String is "@"
-			return textFormat.prefix + cell.Value + textFormat.suffix, nil
-		case "":
-			// If cell is not "General" and there is not an "@" symbol in the format, then the cell's value is not
-			// used when determining what to display. It would be completely legal to have a format of "Error"
-			// for strings, and all values that are not numbers would show up as "Error". In that case, this code would
-			// have a prefix of "Error" and a reduced format string of "" (empty string).
-			return textFormat.prefix + textFormat.suffix, nil
-		default:
-			return cell.Value, errors.New("invalid or unsupported format, unsupported string format")
 		}
+		// If there is not an "@" symbol in the format, then the cell's value is not used when determining what to
+		// display. It would be completely legal to have a format of "Error" for strings, and all values that are not
+		// numbers would show up as "Error".
+		return textFormat.formatText(cell.Value)
 	case CellTypeDate:
 		// These are dates that are stored in date format instead of being stored as numbers with a format to turn them
 		// into a date string.
 		return cell.Value, nil
 	case CellTypeNumeric:
-		return fullFormat.formatNumericCell(cell)
+		return fullFormat.formatNumericCell(cell, locale)
 	default:
 		return cell.Value, errors.New("unknown cell type")
 	}
 }
 
-func (fullFormat *parsedNumberFormat) formatNumericCell(cell *Cell) (string, error) {
+func (fullFormat *parsedNumberFormat) formatNumericCell(cell *Cell, locale *Locale) (string, error) {
 	rawValue := strings.TrimSpace(cell.Value)
 	// If there wasn't a value in the cell, it shouldn't have been marked as Numeric.
 	// It's better to support this case though.
 	if rawValue == "" {
 		return "", nil
 	}
-
-	if fullFormat.isTimeFormat {
-		return fullFormat.parseTime(rawValue, cell.date1904)
-	}
-	var numberFormat *formatOptions
 	floatVal, floatErr := strconv.ParseFloat(rawValue, 64)
 	if floatErr != nil {
 		return rawValue, floatErr
 	}
-	// Choose the correct format. There can be different formats for positive, negative, and zero numbers.
-	// Excel only uses the zero format if the value is literally zero, even if the number is so small that it shows
-	// up as "0" when the positive format is used.
-	if floatVal > 0 {
-		numberFormat = fullFormat.positiveFormat
-	} else if floatVal < 0 {
-		// If format string specified a different format for negative numbers, then the number should be made positive
-		// before getting formatted. The format string itself will contain formatting that denotes a negative number and
-		// this formatting will end up in the prefix or suffix. Commonly if there is a negative format specified, the
-		// number will get surrounded by parenthesis instead of showing it with a minus sign.
-		if fullFormat.negativeFormatExpectsPositive {
-			floatVal = math.Abs(floatVal)
-		}
-		numberFormat = fullFormat.negativeFormat
-	} else {
-		numberFormat = fullFormat.zeroFormat
-	}
-
-	// When showPercent is true, multiply the number by 100.
-	// The percent sign will be in the prefix or suffix already, so it does not need to be added in this function.
-	// The number format itself will be the same as any other number format once the value is multiplied by 100.
-	if numberFormat.showPercent {
-		floatVal = 100 * floatVal
-	}
-
-	// Only the most common format strings are supported here.
-	// Eventually this switch needs to be replaced with a more general solution.
-	// Some of these "supported" formats should have thousand separators, but don't get them since Go fmt
-	// doesn't have a way to request thousands separators.
-	// The only things that should be supported here are in the array formattingCharacters,
-	// everything else has been stripped out before and will be placed in the prefix or suffix.
-	// The formatting characters can have non-formatting characters mixed in with them and those should be maintained.
-	// However, at this time we fail to parse those formatting codes and they get replaced with "General"
-	var formattedNum string
-	switch numberFormat.reducedFormatString {
-	case builtInNumFmt[builtInNumFmtIndex_GENERAL]: // General is literally "general"
-		// prefix, showPercent, and suffix cannot apply to the general format
+
+	numberFormat, expectsPositive := fullFormat.numberSection(floatVal)
+	locale = numberFormat.locale(locale)
+	if numberFormat.isGeneral {
 		// The logic for showing numbers when the format is "general" is much more complicated than the rest of these.
 		generalFormatted, err := generalNumericScientific(cell.Value, true)
 		if err != nil {
 			return rawValue, nil
 		}
-		return generalFormatted, nil
-	case builtInNumFmt[builtInNumFmtIndex_STRING]: // String is "@"
-		formattedNum = cell.Value
-	case builtInNumFmt[builtInNumFmtIndex_INT], "#,##0": // Int is "0"
-		// Previously this case would cast to int and print with %d, but that will not round the value correctly.
-		formattedNum = fmt.Sprintf("%.0f", floatVal)
-	case "0.0", "#,##0.0":
-		formattedNum = fmt.Sprintf("%.1f", floatVal)
-	case builtInNumFmt[builtInNumFmtIndex_FLOAT], "#,##0.00": // Float is "0.00"
-		formattedNum = fmt.Sprintf("%.2f", floatVal)
-	case "0.000", "#,##0.000":
-		formattedNum = fmt.Sprintf("%.3f", floatVal)
-	case "0.0000", "#,##0.0000":
-		formattedNum = fmt.Sprintf("%.4f", floatVal)
-	case "0.00e+00", "##0.0e+0":
-		formattedNum = fmt.Sprintf("%e", floatVal)
-	case "":
-		// Do nothing.
-	default:
-		return rawValue, nil
+		return strings.Replace(generalFormatted, ".", locale.DecimalSeparator, 1), nil
+	}
+	// If the format has a section for negative numbers, then the number should be made positive before getting
+	// formatted. The section itself will contain formatting that denotes a negative number, commonly parentheses
+	// instead of a minus sign. Otherwise the minus sign goes in front of everything else in the section.
+	var sign string
+	if floatVal < 0 {
+		floatVal = -floatVal
+		if !expectsPositive {
+			sign = "-"
+		}
+	}
+	if numberFormat.isTimeFormat {
+		if sign != "" {
+			// Excel fills the cell with # signs instead.
+			return rawValue, errors.New("invalid value for a date or time format, the value is negative")
+		}
+		return numberFormat.formatDate(floatVal, cell.date1904, locale), nil
+	}
+	return sign + numberFormat.formatNumber(floatVal, locale), nil
+}
+
+// numberSection chooses the section of the format for a value, and
+// reports whether the section expects the value to be made positive.
+// Excel only uses the zero section if the value is literally zero,
+// even if the number is so small that it shows up as "0" when the
+// positive section is used.
+func (fullFormat *parsedNumberFormat) numberSection(value float64) (*formatOptions, bool) {
+	if fullFormat.hasConditions {
+		// Conditions replace the positive and negative tests of the
+		// first two sections, and the third section, if any, takes
+		// every other value.
+		positive, negative := fullFormat.positiveFormat.condition, fullFormat.negativeFormat.condition
+		switch {
+		case positive.matches(value):
+			return fullFormat.positiveFormat, false
+		case negative.matches(value):
+			return fullFormat.negativeFormat, false
+		case positive != nil && negative != nil:
+			return fullFormat.zeroFormat, false
+		default:
+			return fullFormat.negativeFormat, false
+		}
 	}
-	return numberFormat.prefix + formattedNum + numberFormat.suffix, nil
+	if value > 0 {
+		return fullFormat.positiveFormat, false
+	} else if value < 0 {
+		return fullFormat.negativeFormat, fullFormat.negativeFormatExpectsPositive
+	}
+	return fullFormat.zeroFormat, false
 }
 
 func generalNumericScientific(value string, allowScientific bool) (string, error) {
@@ -239,19 +306,18 @@ func parseFullNumberFormatString(numFmt string) *parsedNumberFormat {
 	parsedNumFmt := &parsedNumberFormat{
 		numFmt: numFmt,
 	}
-	if isTimeFormat(numFmt) {
-		// Time formats cannot have multiple groups separated by semicolons, there is only one format.
-		// Strings are unaffected by the time format.
-		parsedNumFmt.isTimeFormat = true
-		parsedNumFmt.textFormat, _ = parseNumberFormatSection("general")
-		return parsedNumFmt
-	}
 
 	var fmtOptions []*formatOptions
 	formats, err := splitFormatOnSemicolon(numFmt)
 	if err == nil {
 		for _, formatSection := range formats {
-			parsedFormat, err := parseNumberFormatSection(formatSection)
+			var parsedFormat *formatOptions
+			if formatSection == "" && len(formats) > 1 {
+				// An empty section hides the values it applies to.
+				parsedFormat, err = parseNumberFormatTokens(formatSection)
+			} else {
+				parsedFormat, err = parseNumberFormatSection(formatSection)
+			}
 			if err != nil {
 				// If an invalid number section is found, fall back to general
 				parsedFormat = fallbackErrorFormat
@@ -269,44 +335,45 @@ func parseFullNumberFormatString(numFmt string) *parsedNumberFormat {
 		parsedNumFmt.parseEncounteredError = &err
 	}
 
-	if len(fmtOptions) == 1 {
+	// The fourth section is always for text, but fewer sections can
+	// also end with a text section, as in "yyyy-mm-dd;@".
+	numberFormats := fmtOptions
+	if len(fmtOptions) == 4 || len(fmtOptions) > 1 && fmtOptions[len(fmtOptions)-1].hasText() {
+		parsedNumFmt.textFormat = fmtOptions[len(fmtOptions)-1]
+		numberFormats = fmtOptions[:len(fmtOptions)-1]
+	}
+
+	if len(numberFormats) == 1 {
 		// If there is only one option, it is used for all
-		parsedNumFmt.positiveFormat = fmtOptions[0]
-		parsedNumFmt.negativeFormat = fmtOptions[0]
-		parsedNumFmt.zeroFormat = fmtOptions[0]
-		if strings.Contains(fmtOptions[0].fullFormatString, "@") {
-			parsedNumFmt.textFormat = fmtOptions[0]
-		} else {
-			parsedNumFmt.textFormat, _ = parseNumberFormatSection("general")
+		parsedNumFmt.positiveFormat = numberFormats[0]
+		parsedNumFmt.negativeFormat = numberFormats[0]
+		parsedNumFmt.zeroFormat = numberFormats[0]
+		if parsedNumFmt.textFormat == nil && numberFormats[0].hasText() {
+			parsedNumFmt.textFormat = numberFormats[0]
 		}
-	} else if len(fmtOptions) == 2 {
+	} else if len(numberFormats) == 2 {
 		// If there are two formats, the first is used for positive and zeros, the second gets used as a negative format,
 		// and strings are not formatted.
 		// When negative numbers now have their own format, they should become positive before having the format applied.
 		// The format will contain a negative sign if it is desired, but they may be colored red or wrapped in
 		// parenthesis instead.
 		parsedNumFmt.negativeFormatExpectsPositive = true
-		parsedNumFmt.positiveFormat = fmtOptions[0]
-		parsedNumFmt.negativeFormat = fmtOptions[1]
-		parsedNumFmt.zeroFormat = fmtOptions[0]
-		parsedNumFmt.textFormat, _ = parseNumberFormatSection("general")
-	} else if len(fmtOptions) == 3 {
-		// If there are three formats, the first is used for positive, the second gets used as a negative format,
-		// the third is for negative, and strings are not formatted.
-		parsedNumFmt.negativeFormatExpectsPositive = true
-		parsedNumFmt.positiveFormat = fmtOptions[0]
-		parsedNumFmt.negativeFormat = fmtOptions[1]
-		parsedNumFmt.zeroFormat = fmtOptions[2]
-		parsedNumFmt.textFormat, _ = parseNumberFormatSection("general")
+		parsedNumFmt.positiveFormat = numberFormats[0]
+		parsedNumFmt.negativeFormat = numberFormats[1]
+		parsedNumFmt.zeroFormat = numberFormats[0]
 	} else {
-		// With four options, the first is positive, the second is negative, the third is zero, and the fourth is strings
+		// With three options, the first is positive, the second is negative, and the third is zero.
 		// Negative numbers should be still become positive before having the negative formatting applied.
 		parsedNumFmt.negativeFormatExpectsPositive = true
-		parsedNumFmt.positiveFormat = fmtOptions[0]
-		parsedNumFmt.negativeFormat = fmtOptions[1]
-		parsedNumFmt.zeroFormat = fmtOptions[2]
-		parsedNumFmt.textFormat = fmtOptions[3]
+		parsedNumFmt.positiveFormat = numberFormats[0]
+		parsedNumFmt.negativeFormat = numberFormats[1]
+		parsedNumFmt.zeroFormat = numberFormats[2]
 	}
+	if parsedNumFmt.textFormat == nil {
+		parsedNumFmt.textFormat, _ = parseNumberFormatSection("general")
+	}
+	parsedNumFmt.isTimeFormat = parsedNumFmt.positiveFormat.isTimeFormat
+	parsedNumFmt.hasConditions = parsedNumFmt.positiveFormat.condition != nil || parsedNumFmt.negativeFormat.condition != nil
 	return parsedNumFmt
 }
 
@@ -335,307 +402,848 @@ func splitFormatOnSemicolon(format string) ([]string, error) {
 }
 
 var fallbackErrorFormat = &formatOptions{
-	fullFormatString:    "general",
-	reducedFormatString: "general",
-}
-
-// parseNumberFormatSection takes in individual format and parses out most of the options.
-// Some options are parsed, removed from the string, and set as settings on formatOptions.
-// There remainder of the format string is put in the reducedFormatString attribute, and supported values for these
-// are handled in a switch in the Cell.FormattedValue() function.
-// Ideally more and more of the format string would be parsed out here into settings until there is no remainder string
-// at all.
-// Features that this supports:
-// - Time formats are detected, and marked in the options. Time format strings are handled when doing the formatting.
-//   The logic to detect time formats is currently not correct, and can catch formats that are not time formats as well
-//   as miss formats that are time formats.
-// - Color formats are detected and removed.
-// - Currency annotations are handled properly.
-// - Literal strings wrapped in quotes are handled and put into prefix or suffix.
-// - Numbers that should be percent are detected and marked in the options.
-// - Conditionals are detected and removed, but they are not obeyed. The conditional groups will be used just like the
-//   positive;negative;zero;string format groups. Here is an example of a conditional format: "[Red][<=100];[Blue][>100]"
-// Decoding the actual number formatting portion is out of scope, that is placed into reducedFormatString and is used
-// when formatting the string. The string there will be reduced to only the things in the formattingCharacters array.
-// Everything not in that array has been parsed out and put into formatOptions.
-func parseNumberFormatSection(fullFormat string) (*formatOptions, error) {
-	reducedFormat := strings.TrimSpace(fullFormat)
+	fullFormatString: "general",
+	isGeneral:        true,
+	tokens:           []formatToken{{typ: formatTokenGeneral, text: "General"}},
+}
 
+// parseNumberFormatSection takes in an individual format section and
+// breaks it into tokens that formatNumber, formatDate and formatText
+// render. Literal text may be quoted, escaped with a backslash, or be
+// one of the symbols that need no escaping. Colors such as [Red] are
+// dropped, currency annotations such as [$€-407] become their symbol,
+// and a condition such as [>100] is kept to choose the section for a
+// value. The rest are digit placeholders with their commas, percent
+// signs, exponents and fractions, or the parts of a date or time.
+func parseNumberFormatSection(fullFormat string) (*formatOptions, error) {
 	// general is the only format that does not use the normal format symbols notations
-	if compareFormatString(reducedFormat, "general") {
+	if compareFormatString(strings.TrimSpace(fullFormat), "general") {
 		return &formatOptions{
-			fullFormatString:    "general",
-			reducedFormatString: "general",
+			fullFormatString: "general",
+			isGeneral:        true,
+			tokens:           []formatToken{{typ: formatTokenGeneral, text: "General"}},
 		}, nil
 	}
+	return parseNumberFormatTokens(fullFormat)
+}
 
-	prefix, reducedFormat, showPercent1, err := parseLiterals(reducedFormat)
-	if err != nil {
-		return nil, err
+func parseNumberFormatTokens(fullFormat string) (*formatOptions, error) {
+	section := &formatOptions{
+		fullFormatString: fullFormat,
+		point:            -1,
+		exponent:         -1,
+		fraction:         -1,
 	}
-
-	reducedFormat, suffixFormat := splitFormatAndSuffixFormat(reducedFormat)
-
-	suffix, remaining, showPercent2, err := parseLiterals(suffixFormat)
-	if err != nil {
+	if err := section.tokenize(fullFormat); err != nil {
 		return nil, err
 	}
-	if len(remaining) > 0 {
-		// This paradigm of codes consisting of literals, number formats, then more literals is not always correct, they can
-		// actually be intertwined. Though 99% of the time number formats will not do this.
-		// Excel uses this format string for Social Security Numbers: 000\-00\-0000
-		// and this for US phone numbers: [<=9999999]###\-####;\(###\)\ ###\-####
-		return nil, errors.New("invalid or unsupported format string")
-	}
-
-	return &formatOptions{
-		fullFormatString:    fullFormat,
-		isTimeFormat:        false,
-		reducedFormatString: reducedFormat,
-		prefix:              prefix,
-		suffix:              suffix,
-		showPercent:         showPercent1 || showPercent2,
-	}, nil
-}
-
-// formattingCharacters will be left in the reducedNumberFormat
-// It is important that these be looked for in order so that the slash cases are handled correctly.
-// / (slash) is a fraction format if preceded by 0, #, or ?, otherwise it is not a formatting character
-// E- E+ e- e+ are scientific notation, but E, e, -, + are not formatting characters independently
-// \ (back slash) makes the next character a literal (not formatting)
-// " Anything in double quotes is not a formatting character
-// _ (underscore) skips the width of the next character, so the next character cannot be formatting
-var formattingCharacters = []string{"0/", "#/", "?/", "E-", "E+", "e-", "e+", "0", "#", "?", ".", ",", "@", "*"}
-
-// The following are also time format characters, but since this is only used for detecting, not decoding, they are
-// redundant here: ee, gg, ggg, rr, ss, mm, hh, yyyy, dd, ddd, dddd, mm, mmm, mmmm, mmmmm, ss.0000, ss.000, ss.00, ss.0
-// The .00 type format is very tricky, because it only counts if it comes after ss or s or [ss] or [s]
-// .00 is actually a valid number format by itself.
-var timeFormatCharacters = []string{"m", "d", "yy", "h", "m", "AM/PM", "A/P", "am/pm", "a/p", "r", "g", "e", "b1", "b2", "[hh]", "[h]", "[mm]", "[m]",
-	"s.0000", "s.000", "s.00", "s.0", "s", "[ss].0000", "[ss].000", "[ss].00", "[ss].0", "[ss]", "[s].0000", "[s].000", "[s].00", "[s].0", "[s]"}
-
-func splitFormatAndSuffixFormat(format string) (string, string) {
-	var i int
-	for ; i < len(format); i++ {
-		curReducedFormat := format[i:]
-		var found bool
-		for _, special := range formattingCharacters {
-			if strings.HasPrefix(curReducedFormat, special) {
-				// Skip ahead if the special character was longer than length 1
-				i += len(special) - 1
-				found = true
-				break
-			}
-		}
-		if !found {
-			break
+	tokens := section.tokens
+	for _, token := range tokens {
+		switch token.typ {
+		case formatTokenDate, formatTokenElapsed, formatTokenAMPM:
+			section.isTimeFormat = true
 		}
 	}
-	suffixFormat := format[i:]
-	format = format[:i]
-	return format, suffixFormat
+	// A section such as [Red]General is still general.
+	section.isGeneral = len(tokens) == 1 && tokens[0].typ == formatTokenGeneral
+	if section.isTimeFormat {
+		section.analyseDateTokens()
+	} else {
+		section.analyseNumberTokens()
+	}
+	return section, nil
 }
 
-func parseLiterals(format string) (string, string, bool, error) {
-	var prefix string
-	showPercent := false
-	for i := 0; i < len(format); i++ {
-		curReducedFormat := format[i:]
-		switch curReducedFormat[0] {
-		case '\\':
-			// If there is a slash, skip the next character, and add it to the prefix
-			if len(curReducedFormat) > 1 {
-				i++
-				prefix += curReducedFormat[1:2]
-			}
-		case '_':
-			// If there is an underscore, skip the next character, but don't add it to the prefix
-			if len(curReducedFormat) > 1 {
-				i++
-			}
-		case '*':
-			// Asterisks are used to repeat the next character to fill the full cell width.
-			// There isn't really a cell size in this context, so this will be ignored.
-		case '"':
-			// If there is a quote skip to the next quote, and add the quoted characters to the prefix
-			endQuoteIndex := strings.Index(curReducedFormat[1:], "\"")
+// formatLiteralCharacters are the characters that are shown as they
+// are without being quoted or escaped.
+const formatLiteralCharacters = "$-+/()!^&'~{}<>=: "
+
+// tokenize splits a format section into tokens. The condition and
+// locale of the section are kept separately.
+func (section *formatOptions) tokenize(format string) error {
+	var tokens []formatToken
+	add := func(typ formatTokenType, text string) {
+		tokens = append(tokens, formatToken{typ: typ, text: text})
+	}
+	for i := 0; i < len(format); {
+		rest := format[i:]
+		c := rest[0]
+		switch {
+		case c == '"':
+			endQuoteIndex := strings.IndexByte(rest[1:], '"')
 			if endQuoteIndex == -1 {
-				return "", "", false, errors.New("invalid formatting code, unmatched double quote")
+				return errors.New("invalid formatting code, unmatched double quote")
 			}
-			prefix = prefix + curReducedFormat[1:endQuoteIndex+1]
-			i += endQuoteIndex + 1
-		case '%':
-			showPercent = true
-			prefix += "%"
-		case '[':
-			// Brackets can be currency annotations (e.g. [$$-409])
-			// color formats (e.g. [color1] through [color56], as well as [red] etc.)
-			// conditionals (e.g. [>100], the valid conditionals are =, >, <, >=, <=, <>)
-			bracketIndex := strings.Index(curReducedFormat, "]")
+			add(formatTokenLiteral, rest[1:endQuoteIndex+1])
+			i += endQuoteIndex + 2
+		case c == '\\':
+			// A backslash makes the next character a literal.
+			i++
+			if i < len(format) {
+				_, size := utf8.DecodeRuneInString(format[i:])
+				add(formatTokenLiteral, format[i:i+size])
+				i += size
+			}
+		case c == '_' || c == '*':
+			// An underscore skips the width of the next character, which is written as a space, so that the
+			// values of accounting formats line up. An asterisk repeats the next character to fill the cell,
+			// and as there isn't really a cell size in this context, it is dropped with its character.
+			if c == '_' {
+				add(formatTokenLiteral, " ")
+			}
+			i++
+			if i < len(format) {
+				_, size := utf8.DecodeRuneInString(format[i:])
+				i += size
+			}
+		case c == '[':
+			bracketIndex := strings.IndexByte(rest, ']')
 			if bracketIndex == -1 {
-				return "", "", false, errors.New("invalid formatting code, invalid brackets")
-			}
-			// Currencies in Excel are annotated with this format: [$<Currency String>-<Language Info>]
-			// Currency String is something like $, ¥, €, or £
-			// Language Info is three hexadecimal characters
-			if len(curReducedFormat) > 2 && curReducedFormat[1] == '$' {
-				dashIndex := strings.Index(curReducedFormat, "-")
-				if dashIndex != -1 && dashIndex < bracketIndex {
-					// Get the currency symbol, and skip to the end of the currency format
-					prefix += curReducedFormat[2:dashIndex]
-				} else {
-					return "", "", false, errors.New("invalid formatting code, invalid currency annotation")
+				return errors.New("invalid formatting code, invalid brackets")
+			}
+			content := rest[1:bracketIndex]
+			switch {
+			case strings.HasPrefix(content, "$"):
+				// Currencies in Excel are annotated with this format: [$<Currency String>-<Language Info>]
+				// Currency String is something like $, ¥, €, or £ and may be empty.
+				// Language Info is the hexadecimal LCID of the locale.
+				symbol := content[1:]
+				if dashIndex := strings.IndexByte(symbol, '-'); dashIndex != -1 {
+					section.parseLocale(symbol[dashIndex+1:])
+					symbol = symbol[:dashIndex]
 				}
+				if symbol != "" {
+					add(formatTokenLiteral, symbol)
+				}
+			case content != "" && strings.IndexByte("<>=", content[0]) != -1:
+				condition, err := parseFormatCondition(content)
+				if err != nil {
+					return err
+				}
+				section.condition = condition
+			case isElapsedTimeCode(content):
+				add(formatTokenElapsed, strings.ToLower(content))
+			default:
+				// Colors such as [Red] or [Color10], and other annotations, don't change the text.
 			}
-			i += bracketIndex
-		case '$', '-', '+', '/', '(', ')', ':', '!', '^', '&', '\'', '~', '{', '}', '<', '>', '=', ' ':
-			// These symbols are allowed to be used as literal without escaping
-			prefix += curReducedFormat[0:1]
-		default:
-			for _, special := range formattingCharacters {
-				if strings.HasPrefix(curReducedFormat, special) {
-					// This means we found the start of the actual number formatting portion, and should return.
-					return prefix, format[i:], showPercent, nil
+			i += bracketIndex + 1
+		case c == '0' || c == '#' || c == '?':
+			add(formatTokenDigit, rest[:1])
+			i++
+		case c == '.':
+			add(formatTokenDecimalPoint, ".")
+			i++
+		case c == ',':
+			add(formatTokenThousands, ",")
+			i++
+		case c == '%':
+			add(formatTokenPercent, "%")
+			i++
+		case c == '@':
+			add(formatTokenText, "@")
+			i++
+		case (c == 'E' || c == 'e') && len(rest) > 1 && (rest[1] == '+' || rest[1] == '-'):
+			add(formatTokenExponent, rest[:2])
+			i += 2
+		case c == '/' && len(tokens) > 0 && tokens[len(tokens)-1].typ == formatTokenDigit &&
+			len(rest) > 1 && strings.IndexByte("0#?123456789", rest[1]) != -1:
+			add(formatTokenFraction, "/")
+			i++
+			if rest[1] != '0' && rest[1] != '#' && rest[1] != '?' {
+				j := 1
+				for j < len(rest) && rest[j] >= '0' && rest[j] <= '9' {
+					j++
 				}
+				add(formatTokenDenominator, rest[1:j])
+				i += j - 1
+			}
+		case len(rest) >= 7 && strings.EqualFold(rest[:7], "general"):
+			add(formatTokenGeneral, rest[:7])
+			i += 7
+		case len(rest) >= 5 && strings.EqualFold(rest[:5], "am/pm"):
+			add(formatTokenAMPM, rest[:5])
+			i += 5
+		case len(rest) >= 3 && strings.EqualFold(rest[:3], "a/p"):
+			add(formatTokenAMPM, rest[:3])
+			i += 3
+		case (c == 'b' || c == 'B') && len(rest) > 1 && (rest[1] == '1' || rest[1] == '2'):
+			// B1 and B2 choose between the Hijri and Gregorian calendars. Only Gregorian dates are supported.
+			i += 2
+		case len(rest) >= 3 && strings.EqualFold(rest[:3], "aaa"):
+			// aaa and aaaa are the short and long names of the weekday, as used in Japanese formats.
+			j := 3
+			for j < len(rest) && (rest[j]|0x20) == 'a' {
+				j++
 			}
+			add(formatTokenDate, strings.ToLower(rest[:j]))
+			i += j
+		case strings.IndexByte("yYmMdDhHsSeg", c) != -1:
+			j := 1
+			for j < len(rest) && (rest[j]|0x20) == (c|0x20) {
+				j++
+			}
+			add(formatTokenDate, strings.ToLower(rest[:j]))
+			i += j
+		case c >= '1' && c <= '9', strings.IndexByte(formatLiteralCharacters, c) != -1:
+			add(formatTokenLiteral, rest[:1])
+			i++
+		case c >= utf8.RuneSelf:
+			_, size := utf8.DecodeRuneInString(rest)
+			add(formatTokenLiteral, rest[:size])
+			i += size
+		default:
 			// Symbols that don't have meaning and aren't in the exempt literal characters and are not escaped.
-			return "", "", false, errors.New("invalid formatting code: unsupported or unescaped characters")
+			return errors.New("invalid formatting code: unsupported or unescaped characters")
 		}
 	}
-	return prefix, "", showPercent, nil
+	section.tokens = tokens
+	return nil
 }
 
-// parseTime returns a string parsed using time.Time
-func (fullFormat *parsedNumberFormat) parseTime(value string, date1904 bool) (string, error) {
-	f, err := strconv.ParseFloat(value, 64)
+// parseLocale reads the language info of a currency annotation. The
+// upper bytes of an LCID choose a calendar and number system, which
+// are not supported, so only the lower 16 bits are kept.
+func (section *formatOptions) parseLocale(info string) {
+	switch strings.ToLower(info) {
+	case "x-sysdate":
+		section.systemDate = true
+		return
+	case "x-systime":
+		section.systemTime = true
+		return
+	}
+	lcid, err := strconv.ParseUint(info, 16, 32)
 	if err != nil {
-		return value, err
+		return
 	}
-	val := TimeFromExcelTime(f, date1904)
-	format := fullFormat.numFmt
-	// Replace Excel placeholders with Go time placeholders.
-	// For example, replace yyyy with 2006. These are in a specific order,
-	// due to the fact that m is used in month, minute, and am/pm. It would
-	// be easier to fix that with regular expressions, but if it's possible
-	// to keep this simple it would be easier to maintain.
-	// Full-length month and days (e.g. March, Tuesday) have letters in them that would be replaced
-	// by other characters below (such as the 'h' in March, or the 'd' in Tuesday) below.
-	// First we convert them to arbitrary characters unused in Excel Date formats, and then at the end,
-	// turn them to what they should actually be.
-	// Based off: http://www.ozgrid.com/Excel/CustomFormats.htm
-	replacements := []struct{ xltime, gotime string }{
-		{"yyyy", "2006"},
-		{"yy", "06"},
-		{"mmmm", "%%%%"},
-		{"dddd", "&&&&"},
-		{"dd", "02"},
-		{"d", "2"},
-		{"mmm", "Jan"},
-		{"mmss", "0405"},
-		{"ss", "05"},
-		{"mm:", "04:"},
-		{":mm", ":04"},
-		{"mm", "01"},
-		{"am/pm", "pm"},
-		{"m/", "1/"},
-		{"%%%%", "January"},
-		{"&&&&", "Monday"},
-	}
-	// It is the presence of the "am/pm" indicator that determins
-	// if this is a 12 hour or 24 hours time format, not the
-	// number of 'h' characters.
-	if is12HourTime(format) {
-		format = strings.Replace(format, "hh", "03", 1)
-		format = strings.Replace(format, "h", "3", 1)
-	} else {
-		format = strings.Replace(format, "hh", "15", 1)
-		format = strings.Replace(format, "h", "15", 1)
-	}
-	for _, repl := range replacements {
-		format = strings.Replace(format, repl.xltime, repl.gotime, 1)
-	}
-	// If the hour is optional, strip it out, along with the
-	// possible dangling colon that would remain.
-	if val.Hour() < 1 {
-		format = strings.Replace(format, "]:", "]", 1)
-		format = strings.Replace(format, "[03]", "", 1)
-		format = strings.Replace(format, "[3]", "", 1)
-		format = strings.Replace(format, "[15]", "", 1)
+	switch lcid &= 0xFFFF; lcid {
+	case 0xF800:
+		section.systemDate = true
+	case 0xF400:
+		section.systemTime = true
+	default:
+		section.lcid = int(lcid)
+	}
+}
+
+// locale returns the locale the section is shown in: the one it
+// names, if that is registered, or else the given one.
+func (section *formatOptions) locale(locale *Locale) *Locale {
+	if section.lcid != 0 {
+		if named := LocaleByLCID(section.lcid); named != nil {
+			return named
+		}
+	}
+	if locale == nil {
+		return LocaleEnUS
+	}
+	return locale
+}
+
+func parseFormatCondition(condition string) (*formatCondition, error) {
+	operator := condition[:1]
+	for _, op := range []string{"<=", ">=", "<>"} {
+		if strings.HasPrefix(condition, op) {
+			operator = op
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(condition[len(operator):]), 64)
+	if err != nil {
+		return nil, errors.New("invalid formatting code, invalid condition")
+	}
+	return &formatCondition{operator: operator, value: value}, nil
+}
+
+// isElapsedTimeCode reports whether the contents of brackets are an
+// elapsed time code such as h, mm or ss.
+func isElapsedTimeCode(code string) bool {
+	if code == "" {
+		return false
+	}
+	first := code[0] | 0x20
+	if first != 'h' && first != 'm' && first != 's' {
+		return false
+	}
+	for i := 1; i < len(code); i++ {
+		if code[i]|0x20 != first {
+			return false
+		}
+	}
+	return true
+}
+
+func (section *formatOptions) hasText() bool {
+	for _, token := range section.tokens {
+		if token.typ == formatTokenText {
+			return true
+		}
+	}
+	return false
+}
+
+// analyseNumberTokens finds the layout of a number section: which
+// digit placeholders hold the integer, the decimals, the exponent or
+// the parts of a fraction, and what the commas do.
+func (section *formatOptions) analyseNumberTokens() {
+	tokens := section.tokens
+	for i, token := range tokens {
+		switch token.typ {
+		case formatTokenPercent:
+			section.percent++
+		case formatTokenExponent:
+			if section.exponent == -1 {
+				section.exponent = i
+			}
+		case formatTokenFraction:
+			if section.fraction == -1 {
+				section.fraction = i
+			}
+		}
+	}
+
+	// The mantissa is everything before the exponent, or the integer
+	// part of a fraction.
+	mantissaEnd := len(tokens)
+	switch {
+	case section.exponent != -1:
+		section.fraction = -1
+		mantissaEnd = section.exponent
+		for i := section.exponent + 1; i < len(tokens); i++ {
+			if tokens[i].typ == formatTokenDigit {
+				section.exponentDigits = append(section.exponentDigits, i)
+			}
+		}
+	case section.fraction != -1:
+		mantissaEnd = section.fraction
+		for mantissaEnd > 0 && tokens[mantissaEnd-1].typ == formatTokenDigit {
+			mantissaEnd--
+		}
+		for i := mantissaEnd; i < section.fraction; i++ {
+			section.numerator = append(section.numerator, i)
+		}
+		for i := section.fraction + 1; i < len(tokens); i++ {
+			if tokens[i].typ == formatTokenDenominator {
+				section.fixedDenominator, _ = strconv.Atoi(tokens[i].text)
+				break
+			}
+			if tokens[i].typ != formatTokenDigit {
+				break
+			}
+			section.denominator = append(section.denominator, i)
+		}
+	}
+	for i := 0; i < mantissaEnd; i++ {
+		switch tokens[i].typ {
+		case formatTokenDigit:
+			if section.point == -1 || section.fraction != -1 {
+				section.integer = append(section.integer, i)
+			} else {
+				section.decimals = append(section.decimals, i)
+			}
+		case formatTokenDecimalPoint:
+			if section.point == -1 && section.fraction == -1 {
+				section.point = i
+			} else {
+				tokens[i].typ = formatTokenLiteral
+			}
+		}
+	}
+
+	// A comma between integer digits groups thousands, and commas that
+	// follow the last digit scale the value by a thousand each. Other
+	// commas are literal.
+	digitsBefore, digitsAfter := 0, len(section.integer)+len(section.decimals)
+	integerAfter := len(section.integer)
+	for i := 0; i < mantissaEnd; i++ {
+		switch tokens[i].typ {
+		case formatTokenDigit:
+			digitsBefore++
+			digitsAfter--
+			if section.point == -1 || i < section.point {
+				integerAfter--
+			}
+		case formatTokenThousands:
+			switch {
+			case digitsBefore == 0:
+				tokens[i].typ = formatTokenLiteral
+			case integerAfter > 0 && (section.point == -1 || i < section.point):
+				section.grouping = true
+			case digitsAfter == 0:
+				section.scale++
+			}
+		}
+	}
+	for i := mantissaEnd; i < len(tokens); i++ {
+		if tokens[i].typ == formatTokenThousands {
+			tokens[i].typ = formatTokenLiteral
+		}
+	}
+}
+
+// analyseDateTokens tells minutes from months, and joins fractions of
+// a second into a single token. An m or mm is minutes when it follows
+// hours or precedes seconds.
+func (section *formatOptions) analyseDateTokens() {
+	var tokens []formatToken
+	for i := 0; i < len(section.tokens); i++ {
+		token := section.tokens[i]
+		if token.typ == formatTokenDecimalPoint {
+			j := i + 1
+			for j < len(section.tokens) && section.tokens[j].typ == formatTokenDigit && section.tokens[j].text == "0" {
+				j++
+			}
+			if j > i+1 {
+				token = formatToken{typ: formatTokenSubsecond, text: "." + strings.Repeat("0", j-i-1)}
+				i = j - 1
+			}
+		}
+		tokens = append(tokens, token)
+	}
+	section.tokens = tokens
+	for i, token := range tokens {
+		if token.typ == formatTokenDate && token.text[0] == 'm' && len(token.text) <= 2 &&
+			(section.adjacentDatePart(i, -1) == 'h' || section.adjacentDatePart(i, 1) == 's') {
+			tokens[i].typ = formatTokenMinute
+		}
+	}
+}
+
+// adjacentDatePart returns the letter of the nearest date or time part
+// before (step -1) or after (step 1) the token at index i.
+func (section *formatOptions) adjacentDatePart(i, step int) byte {
+	for j := i + step; j >= 0 && j < len(section.tokens); j += step {
+		switch token := section.tokens[j]; token.typ {
+		case formatTokenDate, formatTokenElapsed:
+			return token.text[0]
+		case formatTokenMinute:
+			return 'm'
+		}
+	}
+	return 0
+}
+
+// formatNumber renders a value that is not negative with a number
+// section.
+func (section *formatOptions) formatNumber(value float64, locale *Locale) string {
+	digits, point := decimalDigits(value)
+	point += 2*section.percent - 3*section.scale
+	parts := make([]string, len(section.tokens))
+	switch {
+	case section.exponent != -1:
+		section.formatScientific(parts, digits, point, locale)
+	case section.fraction != -1:
+		scaled := value * math.Pow(100, float64(section.percent)) / math.Pow(1000, float64(section.scale))
+		section.formatFraction(parts, scaled, locale)
+	default:
+		integer, decimals := roundDigits(digits, point, len(section.decimals))
+		section.formatDecimal(parts, integer, decimals, locale)
+	}
+
+	var b strings.Builder
+	for i, token := range section.tokens {
+		switch token.typ {
+		case formatTokenLiteral, formatTokenPercent:
+			b.WriteString(token.text)
+		case formatTokenGeneral, formatTokenText:
+			general, _ := generalNumericScientific(strconv.FormatFloat(value, 'f', -1, 64), true)
+			b.WriteString(strings.Replace(general, ".", locale.DecimalSeparator, 1))
+		default:
+			b.WriteString(parts[i])
+		}
+	}
+	return b.String()
+}
+
+func (section *formatOptions) formatDecimal(parts []string, integer, decimals string, locale *Locale) {
+	if section.point != -1 {
+		parts[section.point] = locale.DecimalSeparator
+		if len(section.integer) == 0 {
+			// Without integer placeholders, the integer is still shown.
+			parts[section.point] = integer + locale.DecimalSeparator
+		}
+	}
+	section.fillInteger(parts, section.integer, integer, section.groupingSeparator(locale))
+
+	// Trailing zeros are dropped for # and become spaces for ?.
+	shown := len(section.decimals)
+	for shown > 0 && decimals[shown-1] == '0' && section.tokens[section.decimals[shown-1]].text != "0" {
+		shown--
+	}
+	for k, index := range section.decimals {
+		switch {
+		case k < shown:
+			parts[index] = decimals[k : k+1]
+		case section.tokens[index].text == "?":
+			parts[index] = " "
+		}
+	}
+}
+
+func (section *formatOptions) formatScientific(parts []string, digits []byte, point int, locale *Locale) {
+	integerCount := len(section.integer)
+	// Engineering notation such as ##0.0E+0 keeps the exponent a
+	// multiple of the number of integer placeholders.
+	engineering := integerCount > 1 && section.tokens[section.integer[0]].text == "#"
+	exponent, step := 0, 1
+	if digits[0] != '0' {
+		exponent = point - 1
+		switch {
+		case engineering:
+			step = integerCount
+			exponent = int(math.Floor(float64(exponent)/float64(step))) * step
+		case integerCount > 0:
+			exponent -= integerCount - 1
+		default:
+			exponent++
+		}
+	}
+	integer, decimals := roundDigits(digits, point-exponent, len(section.decimals))
+	if len(integer) > integerCount && (integerCount > 0 || integer != "") {
+		// Rounding carried into another digit, as in 9.99 to 10.0.
+		exponent += step
+		integer, decimals = roundDigits(digits, point-exponent, len(section.decimals))
+	}
+	section.formatDecimal(parts, integer, decimals, locale)
+
+	mark := section.tokens[section.exponent].text
+	parts[section.exponent] = mark[:1]
+	if exponent < 0 {
+		parts[section.exponent] += "-"
+		exponent = -exponent
+	} else if mark[1] == '+' {
+		parts[section.exponent] += "+"
+	}
+	section.fillInteger(parts, section.exponentDigits, strconv.Itoa(exponent), "")
+}
+
+func (section *formatOptions) formatFraction(parts []string, value float64, locale *Locale) {
+	whole, fraction := 0.0, value
+	if len(section.integer) > 0 {
+		whole = math.Floor(value)
+		fraction = value - whole
+	}
+	var numerator, denominator int
+	if section.fixedDenominator > 0 {
+		denominator = section.fixedDenominator
+		numerator = int(math.Floor(fraction*float64(denominator) + 0.5))
 	} else {
-		format = strings.Replace(format, "[3]", "3", 1)
-		format = strings.Replace(format, "[15]", "15", 1)
+		maxDenominator := int(math.Pow10(len(section.denominator))) - 1
+		numerator, denominator = approximateFraction(fraction, maxDenominator)
+	}
+	if len(section.integer) > 0 && numerator == denominator {
+		whole++
+		numerator = 0
+	}
+
+	if len(section.integer) > 0 && numerator == 0 {
+		// A whole number is shown without its fraction, which is left
+		// as blank space to keep fractions lined up.
+		section.fillInteger(parts, section.integer, strconv.FormatFloat(whole, 'f', 0, 64), section.groupingSeparator(locale))
+		parts[section.fraction] = " "
+		for _, index := range append(append([]int{}, section.numerator...), section.denominator...) {
+			if section.tokens[index].text != "#" {
+				parts[index] = " "
+			}
+		}
+		if section.fixedDenominator > 0 {
+			parts[section.fraction+1] = strings.Repeat(" ", len(section.tokens[section.fraction+1].text))
+		}
+		return
+	}
+	var integer string
+	if whole > 0 {
+		integer = strconv.FormatFloat(whole, 'f', 0, 64)
+	}
+	section.fillInteger(parts, section.integer, integer, section.groupingSeparator(locale))
+	section.fillInteger(parts, section.numerator, strconv.Itoa(numerator), "")
+	parts[section.fraction] = "/"
+	if section.fixedDenominator > 0 {
+		parts[section.fraction+1] = section.tokens[section.fraction+1].text
+		return
+	}
+	// The denominator is aligned to the left.
+	digits := strconv.Itoa(denominator)
+	for k, index := range section.denominator {
+		switch {
+		case k == len(section.denominator)-1 && k < len(digits):
+			parts[index] = digits[k:]
+		case k < len(digits):
+			parts[index] = digits[k : k+1]
+		case section.tokens[index].text == "0":
+			parts[index] = "0"
+		case section.tokens[index].text == "?":
+			parts[index] = " "
+		}
 	}
-	return val.Format(format), nil
 }
 
-// isTimeFormat checks whether an Excel format string represents a time.Time.
-// This function is now correct, but it can detect time format strings that cannot be correctly handled by parseTime()
-func isTimeFormat(format string) bool {
-	var foundTimeFormatCharacters bool
-	for i := 0; i < len(format); i++ {
-		curReducedFormat := format[i:]
-		switch curReducedFormat[0] {
-		case '\\', '_':
-			// If there is a slash, skip the next character, and add it to the prefix
-			// If there is an underscore, skip the next character, but don't add it to the prefix
-			if len(curReducedFormat) > 1 {
-				i++
-			}
-		case '*':
-			// Asterisks are used to repeat the next character to fill the full cell width.
-			// There isn't really a cell size in this context, so this will be ignored.
-		case '"':
-			// If there is a quote skip to the next quote, and add the quoted characters to the prefix
-			endQuoteIndex := strings.Index(curReducedFormat[1:], "\"")
-			if endQuoteIndex == -1 {
-				// This is not any type of valid format.
-				return false
+// groupingSeparator returns the separator of thousands in the
+// section, or "" if they are not grouped.
+func (section *formatOptions) groupingSeparator(locale *Locale) string {
+	if !section.grouping {
+		return ""
+	}
+	return locale.ThousandsSeparator
+}
+
+// fillInteger places the digits of an integer in digit placeholders,
+// aligned to the right. The first placeholder takes any digits that
+// are left over, and placeholders without a digit show 0 for 0, a
+// space for ? and nothing for #. Thousands are grouped with the
+// separator, unless it is "".
+func (section *formatOptions) fillInteger(parts []string, indexes []int, digits string, separator string) {
+	for k := len(indexes) - 1; k >= 0; k-- {
+		index := indexes[k]
+		switch {
+		case digits == "":
+			switch section.tokens[index].text {
+			case "0":
+				parts[index] = "0"
+			case "?":
+				parts[index] = " "
 			}
-			i += endQuoteIndex + 1
-		case '$', '-', '+', '/', '(', ')', ':', '!', '^', '&', '\'', '~', '{', '}', '<', '>', '=', ' ':
-			// These symbols are allowed to be used as literal without escaping
-		case ',':
-			// This is not documented in the XLSX spec as far as I can tell, but Excel and Numbers will include
-			// commas in number formats without escaping them, so this should be supported.
+		case k == 0:
+			parts[index] = digits
+			digits = ""
 		default:
-			foundInThisLoop := false
-			for _, special := range timeFormatCharacters {
-				if strings.HasPrefix(curReducedFormat, special) {
-					foundTimeFormatCharacters = true
-					foundInThisLoop = true
-					i += len(special) - 1
-					break
+			parts[index] = digits[len(digits)-1:]
+			digits = digits[:len(digits)-1]
+		}
+	}
+	if separator == "" {
+		return
+	}
+	count := 0
+	for k := len(indexes) - 1; k >= 0; k-- {
+		part := parts[indexes[k]]
+		var grouped string
+		for j := len(part) - 1; j >= 0; j-- {
+			if part[j] >= '0' && part[j] <= '9' {
+				if count > 0 && count%3 == 0 {
+					grouped = separator + grouped
 				}
+				count++
 			}
-			if foundInThisLoop {
-				continue
-			}
-			if curReducedFormat[0] == '[' {
-				// For number formats, this code would happen above in a case '[': section.
-				// However, for time formats it must happen after looking for occurrences in timeFormatCharacters
-				// because there are a few time formats that can be wrapped in brackets.
-
-				// Brackets can be currency annotations (e.g. [$$-409])
-				// color formats (e.g. [color1] through [color56], as well as [red] etc.)
-				// conditionals (e.g. [>100], the valid conditionals are =, >, <, >=, <=, <>)
-				bracketIndex := strings.Index(curReducedFormat, "]")
-				if bracketIndex == -1 {
-					// This is not any type of valid format.
-					return false
+			grouped = part[j:j+1] + grouped
+		}
+		parts[indexes[k]] = grouped
+	}
+}
+
+// approximateFraction finds the fraction closest to a value with a
+// denominator no larger than maxDenominator.
+func approximateFraction(value float64, maxDenominator int) (int, int) {
+	numerator, denominator := int(math.Floor(value+0.5)), 1
+	best := math.Abs(value - float64(numerator))
+	for d := 2; d <= maxDenominator && best > 0; d++ {
+		n := int(math.Floor(value*float64(d) + 0.5))
+		if diff := math.Abs(value - float64(n)/float64(d)); diff < best {
+			numerator, denominator, best = n, d, diff
+		}
+	}
+	return numerator, denominator
+}
+
+// decimalDigits returns the decimal digits of a value that is not
+// negative, rounded to the 15 significant digits that Excel keeps,
+// and the position of the decimal point in them.
+func decimalDigits(value float64) ([]byte, int) {
+	if value == 0 {
+		return []byte{'0'}, 1
+	}
+	s := strconv.FormatFloat(value, 'e', 14, 64)
+	e := strings.IndexByte(s, 'e')
+	exponent, _ := strconv.Atoi(s[e+1:])
+	digits := []byte(s[:1] + s[2:e])
+	return digits, exponent + 1
+}
+
+// roundDigits rounds decimal digits half away from zero to a number of
+// decimal places. It returns the integer part, without leading zeros,
+// and exactly places decimals.
+func roundDigits(digits []byte, point, places int) (string, string) {
+	if point < 0 {
+		digits = append(make([]byte, -point), digits...)
+		for i := 0; i < -point; i++ {
+			digits[i] = '0'
+		}
+		point = 0
+	} else {
+		digits = append([]byte{}, digits...)
+	}
+	if keep := point + places; keep < len(digits) {
+		roundUp := digits[keep] >= '5'
+		digits = digits[:keep]
+		if roundUp {
+			i := keep - 1
+			for ; i >= 0 && digits[i] == '9'; i-- {
+				digits[i] = '0'
+			}
+			if i >= 0 {
+				digits[i]++
+			} else {
+				digits = append([]byte{'1'}, digits...)
+				point++
+			}
+		}
+	}
+	for len(digits) < point+places {
+		digits = append(digits, '0')
+	}
+	return strings.TrimLeft(string(digits[:point]), "0"), string(digits[point:])
+}
+
+// formatDate renders a value that is not negative with a date section.
+// The system date and time formats are replaced by the long formats of
+// the locale.
+func (section *formatOptions) formatDate(value float64, date1904 bool, locale *Locale) string {
+	if section.systemDate || section.systemTime {
+		format := locale.LongDateFormat
+		if section.systemTime {
+			format = locale.LongTimeFormat
+		}
+		if system, err := parseNumberFormatTokens(format); err == nil && system.isTimeFormat {
+			return system.formatDate(value, date1904, locale)
+		}
+	}
+	// Excel rounds to the fraction of a second that is shown, or to
+	// the second.
+	precision := 0
+	for _, token := range section.tokens {
+		if token.typ == formatTokenSubsecond && len(token.text)-1 > precision {
+			precision = len(token.text) - 1
+		}
+	}
+	unit := int64(math.Pow10(precision))
+	ticks := int64(math.Floor(value*secondsInADay*float64(unit) + 0.5))
+	days, rem := ticks/(int64(secondsInADay)*unit), ticks%(int64(secondsInADay)*unit)
+	date := TimeFromExcelTime(float64(days), date1904).Round(time.Hour)
+	hour := int(rem / (3600 * unit))
+	minute := int(rem / (60 * unit) % 60)
+	second := int(rem / unit % 60)
+	subsecond := rem % unit
+
+	twelveHour := false
+	for _, token := range section.tokens {
+		if token.typ == formatTokenAMPM {
+			twelveHour = true
+		}
+	}
+
+	var b strings.Builder
+	for _, token := range section.tokens {
+		n := len(token.text)
+		switch token.typ {
+		case formatTokenDate:
+			switch token.text[0] {
+			case 'y', 'e':
+				if token.text[0] == 'y' && n <= 2 {
+					fmt.Fprintf(&b, "%02d", date.Year()%100)
+				} else {
+					fmt.Fprintf(&b, "%04d", date.Year())
+				}
+			case 'm':
+				switch n {
+				case 1, 2:
+					fmt.Fprintf(&b, "%0*d", n, int(date.Month()))
+				case 3:
+					b.WriteString(locale.MonthAbbreviations[date.Month()-1])
+				case 5:
+					name := []rune(locale.MonthNames[date.Month()-1])
+					b.WriteString(string(name[:1]))
+				default:
+					b.WriteString(locale.MonthNames[date.Month()-1])
+				}
+			case 'd':
+				switch n {
+				case 1, 2:
+					fmt.Fprintf(&b, "%0*d", n, date.Day())
+				case 3:
+					b.WriteString(locale.DayAbbreviations[date.Weekday()])
+				default:
+					b.WriteString(locale.DayNames[date.Weekday()])
+				}
+			case 'a':
+				if n == 3 {
+					b.WriteString(locale.DayAbbreviations[date.Weekday()])
+				} else {
+					b.WriteString(locale.DayNames[date.Weekday()])
+				}
+			case 'h':
+				h := hour
+				if twelveHour {
+					if h = hour % 12; h == 0 {
+						h = 12
+					}
 				}
-				i += bracketIndex
-				continue
+				fmt.Fprintf(&b, "%0*d", minInt(n, 2), h)
+			case 's':
+				fmt.Fprintf(&b, "%0*d", minInt(n, 2), second)
 			}
-			// Symbols that don't have meaning, aren't in the exempt literal characters, and aren't escaped are invalid.
-			// The string could still be a valid number format string.
-			return false
+			// g, the era, is empty for Gregorian dates.
+		case formatTokenMinute:
+			fmt.Fprintf(&b, "%0*d", n, minute)
+		case formatTokenElapsed:
+			elapsed := ticks / unit
+			switch token.text[0] {
+			case 'h':
+				elapsed /= 3600
+			case 'm':
+				elapsed /= 60
+			}
+			fmt.Fprintf(&b, "%0*d", n, elapsed)
+		case formatTokenSubsecond:
+			fmt.Fprintf(&b, "%s%0*d", locale.DecimalSeparator, n-1, subsecond/int64(math.Pow10(precision-n+1)))
+		case formatTokenAMPM:
+			half := strings.Split(token.text, "/")
+			marker := locale.AM
+			if hour >= 12 {
+				half = half[1:]
+				marker = locale.PM
+			}
+			if marker == "" || strings.EqualFold(half[0], marker) || len(half[0]) == 1 && strings.EqualFold(half[0], marker[:1]) {
+				// The text is written in the case of the format, so
+				// am/pm gives am or pm.
+				b.WriteString(half[0])
+			} else if len(half[0]) == 1 {
+				b.WriteString(string([]rune(marker)[:1]))
+			} else {
+				b.WriteString(marker)
+			}
+		default:
+			b.WriteString(token.text)
+		}
+	}
+	return b.String()
+}
+
+// formatText renders a string with a text section, in which @ stands
+// for the string.
+func (section *formatOptions) formatText(value string) (string, error) {
+	var b strings.Builder
+	for _, token := range section.tokens {
+		switch token.typ {
+		case formatTokenLiteral, formatTokenThousands:
+			b.WriteString(token.text)
+		case formatTokenText:
+			b.WriteString(value)
+		default:
+			return value, errors.New("invalid or unsupported format, unsupported string format")
 		}
 	}
-	// If the string doesn't have any time formatting characters, it could technically be a time format, but it
-	// would be a pretty weak time format. A valid time format with no time formatting symbols will also be a number
-	// format with no number formatting symbols, which is essentially a constant string that does not depend on the
-	// cell's value in anyway. The downstream logic will do the right thing in that case if this returns false.
-	return foundTimeFormatCharacters
+	return b.String(), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// isTimeFormat checks whether an Excel format string represents a time.Time.
+func isTimeFormat(format string) bool {
+	return parseFullNumberFormatString(format).isTimeFormat
 }
 
 // is12HourTime checks whether an Excel time format string is a 12
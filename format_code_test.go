@@ -105,7 +105,7 @@ func (l *CellSuite) TestFormatStringSupport(c *C) {
 		{
 			formatString:         "_[0",
 			value:                "18.989999999999998",
-			formattedValueOutput: "19",
+			formattedValueOutput: " 19",
 			cellType:             CellTypeNumeric,
 		},
 		{
@@ -240,3 +240,115 @@ func (l *CellSuite) TestFormatStringSupport(c *C) {
 		}
 	}
 }
+
+func (l *CellSuite) TestFormatRenderer(c *C) {
+	testCases := []struct {
+		formatString         string
+		value                string
+		formattedValueOutput string
+	}{
+		// Digit placeholders, grouping and scaling commas.
+		{`#,##0.00`, "1234567.891", "1,234,567.89"},
+		{`#,##0`, "-999.5", "-1,000"},
+		{`#,##0,`, "1234567", "1,235"},
+		{`0.0,,"M"`, "1234567", "1.2M"},
+		{`#.##`, "0.5", ".5"},
+		{`0.0?`, "1.5", "1.5 "},
+		{`???.??`, "1.5", "  1.5 "},
+		{`.00`, "5.5", "5.50"},
+		{`000\-00\-0000`, "123456789", "123-45-6789"},
+		{`(###) ###-####`, "5555551234", "(555) 555-1234"},
+		{`0 "items"`, "3", "3 items"},
+		// Excel rounds half away from zero, on the 15 significant
+		// digits it keeps.
+		{`0`, "2.5", "3"},
+		{`0.00`, "0.125", "0.13"},
+		{`0.00`, "1.005", "1.01"},
+		{`0`, "-0.001", "-0"},
+		// Percent.
+		{`0.0%`, "0.0525", "5.3%"},
+		// Scientific and engineering notation.
+		{`0.00E+00`, "37947.75", "3.79E+04"},
+		{`0.00E+00`, "0.000123", "1.23E-04"},
+		{`0.00E-00`, "37947.75", "3.79E04"},
+		{`0.0E+0`, "999.99", "1.0E+3"},
+		{`00.00E+00`, "12345", "12.35E+03"},
+		{`##0.0E+0`, "0.000123", "123.0E-6"},
+		{`##0.0E+0`, "1234567", "1.2E+6"},
+		{`0.00E+00`, "0", "0.00E+00"},
+		// Fractions.
+		{`# ?/?`, "1.25", "1 1/4"},
+		{`# ?/?`, "0.5", " 1/2"},
+		{`# ?/?`, "5", "5    "},
+		{`# ??/??`, "3.3333", "3  1/3 "},
+		{`# ???/???`, "3.14159", "3  16/113"},
+		{`?/?`, "1.75", "7/4"},
+		{`# ?/8`, "2.3", "2 2/8"},
+		{`# ??/100`, "0.37", " 37/100"},
+		// Conditions and colors.
+		{`[>=1000000]0.0,,"M";[>=1000]0.0,"K";0`, "1234567", "1.2M"},
+		{`[>=1000000]0.0,,"M";[>=1000]0.0,"K";0`, "4321", "4.3K"},
+		{`[>=1000000]0.0,,"M";[>=1000]0.0,"K";0`, "12", "12"},
+		{`[<=9999999]###-####;(###) ###-####`, "5551234", "555-1234"},
+		{`[<=9999999]###-####;(###) ###-####`, "5555551234", "(555) 555-1234"},
+		{`[Red][<0]0;[Blue]0`, "-5", "-5"},
+		{`$#,##0.00_);[Red]($#,##0.00)`, "-1234.5", "($1,234.50)"},
+		{`$#,##0.00_);[Red]($#,##0.00)`, "1234.5", "$1,234.50 "},
+		{`0_)`, "5", "5 "},
+		{`* #,##0`, "5", "5"},
+		{`[$€-407] #,##0.00`, "1234.5", "€ 1.234,50"},
+		{`0;-0;;@`, "0", ""},
+		{`General" kg"`, "12", "12 kg"},
+		// Dates and times.
+		{`yyyy-mm-dd;@`, "43831", "2020-01-01"},
+		{`mmm-yy hh:mm`, "43831.75", "Jan-20 18:00"},
+		{`dddd, mmmm d, yyyy h AM/PM`, "43831.5", "Wednesday, January 1, 2020 12 PM"},
+		{`ddd d mmmmm`, "43831", "Wed 1 J"},
+		{`h:mm A/P`, "43831.25", "6:00 A"},
+		{`h:mm a/p`, "43831.75", "6:00 p"},
+		{`h:mm:ss.00`, "0.5000001", "12:00:00.01"},
+		{`h:mm`, "0.99999", "23:59"},
+		{`hh:mm:ss`, "0.99999999", "00:00:00"},
+		{`[h]:mm`, "1.5", "36:00"},
+		{`[mm]:ss`, "0.0625", "90:00"},
+		{`YYYY-MM-DD`, "43831", "2020-01-01"},
+		{`"Q"0 yyyy`, "43831", "Q0 2020"},
+	}
+	for _, testCase := range testCases {
+		cell := &Cell{
+			cellType: CellTypeNumeric,
+			NumFmt:   testCase.formatString,
+			Value:    testCase.value,
+		}
+		val, err := cell.FormattedValue()
+		c.Assert(err, IsNil, Commentf("%s", testCase.formatString))
+		c.Assert(val, Equals, testCase.formattedValueOutput, Commentf("%s %s", testCase.formatString, testCase.value))
+	}
+
+	// Built in formats are shown the way Excel shows them.
+	for id, expected := range map[int]string{
+		3:  "1,235",
+		4:  "1,234.50",
+		12: "1234 1/2",
+		37: "1,235 ",
+		41: " 1,235 ",
+		42: " $1,235 ",
+		44: " $1,234.50 ",
+		48: "1.2e+3",
+	} {
+		cell := &Cell{cellType: CellTypeNumeric, NumFmt: builtInNumFmt[id], Value: "1234.5"}
+		val, err := cell.FormattedValue()
+		c.Assert(err, IsNil)
+		c.Assert(val, Equals, expected, Commentf("built in format %d", id))
+	}
+
+	// Negative dates can't be shown.
+	cell := &Cell{cellType: CellTypeNumeric, NumFmt: "yyyy-mm-dd", Value: "-1"}
+	val, err := cell.FormattedValue()
+	c.Assert(err, ErrorMatches, "invalid value for a date or time format, the value is negative")
+	c.Assert(val, Equals, "-1")
+
+	c.Assert(isTimeFormat("yyyy-mm-dd;@"), Equals, true)
+	c.Assert(isTimeFormat(`0.00E+00`), Equals, false)
+	c.Assert(isTimeFormat(`"mm"0`), Equals, false)
+}
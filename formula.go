@@ -0,0 +1,1173 @@
+package xlsx
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The error values that a formula can evaluate to.  These are the
+// literal strings Excel stores in the cached value of a cell with
+// the "e" (error) type.
+const (
+	formulaErrorDiv0  = "#DIV/0!"
+	formulaErrorNA    = "#N/A"
+	formulaErrorName  = "#NAME?"
+	formulaErrorNull  = "#NULL!"
+	formulaErrorNum   = "#NUM!"
+	formulaErrorRef   = "#REF!"
+	formulaErrorValue = "#VALUE!"
+)
+
+var formulaErrorLiterals = []string{
+	formulaErrorDiv0,
+	formulaErrorNA,
+	formulaErrorName,
+	formulaErrorNull,
+	formulaErrorNum,
+	formulaErrorRef,
+	formulaErrorValue,
+}
+
+// The largest row and column indexes (zero based) that a worksheet
+// can address.
+const (
+	formulaMaxRow = 1048575
+	formulaMaxCol = 16383
+)
+
+//
+// Lexing
+//
+
+type formulaTokenType int
+
+const (
+	formulaTokenEOF formulaTokenType = iota
+	formulaTokenNumber
+	formulaTokenString
+	formulaTokenBool
+	formulaTokenError
+	formulaTokenRef
+	formulaTokenName
+	formulaTokenFunc
+	formulaTokenOperator
+	formulaTokenOpenParen
+	formulaTokenCloseParen
+	formulaTokenComma
+)
+
+type formulaToken struct {
+	kind  formulaTokenType
+	value string
+	// sheet is only set for references that are qualified with a
+	// sheet name, i.e. Sheet1!A1.
+	sheet string
+}
+
+var (
+	formulaSheetPrefixRegexp = regexp.MustCompile(`^(?:'((?:[^']|'')+)'|([A-Za-z0-9_.]+))!`)
+	formulaCellRangeRegexp   = regexp.MustCompile(`^\$?[A-Za-z]{1,3}\$?[0-9]+(?::\$?[A-Za-z]{1,3}\$?[0-9]+)?`)
+	formulaColRangeRegexp    = regexp.MustCompile(`^\$?[A-Za-z]{1,3}:\$?[A-Za-z]{1,3}`)
+	formulaRowRangeRegexp    = regexp.MustCompile(`^\$?[0-9]+:\$?[0-9]+`)
+	formulaNumberRegexp      = regexp.MustCompile(`^(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?`)
+	formulaNameRegexp        = regexp.MustCompile(`^[A-Za-z_\\][A-Za-z0-9_.\\]*`)
+)
+
+// isFormulaNameChar returns true for the characters that may
+// continue a name, and so must not directly follow a reference.
+func isFormulaNameChar(b byte) bool {
+	switch {
+	case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9':
+		return true
+	case b == '_', b == '.', b == '(', b == '!':
+		return true
+	}
+	return false
+}
+
+// matchFormulaRef returns the length of the reference at the start
+// of s, or 0 if s doesn't start with a reference.
+func matchFormulaRef(s string) int {
+	for _, re := range []*regexp.Regexp{formulaRowRangeRegexp, formulaCellRangeRegexp, formulaColRangeRegexp} {
+		loc := re.FindStringIndex(s)
+		if loc == nil {
+			continue
+		}
+		if loc[1] < len(s) && isFormulaNameChar(s[loc[1]]) {
+			continue
+		}
+		return loc[1]
+	}
+	return 0
+}
+
+// tokenizeFormula splits the text of a formula, as stored in the f
+// element of a cell, into tokens.
+func tokenizeFormula(formula string) ([]formulaToken, error) {
+	var tokens []formulaToken
+	s := strings.TrimPrefix(formula, "=")
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			s = s[1:]
+		case c == '"':
+			var str strings.Builder
+			i := 1
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated string in formula %q", formula)
+				}
+				if s[i] == '"' {
+					if i+1 < len(s) && s[i+1] == '"' {
+						str.WriteByte('"')
+						i += 2
+						continue
+					}
+					break
+				}
+				str.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, formulaToken{kind: formulaTokenString, value: str.String()})
+			s = s[i+1:]
+		case c == '#':
+			lit := ""
+			for _, l := range formulaErrorLiterals {
+				if strings.HasPrefix(strings.ToUpper(s), l) {
+					lit = l
+					break
+				}
+			}
+			if lit == "" {
+				return nil, fmt.Errorf("invalid error literal in formula %q", formula)
+			}
+			tokens = append(tokens, formulaToken{kind: formulaTokenError, value: lit})
+			s = s[len(lit):]
+		case c == '(':
+			tokens = append(tokens, formulaToken{kind: formulaTokenOpenParen, value: "("})
+			s = s[1:]
+		case c == ')':
+			tokens = append(tokens, formulaToken{kind: formulaTokenCloseParen, value: ")"})
+			s = s[1:]
+		case c == ',':
+			tokens = append(tokens, formulaToken{kind: formulaTokenComma, value: ","})
+			s = s[1:]
+		case strings.HasPrefix(s, "<=") || strings.HasPrefix(s, ">=") || strings.HasPrefix(s, "<>"):
+			tokens = append(tokens, formulaToken{kind: formulaTokenOperator, value: s[:2]})
+			s = s[2:]
+		case strings.IndexByte("+-*/^&=<>%", c) >= 0:
+			tokens = append(tokens, formulaToken{kind: formulaTokenOperator, value: s[:1]})
+			s = s[1:]
+		case c == '{':
+			return nil, fmt.Errorf("array constants are not supported in formula %q", formula)
+		default:
+			token, n, err := nextFormulaOperand(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s in formula %q", err.Error(), formula)
+			}
+			tokens = append(tokens, token)
+			s = s[n:]
+		}
+	}
+	return tokens, nil
+}
+
+// nextFormulaOperand lexes a reference, number, boolean, name or
+// function name from the start of s.  It returns the token and the
+// number of bytes consumed.
+func nextFormulaOperand(s string) (formulaToken, int, error) {
+	if m := formulaSheetPrefixRegexp.FindStringSubmatch(s); m != nil {
+		sheet := m[2]
+		if m[1] != "" {
+			sheet = strings.Replace(m[1], "''", "'", -1)
+		}
+		rest := s[len(m[0]):]
+		if strings.HasPrefix(strings.ToUpper(rest), formulaErrorRef) {
+			return formulaToken{kind: formulaTokenError, value: formulaErrorRef}, len(m[0]) + len(formulaErrorRef), nil
+		}
+		n := matchFormulaRef(rest)
+		if n == 0 {
+			return formulaToken{}, 0, fmt.Errorf("invalid reference after %q", m[0])
+		}
+		token := formulaToken{kind: formulaTokenRef, value: strings.ToUpper(rest[:n]), sheet: sheet}
+		return token, len(m[0]) + n, nil
+	}
+	if n := matchFormulaRef(s); n > 0 {
+		return formulaToken{kind: formulaTokenRef, value: strings.ToUpper(s[:n])}, n, nil
+	}
+	if loc := formulaNumberRegexp.FindStringIndex(s); loc != nil {
+		return formulaToken{kind: formulaTokenNumber, value: s[:loc[1]]}, loc[1], nil
+	}
+	if loc := formulaNameRegexp.FindStringIndex(s); loc != nil {
+		name := s[:loc[1]]
+		if loc[1] < len(s) && s[loc[1]] == '(' {
+			return formulaToken{kind: formulaTokenFunc, value: strings.ToUpper(name)}, loc[1] + 1, nil
+		}
+		switch strings.ToUpper(name) {
+		case "TRUE", "FALSE":
+			return formulaToken{kind: formulaTokenBool, value: strings.ToUpper(name)}, loc[1], nil
+		}
+		return formulaToken{kind: formulaTokenName, value: name}, loc[1], nil
+	}
+	return formulaToken{}, 0, fmt.Errorf("unexpected character %q", s[0])
+}
+
+//
+// Parsing
+//
+
+// formulaNode is a node in the syntax tree of a parsed formula.
+type formulaNode interface {
+	eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error)
+}
+
+type formulaConstNode struct {
+	value formulaValue
+}
+
+// formulaRefNode is a reference to a single cell or a rectangular
+// range of cells.  The bounds are zero based and inclusive.
+type formulaRefNode struct {
+	sheet    string
+	firstRow int
+	firstCol int
+	lastRow  int
+	lastCol  int
+}
+
+type formulaNameNode struct {
+	name string
+}
+
+type formulaUnaryNode struct {
+	op      string
+	operand formulaNode
+}
+
+type formulaBinaryNode struct {
+	op    string
+	left  formulaNode
+	right formulaNode
+}
+
+type formulaFuncNode struct {
+	name string
+	args []formulaNode
+}
+
+// formulaMissingNode stands in for an argument that has been left
+// out of a function call, as in IF(A1,,1).
+type formulaMissingNode struct{}
+
+type formulaParser struct {
+	formula string
+	tokens  []formulaToken
+	pos     int
+}
+
+// parseFormula parses the text of a formula into a syntax tree that
+// can be evaluated.
+func parseFormula(formula string) (formulaNode, error) {
+	tokens, err := tokenizeFormula(formula)
+	if err != nil {
+		return nil, err
+	}
+	p := &formulaParser{formula: formula, tokens: tokens}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty formula")
+	}
+	node, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != formulaTokenEOF {
+		return nil, p.errorf("unexpected %q", p.peek().value)
+	}
+	return node, nil
+}
+
+func (p *formulaParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s in formula %q", fmt.Sprintf(format, args...), p.formula)
+}
+
+func (p *formulaParser) peek() formulaToken {
+	if p.pos >= len(p.tokens) {
+		return formulaToken{kind: formulaTokenEOF}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *formulaParser) next() formulaToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// acceptOperator consumes the next token if it is one of the given
+// operators, and returns it.
+func (p *formulaParser) acceptOperator(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != formulaTokenOperator {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.value == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// parseBinary parses a left associative sequence of operands,
+// produced by parseOperand, joined by any of the given operators.
+func (p *formulaParser) parseBinary(parseOperand func() (formulaNode, error), ops ...string) (formulaNode, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOperator(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = &formulaBinaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *formulaParser) parseComparison() (formulaNode, error) {
+	return p.parseBinary(p.parseConcat, "=", "<>", "<", ">", "<=", ">=")
+}
+
+func (p *formulaParser) parseConcat() (formulaNode, error) {
+	return p.parseBinary(p.parseAdditive, "&")
+}
+
+func (p *formulaParser) parseAdditive() (formulaNode, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *formulaParser) parseMultiplicative() (formulaNode, error) {
+	return p.parseBinary(p.parsePower, "*", "/")
+}
+
+func (p *formulaParser) parsePower() (formulaNode, error) {
+	return p.parseBinary(p.parseUnary, "^")
+}
+
+// parseUnary parses prefix signs.  Note that in Excel negation binds
+// more tightly than exponentiation, so -2^2 is 4.
+func (p *formulaParser) parseUnary() (formulaNode, error) {
+	if op, ok := p.acceptOperator("+", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &formulaUnaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePercent()
+}
+
+func (p *formulaParser) parsePercent() (formulaNode, error) {
+	node, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.acceptOperator("%"); !ok {
+			return node, nil
+		}
+		node = &formulaUnaryNode{op: "%", operand: node}
+	}
+}
+
+func (p *formulaParser) parsePrimary() (formulaNode, error) {
+	t := p.next()
+	switch t.kind {
+	case formulaTokenNumber:
+		n, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.value)
+		}
+		return &formulaConstNode{formulaNumber(n)}, nil
+	case formulaTokenString:
+		return &formulaConstNode{formulaString(t.value)}, nil
+	case formulaTokenBool:
+		return &formulaConstNode{formulaBool(t.value == "TRUE")}, nil
+	case formulaTokenError:
+		return &formulaConstNode{formulaError(t.value)}, nil
+	case formulaTokenRef:
+		return parseFormulaRef(t.sheet, t.value)
+	case formulaTokenName:
+		return &formulaNameNode{name: t.value}, nil
+	case formulaTokenFunc:
+		return p.parseFuncArgs(t.value)
+	case formulaTokenOpenParen:
+		node, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != formulaTokenCloseParen {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+		return node, nil
+	case formulaTokenEOF:
+		return nil, p.errorf("unexpected end")
+	}
+	return nil, p.errorf("unexpected %q", t.value)
+}
+
+// parseFuncArgs parses the argument list of a function call, the
+// opening parenthesis has already been consumed by the lexer.
+func (p *formulaParser) parseFuncArgs(name string) (formulaNode, error) {
+	node := &formulaFuncNode{name: strings.TrimPrefix(name, "_XLFN.")}
+	if p.peek().kind == formulaTokenCloseParen {
+		p.pos++
+		return node, nil
+	}
+	for {
+		switch p.peek().kind {
+		case formulaTokenComma, formulaTokenCloseParen:
+			node.args = append(node.args, &formulaMissingNode{})
+		default:
+			arg, err := p.parseComparison()
+			if err != nil {
+				return nil, err
+			}
+			node.args = append(node.args, arg)
+		}
+		switch p.next().kind {
+		case formulaTokenComma:
+			continue
+		case formulaTokenCloseParen:
+			return node, nil
+		}
+		return nil, p.errorf("missing closing parenthesis in call to %s", name)
+	}
+}
+
+// parseFormulaRef converts the text of a reference, such as "A1",
+// "$A$1:B2", "A:C" or "1:3" into a formulaRefNode.
+func parseFormulaRef(sheet, ref string) (*formulaRefNode, error) {
+	parts := strings.SplitN(strings.Replace(ref, fixedCellRefChar, "", -1), cellRangeChar, 2)
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	node := &formulaRefNode{sheet: sheet}
+	switch {
+	case strings.Map(intOnlyMapF, parts[0]) == "":
+		// A whole column range, A:C
+		node.firstCol = ColLettersToIndex(parts[0])
+		node.lastCol = ColLettersToIndex(parts[1])
+		node.lastRow = formulaMaxRow
+	case strings.Map(letterOnlyMapF, parts[0]) == "":
+		// A whole row range, 1:3
+		first, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		last, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		node.firstRow = first - 1
+		node.lastRow = last - 1
+		node.lastCol = formulaMaxCol
+	default:
+		var err error
+		node.firstCol, node.firstRow, err = GetCoordsFromCellIDString(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		node.lastCol, node.lastRow, err = GetCoordsFromCellIDString(parts[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if node.firstRow > node.lastRow {
+		node.firstRow, node.lastRow = node.lastRow, node.firstRow
+	}
+	if node.firstCol > node.lastCol {
+		node.firstCol, node.lastCol = node.lastCol, node.firstCol
+	}
+	return node, nil
+}
+
+//
+// Values
+//
+
+type formulaValueType int
+
+const (
+	formulaValueBlank formulaValueType = iota
+	formulaValueNumber
+	formulaValueString
+	formulaValueBool
+	formulaValueError
+	formulaValueArray
+)
+
+// formulaValue is the result of evaluating a formula, or any part of
+// one.  References evaluate to arrays, which are reduced to a single
+// value where an operator or function requires it.
+type formulaValue struct {
+	kind  formulaValueType
+	num   float64
+	str   string
+	b     bool
+	array [][]formulaValue
+	// row and col are the zero based coordinates of the top left
+	// cell of an array that was produced by a reference.
+	row int
+	col int
+}
+
+func formulaNumber(n float64) formulaValue {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return formulaError(formulaErrorNum)
+	}
+	return formulaValue{kind: formulaValueNumber, num: n}
+}
+
+func formulaString(s string) formulaValue {
+	return formulaValue{kind: formulaValueString, str: s}
+}
+
+func formulaBool(b bool) formulaValue {
+	return formulaValue{kind: formulaValueBool, b: b}
+}
+
+func formulaError(e string) formulaValue {
+	return formulaValue{kind: formulaValueError, str: e}
+}
+
+func (v formulaValue) isError() bool {
+	return v.kind == formulaValueError
+}
+
+// errorOr returns v if it is an error value, otherwise it returns the
+// given error value.  It is used to propagate errors from operands
+// that couldn't be coerced to the required type.
+func (v formulaValue) errorOr(e string) formulaValue {
+	if v.isError() {
+		return v
+	}
+	return formulaError(e)
+}
+
+// scalar reduces an array to a single value.  A single cell is
+// simply unwrapped, otherwise we use Excel's implicit intersection:
+// a single row or column range yields the value in the same column
+// or row as the cell holding the formula.
+func (v formulaValue) scalar(s *formulaScope) formulaValue {
+	if v.kind != formulaValueArray {
+		return v
+	}
+	if len(v.array) == 0 || len(v.array[0]) == 0 {
+		return formulaValue{}
+	}
+	if len(v.array) == 1 && len(v.array[0]) == 1 {
+		return v.array[0][0]
+	}
+	if s != nil && s.cell != nil {
+		if len(v.array[0]) == 1 {
+			if i := s.row - v.row; i >= 0 && i < len(v.array) {
+				return v.array[i][0]
+			}
+		}
+		if len(v.array) == 1 {
+			if i := s.col - v.col; i >= 0 && i < len(v.array[0]) {
+				return v.array[0][i]
+			}
+		}
+	}
+	return formulaError(formulaErrorValue)
+}
+
+// toNumber coerces a scalar value to a number in the way that Excel
+// does for the operands of arithmetic operators.
+func (v formulaValue) toNumber() (float64, bool) {
+	switch v.kind {
+	case formulaValueBlank:
+		return 0, true
+	case formulaValueNumber:
+		return v.num, true
+	case formulaValueBool:
+		if v.b {
+			return 1, true
+		}
+		return 0, true
+	case formulaValueString:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// toText coerces a scalar value to a string in the way that Excel
+// does for the operands of the & operator.
+func (v formulaValue) toText() (string, bool) {
+	switch v.kind {
+	case formulaValueBlank:
+		return "", true
+	case formulaValueNumber:
+		return formatFormulaNumber(v.num), true
+	case formulaValueString:
+		return v.str, true
+	case formulaValueBool:
+		if v.b {
+			return "TRUE", true
+		}
+		return "FALSE", true
+	}
+	return "", false
+}
+
+// toBool coerces a scalar value to a boolean in the way that Excel
+// does for logical tests.
+func (v formulaValue) toBool() (bool, bool) {
+	switch v.kind {
+	case formulaValueBlank:
+		return false, true
+	case formulaValueNumber:
+		return v.num != 0, true
+	case formulaValueBool:
+		return v.b, true
+	case formulaValueString:
+		switch strings.ToUpper(v.str) {
+		case "TRUE":
+			return true, true
+		case "FALSE":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// formatFormulaNumber formats a number the way Excel's "General"
+// format does when a number is converted to text, that is with no
+// more than 15 significant digits.
+func formatFormulaNumber(n float64) string {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(n, 'g', 15, 64), 64)
+	if err != nil {
+		rounded = n
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// compareFormulaValues compares two scalar values using Excel's
+// rules: numbers sort before text, which sorts before booleans, text
+// is compared without regard to case, and a blank value is treated as
+// the zero value of the type it is compared with.
+func compareFormulaValues(a, b formulaValue) int {
+	if a.kind == formulaValueBlank {
+		a = zeroFormulaValue(b.kind)
+	}
+	if b.kind == formulaValueBlank {
+		b = zeroFormulaValue(a.kind)
+	}
+	rank := func(v formulaValue) int {
+		switch v.kind {
+		case formulaValueNumber:
+			return 1
+		case formulaValueString:
+			return 2
+		case formulaValueBool:
+			return 3
+		}
+		return 0
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch a.kind {
+	case formulaValueNumber:
+		switch {
+		case a.num < b.num:
+			return -1
+		case a.num > b.num:
+			return 1
+		}
+	case formulaValueString:
+		return strings.Compare(strings.ToLower(a.str), strings.ToLower(b.str))
+	case formulaValueBool:
+		switch {
+		case !a.b && b.b:
+			return -1
+		case a.b && !b.b:
+			return 1
+		}
+	}
+	return 0
+}
+
+func zeroFormulaValue(kind formulaValueType) formulaValue {
+	switch kind {
+	case formulaValueString:
+		return formulaString("")
+	case formulaValueBool:
+		return formulaBool(false)
+	}
+	return formulaNumber(0)
+}
+
+// formulaValueOfCell returns the value held by a cell that doesn't
+// contain a formula.
+func formulaValueOfCell(cell *Cell) formulaValue {
+	switch cell.cellType {
+	case CellTypeBool:
+		return formulaBool(cell.Value == "1")
+	case CellTypeError:
+		return formulaError(cell.Value)
+	case CellTypeNumeric, CellTypeDate:
+		if cell.Value == "" {
+			return formulaValue{}
+		}
+		if n, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+			return formulaNumber(n)
+		}
+		return formulaString(cell.Value)
+	}
+	if cell.Value == "" {
+		return formulaValue{}
+	}
+	return formulaString(cell.Value)
+}
+
+//
+// Evaluation
+//
+
+// formulaEvaluator evaluates the formulas in a File.  The results of
+// evaluating a cell are remembered, so that every cell is only
+// evaluated once however often it is referenced.
+type formulaEvaluator struct {
+	file    *File
+	results map[*Cell]formulaValue
+	errors  map[*Cell]error
+	pending map[*Cell]bool
+}
+
+// formulaScope holds the cell that a formula is being evaluated
+// for.  Unqualified references are resolved against its sheet.
+type formulaScope struct {
+	sheet *Sheet
+	cell  *Cell
+	row   int
+	col   int
+}
+
+func newFormulaEvaluator(file *File) *formulaEvaluator {
+	return &formulaEvaluator{
+		file:    file,
+		results: make(map[*Cell]formulaValue),
+		errors:  make(map[*Cell]error),
+		pending: make(map[*Cell]bool),
+	}
+}
+
+// evaluateCell returns the value of a cell, evaluating its formula
+// if it has one.  row and col are the cell's coordinates in sheet.
+func (e *formulaEvaluator) evaluateCell(sheet *Sheet, cell *Cell, row, col int) (formulaValue, error) {
+	if cell.formula == "" {
+		return formulaValueOfCell(cell), nil
+	}
+	if v, ok := e.results[cell]; ok {
+		return v, nil
+	}
+	if err, ok := e.errors[cell]; ok {
+		return formulaValue{}, err
+	}
+	ref := GetCellIDStringFromCoords(col, row)
+	if sheet != nil {
+		ref = sheet.Name + externalSheetBangChar + ref
+	}
+	if e.pending[cell] {
+		return formulaValue{}, fmt.Errorf("circular reference in %s", ref)
+	}
+	e.pending[cell] = true
+	defer delete(e.pending, cell)
+
+	v, err := e.evaluateFormula(cell.formula, &formulaScope{sheet: sheet, cell: cell, row: row, col: col})
+	if err != nil {
+		err = fmt.Errorf("cannot evaluate %s: %s", ref, err.Error())
+		e.errors[cell] = err
+		return formulaValue{}, err
+	}
+	e.results[cell] = v
+	return v, nil
+}
+
+// evaluateFormula parses and evaluates a formula in the given scope,
+// returning a single value.
+func (e *formulaEvaluator) evaluateFormula(formula string, s *formulaScope) (formulaValue, error) {
+	node, err := parseFormula(formula)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	v, err := node.eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	v = v.scalar(s)
+	if v.kind == formulaValueBlank {
+		// A formula that refers to an empty cell yields zero.
+		v = formulaNumber(0)
+	}
+	return v, nil
+}
+
+// lookupSheet finds a sheet by name, Excel treats sheet names as
+// case insensitive.
+func (e *formulaEvaluator) lookupSheet(name string) *Sheet {
+	if e.file == nil {
+		return nil
+	}
+	if sheet, ok := e.file.Sheet[name]; ok {
+		sheet.load()
+		return sheet
+	}
+	for _, sheet := range e.file.Sheets {
+		if strings.EqualFold(sheet.Name, name) {
+			sheet.load()
+			return sheet
+		}
+	}
+	return nil
+}
+
+// scalarArgs evaluates the given nodes, reducing each to a single
+// value.
+func (e *formulaEvaluator) scalarArgs(s *formulaScope, args []formulaNode) ([]formulaValue, error) {
+	values := make([]formulaValue, len(args))
+	for i, arg := range args {
+		v, err := arg.eval(e, s)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v.scalar(s)
+	}
+	return values, nil
+}
+
+func (n *formulaConstNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	return n.value, nil
+}
+
+func (n *formulaMissingNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	return formulaValue{}, nil
+}
+
+func (n *formulaRefNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	sheet := s.sheet
+	if n.sheet != "" {
+		sheet = e.lookupSheet(n.sheet)
+		if sheet == nil {
+			return formulaError(formulaErrorRef), nil
+		}
+	}
+	if sheet == nil {
+		return formulaValue{}, fmt.Errorf("cell is not part of a sheet")
+	}
+	lastRow, lastCol := n.lastRow, n.lastCol
+	// Whole row and column ranges are limited to the part of the
+	// sheet that is in use.
+	if lastRow == formulaMaxRow {
+		lastRow = len(sheet.Rows) - 1
+	}
+	if lastCol == formulaMaxCol {
+		lastCol = n.firstCol
+		for _, row := range sheet.Rows {
+			if row != nil && len(row.Cells)-1 > lastCol {
+				lastCol = len(row.Cells) - 1
+			}
+		}
+	}
+	v := formulaValue{kind: formulaValueArray, row: n.firstRow, col: n.firstCol}
+	for r := n.firstRow; r <= lastRow; r++ {
+		values := make([]formulaValue, 0, lastCol-n.firstCol+1)
+		for c := n.firstCol; c <= lastCol; c++ {
+			var value formulaValue
+			if cell := formulaCellAt(sheet, r, c); cell != nil {
+				var err error
+				value, err = e.evaluateCell(sheet, cell, r, c)
+				if err != nil {
+					return formulaValue{}, err
+				}
+			}
+			values = append(values, value)
+		}
+		v.array = append(v.array, values)
+	}
+	return v, nil
+}
+
+// formulaCellAt returns the cell at the given zero based coordinates
+// or nil if the sheet doesn't extend that far.  Unlike Sheet.Cell it
+// never adds rows or cells to the sheet.
+func formulaCellAt(sheet *Sheet, row, col int) *Cell {
+	if row < 0 || row >= len(sheet.Rows) || sheet.Rows[row] == nil {
+		return nil
+	}
+	cells := sheet.Rows[row].Cells
+	if col < 0 || col >= len(cells) {
+		return nil
+	}
+	return cells[col]
+}
+
+func (n *formulaNameNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	if e.file != nil {
+		var found *xlsxDefinedName
+		for _, dn := range e.file.DefinedNames {
+			if !strings.EqualFold(dn.Name, n.name) {
+				continue
+			}
+			// A name that is local to the current sheet takes
+			// precedence over a global one.
+			if dn.LocalSheetID != 0 && s.sheet != nil {
+				idx := dn.LocalSheetID
+				if idx < len(e.file.Sheets) && e.file.Sheets[idx] == s.sheet {
+					found = dn
+					break
+				}
+				continue
+			}
+			if found == nil {
+				found = dn
+			}
+		}
+		if found != nil {
+			node, err := parseFormula(found.Data)
+			if err != nil {
+				return formulaValue{}, err
+			}
+			return node.eval(e, s)
+		}
+	}
+	return formulaValue{}, fmt.Errorf("unknown name %s", n.name)
+}
+
+func (n *formulaUnaryNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	v, err := n.operand.eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	v = v.scalar(s)
+	f, ok := v.toNumber()
+	if !ok {
+		return v.errorOr(formulaErrorValue), nil
+	}
+	switch n.op {
+	case "-":
+		return formulaNumber(-f), nil
+	case "%":
+		return formulaNumber(f / 100), nil
+	}
+	return formulaNumber(f), nil
+}
+
+func (n *formulaBinaryNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	values, err := e.scalarArgs(s, []formulaNode{n.left, n.right})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	left, right := values[0], values[1]
+	if left.isError() {
+		return left, nil
+	}
+	if right.isError() {
+		return right, nil
+	}
+	switch n.op {
+	case "&":
+		l, _ := left.toText()
+		r, _ := right.toText()
+		return formulaString(l + r), nil
+	case "=":
+		return formulaBool(compareFormulaValues(left, right) == 0), nil
+	case "<>":
+		return formulaBool(compareFormulaValues(left, right) != 0), nil
+	case "<":
+		return formulaBool(compareFormulaValues(left, right) < 0), nil
+	case ">":
+		return formulaBool(compareFormulaValues(left, right) > 0), nil
+	case "<=":
+		return formulaBool(compareFormulaValues(left, right) <= 0), nil
+	case ">=":
+		return formulaBool(compareFormulaValues(left, right) >= 0), nil
+	}
+	l, ok := left.toNumber()
+	if !ok {
+		return formulaError(formulaErrorValue), nil
+	}
+	r, ok := right.toNumber()
+	if !ok {
+		return formulaError(formulaErrorValue), nil
+	}
+	switch n.op {
+	case "+":
+		return formulaNumber(l + r), nil
+	case "-":
+		return formulaNumber(l - r), nil
+	case "*":
+		return formulaNumber(l * r), nil
+	case "/":
+		if r == 0 {
+			return formulaError(formulaErrorDiv0), nil
+		}
+		return formulaNumber(l / r), nil
+	case "^":
+		if l == 0 && r == 0 {
+			return formulaError(formulaErrorNum), nil
+		}
+		return formulaNumber(math.Pow(l, r)), nil
+	}
+	return formulaValue{}, fmt.Errorf("unknown operator %s", n.op)
+}
+
+func (n *formulaFuncNode) eval(e *formulaEvaluator, s *formulaScope) (formulaValue, error) {
+	fn, ok := formulaFunctions[n.name]
+	if !ok {
+		return formulaValue{}, fmt.Errorf("unsupported function %s", n.name)
+	}
+	return fn(e, s, n.args)
+}
+
+//
+// Public API
+//
+
+// setFormulaResult stores the result of evaluating a cell's formula
+// as the cell's cached value, updating the cell type to match.
+func (c *Cell) setFormulaResult(v formulaValue) {
+	switch v.kind {
+	case formulaValueString:
+		c.Value = v.str
+		c.cellType = CellTypeStringFormula
+	case formulaValueBool:
+		c.Value = "0"
+		if v.b {
+			c.Value = "1"
+		}
+		c.cellType = CellTypeBool
+	case formulaValueError:
+		c.Value = v.str
+		c.cellType = CellTypeError
+	default:
+		// Cells that hold dates as dates rather than as numbers with a
+		// date format keep doing so.
+		if c.cellType == CellTypeDate {
+			c.Value = TimeFromExcelTime(v.num, c.date1904).Format(formulaDateLayout)
+			return
+		}
+		c.Value = strconv.FormatFloat(v.num, 'f', -1, 64)
+		c.cellType = CellTypeNumeric
+	}
+}
+
+// formulaDateLayout is the ISO 8601 layout of the results of formulas
+// in cells that hold dates as dates.
+const formulaDateLayout = "2006-01-02T15:04:05"
+
+// calculate evaluates every formula in the sheet and stores the
+// results as the cached values of the cells.  Cells whose formula
+// can't be evaluated keep their existing value, and the first error
+// encountered is returned.
+func (s *Sheet) calculate(e *formulaEvaluator) error {
+	var firstErr error
+	for r, row := range s.Rows {
+		if row == nil {
+			continue
+		}
+		for c, cell := range row.Cells {
+			if cell == nil || cell.formula == "" {
+				continue
+			}
+			v, err := e.evaluateCell(s, cell, r, c)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			cell.setFormulaResult(v)
+		}
+	}
+	return firstErr
+}
+
+// Calculate evaluates the formula of the cell and stores the result
+// as the cell's value.  Formulas that refer to other cells can only
+// be evaluated if the cell belongs to a Sheet.  Formulas in the
+// referenced cells are evaluated as well, but their cached values are
+// not updated.  If the formula can't be evaluated, for example
+// because it uses a function that isn't supported, an error is
+// returned and the cell is left unchanged.
+func (c *Cell) Calculate() error {
+	if c.formula == "" {
+		return nil
+	}
+	var sheet *Sheet
+	var file *File
+	row, col := -1, -1
+	if c.Row != nil && c.Row.Sheet != nil {
+		sheet = c.Row.Sheet
+		file = sheet.File
+		for r, candidate := range sheet.Rows {
+			if candidate != c.Row {
+				continue
+			}
+			for i, cell := range candidate.Cells {
+				if cell == c {
+					row, col = r, i
+				}
+			}
+		}
+	}
+	v, err := newFormulaEvaluator(file).evaluateCell(sheet, c, row, col)
+	if err != nil {
+		return err
+	}
+	c.setFormulaResult(v)
+	return nil
+}
+
+// Calculate evaluates every formula in the sheet, storing the results
+// as the values of the cells.  This makes it possible to read the
+// results of formulas from files that were saved without them.
+// Cells whose formula can't be evaluated are left unchanged, and the
+// first such error is returned.
+func (s *Sheet) Calculate() error {
+	s.load()
+	return s.calculate(newFormulaEvaluator(s.File))
+}
+
+// Calculate evaluates every formula in every sheet of the file,
+// storing the results as the values of the cells.  Cells whose
+// formula can't be evaluated are left unchanged, and the first such
+// error is returned.
+func (f *File) Calculate() error {
+	e := newFormulaEvaluator(f)
+	var firstErr error
+	for _, sheet := range f.Sheets {
+		if err := sheet.calculate(e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,1176 @@
+package xlsx
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// formulaFunction implements a worksheet function.  Functions receive
+// their arguments unevaluated, so that functions like IF can avoid
+// evaluating the branch that isn't taken.
+type formulaFunction func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error)
+
+// formulaFunctions maps the upper case name of each supported
+// worksheet function to its implementation.  It is populated in
+// init, because the functions refer back to the evaluator.
+var formulaFunctions map[string]formulaFunction
+
+func init() {
+	formulaFunctions = map[string]formulaFunction{
+		// Math
+		"SUM":        formulaAggregate(1, -1, formulaSum),
+		"PRODUCT":    formulaAggregate(1, -1, formulaProduct),
+		"AVERAGE":    formulaAggregate(1, -1, formulaAverage),
+		"MIN":        formulaAggregate(1, -1, formulaMin),
+		"MAX":        formulaAggregate(1, -1, formulaMax),
+		"COUNT":      formulaCount,
+		"COUNTA":     formulaCountA,
+		"COUNTBLANK": formulaCountBlank,
+		"SUMIF":      formulaSumIf,
+		"COUNTIF":    formulaCountIf,
+		"AVERAGEIF":  formulaAverageIf,
+		"ABS":        formulaMath1(math.Abs),
+		"INT":        formulaMath1(math.Floor),
+		"SQRT":       formulaMath1(math.Sqrt),
+		"PI":         formulaPi,
+		"ROUND":      formulaRound(roundHalfAwayFromZero),
+		"ROUNDUP":    formulaRound(roundAwayFromZero),
+		"ROUNDDOWN":  formulaRound(math.Trunc),
+		"MOD":        formulaMod,
+		"POWER":      formulaPower,
+		// Logical
+		"IF":      formulaIf,
+		"IFERROR": formulaIfError,
+		"AND":     formulaAnd,
+		"OR":      formulaOr,
+		"NOT":     formulaNot,
+		"TRUE":    formulaConst(formulaBool(true)),
+		"FALSE":   formulaConst(formulaBool(false)),
+		// Information
+		"ISBLANK":  formulaIs(func(v formulaValue) bool { return v.kind == formulaValueBlank }),
+		"ISNUMBER": formulaIs(func(v formulaValue) bool { return v.kind == formulaValueNumber }),
+		"ISTEXT":   formulaIs(func(v formulaValue) bool { return v.kind == formulaValueString }),
+		"ISERROR":  formulaIs(func(v formulaValue) bool { return v.isError() }),
+		"ISNA":     formulaIs(func(v formulaValue) bool { return v.isError() && v.str == formulaErrorNA }),
+		"NA":       formulaConst(formulaError(formulaErrorNA)),
+		// Lookup and reference
+		"VLOOKUP": formulaVLookup,
+		"HLOOKUP": formulaHLookup,
+		"INDEX":   formulaIndex,
+		"MATCH":   formulaMatch,
+		// Text
+		"CONCATENATE": formulaConcatenate,
+		"CONCAT":      formulaConcat,
+		"LEN":         formulaLen,
+		"LEFT":        formulaLeft,
+		"RIGHT":       formulaRight,
+		"MID":         formulaMid,
+		"UPPER":       formulaText1(strings.ToUpper),
+		"LOWER":       formulaText1(strings.ToLower),
+		"TRIM":        formulaText1(func(s string) string { return strings.Join(strings.Fields(s), " ") }),
+		"VALUE":       formulaValueFunc,
+		// Date
+		"DATE":  formulaDate,
+		"YEAR":  formulaDatePart(func(t time.Time) int { return t.Year() }),
+		"MONTH": formulaDatePart(func(t time.Time) int { return int(t.Month()) }),
+		"DAY":   formulaDatePart(func(t time.Time) int { return t.Day() }),
+	}
+}
+
+//
+// Argument helpers
+//
+
+// formulaWrongArgCount reports whether a function has been called
+// with fewer than min or more than max arguments.  A negative max
+// means there is no upper limit.
+func formulaWrongArgCount(args []formulaNode, min, max int) bool {
+	return len(args) < min || (max >= 0 && len(args) > max)
+}
+
+// formulaNumberArg evaluates a single argument as a number.  If the
+// argument can't be converted, the error value to return is given
+// instead.
+func formulaNumberArg(e *formulaEvaluator, s *formulaScope, arg formulaNode) (float64, *formulaValue, error) {
+	v, err := arg.eval(e, s)
+	if err != nil {
+		return 0, nil, err
+	}
+	v = v.scalar(s)
+	n, ok := v.toNumber()
+	if !ok {
+		errv := v.errorOr(formulaErrorValue)
+		return 0, &errv, nil
+	}
+	return n, nil, nil
+}
+
+// formulaTextArg evaluates a single argument as text.
+func formulaTextArg(e *formulaEvaluator, s *formulaScope, arg formulaNode) (string, *formulaValue, error) {
+	v, err := arg.eval(e, s)
+	if err != nil {
+		return "", nil, err
+	}
+	v = v.scalar(s)
+	str, ok := v.toText()
+	if !ok {
+		errv := v.errorOr(formulaErrorValue)
+		return "", &errv, nil
+	}
+	return str, nil, nil
+}
+
+// formulaArrayArg evaluates an argument that is expected to be a
+// range.  A scalar is treated as a single cell range.
+func formulaArrayArg(e *formulaEvaluator, s *formulaScope, arg formulaNode) (formulaValue, error) {
+	v, err := arg.eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	if v.kind != formulaValueArray {
+		v = formulaValue{kind: formulaValueArray, array: [][]formulaValue{{v}}}
+	}
+	return v, nil
+}
+
+// formulaCollectNumbers gathers the numbers from the arguments of an
+// aggregate function like SUM.  Values in ranges are only counted if
+// they are numbers, whereas values given directly are converted.
+// The first error value found is returned in place of the numbers.
+func formulaCollectNumbers(e *formulaEvaluator, s *formulaScope, args []formulaNode) ([]float64, *formulaValue, error) {
+	var numbers []float64
+	for _, arg := range args {
+		v, err := arg.eval(e, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v.kind == formulaValueArray {
+			for _, row := range v.array {
+				for _, item := range row {
+					switch item.kind {
+					case formulaValueError:
+						errv := item
+						return nil, &errv, nil
+					case formulaValueNumber:
+						numbers = append(numbers, item.num)
+					}
+				}
+			}
+			continue
+		}
+		if _, missing := arg.(*formulaMissingNode); missing {
+			continue
+		}
+		n, ok := v.toNumber()
+		if !ok {
+			errv := v.errorOr(formulaErrorValue)
+			return nil, &errv, nil
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil, nil
+}
+
+// formulaEachValue calls fn with every value in the arguments,
+// flattening ranges.
+func formulaEachValue(e *formulaEvaluator, s *formulaScope, args []formulaNode, fn func(v formulaValue, inRange bool)) error {
+	for _, arg := range args {
+		v, err := arg.eval(e, s)
+		if err != nil {
+			return err
+		}
+		if v.kind != formulaValueArray {
+			fn(v, false)
+			continue
+		}
+		for _, row := range v.array {
+			for _, item := range row {
+				fn(item, true)
+			}
+		}
+	}
+	return nil
+}
+
+//
+// Math
+//
+
+// formulaAggregate builds a function that reduces all of the numbers
+// in its arguments to a single value.
+func formulaAggregate(min, max int, reduce func([]float64) formulaValue) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, min, max) {
+			return formulaError(formulaErrorValue), nil
+		}
+		numbers, errv, err := formulaCollectNumbers(e, s, args)
+		if err != nil {
+			return formulaValue{}, err
+		}
+		if errv != nil {
+			return *errv, nil
+		}
+		return reduce(numbers), nil
+	}
+}
+
+func formulaSum(numbers []float64) formulaValue {
+	sum := 0.0
+	for _, n := range numbers {
+		sum += n
+	}
+	return formulaNumber(sum)
+}
+
+func formulaProduct(numbers []float64) formulaValue {
+	if len(numbers) == 0 {
+		return formulaNumber(0)
+	}
+	product := 1.0
+	for _, n := range numbers {
+		product *= n
+	}
+	return formulaNumber(product)
+}
+
+func formulaAverage(numbers []float64) formulaValue {
+	if len(numbers) == 0 {
+		return formulaError(formulaErrorDiv0)
+	}
+	sum := 0.0
+	for _, n := range numbers {
+		sum += n
+	}
+	return formulaNumber(sum / float64(len(numbers)))
+}
+
+func formulaMin(numbers []float64) formulaValue {
+	if len(numbers) == 0 {
+		return formulaNumber(0)
+	}
+	min := numbers[0]
+	for _, n := range numbers[1:] {
+		if n < min {
+			min = n
+		}
+	}
+	return formulaNumber(min)
+}
+
+func formulaMax(numbers []float64) formulaValue {
+	if len(numbers) == 0 {
+		return formulaNumber(0)
+	}
+	max := numbers[0]
+	for _, n := range numbers[1:] {
+		if n > max {
+			max = n
+		}
+	}
+	return formulaNumber(max)
+}
+
+func formulaCount(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	count := 0
+	err := formulaEachValue(e, s, args, func(v formulaValue, inRange bool) {
+		if v.kind == formulaValueNumber {
+			count++
+			return
+		}
+		if !inRange && (v.kind == formulaValueBool || v.kind == formulaValueString) {
+			if _, ok := v.toNumber(); ok {
+				count++
+			}
+		}
+	})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	return formulaNumber(float64(count)), nil
+}
+
+func formulaCountA(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	count := 0
+	err := formulaEachValue(e, s, args, func(v formulaValue, inRange bool) {
+		if v.kind != formulaValueBlank || !inRange {
+			count++
+		}
+	})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	return formulaNumber(float64(count)), nil
+}
+
+func formulaCountBlank(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 1) {
+		return formulaError(formulaErrorValue), nil
+	}
+	count := 0
+	err := formulaEachValue(e, s, args, func(v formulaValue, inRange bool) {
+		if v.kind == formulaValueBlank || (v.kind == formulaValueString && v.str == "") {
+			count++
+		}
+	})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	return formulaNumber(float64(count)), nil
+}
+
+// formulaCriteria builds a predicate from the criteria argument of
+// functions like SUMIF.  The criteria may start with a comparison
+// operator, and text criteria may use the wildcards * and ?.
+func formulaCriteria(criteria formulaValue) func(formulaValue) bool {
+	if criteria.kind != formulaValueString {
+		return func(v formulaValue) bool {
+			return v.kind != formulaValueBlank && compareFormulaValues(v, criteria) == 0
+		}
+	}
+	op, operand := "=", criteria.str
+	for _, prefix := range []string{"<=", ">=", "<>", "<", ">", "="} {
+		if strings.HasPrefix(operand, prefix) {
+			op, operand = prefix, operand[len(prefix):]
+			break
+		}
+	}
+	var target formulaValue
+	if n, err := strconv.ParseFloat(operand, 64); err == nil {
+		target = formulaNumber(n)
+	} else if b, ok := formulaString(operand).toBool(); ok {
+		target = formulaBool(b)
+	} else {
+		target = formulaString(operand)
+	}
+
+	var wildcard *regexp.Regexp
+	if target.kind == formulaValueString && (op == "=" || op == "<>") && strings.ContainsAny(operand, "*?") {
+		wildcard = formulaWildcardRegexp(operand)
+	}
+	return func(v formulaValue) bool {
+		if wildcard != nil {
+			matched := v.kind == formulaValueString && wildcard.MatchString(v.str)
+			return matched == (op == "=")
+		}
+		if target.kind == formulaValueString && operand == "" {
+			// "=" matches empty cells, "<>" matches any
+			// other cell.
+			empty := v.kind == formulaValueBlank || (v.kind == formulaValueString && v.str == "")
+			return empty == (op == "=")
+		}
+		if v.kind != target.kind {
+			return op == "<>"
+		}
+		cmp := compareFormulaValues(v, target)
+		switch op {
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		case "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		}
+		return cmp == 0
+	}
+}
+
+// formulaWildcardRegexp converts a pattern using Excel's wildcards
+// into a case insensitive regular expression.  A ~ escapes the
+// following wildcard.
+func formulaWildcardRegexp(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '~' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '*':
+			expr.WriteString(".*")
+		case c == '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// formulaConditional implements the functions that aggregate the
+// values of one range selected by criteria applied to another, such
+// as SUMIF.  reduce receives the selected numbers and the number of
+// cells matched.
+func formulaConditional(e *formulaEvaluator, s *formulaScope, args []formulaNode, reduce func([]float64, int) formulaValue) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	rng, err := formulaArrayArg(e, s, args[0])
+	if err != nil {
+		return formulaValue{}, err
+	}
+	criteria, err := args[1].eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	criteria = criteria.scalar(s)
+	if criteria.isError() {
+		return criteria, nil
+	}
+	values := rng
+	if len(args) == 3 {
+		values, err = formulaArrayArg(e, s, args[2])
+		if err != nil {
+			return formulaValue{}, err
+		}
+	}
+	match := formulaCriteria(criteria)
+	var numbers []float64
+	matched := 0
+	for r, row := range rng.array {
+		for c, item := range row {
+			if !match(item) {
+				continue
+			}
+			matched++
+			if r < len(values.array) && c < len(values.array[r]) {
+				v := values.array[r][c]
+				switch v.kind {
+				case formulaValueNumber:
+					numbers = append(numbers, v.num)
+				case formulaValueError:
+					return v, nil
+				}
+			}
+		}
+	}
+	return reduce(numbers, matched), nil
+}
+
+func formulaSumIf(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaConditional(e, s, args, func(numbers []float64, matched int) formulaValue {
+		return formulaSum(numbers)
+	})
+}
+
+func formulaAverageIf(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaConditional(e, s, args, func(numbers []float64, matched int) formulaValue {
+		return formulaAverage(numbers)
+	})
+}
+
+func formulaCountIf(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	return formulaConditional(e, s, args, func(numbers []float64, matched int) formulaValue {
+		return formulaNumber(float64(matched))
+	})
+}
+
+// formulaMath1 builds a function of one number.
+func formulaMath1(fn func(float64) float64) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, 1, 1) {
+			return formulaError(formulaErrorValue), nil
+		}
+		n, errv, err := formulaNumberArg(e, s, args[0])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		return formulaNumber(fn(n)), nil
+	}
+}
+
+// derefFormulaValue returns the value pointed to by v, or the blank
+// value if v is nil.
+func derefFormulaValue(v *formulaValue) formulaValue {
+	if v == nil {
+		return formulaValue{}
+	}
+	return *v
+}
+
+func formulaPi(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if len(args) > 0 {
+		return formulaError(formulaErrorValue), nil
+	}
+	return formulaNumber(math.Pi), nil
+}
+
+// roundToPrecision removes the noise in the last few bits of a
+// float, so that, for example, 2.675*100 is 267.5 rather than
+// 267.49999999999997, and rounds as Excel would.
+func roundToPrecision(n float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(n, 'g', 15, 64), 64)
+	if err != nil {
+		return n
+	}
+	return rounded
+}
+
+func roundHalfAwayFromZero(n float64) float64 {
+	return math.Round(n)
+}
+
+func roundAwayFromZero(n float64) float64 {
+	if n < 0 {
+		return -math.Ceil(-n)
+	}
+	return math.Ceil(n)
+}
+
+// formulaRound builds one of the ROUND functions, which round a
+// number to a number of digits using the given rounding mode.
+func formulaRound(mode func(float64) float64) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, 2, 2) {
+			return formulaError(formulaErrorValue), nil
+		}
+		n, errv, err := formulaNumberArg(e, s, args[0])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		digits, errv, err := formulaNumberArg(e, s, args[1])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		p := math.Pow(10, math.Trunc(digits))
+		return formulaNumber(mode(roundToPrecision(n*p)) / p), nil
+	}
+}
+
+func formulaMod(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	n, errv, err := formulaNumberArg(e, s, args[0])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	d, errv, err := formulaNumberArg(e, s, args[1])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	if d == 0 {
+		return formulaError(formulaErrorDiv0), nil
+	}
+	// The result has the same sign as the divisor.
+	return formulaNumber(n - d*math.Floor(n/d)), nil
+}
+
+func formulaPower(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	return (&formulaBinaryNode{op: "^", left: args[0], right: args[1]}).eval(e, s)
+}
+
+//
+// Logical
+//
+
+func formulaConst(v formulaValue) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if len(args) > 0 {
+			return formulaError(formulaErrorValue), nil
+		}
+		return v, nil
+	}
+}
+
+func formulaIf(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	cond, err := args[0].eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	cond = cond.scalar(s)
+	b, ok := cond.toBool()
+	if !ok {
+		return cond.errorOr(formulaErrorValue), nil
+	}
+	branch := 1
+	if !b {
+		branch = 2
+	}
+	if branch >= len(args) {
+		// An omitted branch yields the result of the test.
+		return formulaBool(b), nil
+	}
+	if _, missing := args[branch].(*formulaMissingNode); missing {
+		return formulaNumber(0), nil
+	}
+	return args[branch].eval(e, s)
+}
+
+func formulaIfError(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	v, err := args[0].eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	if !v.scalar(s).isError() {
+		return v, nil
+	}
+	return args[1].eval(e, s)
+}
+
+// formulaLogical implements AND and OR, which combine every logical
+// value in their arguments.  Text in ranges is ignored.
+func formulaLogical(e *formulaEvaluator, s *formulaScope, args []formulaNode, and bool) (formulaValue, error) {
+	if len(args) == 0 {
+		return formulaError(formulaErrorValue), nil
+	}
+	var errv *formulaValue
+	seen := false
+	result := and
+	err := formulaEachValue(e, s, args, func(v formulaValue, inRange bool) {
+		if errv != nil {
+			return
+		}
+		if v.isError() {
+			errv = &v
+			return
+		}
+		if inRange && (v.kind == formulaValueString || v.kind == formulaValueBlank) {
+			return
+		}
+		b, ok := v.toBool()
+		if !ok {
+			value := formulaError(formulaErrorValue)
+			errv = &value
+			return
+		}
+		seen = true
+		if and {
+			result = result && b
+		} else {
+			result = result || b
+		}
+	})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	if errv != nil {
+		return *errv, nil
+	}
+	if !seen {
+		return formulaError(formulaErrorValue), nil
+	}
+	return formulaBool(result), nil
+}
+
+func formulaAnd(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaLogical(e, s, args, true)
+}
+
+func formulaOr(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaLogical(e, s, args, false)
+}
+
+func formulaNot(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 1) {
+		return formulaError(formulaErrorValue), nil
+	}
+	v, err := args[0].eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	v = v.scalar(s)
+	b, ok := v.toBool()
+	if !ok {
+		return v.errorOr(formulaErrorValue), nil
+	}
+	return formulaBool(!b), nil
+}
+
+//
+// Information
+//
+
+// formulaIs builds one of the IS functions, which test the type of a
+// single value.
+func formulaIs(test func(formulaValue) bool) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, 1, 1) {
+			return formulaError(formulaErrorValue), nil
+		}
+		v, err := args[0].eval(e, s)
+		if err != nil {
+			return formulaValue{}, err
+		}
+		return formulaBool(test(v.scalar(s))), nil
+	}
+}
+
+//
+// Lookup and reference
+//
+
+// formulaLookupPosition finds value in a list of values.  If exact
+// is false the values are assumed to be sorted in ascending order and
+// the position of the largest value that is less than or equal to
+// value is returned.  The result is -1 if there is no match.
+func formulaLookupPosition(value formulaValue, values []formulaValue, exact bool) int {
+	if exact {
+		var wildcard *regexp.Regexp
+		if value.kind == formulaValueString && strings.ContainsAny(value.str, "*?") {
+			wildcard = formulaWildcardRegexp(value.str)
+		}
+		for i, v := range values {
+			if wildcard != nil {
+				if v.kind == formulaValueString && wildcard.MatchString(v.str) {
+					return i
+				}
+				continue
+			}
+			if v.kind == value.kind && compareFormulaValues(v, value) == 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	found := -1
+	for i, v := range values {
+		if v.kind != value.kind {
+			continue
+		}
+		if compareFormulaValues(v, value) > 0 {
+			break
+		}
+		found = i
+	}
+	return found
+}
+
+// formulaLookup implements VLOOKUP and HLOOKUP.
+func formulaLookup(e *formulaEvaluator, s *formulaScope, args []formulaNode, vertical bool) (formulaValue, error) {
+	if formulaWrongArgCount(args, 3, 4) {
+		return formulaError(formulaErrorValue), nil
+	}
+	values, err := e.scalarArgs(s, []formulaNode{args[0]})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	value := values[0]
+	if value.isError() {
+		return value, nil
+	}
+	table, err := formulaArrayArg(e, s, args[1])
+	if err != nil {
+		return formulaValue{}, err
+	}
+	index, errv, err := formulaNumberArg(e, s, args[2])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	approximate := true
+	if len(args) == 4 {
+		if _, missing := args[3].(*formulaMissingNode); missing {
+			approximate = false
+		} else {
+			v, err := args[3].eval(e, s)
+			if err != nil {
+				return formulaValue{}, err
+			}
+			v = v.scalar(s)
+			b, ok := v.toBool()
+			if !ok {
+				return v.errorOr(formulaErrorValue), nil
+			}
+			approximate = b
+		}
+	}
+
+	// Build the list of keys and the lines that hold the results,
+	// which are columns for VLOOKUP and rows for HLOOKUP.
+	var keys []formulaValue
+	line := int(index) - 1
+	if vertical {
+		for _, row := range table.array {
+			keys = append(keys, row[0])
+		}
+		if line < 0 {
+			return formulaError(formulaErrorValue), nil
+		}
+		if len(table.array) > 0 && line >= len(table.array[0]) {
+			return formulaError(formulaErrorRef), nil
+		}
+	} else {
+		if len(table.array) > 0 {
+			keys = table.array[0]
+		}
+		if line < 0 {
+			return formulaError(formulaErrorValue), nil
+		}
+		if line >= len(table.array) {
+			return formulaError(formulaErrorRef), nil
+		}
+	}
+	pos := formulaLookupPosition(value, keys, !approximate)
+	if pos < 0 {
+		return formulaError(formulaErrorNA), nil
+	}
+	if vertical {
+		return table.array[pos][line], nil
+	}
+	return table.array[line][pos], nil
+}
+
+func formulaVLookup(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaLookup(e, s, args, true)
+}
+
+func formulaHLookup(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	return formulaLookup(e, s, args, false)
+}
+
+func formulaIndex(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	array, err := formulaArrayArg(e, s, args[0])
+	if err != nil {
+		return formulaValue{}, err
+	}
+	row, errv, err := formulaNumberArg(e, s, args[1])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	col := 0.0
+	if len(args) == 3 {
+		col, errv, err = formulaNumberArg(e, s, args[2])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+	}
+	r, c := int(row), int(col)
+	if r < 0 || c < 0 {
+		return formulaError(formulaErrorValue), nil
+	}
+	if len(array.array) == 0 {
+		return formulaError(formulaErrorRef), nil
+	}
+	// With a single row, a lone index selects the column.
+	if len(array.array) == 1 && len(args) == 2 {
+		r, c = 1, r
+	}
+	switch {
+	case r == 0 && c == 0:
+		return array, nil
+	case r == 0:
+		if c > len(array.array[0]) {
+			return formulaError(formulaErrorRef), nil
+		}
+		column := formulaValue{kind: formulaValueArray, row: array.row, col: array.col + c - 1}
+		for _, line := range array.array {
+			column.array = append(column.array, []formulaValue{line[c-1]})
+		}
+		return column, nil
+	case c == 0:
+		if r > len(array.array) {
+			return formulaError(formulaErrorRef), nil
+		}
+		if len(array.array[0]) > 1 {
+			return formulaValue{kind: formulaValueArray, row: array.row + r - 1, col: array.col, array: [][]formulaValue{array.array[r-1]}}, nil
+		}
+		c = 1
+	}
+	if r > len(array.array) || c > len(array.array[r-1]) {
+		return formulaError(formulaErrorRef), nil
+	}
+	return array.array[r-1][c-1], nil
+}
+
+func formulaMatch(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 2, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	values, err := e.scalarArgs(s, args[:1])
+	if err != nil {
+		return formulaValue{}, err
+	}
+	value := values[0]
+	if value.isError() {
+		return value, nil
+	}
+	array, err := formulaArrayArg(e, s, args[1])
+	if err != nil {
+		return formulaValue{}, err
+	}
+	matchType := 1.0
+	if len(args) == 3 {
+		var errv *formulaValue
+		matchType, errv, err = formulaNumberArg(e, s, args[2])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+	}
+	var list []formulaValue
+	switch {
+	case len(array.array) == 1:
+		list = array.array[0]
+	case len(array.array) > 0 && len(array.array[0]) == 1:
+		for _, row := range array.array {
+			list = append(list, row[0])
+		}
+	default:
+		return formulaError(formulaErrorNA), nil
+	}
+	pos := -1
+	switch {
+	case matchType == 0:
+		pos = formulaLookupPosition(value, list, true)
+	case matchType > 0:
+		pos = formulaLookupPosition(value, list, false)
+	default:
+		// The list is sorted in descending order, find the
+		// smallest value that is greater than or equal to value.
+		for i, v := range list {
+			if v.kind != value.kind {
+				continue
+			}
+			if compareFormulaValues(v, value) < 0 {
+				break
+			}
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return formulaError(formulaErrorNA), nil
+	}
+	return formulaNumber(float64(pos + 1)), nil
+}
+
+//
+// Text
+//
+
+func formulaConcatenate(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	values, err := e.scalarArgs(s, args)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	var result strings.Builder
+	for _, v := range values {
+		str, ok := v.toText()
+		if !ok {
+			return v.errorOr(formulaErrorValue), nil
+		}
+		result.WriteString(str)
+	}
+	return formulaString(result.String()), nil
+}
+
+// formulaConcat implements CONCAT, which unlike CONCATENATE accepts
+// ranges.
+func formulaConcat(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	var result strings.Builder
+	var errv *formulaValue
+	err := formulaEachValue(e, s, args, func(v formulaValue, inRange bool) {
+		if errv != nil {
+			return
+		}
+		str, ok := v.toText()
+		if !ok {
+			value := v.errorOr(formulaErrorValue)
+			errv = &value
+			return
+		}
+		result.WriteString(str)
+	})
+	if err != nil {
+		return formulaValue{}, err
+	}
+	if errv != nil {
+		return *errv, nil
+	}
+	return formulaString(result.String()), nil
+}
+
+// formulaText1 builds a function of one string.
+func formulaText1(fn func(string) string) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, 1, 1) {
+			return formulaError(formulaErrorValue), nil
+		}
+		str, errv, err := formulaTextArg(e, s, args[0])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		return formulaString(fn(str)), nil
+	}
+}
+
+func formulaLen(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 1) {
+		return formulaError(formulaErrorValue), nil
+	}
+	str, errv, err := formulaTextArg(e, s, args[0])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	return formulaNumber(float64(utf8.RuneCountInString(str))), nil
+}
+
+// formulaSubstring extracts count characters from the text argument,
+// starting at the zero based position returned by start.
+func formulaSubstring(e *formulaEvaluator, s *formulaScope, textArg formulaNode, countArg formulaNode, start func(length, count int) int) (formulaValue, error) {
+	str, errv, err := formulaTextArg(e, s, textArg)
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	count := 1.0
+	if countArg != nil {
+		count, errv, err = formulaNumberArg(e, s, countArg)
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+	}
+	if count < 0 {
+		return formulaError(formulaErrorValue), nil
+	}
+	runes := []rune(str)
+	from := start(len(runes), int(count))
+	if from < 0 {
+		from = 0
+	}
+	if from > len(runes) {
+		from = len(runes)
+	}
+	to := from + int(count)
+	if to > len(runes) {
+		to = len(runes)
+	}
+	return formulaString(string(runes[from:to])), nil
+}
+
+func formulaLeft(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	var countArg formulaNode
+	if len(args) == 2 {
+		countArg = args[1]
+	}
+	return formulaSubstring(e, s, args[0], countArg, func(length, count int) int { return 0 })
+}
+
+func formulaRight(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 2) {
+		return formulaError(formulaErrorValue), nil
+	}
+	var countArg formulaNode
+	if len(args) == 2 {
+		countArg = args[1]
+	}
+	return formulaSubstring(e, s, args[0], countArg, func(length, count int) int { return length - count })
+}
+
+func formulaMid(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 3, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	start, errv, err := formulaNumberArg(e, s, args[1])
+	if err != nil || errv != nil {
+		return derefFormulaValue(errv), err
+	}
+	if start < 1 {
+		return formulaError(formulaErrorValue), nil
+	}
+	return formulaSubstring(e, s, args[0], args[2], func(length, count int) int { return int(start) - 1 })
+}
+
+func formulaValueFunc(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 1, 1) {
+		return formulaError(formulaErrorValue), nil
+	}
+	v, err := args[0].eval(e, s)
+	if err != nil {
+		return formulaValue{}, err
+	}
+	v = v.scalar(s)
+	if v.kind == formulaValueBool {
+		return formulaError(formulaErrorValue), nil
+	}
+	n, ok := v.toNumber()
+	if !ok {
+		return v.errorOr(formulaErrorValue), nil
+	}
+	return formulaNumber(n), nil
+}
+
+//
+// Date
+//
+
+// formulaDate1904 reports whether the cell being evaluated uses the
+// 1904 date system.
+func formulaDate1904(s *formulaScope) bool {
+	return s.cell != nil && s.cell.date1904
+}
+
+func formulaDate(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+	if formulaWrongArgCount(args, 3, 3) {
+		return formulaError(formulaErrorValue), nil
+	}
+	var parts [3]int
+	for i, arg := range args {
+		n, errv, err := formulaNumberArg(e, s, arg)
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		parts[i] = int(n)
+	}
+	year := parts[0]
+	if year >= 0 && year < 1900 {
+		year += 1900
+	}
+	if year < 1900 || year > 9999 {
+		return formulaError(formulaErrorNum), nil
+	}
+	// time.Date normalises months and days that are out of
+	// range, just as Excel does.
+	t := time.Date(year, time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC)
+	serial := TimeToExcelTime(t, formulaDate1904(s))
+	if !formulaDate1904(s) && serial < 61 {
+		// Excel believes that 1900 was a leap year, so
+		// dates before March 1st 1900 are one day earlier.
+		serial--
+	}
+	if serial < 0 {
+		return formulaError(formulaErrorNum), nil
+	}
+	return formulaNumber(serial), nil
+}
+
+// formulaDatePart builds one of the functions that extract part of
+// a date serial number.
+func formulaDatePart(part func(time.Time) int) formulaFunction {
+	return func(e *formulaEvaluator, s *formulaScope, args []formulaNode) (formulaValue, error) {
+		if formulaWrongArgCount(args, 1, 1) {
+			return formulaError(formulaErrorValue), nil
+		}
+		n, errv, err := formulaNumberArg(e, s, args[0])
+		if err != nil || errv != nil {
+			return derefFormulaValue(errv), err
+		}
+		if n < 0 {
+			return formulaError(formulaErrorNum), nil
+		}
+		return formulaNumber(float64(part(TimeFromExcelTime(n, formulaDate1904(s))))), nil
+	}
+}
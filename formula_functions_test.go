@@ -0,0 +1,171 @@
+package xlsx
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// makeFormulaFunctionsTestSheet builds a sheet with a small price
+// list for the lookup and aggregate functions to work on:
+//
+//	   A        B      C
+//	1  Item     Price  Stock
+//	2  Apple    1.5    10
+//	3  Banana   0.25   (blank)
+//	4  Cherry   4      TRUE
+//	5  Date     "n/a"  3
+func makeFormulaFunctionsTestSheet(c *qt.C) *Sheet {
+	file := NewFile()
+	sheet, err := file.AddSheet("Prices")
+	c.Assert(err, qt.IsNil)
+	rows := [][]interface{}{
+		{"Item", "Price", "Stock"},
+		{"Apple", 1.5, 10},
+		{"Banana", 0.25, nil},
+		{"Cherry", 4, true},
+		{"Date", "n/a", 3},
+	}
+	for r, row := range rows {
+		for col, value := range row {
+			switch v := value.(type) {
+			case nil:
+			case bool:
+				sheet.Cell(r, col).SetBool(v)
+			default:
+				sheet.Cell(r, col).SetValue(v)
+			}
+		}
+	}
+	return sheet
+}
+
+func TestFormulaFunctions(t *testing.T) {
+	c := qt.New(t)
+
+	testCases := []struct {
+		formula  string
+		expected string
+	}{
+		// Math
+		{"SUM(B2:B5)", "5.75"},
+		{"SUM(B2:C5, 1, \"2\", TRUE)", "22.75"},
+		{"SUM(\"x\")", "#VALUE!"},
+		{"PRODUCT(B2:B4)", "1.5"},
+		{"AVERAGE(B2:B5)", "1.9166666666666667"},
+		{"AVERAGE(A1:A5)", "#DIV/0!"},
+		{"MIN(B2:B5)", "0.25"},
+		{"MAX(B2:B5, 10)", "10"},
+		{"MAX(A1:A5)", "0"},
+		{"COUNT(B1:C5)", "5"},
+		{"COUNTA(A1:C5)", "14"},
+		{"COUNTBLANK(A1:C5)", "1"},
+		{"SUMIF(B2:B5, \">1\")", "5.5"},
+		{"SUMIF(A2:A5, \"?a*\", C2:C5)", "3"},
+		{"SUMIF(A2:A5, \"apple\", B2:B5)", "1.5"},
+		{"COUNTIF(C2:C5, \"\")", "1"},
+		{"COUNTIF(C2:C5, \"<>3\")", "3"},
+		{"AVERAGEIF(B2:B5, \"<2\")", "0.875"},
+		{"ABS(-2)", "2"},
+		{"INT(-2.5)", "-3"},
+		{"SQRT(-1)", "#NUM!"},
+		{"ROUND(2.675, 2)", "2.68"},
+		{"ROUND(-2.5, 0)", "-3"},
+		{"ROUND(1234, -2)", "1200"},
+		{"ROUNDUP(1.21, 1)", "1.3"},
+		{"ROUNDDOWN(-1.29, 1)", "-1.2"},
+		{"MOD(-3, 2)", "1"},
+		{"MOD(3, 0)", "#DIV/0!"},
+		{"POWER(2, 10)", "1024"},
+		// Logical
+		{"IF(B2>1, \"dear\", \"cheap\")", "dear"},
+		{"IF(B3>1, \"dear\")", "FALSE"},
+		{"IF(B3>1, \"dear\",)", "0"},
+		{"IF(TRUE, 1, NOSUCHFUNCTION())", "1"},
+		{"IF(\"x\", 1, 2)", "#VALUE!"},
+		{"IFERROR(1/0, \"oops\")", "oops"},
+		{"IFERROR(1/1, \"oops\")", "1"},
+		{"AND(C4, B2>1)", "TRUE"},
+		{"AND(A2:A3)", "#VALUE!"},
+		{"OR(FALSE, 0, C2:C5)", "TRUE"},
+		{"NOT(0)", "TRUE"},
+		// Information
+		{"ISBLANK(C3)", "TRUE"},
+		{"ISNUMBER(B5)", "FALSE"},
+		{"ISTEXT(B5)", "TRUE"},
+		{"ISERROR(1/0)", "TRUE"},
+		{"ISNA(NA())", "TRUE"},
+		// Lookup and reference
+		{"VLOOKUP(\"cherry\", A2:C5, 2, FALSE)", "4"},
+		{"VLOOKUP(\"Fig\", A2:C5, 2, FALSE)", "#N/A"},
+		{"VLOOKUP(\"Blueberry\", A2:C5, 2)", "0.25"},
+		{"VLOOKUP(\"Ch*\", A2:C5, 3, 0)", "TRUE"},
+		{"VLOOKUP(\"Apple\", A2:C5, 4, FALSE)", "#REF!"},
+		{"HLOOKUP(\"Price\", A1:C5, 3, FALSE)", "0.25"},
+		{"INDEX(A1:C5, 4, 1)", "Cherry"},
+		{"INDEX(A2:A5, 2)", "Banana"},
+		{"INDEX(A1:C1, 2)", "Price"},
+		{"SUM(INDEX(B2:C5, 0, 2))", "13"},
+		{"INDEX(A1:C5, 6, 1)", "#REF!"},
+		{"MATCH(\"Cherry\", A1:A5, 0)", "4"},
+		{"MATCH(3, B2:B4)", "2"},
+		{"MATCH(\"Stock\", A1:C1, 0)", "3"},
+		{"MATCH(\"Fig\", A1:A5, 0)", "#N/A"},
+		{"INDEX(B1:B5, MATCH(\"Cherry\", A1:A5, 0))", "4"},
+		// Text
+		{"CONCATENATE(A2, \" costs \", B2)", "Apple costs 1.5"},
+		{"CONCAT(A2:A3, C4)", "AppleBananaTRUE"},
+		{"LEN(A3)", "6"},
+		{"LEFT(A2, 3)", "App"},
+		{"LEFT(A2)", "A"},
+		{"RIGHT(A2, 2)", "le"},
+		{"RIGHT(A2, 10)", "Apple"},
+		{"MID(A3, 2, 3)", "ana"},
+		{"MID(A3, 0, 3)", "#VALUE!"},
+		{"UPPER(A2)&LOWER(\"X\")", "APPLEx"},
+		{"TRIM(\"  a   b \")", "a b"},
+		{"VALUE(\"1.5\")*2", "3"},
+		{"VALUE(A2)", "#VALUE!"},
+		// Date
+		{"DATE(2019, 12, 17)", "43816"},
+		{"DATE(2019, 13, 1)", "43831"},
+		{"DATE(1900, 3, 1)", "61"},
+		{"DATE(1900, 1, 1)", "1"},
+		{"DATE(119, 12, 17)", "43816"},
+		{"YEAR(43816)&\"-\"&MONTH(43816)&\"-\"&DAY(43816)", "2019-12-17"},
+		{"DAY(DATE(2020, 2, 30))", "1"},
+		// Cross sheet
+		{"SUM(Prices!B2:B3)", "1.75"},
+		{"SUM(Other!A1:A2)", "#REF!"},
+		{"_xlfn.CONCAT(\"a\", \"b\")", "ab"},
+	}
+
+	sheet := makeFormulaFunctionsTestSheet(c)
+	for _, tc := range testCases {
+		cell := sheet.Cell(9, 0)
+		cell.SetFormula(tc.formula)
+		c.Assert(cell.Calculate(), qt.IsNil, qt.Commentf(tc.formula))
+		value := cell.Value
+		if cell.Type() == CellTypeBool {
+			value = "FALSE"
+			if cell.Value == "1" {
+				value = "TRUE"
+			}
+		}
+		c.Assert(value, qt.Equals, tc.expected, qt.Commentf(tc.formula))
+	}
+}
+
+func TestFormulaFunctionArgumentCounts(t *testing.T) {
+	c := qt.New(t)
+
+	for _, formula := range []string{
+		"SUM()", "IF()", "IF(1,2,3,4)", "ROUND(1)", "VLOOKUP(1,A1:A2)",
+		"INDEX(A1:A2)", "MID(\"a\",1)", "DATE(2019,1)", "PI(1)", "NOT(1,2)",
+	} {
+		cell := makeFormulaFunctionsTestSheet(c).Cell(9, 0)
+		cell.SetFormula(formula)
+		c.Assert(cell.Calculate(), qt.IsNil, qt.Commentf(formula))
+		c.Assert(cell.Value, qt.Equals, formulaErrorValue, qt.Commentf(formula))
+	}
+}
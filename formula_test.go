@@ -0,0 +1,316 @@
+package xlsx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// evaluateTestFormula evaluates a formula in cell A1 of a sheet, with
+// no other content, and returns the resulting value.
+func evaluateTestFormula(c *qt.C, formula string) formulaValue {
+	file := NewFile()
+	sheet, err := file.AddSheet("Sheet1")
+	c.Assert(err, qt.IsNil)
+	cell := sheet.Cell(0, 0)
+	v, err := newFormulaEvaluator(file).evaluateFormula(formula, &formulaScope{sheet: sheet, cell: cell})
+	c.Assert(err, qt.IsNil, qt.Commentf(formula))
+	return v
+}
+
+// assertFormulaValue checks that a formulaValue is as expected,
+// quicktest's DeepEquals can't see the unexported fields.
+func assertFormulaValue(c *qt.C, actual, expected formulaValue, comment string) {
+	c.Assert(reflect.DeepEqual(actual, expected), qt.Equals, true, qt.Commentf("%s: got %#v", comment, actual))
+}
+
+func TestTokenizeFormula(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Operands", func(c *qt.C) {
+		tokens, err := tokenizeFormula(`=SUM($A$1:b2, 'My ''Sheet'!C3, Data!A:B, 1:2)&"a""b"+1.5e2%-#N/A<>TRUE`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(reflect.DeepEqual(tokens, []formulaToken{
+			{kind: formulaTokenFunc, value: "SUM"},
+			{kind: formulaTokenRef, value: "$A$1:B2"},
+			{kind: formulaTokenComma, value: ","},
+			{kind: formulaTokenRef, value: "C3", sheet: "My 'Sheet"},
+			{kind: formulaTokenComma, value: ","},
+			{kind: formulaTokenRef, value: "A:B", sheet: "Data"},
+			{kind: formulaTokenComma, value: ","},
+			{kind: formulaTokenRef, value: "1:2"},
+			{kind: formulaTokenCloseParen, value: ")"},
+			{kind: formulaTokenOperator, value: "&"},
+			{kind: formulaTokenString, value: `a"b`},
+			{kind: formulaTokenOperator, value: "+"},
+			{kind: formulaTokenNumber, value: "1.5e2"},
+			{kind: formulaTokenOperator, value: "%"},
+			{kind: formulaTokenOperator, value: "-"},
+			{kind: formulaTokenError, value: "#N/A"},
+			{kind: formulaTokenOperator, value: "<>"},
+			{kind: formulaTokenBool, value: "TRUE"},
+		}), qt.Equals, true, qt.Commentf("%#v", tokens))
+	})
+
+	c.Run("FunctionsThatLookLikeReferences", func(c *qt.C) {
+		tokens, err := tokenizeFormula(`LOG10(A1)+_xlfn.CONCAT(A1)`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(tokens[0], qt.Equals, formulaToken{kind: formulaTokenFunc, value: "LOG10"})
+		c.Assert(tokens[4], qt.Equals, formulaToken{kind: formulaTokenFunc, value: "_XLFN.CONCAT"})
+	})
+
+	c.Run("Errors", func(c *qt.C) {
+		for _, formula := range []string{`"abc`, `#FOO`, `{1,2}`, `Sheet1!`, `A1 ~ B1`} {
+			_, err := tokenizeFormula(formula)
+			c.Assert(err, qt.Not(qt.IsNil), qt.Commentf(formula))
+		}
+	})
+}
+
+func TestParseFormula(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("References", func(c *qt.C) {
+		node, err := parseFormula("B3:$A$1")
+		c.Assert(err, qt.IsNil)
+		c.Assert(*node.(*formulaRefNode), qt.Equals, formulaRefNode{firstRow: 0, firstCol: 0, lastRow: 2, lastCol: 1})
+
+		node, err = parseFormula("Data!C:D")
+		c.Assert(err, qt.IsNil)
+		c.Assert(*node.(*formulaRefNode), qt.Equals, formulaRefNode{sheet: "Data", firstCol: 2, lastCol: 3, lastRow: formulaMaxRow})
+
+		node, err = parseFormula("2:4")
+		c.Assert(err, qt.IsNil)
+		c.Assert(*node.(*formulaRefNode), qt.Equals, formulaRefNode{firstRow: 1, lastRow: 3, lastCol: formulaMaxCol})
+	})
+
+	c.Run("MissingArguments", func(c *qt.C) {
+		node, err := parseFormula("IF(A1,,1)")
+		c.Assert(err, qt.IsNil)
+		fn := node.(*formulaFuncNode)
+		c.Assert(fn.args, qt.HasLen, 3)
+		_, missing := fn.args[1].(*formulaMissingNode)
+		c.Assert(missing, qt.Equals, true)
+	})
+
+	c.Run("Errors", func(c *qt.C) {
+		for _, formula := range []string{"", "1+", "(1", "SUM(1", "1 2", ")"} {
+			_, err := parseFormula(formula)
+			c.Assert(err, qt.Not(qt.IsNil), qt.Commentf(formula))
+		}
+	})
+}
+
+func TestEvaluateFormula(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("Operators", func(c *qt.C) {
+		testCases := []struct {
+			formula  string
+			expected formulaValue
+		}{
+			{"1+2*3", formulaNumber(7)},
+			{"(1+2)*3", formulaNumber(9)},
+			{"2^3^2", formulaNumber(64)},
+			{"-2^2", formulaNumber(4)},
+			{"10-4-3", formulaNumber(3)},
+			{"50%", formulaNumber(0.5)},
+			{"1/0", formulaError(formulaErrorDiv0)},
+			{`"3"+1`, formulaNumber(4)},
+			{`"a"+1`, formulaError(formulaErrorValue)},
+			{"TRUE+1", formulaNumber(2)},
+			{`"a"&1.5&TRUE`, formulaString("a1.5TRUE")},
+			{"0.1+0.2&\"\"", formulaString("0.3")},
+			{"1<2", formulaBool(true)},
+			{`"abc"="ABC"`, formulaBool(true)},
+			{`"a"<"b"`, formulaBool(true)},
+			{`1<"a"`, formulaBool(true)},
+			{`"a"<TRUE`, formulaBool(true)},
+			{"2>=2", formulaBool(true)},
+			{"2<>2", formulaBool(false)},
+			{"#N/A+1", formulaError(formulaErrorNA)},
+			{"A1+1", formulaNumber(1)},
+		}
+		for _, tc := range testCases {
+			assertFormulaValue(c, evaluateTestFormula(c, tc.formula), tc.expected, tc.formula)
+		}
+	})
+
+	c.Run("References", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		other, err := file.AddSheet("Other Sheet")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(2)
+		sheet.Cell(0, 1).SetFormula("A1*3")
+		sheet.Cell(1, 0).SetString("x")
+		sheet.Cell(1, 1).SetFormula("'Other Sheet'!A1+B1")
+		sheet.Cell(2, 0).SetFormula("A1:A2")
+		sheet.Cell(3, 0).SetFormula("Missing!A1")
+		sheet.Cell(3, 1).SetFormula("C10")
+		other.Cell(0, 0).SetFloat(0.5)
+
+		c.Assert(file.Calculate(), qt.IsNil)
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "6")
+		c.Assert(sheet.Cell(0, 1).Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "6.5")
+		// Implicit intersection doesn't apply outside the range.
+		c.Assert(sheet.Cell(2, 0).Value, qt.Equals, formulaErrorValue)
+		c.Assert(sheet.Cell(2, 0).Type(), qt.Equals, CellTypeError)
+		c.Assert(sheet.Cell(3, 0).Value, qt.Equals, formulaErrorRef)
+		c.Assert(sheet.Cell(3, 1).Value, qt.Equals, "0")
+		// Referencing a cell doesn't add it to the sheet.
+		c.Assert(sheet.Rows, qt.HasLen, 4)
+	})
+
+	c.Run("ImplicitIntersection", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < 3; i++ {
+			sheet.Cell(i, 0).SetInt(i + 1)
+			sheet.Cell(i, 1).SetFormula("$A$1:$A$3*10")
+		}
+		c.Assert(sheet.Calculate(), qt.IsNil)
+		c.Assert(sheet.Cell(2, 1).Value, qt.Equals, "30")
+	})
+
+	c.Run("DefinedNames", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(4)
+		sheet.Cell(1, 0).SetInt(5)
+		sheet.Cell(2, 0).SetFormula("SUM(Values)")
+		file.DefinedNames = append(file.DefinedNames, &xlsxDefinedName{Name: "Values", Data: "Sheet1!$A$1:$A$2"})
+		c.Assert(sheet.Calculate(), qt.IsNil)
+		c.Assert(sheet.Cell(2, 0).Value, qt.Equals, "9")
+	})
+
+	c.Run("ResultTypes", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetFormula(`"a"&"b"`)
+		sheet.Cell(0, 1).SetFormula("1=1")
+		c.Assert(sheet.Calculate(), qt.IsNil)
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "ab")
+		c.Assert(sheet.Cell(0, 0).Type(), qt.Equals, CellTypeStringFormula)
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "1")
+		c.Assert(sheet.Cell(0, 1).Type(), qt.Equals, CellTypeBool)
+		c.Assert(sheet.Cell(0, 1).Formula(), qt.Equals, "1=1")
+	})
+
+	c.Run("FailuresLeaveTheCellAlone", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetFormula("NOSUCHFUNCTION(1)")
+		sheet.Cell(0, 0).Value = "42"
+		sheet.Cell(0, 1).SetFormula("B2+1")
+		sheet.Cell(1, 1).SetFormula("B1+1")
+		sheet.Cell(0, 2).SetFormula("Unknown*2")
+		sheet.Cell(0, 3).SetFormula("1+1")
+
+		err = sheet.Calculate()
+		c.Assert(err, qt.ErrorMatches, `cannot evaluate Sheet1!A1: unsupported function NOSUCHFUNCTION`)
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "42")
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "")
+		c.Assert(sheet.Cell(0, 2).Value, qt.Equals, "")
+		c.Assert(sheet.Cell(0, 3).Value, qt.Equals, "2")
+
+		c.Assert(sheet.Cell(0, 1).Calculate(), qt.ErrorMatches, `.*circular reference in Sheet1!B1`)
+		c.Assert(sheet.Cell(0, 2).Calculate(), qt.ErrorMatches, `.*unknown name Unknown`)
+	})
+
+	c.Run("CellWithoutSheet", func(c *qt.C) {
+		cell := &Cell{}
+		cell.SetFormula("ROUND(2.5,0)")
+		c.Assert(cell.Calculate(), qt.IsNil)
+		c.Assert(cell.Value, qt.Equals, "3")
+
+		cell.SetFormula("A1")
+		c.Assert(cell.Calculate(), qt.Not(qt.IsNil))
+	})
+}
+
+func TestFormulaCachedValues(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("WrittenOnSave", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(3)
+		sheet.Cell(0, 1).SetInt(4)
+		sheet.Cell(0, 2).SetFormula("SUM(A1:B1)")
+		sheet.Cell(0, 3).SetStringFormula(`IF(C1>5,"big","small")`)
+		file.CalculateOnSave = true
+
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<c r="C1"><f>SUM(A1:B1)</f><v>7</v></c>`)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<c r="D1" t="str"><f>IF(C1&gt;5,&#34;big&#34;,&#34;small&#34;)</f><v>big</v></c>`)
+	})
+
+	c.Run("KeptOnSave", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(3)
+		sheet.Cell(0, 1).SetFormula("A1*2")
+		sheet.Cell(0, 1).Value = "5"
+		sheet.Cell(0, 2).SetFormula("NOSUCHFUNCTION(A1)")
+		sheet.Cell(0, 2).Value = "8"
+
+		// Formulas are only evaluated on save if asked to be.
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<c r="B1"><f>A1*2</f><v>5</v></c>`)
+
+		// Formulas that can't be evaluated keep their values.
+		file.CalculateOnSave = true
+		parts, err = file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<c r="B1"><f>A1*2</f><v>6</v></c>`)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<c r="C1"><f>NOSUCHFUNCTION(A1)</f><v>8</v></c>`)
+	})
+
+	c.Run("DateType", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(43770)
+		cell := sheet.Cell(0, 1)
+		cell.SetFormula("A1+0.5")
+		cell.cellType = CellTypeDate
+		c.Assert(cell.Calculate(), qt.IsNil)
+		c.Assert(cell.Type(), qt.Equals, CellTypeDate)
+		c.Assert(cell.Value, qt.Equals, "2019-11-01T12:00:00")
+	})
+
+	c.Run("ComputedAfterReading", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(3)
+		sheet.Cell(1, 0).SetInt(4)
+		sheet.Cell(2, 0).SetFormula("A1*A2")
+		file.CalculateOnSave = true
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		// Strip the cached value, as some writers do.
+		parts["xl/worksheets/sheet1.xml"] = string(bytes.Replace([]byte(parts["xl/worksheets/sheet1.xml"]), []byte("<v>12</v>"), nil, 1))
+
+		file, err = OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		cell := file.Sheets[0].Cell(2, 0)
+		c.Assert(cell.Value, qt.Equals, "")
+		c.Assert(file.Calculate(), qt.IsNil)
+		c.Assert(cell.Value, qt.Equals, "12")
+	})
+
+}
@@ -0,0 +1,355 @@
+package xlsx
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTMLOptions control how a sheet is rendered as HTML.  The zero value
+// renders the visible rows and columns, with the styles of the cells
+// in style attributes.
+type HTMLOptions struct {
+	// Classes puts the styles of the cells in a <style> element
+	// before the table, as classes, rather than in style attributes.
+	Classes bool
+	// ClassPrefix is the prefix of the names of the classes, so that
+	// the tables of several sheets can share a page.  If it is
+	// empty, "xlsx-" is used.
+	ClassPrefix string
+	// ShowHidden renders the hidden rows and columns too.
+	ShowHidden bool
+	// Locale is the locale in which values are formatted, as in
+	// Cell.FormattedValueWithLocale.
+	Locale *Locale
+}
+
+func (o HTMLOptions) classPrefix() string {
+	if o.ClassPrefix == "" {
+		return "xlsx-"
+	}
+	return o.ClassPrefix
+}
+
+// The width of a column is the number of characters of the default
+// font that it holds, each of which is seven pixels wide.
+const (
+	htmlPixelsPerChar    = 7
+	htmlDefaultColPixels = 64
+)
+
+// RenderHTML writes the sheet to w as an HTML table.  Merged cells span
+// the rows and columns that they cover, the font, fill, borders and
+// alignment of cells become CSS, columns and rows keep their widths and
+// heights, and hyperlinks become anchors.  The text of each cell is its
+// formatted value; a cell whose value can't be formatted shows its raw
+// value.  Hidden rows and columns are left out, unless
+// opts.ShowHidden is set.
+func (s *Sheet) RenderHTML(w io.Writer, opts HTMLOptions) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+	maxRow, maxCol := s.extent()
+	rowShown := func(y int) bool {
+		return opts.ShowHidden || y >= len(s.Rows) || s.Rows[y] == nil || !s.Rows[y].Hidden
+	}
+	colShown := func(x int) bool {
+		return opts.ShowHidden || !colHidden(s.Cols, x)
+	}
+
+	// covered holds the cells that are covered by a merged cell, so
+	// they aren't rendered.
+	covered := make(map[[2]int]bool)
+	for y, row := range s.Rows {
+		if row == nil || !rowShown(y) {
+			continue
+		}
+		for x, cell := range row.Cells {
+			if cell == nil || (cell.HMerge == 0 && cell.VMerge == 0) || !colShown(x) {
+				continue
+			}
+			for dy := 0; dy <= cell.VMerge; dy++ {
+				for dx := 0; dx <= cell.HMerge; dx++ {
+					if dy != 0 || dx != 0 {
+						covered[[2]int{y + dy, x + dx}] = true
+					}
+				}
+			}
+		}
+	}
+
+	classes := make(map[string]string)
+	var classOrder []string
+	styleAttr := func(css string) string {
+		if css == "" {
+			return ""
+		}
+		if !opts.Classes {
+			return ` style="` + html.EscapeString(css) + `"`
+		}
+		class, ok := classes[css]
+		if !ok {
+			class = opts.classPrefix() + strconv.Itoa(len(classes))
+			classes[css] = class
+			classOrder = append(classOrder, css)
+		}
+		return ` class="` + html.EscapeString(class) + `"`
+	}
+
+	var table bytes.Buffer
+	table.WriteString(`<table style="border-collapse:collapse;table-layout:fixed">`)
+	table.WriteString("\n<colgroup>")
+	for x := 0; x < maxCol; x++ {
+		if !colShown(x) {
+			continue
+		}
+		pixels := htmlDefaultColPixels
+		if s.SheetFormat.DefaultColWidth > 0 {
+			pixels = int(s.SheetFormat.DefaultColWidth*htmlPixelsPerChar + 0.5)
+		}
+		if s.Cols != nil {
+			if col := s.Cols.FindColByIndex(x + 1); col != nil && col.Width > 0 {
+				pixels = int(col.Width*htmlPixelsPerChar + 0.5)
+			}
+		}
+		fmt.Fprintf(&table, `<col style="width:%dpx">`, pixels)
+	}
+	table.WriteString("</colgroup>\n")
+	for y := 0; y < maxRow; y++ {
+		if !rowShown(y) {
+			continue
+		}
+		var row *Row
+		if y < len(s.Rows) {
+			row = s.Rows[y]
+		}
+		table.WriteString("<tr")
+		if row != nil && row.Height > 0 {
+			table.WriteString(styleAttr("height:" + strconv.FormatFloat(row.Height, 'f', -1, 64) + "pt"))
+		}
+		table.WriteString(">")
+		for x := 0; x < maxCol; x++ {
+			if !colShown(x) || covered[[2]int{y, x}] {
+				continue
+			}
+			var cell *Cell
+			if row != nil && x < len(row.Cells) {
+				cell = row.Cells[x]
+			}
+			if cell == nil {
+				table.WriteString("<td></td>")
+				continue
+			}
+			table.WriteString("<td")
+			if span := visibleSpan(cell.HMerge, x, colShown); span > 1 {
+				fmt.Fprintf(&table, ` colspan="%d"`, span)
+			}
+			if span := visibleSpan(cell.VMerge, y, rowShown); span > 1 {
+				fmt.Fprintf(&table, ` rowspan="%d"`, span)
+			}
+			table.WriteString(styleAttr(cellCSS(cell)))
+			table.WriteString(">")
+			text, _ := cell.FormattedValueWithLocale(opts.Locale)
+			if link := cell.Hyperlink; link.Link != "" && safeHTMLLink(link.Link) {
+				if text == "" {
+					text = link.DisplayString
+				}
+				if text == "" {
+					text = link.Link
+				}
+				table.WriteString(`<a href="` + html.EscapeString(link.Link) + `"`)
+				if link.Tooltip != "" {
+					table.WriteString(` title="` + html.EscapeString(link.Tooltip) + `"`)
+				}
+				table.WriteString(">" + html.EscapeString(text) + "</a>")
+			} else {
+				table.WriteString(html.EscapeString(text))
+			}
+			table.WriteString("</td>")
+		}
+		table.WriteString("</tr>\n")
+	}
+	table.WriteString("</table>\n")
+
+	if len(classOrder) > 0 {
+		var style bytes.Buffer
+		style.WriteString("<style>\n")
+		for _, css := range classOrder {
+			fmt.Fprintf(&style, ".%s{%s}\n", classes[css], css)
+		}
+		style.WriteString("</style>\n")
+		if _, err := w.Write(style.Bytes()); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(table.Bytes())
+	return err
+}
+
+// visibleSpan returns the number of the rows or columns from start to
+// start+merge that are shown.
+func visibleSpan(merge, start int, shown func(int) bool) int {
+	span := 0
+	for i := start; i <= start+merge; i++ {
+		if shown(i) {
+			span++
+		}
+	}
+	return span
+}
+
+// safeHTMLLink returns true if link can be the target of an anchor,
+// which rules out schemes such as javascript: that would run in the
+// page that shows the sheet.
+func safeHTMLLink(link string) bool {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto", "ftp":
+		return true
+	}
+	return false
+}
+
+// cellCSS returns the CSS declarations that show the style of a cell.
+func cellCSS(cell *Cell) string {
+	style := cell.GetStyle()
+	var css []string
+	add := func(property, value string) {
+		css = append(css, property+":"+value)
+	}
+
+	font := style.Font
+	if font.Name != "" {
+		add("font-family", cssString(font.Name))
+	}
+	if font.Size > 0 {
+		add("font-size", strconv.Itoa(font.Size)+"pt")
+	}
+	if color := cssColor(font.Color); color != "" {
+		add("color", color)
+	}
+	if font.Bold {
+		add("font-weight", "bold")
+	}
+	if font.Italic {
+		add("font-style", "italic")
+	}
+	if font.Underline {
+		add("text-decoration", "underline")
+	}
+
+	fill := style.Fill
+	if fill.PatternType != "" && fill.PatternType != "none" {
+		if color := cssColor(fill.FgColor); color != "" {
+			add("background-color", color)
+		}
+	}
+
+	border := style.Border
+	for _, side := range []struct{ name, style, color string }{
+		{"top", border.Top, border.TopColor},
+		{"right", border.Right, border.RightColor},
+		{"bottom", border.Bottom, border.BottomColor},
+		{"left", border.Left, border.LeftColor},
+	} {
+		if value := cssBorder(side.style, side.color); value != "" {
+			add("border-"+side.name, value)
+		}
+	}
+
+	alignment := style.Alignment
+	switch alignment.Horizontal {
+	case "left", "right", "center", "justify":
+		add("text-align", alignment.Horizontal)
+	case "centerContinuous":
+		add("text-align", "center")
+	case "", "general":
+		// Spreadsheets align numbers to the right and
+		// booleans to the middle.
+		switch cell.Type() {
+		case CellTypeNumeric, CellTypeDate:
+			add("text-align", "right")
+		case CellTypeBool, CellTypeError:
+			add("text-align", "center")
+		}
+	}
+	switch alignment.Vertical {
+	case "top":
+		add("vertical-align", "top")
+	case "center":
+		add("vertical-align", "middle")
+	case "", "bottom":
+		add("vertical-align", "bottom")
+	}
+	if alignment.Indent > 0 {
+		// An indent is three characters wide.
+		add("padding-left", strconv.Itoa(alignment.Indent*3*htmlPixelsPerChar)+"px")
+	}
+	if alignment.WrapText {
+		add("white-space", "pre-wrap")
+	} else {
+		add("white-space", "nowrap")
+	}
+	return strings.Join(css, ";")
+}
+
+// cssString returns s as a CSS string, without the characters that
+// could end the string, the declaration or the element that holds it.
+func cssString(s string) string {
+	return "'" + strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`'"\;{}<>`, r) {
+			return -1
+		}
+		return r
+	}, s) + "'"
+}
+
+// cssBorder returns the CSS value of a border of the given style and
+// color, or "" if there is no border.
+func cssBorder(style, color string) string {
+	var value string
+	switch style {
+	case "thin", "hair":
+		value = "1px solid"
+	case "medium":
+		value = "2px solid"
+	case "thick":
+		value = "3px solid"
+	case "dashed", "dashDot", "dashDotDot":
+		value = "1px dashed"
+	case "mediumDashed", "mediumDashDot", "mediumDashDotDot", "slantDashDot":
+		value = "2px dashed"
+	case "dotted":
+		value = "1px dotted"
+	case "double":
+		value = "3px double"
+	default:
+		return ""
+	}
+	if c := cssColor(color); c != "" {
+		return value + " " + c
+	}
+	return value + " #000000"
+}
+
+// cssColor returns the CSS form of an RGB or ARGB color, or "" if it
+// isn't one.  Like spreadsheet programs, it ignores the alpha channel.
+func cssColor(color string) string {
+	if len(color) == 8 {
+		color = color[2:]
+	}
+	if len(color) != 6 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(color, 16, 32); err != nil {
+		return ""
+	}
+	return "#" + strings.ToUpper(color)
+}
@@ -0,0 +1,103 @@
+package xlsx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRenderHTML(t *testing.T) {
+	c := qt.New(t)
+
+	// htmlSheet returns a sheet with a styled header, a merged cell,
+	// a link, a hidden row and column, and set widths and heights.
+	htmlSheet := func(c *qt.C) *Sheet {
+		sheet, err := NewFile().AddSheet("Report")
+		c.Assert(err, qt.IsNil)
+		header := NewStyle()
+		header.Font = Font{Name: "Arial", Size: 11, Bold: true, Color: "FF9C0006"}
+		header.Fill = Fill{PatternType: "solid", FgColor: "FFC6EFCE"}
+		header.Border = Border{Bottom: "medium", BottomColor: "FF000000"}
+		header.Alignment = Alignment{Horizontal: "center", Vertical: "center"}
+		for x, name := range []string{"Name", "Secret", "Amount", "Link"} {
+			sheet.Cell(0, x).SetString(name)
+			sheet.Cell(0, x).SetStyle(header)
+		}
+		sheet.Cell(1, 0).SetString("Fish & <Chips>")
+		sheet.Cell(1, 2).SetFloatWithFormat(1234.5, "#,##0.00")
+		sheet.Cell(1, 3).SetHyperlink("https://example.com/?a=1&b=2", "Example", "Go there")
+		sheet.Cell(2, 0).SetString("Hidden")
+		sheet.Row(2).Hidden = true
+		sheet.Cell(3, 0).SetString("Total")
+		sheet.Cell(3, 0).Merge(2, 1)
+		sheet.Cell(3, 3).SetBool(true)
+		sheet.Cell(5, 3).SetHyperlink("javascript:alert(1)", "", "")
+		sheet.Row(1).SetHeight(30)
+		sheet.SetColParameters(&Col{Min: 1, Max: 1, Width: 20})
+		sheet.SetColParameters(&Col{Min: 2, Max: 2, Hidden: true})
+		return sheet
+	}
+	render := func(c *qt.C, sheet *Sheet, opts HTMLOptions) string {
+		var buf bytes.Buffer
+		c.Assert(sheet.RenderHTML(&buf, opts), qt.IsNil)
+		return buf.String()
+	}
+
+	c.Run("Inline", func(c *qt.C) {
+		out := render(c, htmlSheet(c), HTMLOptions{})
+		lines := strings.Split(out, "\n")
+		c.Assert(lines[0], qt.Equals, `<table style="border-collapse:collapse;table-layout:fixed">`)
+		c.Assert(lines[1], qt.Equals, `<colgroup><col style="width:140px"><col style="width:64px"><col style="width:64px"></colgroup>`)
+		c.Assert(lines[2], qt.Equals, `<tr>`+
+			`<td style="font-family:&#39;Arial&#39;;font-size:11pt;color:#9C0006;font-weight:bold;background-color:#C6EFCE;border-bottom:2px solid #000000;text-align:center;vertical-align:middle;white-space:nowrap">Name</td>`+
+			`<td style="font-family:&#39;Arial&#39;;font-size:11pt;color:#9C0006;font-weight:bold;background-color:#C6EFCE;border-bottom:2px solid #000000;text-align:center;vertical-align:middle;white-space:nowrap">Amount</td>`+
+			`<td style="font-family:&#39;Arial&#39;;font-size:11pt;color:#9C0006;font-weight:bold;background-color:#C6EFCE;border-bottom:2px solid #000000;text-align:center;vertical-align:middle;white-space:nowrap">Link</td>`+
+			`</tr>`)
+		c.Assert(lines[3], qt.Equals, `<tr style="height:30pt">`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap">Fish &amp; &lt;Chips&gt;</td>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;text-align:right;vertical-align:bottom;white-space:nowrap">1,234.50</td>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap"><a href="https://example.com/?a=1&amp;b=2" title="Go there">Example</a></td>`+
+			`</tr>`)
+		// The hidden row and column are left out of the merged cell.
+		c.Assert(lines[4], qt.Equals, `<tr>`+
+			`<td colspan="2" rowspan="2" style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap">Total</td>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;text-align:center;vertical-align:bottom;white-space:nowrap">TRUE</td>`+
+			`</tr>`)
+		c.Assert(lines[5], qt.Equals, `<tr><td></td></tr>`)
+		// Links that could run scripts aren't anchors.
+		c.Assert(lines[6], qt.Equals, `<tr>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap"></td>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap"></td>`+
+			`<td style="font-family:&#39;Verdana&#39;;font-size:12pt;vertical-align:bottom;white-space:nowrap">javascript:alert(1)</td>`+
+			`</tr>`)
+		c.Assert(lines[7:], qt.DeepEquals, []string{"</table>", ""})
+	})
+
+	c.Run("Classes", func(c *qt.C) {
+		out := render(c, htmlSheet(c), HTMLOptions{Classes: true, ClassPrefix: "r-", ShowHidden: true})
+		c.Assert(out, qt.Contains, "<style>\n"+
+			".r-0{font-family:'Arial';font-size:11pt;color:#9C0006;font-weight:bold;background-color:#C6EFCE;border-bottom:2px solid #000000;text-align:center;vertical-align:middle;white-space:nowrap}\n"+
+			".r-1{height:30pt}\n")
+		c.Assert(out, qt.Contains, `<tr class="r-1"><td class="r-2">Fish &amp; &lt;Chips&gt;</td>`)
+		c.Assert(out, qt.Contains, `<col style="width:140px"><col style="width:64px"><col style="width:64px"><col style="width:64px"></colgroup>`)
+		c.Assert(out, qt.Contains, `<td class="r-2">Hidden</td>`)
+		c.Assert(out, qt.Contains, `<td colspan="3" rowspan="2" class="r-2">Total</td>`)
+		c.Assert(strings.Count(out, "<tr"), qt.Equals, 6)
+	})
+
+	c.Run("CSS", func(c *qt.C) {
+		c.Assert(cssColor("FF00ff00"), qt.Equals, "#00FF00")
+		c.Assert(cssColor("123456"), qt.Equals, "#123456")
+		c.Assert(cssColor(""), qt.Equals, "")
+		c.Assert(cssColor("FFGGGGGG"), qt.Equals, "")
+		c.Assert(cssBorder("dashed", ""), qt.Equals, "1px dashed #000000")
+		c.Assert(cssBorder("none", "FF000000"), qt.Equals, "")
+		c.Assert(cssString(`x'</style><script>`), qt.Equals, `'x/stylescript'`)
+		c.Assert(safeHTMLLink("mailto:someone@example.com"), qt.Equals, true)
+		c.Assert(safeHTMLLink("#Sheet2!A1"), qt.Equals, true)
+		c.Assert(safeHTMLLink(" JavaScript:alert(1)"), qt.Equals, false)
+		c.Assert(safeHTMLLink("data:text/html,x"), qt.Equals, false)
+	})
+}
@@ -0,0 +1,475 @@
+package xlsx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// JSONOptions control how sheets are written as JSON and NDJSON.  The
+// zero value writes each row as an array of the values of its cells.
+type JSONOptions struct {
+	// Records writes each row after the first as an object, keyed by
+	// the headers in the first row, rather than as an array.  Columns
+	// without a header, or with the header of an earlier column, are
+	// keyed by their letters, such as "C".
+	Records bool
+}
+
+// jsonWorkbook is the form of a file in JSON.
+type jsonWorkbook struct {
+	Sheets []jsonSheet `json:"sheets"`
+}
+
+type jsonSheet struct {
+	Name string            `json:"name"`
+	Rows []json.RawMessage `json:"rows"`
+}
+
+// jsonFormula is the form in JSON of a cell with a formula.
+type jsonFormula struct {
+	Formula string      `json:"formula"`
+	Value   interface{} `json:"value"`
+}
+
+// jsonDate and jsonError are the forms in JSON of dates and errors.
+// They are tagged, so that they aren't read back as text, and text
+// isn't read back as dates or errors.
+type jsonDate struct {
+	Date string `json:"date"`
+}
+
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// WriteJSON writes the sheets of the file to w as a JSON object, such
+// as
+//
+//	{"sheets":[{"name":"Sheet1","rows":[["Name","Amount"],["Widget",3]]}]}
+//
+// with the rows of each sheet in the form that Sheet.WriteJSON writes.
+func (f *File) WriteJSON(w io.Writer, opts JSONOptions) error {
+	workbook := jsonWorkbook{Sheets: make([]jsonSheet, 0, len(f.Sheets))}
+	for _, sheet := range f.Sheets {
+		rows, err := sheet.jsonRows(opts)
+		if err != nil {
+			return fmt.Errorf("sheet '%s': %v", sheet.Name, err)
+		}
+		workbook.Sheets = append(workbook.Sheets, jsonSheet{Name: sheet.Name, Rows: rows})
+	}
+	data, err := marshalJSON(workbook)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteJSON writes the rows of the sheet to w as a JSON array, with an
+// element for each row up to the last one that is used.  A row is an
+// array with the value of each of its cells, or with opts.Records, an
+// object keyed by the headers.
+//
+// Cells keep their types: numbers are written as numbers, booleans as
+// true and false, text as strings and empty cells as null.  Dates are
+// written as objects with an ISO 8601 date, such as
+// {"date":"2019-11-04"} or {"date":"2019-11-04T10:30:00"}, and errors
+// as objects such as {"error":"#DIV/0!"}.  A cell with a formula is
+// written as an object with the formula and its value, such as
+// {"formula":"SUM(B2:B3)","value":7}.
+func (s *Sheet) WriteJSON(w io.Writer, opts JSONOptions) error {
+	rows, err := s.jsonRows(opts)
+	if err != nil {
+		return err
+	}
+	if rows == nil {
+		rows = []json.RawMessage{}
+	}
+	data, err := marshalJSON(rows)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteNDJSON writes the rows of the sheet to w as newline delimited
+// JSON, one row per line, in the form that Sheet.WriteJSON writes.
+func (s *Sheet) WriteNDJSON(w io.Writer, opts JSONOptions) error {
+	rows, err := s.jsonRows(opts)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, row := range rows {
+		buf.Write(row)
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// jsonRows returns the rows of the sheet in JSON.
+func (s *Sheet) jsonRows(opts JSONOptions) ([]json.RawMessage, error) {
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+	maxRow, _ := s.extent()
+	first := 0
+	var keys []string
+	if opts.Records {
+		first = 1
+		keys = s.jsonKeys()
+	}
+	var rows []json.RawMessage
+	for y := first; y < maxRow; y++ {
+		var cells []*Cell
+		if y < len(s.Rows) && s.Rows[y] != nil {
+			cells = s.Rows[y].Cells
+		}
+		values := make([]interface{}, len(cells))
+		for x, cell := range cells {
+			value, err := jsonValue(cell)
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, y), err)
+			}
+			values[x] = value
+		}
+		var row json.RawMessage
+		var err error
+		if opts.Records {
+			row, err = jsonRecord(keys, values)
+		} else {
+			row, err = marshalJSON(values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", y+1, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// jsonKeys returns the key of each column of the first row in records.
+func (s *Sheet) jsonKeys() []string {
+	if len(s.Rows) == 0 || s.Rows[0] == nil {
+		return nil
+	}
+	keys := make([]string, len(s.Rows[0].Cells))
+	seen := make(map[string]bool)
+	for x, cell := range s.Rows[0].Cells {
+		if cell == nil {
+			continue
+		}
+		header := cell.String()
+		if header != "" && !seen[header] {
+			keys[x] = header
+			seen[header] = true
+		}
+	}
+	return keys
+}
+
+// jsonRecord returns a row as a JSON object, with the keys in the order
+// of the columns.
+func jsonRecord(keys []string, values []interface{}) (json.RawMessage, error) {
+	n := len(keys)
+	if len(values) > n {
+		n = len(values)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for x := 0; x < n; x++ {
+		key := ColIndexToLetters(x)
+		if x < len(keys) && keys[x] != "" {
+			key = keys[x]
+		}
+		var value interface{}
+		if x < len(values) {
+			value = values[x]
+		}
+		k, err := marshalJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshalJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		if x > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is json.Marshal, without escaping <, > and &, which
+// would only be needed if the JSON were put in HTML.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonValue returns the value of a cell in JSON.  cell may be nil.
+func jsonValue(cell *Cell) (interface{}, error) {
+	if cell == nil {
+		return nil, nil
+	}
+	var value interface{}
+	switch cell.Type() {
+	case CellTypeBool:
+		value = cell.Bool()
+	case CellTypeNumeric:
+		if cell.Value == "" {
+			break
+		}
+		if cell.IsTime() {
+			if t, err := cell.GetTime(cell.date1904); err == nil {
+				value = jsonDate{jsonTime(t)}
+				break
+			}
+		}
+		if numberRegexp.MatchString(cell.Value) {
+			value = json.Number(cell.Value)
+			break
+		}
+		n, err := strconv.ParseFloat(cell.Value, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, fmt.Errorf("cannot write %q as a number", cell.Value)
+		}
+		value = json.Number(strconv.FormatFloat(n, 'g', -1, 64))
+	case CellTypeDate:
+		if cell.Value != "" {
+			value = jsonDate{cell.Value}
+		}
+	case CellTypeError:
+		if cell.Value != "" {
+			value = jsonError{cell.Value}
+		}
+	default:
+		if cell.Value != "" {
+			value = cell.Value
+		}
+	}
+	if cell.Formula() != "" {
+		return jsonFormula{Formula: cell.Formula(), Value: value}, nil
+	}
+	return value, nil
+}
+
+// jsonTime returns a time in ISO 8601, without the time of day if it is
+// midnight.  Times are rounded to the millisecond, which hides the
+// rounding errors of their representation in spreadsheets.
+func jsonTime(t time.Time) string {
+	t = t.Round(time.Millisecond)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02T15:04:05.999")
+}
+
+// ImportJSON reads sheets from r, in the form that File.WriteJSON
+// writes, and adds them to the file.  The rows of each sheet are read
+// as by File.ImportNDJSON.
+func (f *File) ImportJSON(r io.Reader) ([]*Sheet, error) {
+	var workbook jsonWorkbook
+	if err := json.NewDecoder(r).Decode(&workbook); err != nil {
+		return nil, err
+	}
+	sheets := make([]*Sheet, 0, len(workbook.Sheets))
+	for _, js := range workbook.Sheets {
+		sheet, err := f.AddSheet(js.Name)
+		if err != nil {
+			return sheets, err
+		}
+		sheets = append(sheets, sheet)
+		jr := &jsonRowReader{sheet: sheet}
+		for _, row := range js.Rows {
+			if err := jr.addRow(row); err != nil {
+				return sheets, fmt.Errorf("sheet '%s': %v", js.Name, err)
+			}
+		}
+	}
+	return sheets, nil
+}
+
+// ImportNDJSON reads rows from r, one JSON value per line, into a new
+// sheet called sheetName.  A row that is an array becomes a row of
+// cells.  A row that is an object is a record: its values are put in
+// the columns of its keys, whose headers are in the first row of the
+// sheet, and keys that haven't been seen before add columns.
+//
+// Values become cells of their types, and strings become text.  Objects
+// with a date in an ISO 8601 layout, such as {"date":"2019-11-04"},
+// become dates with the default date or date and time format, objects
+// with an error become errors, and objects with a formula become cells
+// with that formula and value, as Sheet.WriteJSON writes them.
+func (f *File) ImportNDJSON(r io.Reader, sheetName string) (*Sheet, error) {
+	sheet, err := f.AddSheet(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	jr := &jsonRowReader{sheet: sheet}
+	dec := json.NewDecoder(r)
+	for {
+		var row json.RawMessage
+		err := dec.Decode(&row)
+		if err == io.EOF {
+			return sheet, nil
+		}
+		if err != nil {
+			return sheet, err
+		}
+		if err := jr.addRow(row); err != nil {
+			return sheet, err
+		}
+	}
+}
+
+// jsonRowReader adds rows read from JSON to a sheet.
+type jsonRowReader struct {
+	sheet *Sheet
+	// header is the row of the headers of records, and columns the
+	// column of each header.
+	header  *Row
+	columns map[string]int
+}
+
+func (jr *jsonRowReader) addRow(data json.RawMessage) error {
+	y := len(jr.sheet.Rows)
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		return jr.addRecord(data)
+	}
+	if len(data) == 0 || data[0] != '[' {
+		return fmt.Errorf("row %d: not an array or an object", y+1)
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("row %d: %v", y+1, err)
+	}
+	row := jr.sheet.AddRow()
+	for x, value := range values {
+		if err := setJSONCell(row.AddCell(), value); err != nil {
+			return fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, y), err)
+		}
+	}
+	return nil
+}
+
+// addRecord adds a row from a JSON object.  The keys are read in order,
+// so that the columns are in the order in which they were written.
+func (jr *jsonRowReader) addRecord(data json.RawMessage) error {
+	if jr.header == nil {
+		if len(jr.sheet.Rows) == 0 {
+			jr.sheet.AddRow()
+		}
+		jr.header = jr.sheet.Rows[0]
+		jr.columns = make(map[string]int)
+		for x, cell := range jr.header.Cells {
+			if _, ok := jr.columns[cell.Value]; cell.Value != "" && !ok {
+				jr.columns[cell.Value] = x
+			}
+		}
+	}
+	y := len(jr.sheet.Rows)
+	row := jr.sheet.AddRow()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("row %d: %v", y+1, err)
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("row %d: %v", y+1, err)
+		}
+		key := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("row %d: %v", y+1, err)
+		}
+		x, ok := jr.columns[key]
+		if !ok {
+			x = len(jr.header.Cells)
+			jr.header.AddCell().SetString(key)
+			jr.columns[key] = x
+		}
+		for len(row.Cells) <= x {
+			row.AddCell()
+		}
+		if err := setJSONCell(row.Cells[x], value); err != nil {
+			return fmt.Errorf("cell %s (%s): %v", GetCellIDStringFromCoords(x, y), key, err)
+		}
+	}
+	return nil
+}
+
+// setJSONCell sets the value of a cell from JSON.
+func setJSONCell(cell *Cell, data json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	var formula string
+	if object, ok := value.(map[string]interface{}); ok && object["formula"] != nil {
+		if formula, ok = object["formula"].(string); !ok || formula == "" {
+			return errors.New("an object must have a formula")
+		}
+		value = object["value"]
+	}
+	switch v := value.(type) {
+	case nil:
+		if formula != "" {
+			cell.SetFormula(formula)
+		}
+		return nil
+	case bool:
+		cell.SetBool(v)
+	case json.Number:
+		cell.SetNumeric(v.String())
+	case string:
+		cell.SetString(v)
+	case map[string]interface{}:
+		if date, ok := v["date"].(string); ok && len(v) == 1 {
+			switch kind, t := inferDate(date, ""); kind {
+			case csvDate:
+				cell.SetDate(t)
+			case csvDateTime:
+				cell.SetDateTime(t)
+			default:
+				return fmt.Errorf("cannot read %q as a date", date)
+			}
+		} else if text, ok := v["error"].(string); ok && len(v) == 1 {
+			cell.Value = text
+			cell.cellType = CellTypeError
+		} else {
+			return errors.New("an object must have a formula, a date or an error")
+		}
+	default:
+		return fmt.Errorf("cannot read %s as a cell", data)
+	}
+	if formula != "" {
+		cell.formula = formula
+		if cell.cellType == CellTypeString {
+			cell.cellType = CellTypeStringFormula
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package xlsx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestJSON(t *testing.T) {
+	c := qt.New(t)
+
+	// jsonFile returns a file with a sheet of text, numbers, dates,
+	// booleans, errors and a formula.
+	jsonFile := func(c *qt.C) *File {
+		file := NewFile()
+		sheet, err := file.AddSheet("Orders")
+		c.Assert(err, qt.IsNil)
+		for x, header := range []string{"Name", "Amount", "Due", "At", "Paid", "", "Name"} {
+			sheet.Cell(0, x).SetString(header)
+		}
+		sheet.Cell(1, 0).SetString(`Widget "large" <b>`)
+		sheet.Cell(1, 1).SetFloatWithFormat(1234.5, "#,##0.00")
+		sheet.Cell(1, 2).SetDate(time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+		sheet.Cell(1, 3).SetDateTime(time.Date(2019, 11, 4, 10, 30, 0, 0, time.UTC))
+		sheet.Cell(1, 4).SetBool(true)
+		sheet.Cell(1, 5).SetString("x")
+		sheet.Cell(1, 6).SetString("y")
+		sheet.Cell(2, 0).SetString("Total")
+		sheet.Cell(2, 1).SetFormula("SUM(B2:B2)")
+		sheet.Cell(2, 1).Value = "1234.5"
+		sheet.Cell(2, 7).SetInt(-3)
+		sheet.Cell(3, 0).SetString("2019-11-04")
+		sheet.Cell(3, 1).SetFormula("1/0")
+		sheet.Cell(3, 1).Value = "#DIV/0!"
+		sheet.Cell(3, 1).cellType = CellTypeError
+		sheet.Cell(3, 2).SetString("#N/A")
+		return file
+	}
+
+	c.Run("Grid", func(c *qt.C) {
+		var buf bytes.Buffer
+		c.Assert(jsonFile(c).WriteJSON(&buf, JSONOptions{}), qt.IsNil)
+		c.Assert(buf.String(), qt.Equals, `{"sheets":[{"name":"Orders","rows":[`+
+			`["Name","Amount","Due","At","Paid",null,"Name"],`+
+			`["Widget \"large\" <b>",1234.5,{"date":"2019-11-04"},{"date":"2019-11-04T10:30:00"},true,"x","y"],`+
+			`["Total",{"formula":"SUM(B2:B2)","value":1234.5},null,null,null,null,null,-3],`+
+			`["2019-11-04",{"formula":"1/0","value":{"error":"#DIV/0!"}},"#N/A"]`+
+			`]}]}`+"\n")
+	})
+
+	c.Run("Records", func(c *qt.C) {
+		sheet := jsonFile(c).Sheets[0]
+		var buf bytes.Buffer
+		c.Assert(sheet.WriteNDJSON(&buf, JSONOptions{Records: true}), qt.IsNil)
+		c.Assert(buf.String(), qt.Equals, ""+
+			`{"Name":"Widget \"large\" <b>","Amount":1234.5,"Due":{"date":"2019-11-04"},"At":{"date":"2019-11-04T10:30:00"},"Paid":true,"F":"x","G":"y"}`+"\n"+
+			`{"Name":"Total","Amount":{"formula":"SUM(B2:B2)","value":1234.5},"Due":null,"At":null,"Paid":null,"F":null,"G":null,"H":-3}`+"\n"+
+			`{"Name":"2019-11-04","Amount":{"formula":"1/0","value":{"error":"#DIV/0!"}},"Due":"#N/A","At":null,"Paid":null,"F":null,"G":null}`+"\n")
+
+		buf.Reset()
+		empty, err := NewFile().AddSheet("Empty")
+		c.Assert(err, qt.IsNil)
+		c.Assert(empty.WriteJSON(&buf, JSONOptions{Records: true}), qt.IsNil)
+		c.Assert(buf.String(), qt.Equals, "[]\n")
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		var buf bytes.Buffer
+		c.Assert(jsonFile(c).WriteJSON(&buf, JSONOptions{}), qt.IsNil)
+		file := NewFile()
+		sheets, err := file.ImportJSON(&buf)
+		c.Assert(err, qt.IsNil)
+		c.Assert(sheets, qt.HasLen, 1)
+		sheet := file.Sheet["Orders"]
+		c.Assert(sheets[0], qt.Equals, sheet)
+		c.Assert(sheet.Cell(1, 0).Value, qt.Equals, `Widget "large" <b>`)
+		c.Assert(sheet.Cell(1, 1).Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "1234.5")
+		due, err := sheet.Cell(1, 2).GetTime(false)
+		c.Assert(err, qt.IsNil)
+		c.Assert(due, qt.Equals, time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+		c.Assert(sheet.Cell(1, 2).NumFmt, qt.Equals, DefaultDateFormat)
+		c.Assert(sheet.Cell(1, 3).NumFmt, qt.Equals, DefaultDateTimeFormat)
+		c.Assert(sheet.Cell(1, 4).Type(), qt.Equals, CellTypeBool)
+		c.Assert(sheet.Cell(1, 4).Bool(), qt.Equals, true)
+		c.Assert(sheet.Cell(2, 1).Formula(), qt.Equals, "SUM(B2:B2)")
+		c.Assert(sheet.Cell(2, 1).Value, qt.Equals, "1234.5")
+		c.Assert(sheet.Cell(2, 1).Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(2, 2).Value, qt.Equals, "")
+
+		// Text that looks like a date or an error stays text.
+		c.Assert(sheet.Cell(3, 0).Type(), qt.Equals, CellTypeString)
+		c.Assert(sheet.Cell(3, 0).Value, qt.Equals, "2019-11-04")
+		c.Assert(sheet.Cell(3, 1).Type(), qt.Equals, CellTypeError)
+		c.Assert(sheet.Cell(3, 1).Value, qt.Equals, "#DIV/0!")
+		c.Assert(sheet.Cell(3, 1).Formula(), qt.Equals, "1/0")
+		c.Assert(sheet.Cell(3, 2).Type(), qt.Equals, CellTypeString)
+
+		// The sheet is written back as it was read.
+		var out bytes.Buffer
+		c.Assert(sheet.WriteNDJSON(&out, JSONOptions{}), qt.IsNil)
+		var want bytes.Buffer
+		c.Assert(jsonFile(c).Sheets[0].WriteNDJSON(&want, JSONOptions{}), qt.IsNil)
+		c.Assert(out.String(), qt.Equals, want.String())
+	})
+
+	c.Run("ImportNDJSON", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.ImportNDJSON(strings.NewReader(""+
+			`{"id":1,"name":"Widget","due":{"date":"2019-11-04"},"code":"007","day":"2019-11-05"}`+"\n"+
+			"\n"+
+			`{"name":"Gadget","id":2,"paid":false,"total":{"formula":"\"a\"&\"b\"","value":"ab"}}`+"\n"+
+			`{}`+"\n"), "Imported")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Imported"], qt.Equals, sheet)
+		c.Assert(sheet.MaxRow, qt.Equals, 4)
+
+		headers := []string{}
+		for _, cell := range sheet.Rows[0].Cells {
+			headers = append(headers, cell.Value)
+		}
+		c.Assert(headers, qt.DeepEquals, []string{"id", "name", "due", "code", "day", "paid", "total"})
+		c.Assert(sheet.Cell(1, 0).Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(1, 2).IsTime(), qt.Equals, true)
+		c.Assert(sheet.Cell(1, 3).Type(), qt.Equals, CellTypeString)
+		c.Assert(sheet.Cell(1, 3).Value, qt.Equals, "007")
+		c.Assert(sheet.Cell(1, 4).Type(), qt.Equals, CellTypeString)
+		c.Assert(sheet.Cell(2, 0).Value, qt.Equals, "2")
+		c.Assert(sheet.Cell(2, 1).Value, qt.Equals, "Gadget")
+		c.Assert(sheet.Cell(2, 5).Type(), qt.Equals, CellTypeBool)
+		c.Assert(sheet.Cell(2, 6).Type(), qt.Equals, CellTypeStringFormula)
+		c.Assert(sheet.Cell(2, 6).Formula(), qt.Equals, `"a"&"b"`)
+		c.Assert(sheet.Cell(2, 6).Value, qt.Equals, "ab")
+		c.Assert(sheet.Rows[3].Cells, qt.HasLen, 0)
+
+		// Arrays are rows of cells.
+		sheet, err = file.ImportNDJSON(strings.NewReader(`["a",1.5,null,true]`+"\n"+`[]`), "Grid")
+		c.Assert(err, qt.IsNil)
+		c.Assert(sheet.MaxRow, qt.Equals, 2)
+		c.Assert(sheet.Rows[0].Cells, qt.HasLen, 4)
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "1.5")
+		c.Assert(sheet.Cell(0, 2).Value, qt.Equals, "")
+	})
+
+	c.Run("Errors", func(c *qt.C) {
+		file := NewFile()
+		_, err := file.ImportNDJSON(strings.NewReader(`["a"]`+"\n"+`5`), "Number")
+		c.Assert(err, qt.ErrorMatches, `row 2: not an array or an object`)
+		_, err = file.ImportNDJSON(strings.NewReader(`["a",[1]]`), "Nested")
+		c.Assert(err, qt.ErrorMatches, `cell B1: cannot read \[1\] as a cell`)
+		_, err = file.ImportNDJSON(strings.NewReader(`{"a":{"value":1}}`), "Object")
+		c.Assert(err, qt.ErrorMatches, `cell A2 \(a\): an object must have a formula, a date or an error`)
+		_, err = file.ImportNDJSON(strings.NewReader(`[{"date":"soon"}]`), "Date")
+		c.Assert(err, qt.ErrorMatches, `cell A1: cannot read "soon" as a date`)
+		_, err = file.ImportNDJSON(strings.NewReader(`["a"`), "Broken")
+		c.Assert(err, qt.ErrorMatches, `unexpected EOF`)
+		_, err = file.ImportJSON(strings.NewReader(`{"sheets":[{"name":"Number","rows":[]}]}`))
+		c.Assert(err, qt.ErrorMatches, `duplicate sheet name 'Number'.`)
+		_, err = file.ImportJSON(strings.NewReader(`{"sheets":[{"name":"Rows","rows":[["a"],"b"]}]}`))
+		c.Assert(err, qt.ErrorMatches, `sheet 'Rows': row 2: not an array or an object`)
+
+		sheet, err := file.AddSheet("Bad")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetNumeric("lots")
+		c.Assert(sheet.WriteJSON(&bytes.Buffer{}, JSONOptions{}), qt.ErrorMatches, `cell A1: cannot write "lots" as a number`)
+		c.Assert(file.WriteJSON(&bytes.Buffer{}, JSONOptions{}), qt.ErrorMatches, `sheet 'Bad': cell A1: cannot write "lots" as a number`)
+	})
+}
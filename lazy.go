@@ -0,0 +1,208 @@
+package xlsx
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+// A file that is opened with the Lazy option holds placeholders for
+// its sheets, which are read when they are first used.  The sheets
+// that haven't been read when the file is written are copied from the
+// file that was read, along with the parts they refer to, and so the
+// styles and shared strings of that file are kept for them as well.
+
+// lazySheet holds what is needed to read a sheet of a file that was
+// opened with the Lazy option, when the sheet is first used.
+type lazySheet struct {
+	rsheet      xlsxSheet
+	sheetXMLMap map[string]string
+	options     ReadOptions
+	loaded      bool
+	// err is the error that reading the sheet gave, if any.
+	err error
+}
+
+// newLazySheet returns the placeholder for a sheet of a file that is
+// opened with the Lazy option.
+func newLazySheet(rsheet xlsxSheet, file *File, sheetXMLMap map[string]string, options ReadOptions) *Sheet {
+	sheet := &Sheet{
+		Name:   rsheet.Name,
+		File:   file,
+		Hidden: rsheet.State == sheetStateHidden || rsheet.State == sheetStateVeryHidden,
+		lazy:   &lazySheet{rsheet: rsheet, sheetXMLMap: sheetXMLMap, options: options},
+	}
+	if worksheetFile := worksheetFileForSheet(rsheet, file.worksheets, sheetXMLMap); worksheetFile != nil {
+		sheet.part = worksheetFile.Name
+	}
+	return sheet
+}
+
+// Load reads the sheet, if it belongs to a file that was opened with
+// the Lazy option and hasn't been read yet.  Methods such as Cell, Row
+// and AddRow read the sheet when they are first called, but fields
+// such as Rows and MaxRow are empty until the sheet has been read.
+// If the sheet can't be read, it is left empty, and the error is
+// returned each time Load is called.
+func (s *Sheet) Load() (err error) {
+	l := s.lazy
+	if l == nil {
+		return nil
+	}
+	if l.loaded {
+		return l.err
+	}
+	l.loaded = true
+	name, selected := s.Name, s.Selected
+	defer func() {
+		if e := recover(); e != nil {
+			err = &ReadError{Err: fmt.Errorf("unexpected error: %v", e)}
+		}
+		if err != nil {
+			s.clear()
+			err = wrapReadError(err, s.part, l.rsheet.Name)
+		}
+		// The sheet may have been renamed before it was read.
+		s.Name, s.Selected = name, selected
+		l.err = err
+	}()
+	return readSheet(s, l.rsheet, s.File, l.sheetXMLMap, l.options)
+}
+
+// Unload releases what has been read of a sheet of a file that was
+// opened with the Lazy option, so that the sheet is read again when it
+// is next used.  Changes made to the sheet are lost, and until it is
+// read again the sheet is written as it was in the file.  Unload does
+// nothing to other sheets.
+func (s *Sheet) Unload() {
+	if s.lazy == nil {
+		return
+	}
+	s.clear()
+	s.lazy.loaded = false
+	s.lazy.err = nil
+}
+
+// load reads the sheet, if it hasn't been read yet, for the methods
+// that use its contents.  The error is left for Load to return.
+func (s *Sheet) load() {
+	if s.lazy != nil && !s.lazy.loaded {
+		s.Load()
+	}
+}
+
+// clear leaves the sheet as its placeholder was.
+func (s *Sheet) clear() {
+	*s = Sheet{
+		Name:     s.Name,
+		File:     s.File,
+		Hidden:   s.Hidden,
+		Selected: s.Selected,
+		part:     s.part,
+		lazy:     s.lazy,
+	}
+}
+
+// writesOriginal reports whether the sheet is written as it was in the
+// file that was read, which is the case for a sheet of a file that was
+// opened with the Lazy option that hasn't been read.  That needs the
+// parts that the sheet refers to, and the styles that its cells refer
+// to, to have been kept.
+func (s *Sheet) writesOriginal() bool {
+	l := s.lazy
+	if l == nil || l.loaded || s.File == nil || !s.File.passThrough() {
+		return false
+	}
+	return !l.options.SkipStyles && !l.options.ValuesOnly && path.Dir(s.part) == "xl/worksheets"
+}
+
+// readLazily reports whether the file was opened with the Lazy option.
+// The styles and shared strings of such a file are kept when it is
+// written, as the sheets that are written as they were refer to them
+// by index.
+func (f *File) readLazily() bool {
+	for _, sheet := range f.Sheets {
+		if sheet.lazy != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unreadSheets returns the sheets of a file that was opened with the
+// Lazy option that haven't been read.
+func (f *File) unreadSheets() []*Sheet {
+	var sheets []*Sheet
+	for _, sheet := range f.Sheets {
+		if sheet.lazy != nil && !sheet.lazy.loaded {
+			sheets = append(sheets, sheet)
+		}
+	}
+	return sheets
+}
+
+// writeOriginalSheet adds a sheet that is written as it was in the
+// file that was read to parts, as the sheetIndex'th sheet of the
+// workbook.
+func (f *File) writeOriginalSheet(sheet *Sheet, sheetIndex int, parts map[string]string, types *xlsxTypes, workbookRels WorkBookRels, workbook *xlsxWorkbook) error {
+	data, err := readZipPart(f.parts, sheet.part)
+	if err != nil {
+		return &ReadError{Part: sheet.part, Sheet: sheet.Name, Err: err}
+	}
+	rId := fmt.Sprintf("rId%d", sheetIndex)
+	sheetPath := fmt.Sprintf("worksheets/sheet%d.xml", sheetIndex)
+	partName := "xl/" + sheetPath
+	parts[partName] = string(data)
+	// The targets of the relationships are relative to the
+	// worksheets directory, so they stay the same.
+	if rels, ok := f.original.parts[relsPartName(sheet.part)]; ok {
+		parts[relsPartName(partName)] = rels
+	}
+	types.Overrides = append(
+		types.Overrides,
+		xlsxOverride{
+			PartName:    "/" + partName,
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"})
+	workbookRels[rId] = sheetPath
+	workbook.Sheets.Sheet[sheetIndex-1] = xlsxSheet{
+		Name:    sheet.Name,
+		SheetId: strconv.Itoa(sheetIndex),
+		Id:      rId,
+		State:   "visible"}
+	return nil
+}
+
+var tableIDRegexp = regexp.MustCompile(`<table\s[^>]*?\bid="(\d+)"`)
+
+// maxOriginalTableID returns the highest id of the tables among the
+// parts that are kept from the file that was read, so that the tables
+// that are written don't take their ids.
+func (f *File) maxOriginalTableID(keep map[string]bool) int {
+	max := 0
+	for name := range keep {
+		if path.Dir(name) != "xl/tables" {
+			continue
+		}
+		if match := tableIDRegexp.FindStringSubmatch(f.original.parts[name]); match != nil {
+			if id, _ := strconv.Atoi(match[1]); id > max {
+				max = id
+			}
+		}
+	}
+	return max
+}
+
+// recount sets the counts of the lists of a style sheet that was read
+// to their lengths, as the counts are used as the index of the next
+// item that is added.
+func (styles *xlsxStyleSheet) recount() {
+	styles.Fonts.Count = len(styles.Fonts.Font)
+	styles.Fills.Count = len(styles.Fills.Fill)
+	styles.Borders.Count = len(styles.Borders.Border)
+	if styles.CellStyleXfs != nil {
+		styles.CellStyleXfs.Count = len(styles.CellStyleXfs.Xf)
+	}
+	styles.CellXfs.Count = len(styles.CellXfs.Xf)
+	styles.DXfs.Count = len(styles.DXfs.Dxf)
+}
@@ -0,0 +1,176 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// unzipParts returns the parts of the zip file data.
+func unzipParts(c *qt.C, data []byte) map[string]string {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	c.Assert(err, qt.IsNil)
+	parts := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		c.Assert(err, qt.IsNil)
+		part, err := ioutil.ReadAll(rc)
+		c.Assert(err, qt.IsNil)
+		rc.Close()
+		parts[f.Name] = string(part)
+	}
+	return parts
+}
+
+func TestLazy(t *testing.T) {
+	c := qt.New(t)
+
+	// lazyParts returns the parts of a file with the sheets Summary,
+	// Data and Notes.
+	lazyParts := func(c *qt.C) map[string]string {
+		file := NewFile()
+		summary, err := file.AddSheet("Summary")
+		c.Assert(err, qt.IsNil)
+		summary.Cell(0, 0).SetString("Total")
+		summary.Cell(0, 1).SetFormula("SUM(Data!B2:B3)")
+		data, err := file.AddSheet("Data")
+		c.Assert(err, qt.IsNil)
+		data.Cell(0, 0).SetString("Name")
+		data.Cell(0, 1).SetString("Amount")
+		data.Cell(1, 0).SetString("Widget")
+		data.Cell(1, 1).SetInt(3)
+		data.Cell(2, 0).SetString("Gadget")
+		data.Cell(2, 1).SetInt(4)
+		bold := NewStyle()
+		bold.Font.Bold = true
+		bold.ApplyFont = true
+		data.Cell(0, 0).SetStyle(bold)
+		data.Cell(1, 0).Comment = Comment{Author: "Ann", Text: "Best seller"}
+		data.Cell(2, 0).SetHyperlink("https://example.com/gadget", "Gadget", "")
+		_, err = data.AddTable("Stock", "A1:B3", []string{"Name", "Amount"}, "", false)
+		c.Assert(err, qt.IsNil)
+		notes, err := file.AddSheet("Notes")
+		c.Assert(err, qt.IsNil)
+		notes.Cell(0, 0).SetString("Widget")
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		return parts
+	}
+	openLazily := func(c *qt.C, parts map[string]string) *File {
+		file, err := OpenBinary(zipParts(c, parts), Lazy())
+		c.Assert(err, qt.IsNil)
+		return file
+	}
+
+	c.Run("Load", func(c *qt.C) {
+		file := openLazily(c, lazyParts(c))
+		c.Assert(file.Sheets, qt.HasLen, 3)
+		for _, sheet := range file.Sheets {
+			c.Assert(sheet.lazy.loaded, qt.Equals, false)
+			c.Assert(sheet.Rows, qt.IsNil)
+		}
+
+		// The sheet is read when it is first used.
+		data := file.Sheet["Data"]
+		c.Assert(data.Cell(1, 0).Value, qt.Equals, "Widget")
+		c.Assert(data.lazy.loaded, qt.Equals, true)
+		c.Assert(data.MaxRow, qt.Equals, 3)
+		c.Assert(data.Cell(0, 0).GetStyle().Font.Bold, qt.Equals, true)
+		c.Assert(data.Cell(1, 0).Comment.Text, qt.Equals, "Best seller")
+		c.Assert(data.Tables, qt.HasLen, 1)
+		c.Assert(data.Load(), qt.IsNil)
+		c.Assert(file.Sheet["Notes"].lazy.loaded, qt.Equals, false)
+
+		data.Unload()
+		c.Assert(data.lazy.loaded, qt.Equals, false)
+		c.Assert(data.Rows, qt.IsNil)
+		c.Assert(data.Name, qt.Equals, "Data")
+		c.Assert(data.Load(), qt.IsNil)
+		c.Assert(data.Cell(2, 1).Value, qt.Equals, "4")
+
+		// Formulas read the sheets they refer to.
+		summary := file.Sheet["Summary"]
+		c.Assert(summary.Calculate(), qt.IsNil)
+		c.Assert(summary.Cell(0, 1).Value, qt.Equals, "7")
+	})
+
+	c.Run("WriteUntouched", func(c *qt.C) {
+		parts := lazyParts(c)
+		file := openLazily(c, parts)
+		summary := file.Sheet["Summary"]
+		summary.Cell(1, 0).SetString("Checked")
+		italic := NewStyle()
+		italic.Font.Italic = true
+		italic.ApplyFont = true
+		summary.Cell(1, 0).SetStyle(italic)
+		// A sheet that is unloaded is written as it was.
+		c.Assert(file.Sheet["Data"].Load(), qt.IsNil)
+		file.Sheet["Data"].Unload()
+
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+		c.Assert(file.Sheet["Notes"].lazy.loaded, qt.Equals, false)
+		written := unzipParts(c, buf.Bytes())
+		c.Assert(written["xl/worksheets/sheet2.xml"], qt.Equals, parts["xl/worksheets/sheet2.xml"])
+		c.Assert(written["xl/worksheets/sheet3.xml"], qt.Equals, parts["xl/worksheets/sheet3.xml"])
+		c.Assert(written["xl/worksheets/_rels/sheet2.xml.rels"], qt.Equals, parts["xl/worksheets/_rels/sheet2.xml.rels"])
+		c.Assert(written["xl/worksheets/sheet1.xml"], qt.Not(qt.Equals), parts["xl/worksheets/sheet1.xml"])
+
+		// The untouched sheets still refer to the right shared
+		// strings and styles, and keep the parts they refer to.
+		file, err := OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		summary = file.Sheet["Summary"]
+		c.Assert(summary.Cell(1, 0).Value, qt.Equals, "Checked")
+		c.Assert(summary.Cell(1, 0).GetStyle().Font.Italic, qt.Equals, true)
+		data := file.Sheet["Data"]
+		c.Assert(data.Cell(2, 0).Value, qt.Equals, "Gadget")
+		c.Assert(data.Cell(0, 0).GetStyle().Font.Bold, qt.Equals, true)
+		c.Assert(data.Cell(0, 0).GetStyle().Font.Italic, qt.Equals, false)
+		c.Assert(data.Cell(1, 0).Comment.Text, qt.Equals, "Best seller")
+		c.Assert(data.Cell(2, 0).Hyperlink.Link, qt.Equals, "https://example.com/gadget")
+		c.Assert(data.Tables, qt.HasLen, 1)
+		c.Assert(data.Tables[0].Name, qt.Equals, "Stock")
+		c.Assert(file.Sheet["Notes"].Cell(0, 0).Value, qt.Equals, "Widget")
+	})
+
+	c.Run("WithoutPassThrough", func(c *qt.C) {
+		file := openLazily(c, lazyParts(c))
+		file.PassThrough = false
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+		// The sheets are read to be written from the model.
+		c.Assert(file.Sheet["Data"].lazy.loaded, qt.Equals, true)
+		file, err := OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Data"].Cell(1, 1).Value, qt.Equals, "3")
+	})
+
+	c.Run("Broken", func(c *qt.C) {
+		parts := lazyParts(c)
+		parts["xl/worksheets/sheet3.xml"] = `<broken`
+		file := openLazily(c, parts)
+		notes := file.Sheet["Notes"]
+		c.Assert(notes.Load(), qt.ErrorMatches, `Sheet 'Notes': XML syntax error .*`)
+		c.Assert(notes.Load(), qt.ErrorMatches, `Sheet 'Notes': XML syntax error .*`)
+		c.Assert(notes.Rows, qt.IsNil)
+		c.Assert(file.Write(ioutil.Discard), qt.ErrorMatches, `Sheet 'Notes': XML syntax error .*`)
+	})
+
+	c.Run("OpenFile", func(c *qt.C) {
+		dir, err := ioutil.TempDir("", "xlsx")
+		c.Assert(err, qt.IsNil)
+		defer os.RemoveAll(dir)
+		name := filepath.Join(dir, "lazy.xlsx")
+		c.Assert(ioutil.WriteFile(name, zipParts(c, lazyParts(c)), 0644), qt.IsNil)
+		file, err := OpenFile(name, Lazy(), OnlySheets("Data", "Notes"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 2)
+		c.Assert(file.Sheet["Data"].Cell(1, 1).Value, qt.Equals, "3")
+	})
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -31,12 +33,154 @@ func (e *XLSXReaderError) Error() string {
 	return e.Err
 }
 
+// ReadError is the error returned when a part of an XLSX file cannot
+// be read.  It records where the problem was found, as far as that is
+// known, and the error that caused it, which Unwrap returns.
+type ReadError struct {
+	// Part is the name of the part in the zip file, such as
+	// "xl/worksheets/sheet1.xml".
+	Part string
+	// Sheet is the name of the sheet, if the part belongs to one.
+	Sheet string
+	// Cell is the reference of the cell, such as "D17", if the
+	// problem is with a single cell.
+	Cell string
+	Err  error
+}
+
+// Error returns a description of the error for end users, such as
+// "Sheet 'Orders' cell D17: invalid shared string index 12".
+func (e *ReadError) Error() string {
+	var where []string
+	if e.Sheet != "" {
+		where = append(where, fmt.Sprintf("Sheet '%s'", e.Sheet))
+	} else if e.Part != "" {
+		where = append(where, e.Part)
+	}
+	if e.Cell != "" {
+		where = append(where, "cell "+e.Cell)
+	}
+	if len(where) == 0 {
+		return e.Err.Error()
+	}
+	return strings.Join(where, " ") + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error that caused the ReadError.
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// wrapReadError returns err as a ReadError for a part and sheet.  If
+// err is already a ReadError, the fields that it leaves empty are
+// filled in instead, so that errors are not wrapped twice.
+func wrapReadError(err error, part, sheet string) error {
+	if err == nil {
+		return nil
+	}
+	readErr, ok := err.(*ReadError)
+	if !ok {
+		return &ReadError{Part: part, Sheet: sheet, Err: err}
+	}
+	if readErr.Part == "" {
+		readErr.Part = part
+	}
+	if readErr.Sheet == "" {
+		readErr.Sheet = sheet
+	}
+	return readErr
+}
+
+// readWarnings collects the problems found reading a file in lenient
+// mode.  Sheets may be read concurrently, so it is guarded by a mutex.
+type readWarnings struct {
+	mutex    sync.Mutex
+	warnings []*ReadError
+	// noSharedStrings holds, by sheet, the warning about cells that
+	// refer to a missing shared strings table.
+	noSharedStrings map[string]*ReadError
+	// brokenSharedStrings is set once a shared strings table that
+	// can't be read has been reported, which is the cause of any
+	// missing shared strings.
+	brokenSharedStrings bool
+}
+
+// warn records err as a warning about a part and sheet of the file,
+// and returns true, if the file is being read in lenient mode.
+// Otherwise it returns false, and the caller should fail with err.
+func (f *File) warn(err error, part, sheet string) bool {
+	w := f.readWarnings
+	if w == nil {
+		return false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.warnings = append(w.warnings, wrapReadError(err, part, sheet).(*ReadError))
+	return true
+}
+
+// warnNoSharedStrings is like warn, for cells that refer to shared
+// strings in a file without a shared strings table.  Only the first of
+// them is kept, as they all have the same cause.
+func (f *File) warnNoSharedStrings(err error, part, sheet string) bool {
+	w := f.readWarnings
+	if w == nil {
+		return false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// As the sheets are read at the same time, the first one is
+	// recorded for each sheet, and sortBySheet keeps the first of
+	// those.
+	if _, ok := w.noSharedStrings[sheet]; ok || w.brokenSharedStrings {
+		return true
+	}
+	if w.noSharedStrings == nil {
+		w.noSharedStrings = make(map[string]*ReadError)
+	}
+	warning := wrapReadError(err, part, sheet).(*ReadError)
+	w.noSharedStrings[sheet] = warning
+	w.warnings = append(w.warnings, warning)
+	return true
+}
+
+// sortBySheet puts the warnings about the sheets in the order of the
+// sheets, as the sheets are read at the same time.  The warnings about
+// a sheet keep their order, and come after those that aren't about a
+// sheet.
+func (w *readWarnings) sortBySheet(sheets []xlsxSheet) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	order := make(map[string]int, len(sheets))
+	for i, sheet := range sheets {
+		order[sheet.Name] = i + 1
+	}
+	sort.SliceStable(w.warnings, func(i, j int) bool {
+		return order[w.warnings[i].Sheet] < order[w.warnings[j].Sheet]
+	})
+	noSharedStrings := false
+	warnings := w.warnings[:0]
+	for _, warning := range w.warnings {
+		if w.noSharedStrings[warning.Sheet] == warning {
+			if noSharedStrings {
+				continue
+			}
+			noSharedStrings = true
+		}
+		warnings = append(warnings, warning)
+	}
+	w.warnings = warnings
+}
+
 // getRangeFromString is an internal helper function that converts
 // XLSX internal range syntax to a pair of integers.  For example,
 // the range string "1:3" yield the upper and lower integers 1 and 3.
 func getRangeFromString(rangeString string) (lower int, upper int, error error) {
 	var parts []string
 	parts = strings.SplitN(rangeString, cellRangeChar, 2)
+	if len(parts) < 2 {
+		return 0, 0, errors.New(fmt.Sprintf("Invalid range '%s'\n", rangeString))
+	}
 	if parts[0] == "" {
 		error = errors.New(fmt.Sprintf("Invalid range '%s'\n", rangeString))
 	}
@@ -97,12 +241,12 @@ func formatColumnName(colId []int) string {
 			// range 0-25, all other numbers are 1-26,
 			// hence we use a differente offset for the
 			// last part.
-			result += string(part + 65)
+			result += string(rune(part + 65))
 		} else {
 			// Don't output leading 0s, as there is no
 			// representation of 0 in this format.
 			if part > 0 {
-				result += string(part + 64)
+				result += string(rune(part + 64))
 			}
 		}
 	}
@@ -275,7 +419,7 @@ func calculateMaxMinFromWorksheet(worksheet *xlsxWorksheet) (minx, miny, maxx, m
 // return an empty Row large enough to encompass that span and
 // populate it with empty cells.  All rows start from cell 1 -
 // regardless of the lower bound of the span.
-func makeRowFromSpan(spans string, sheet *Sheet) *Row {
+func makeRowFromSpan(spans string, sheet *Sheet) (*Row, error) {
 	var error error
 	var upper int
 	var row *Row
@@ -285,20 +429,22 @@ func makeRowFromSpan(spans string, sheet *Sheet) *Row {
 	row.Sheet = sheet
 	_, upper, error = getRangeFromString(spans)
 	if error != nil {
-		panic(error)
+		return nil, error
+	}
+	if upper < 1 {
+		return nil, errors.New(fmt.Sprintf("Invalid range '%s'\n", spans))
 	}
-	error = nil
 	row.Cells = make([]*Cell, upper)
 	for i := 0; i < upper; i++ {
 		cell = new(Cell)
 		cell.Value = ""
 		row.Cells[i] = cell
 	}
-	return row
+	return row, nil
 }
 
 // makeRowFromRaw returns the Row representation of the xlsxRow.
-func makeRowFromRaw(rawrow xlsxRow, sheet *Sheet) *Row {
+func makeRowFromRaw(rawrow xlsxRow, sheet *Sheet) (*Row, error) {
 	var upper int
 	var row *Row
 	var cell *Cell
@@ -311,7 +457,7 @@ func makeRowFromRaw(rawrow xlsxRow, sheet *Sheet) *Row {
 		if rawcell.R != "" {
 			x, _, error := GetCoordsFromCellIDString(rawcell.R)
 			if error != nil {
-				panic(fmt.Sprintf("Invalid Cell Coord, %s\n", rawcell.R))
+				return nil, &ReadError{Cell: rawcell.R, Err: errors.New("invalid cell reference")}
 			}
 			if x > upper {
 				upper = x
@@ -330,7 +476,7 @@ func makeRowFromRaw(rawrow xlsxRow, sheet *Sheet) *Row {
 		cell.Value = ""
 		row.Cells[i] = cell
 	}
-	return row
+	return row, nil
 }
 
 func makeEmptyRow(sheet *Sheet) *Row {
@@ -465,7 +611,8 @@ func shiftCell(cellID string, dx, dy int) string {
 // fillCellData attempts to extract a valid value, usable in
 // CSV form from the raw cell value.  Note - this is not actually
 // general enough - we should support retaining tabs and newlines.
-func fillCellData(rawCell xlsxC, refTable *RefTable, sharedFormulas map[int]sharedFormula, cell *Cell) {
+// Errors are returned as a ReadError for the cell.
+func fillCellData(rawCell xlsxC, refTable *RefTable, sharedFormulas map[int]sharedFormula, cell *Cell) error {
 	val := strings.Trim(rawCell.V, " \t\n\r")
 	cell.formula = formulaForCell(rawCell, sharedFormulas)
 	switch rawCell.T {
@@ -473,10 +620,11 @@ func fillCellData(rawCell xlsxC, refTable *RefTable, sharedFormulas map[int]shar
 		cell.cellType = CellTypeString
 		if val != "" {
 			ref, err := strconv.Atoi(val)
-			if err != nil {
-				panic(err)
+			if err != nil || refTable == nil || ref < 0 || ref >= len(refTable.indexedStrings) {
+				return &ReadError{Cell: rawCell.R, Err: fmt.Errorf("invalid shared string index %s", val)}
 			}
 			cell.Value = refTable.ResolveSharedString(ref)
+			cell.richText = refTable.ResolveSharedRichText(ref)
 		}
 	case "inlineStr":
 		cell.cellType = CellTypeInline
@@ -501,8 +649,12 @@ func fillCellData(rawCell xlsxC, refTable *RefTable, sharedFormulas map[int]shar
 		cell.Value = val
 		cell.cellType = CellTypeNumeric
 	default:
-		panic(errors.New("invalid cell type"))
+		// The value is kept as a string for lenient reading.
+		cell.Value = val
+		cell.cellType = CellTypeString
+		return &ReadError{Cell: rawCell.R, Err: fmt.Errorf("invalid cell type %q", rawCell.T)}
 	}
+	return nil
 }
 
 // fillCellDataFromInlineString attempts to get inline string data and put it into a Cell.
@@ -513,66 +665,98 @@ func fillCellDataFromInlineString(rawcell xlsxC, cell *Cell) {
 			cell.Value = strings.Trim(rawcell.Is.T, " \t\n\r")
 		} else {
 			for _, r := range rawcell.Is.R {
-				cell.Value += r.T
+				cell.Value += r.T.Text
 			}
 		}
 	}
 }
 
+// repairCellRefs gives each cell of a worksheet that has a missing or
+// invalid reference the reference of its position, counting on from
+// the cell and row before it.  Invalid references are reported to
+// warn.
+func repairCellRefs(worksheet *xlsxWorksheet, warn func(error)) {
+	y := -1
+	for i := range worksheet.SheetData.Row {
+		rawrow := &worksheet.SheetData.Row[i]
+		if rawrow.R > 0 {
+			y = rawrow.R - 1
+		} else {
+			y++
+		}
+		x := -1
+		for j := range rawrow.C {
+			rawcell := &rawrow.C[j]
+			if rawcell.R != "" {
+				if cx, cy, err := GetCoordsFromCellIDString(rawcell.R); err == nil && cx >= 0 && cy >= 0 {
+					x = cx
+					continue
+				}
+			}
+			x++
+			ref := GetCellIDStringFromCoords(x, y)
+			if rawcell.R != "" {
+				warn(&ReadError{Cell: rawcell.R, Err: fmt.Errorf("invalid cell reference, using %s", ref)})
+			}
+			rawcell.R = ref
+		}
+	}
+}
+
 // readRowsFromSheet is an internal helper function that extracts the
 // rows from a XSLXWorksheet, populates them with Cells and resolves
 // the value references from the reference table and stores them in
-// the rows and columns.
-func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLimit int) ([]*Row, *ColStore, int, int) {
-	var rows []*Row
-	var cols *ColStore
+// the rows and columns.  Problems with single cells are returned as
+// a ReadError for the cell, as is a panic while reading one.  Only the
+// rows and columns within the limits of the options are read.
+func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, options ReadOptions) (rows []*Row, cols *ColStore, colCount, rowCount int, err error) {
 	var row *Row
-	var minCol, maxCol, maxRow, colCount, rowCount int
+	var minCol, maxCol, maxRow int
 	var reftable *RefTable
-	var err error
 	var insertRowIndex, insertColIndex int
 	sharedFormulas := map[int]sharedFormula{}
+	rowLimit := options.rowLimit()
+	warn := func(err error) bool {
+		return file.warn(err, sheet.part, sheet.Name)
+	}
+	// ref is the cell being read, which is reported if reading it
+	// panics in spite of the checks below.
+	var ref string
+	defer func() {
+		if e := recover(); e != nil {
+			rows, cols, colCount, rowCount = nil, nil, 0, 0
+			err = &ReadError{Cell: ref, Err: fmt.Errorf("unexpected error: %v", e)}
+		}
+	}()
 
 	if len(Worksheet.SheetData.Row) == 0 {
-		return nil, nil, 0, 0
+		// A sheet without any cells, which may still have
+		// column widths and drawings that need them.
+		return nil, readColsFromSheet(Worksheet.Cols, file), 0, 0, nil
 	}
 	reftable = file.referenceTable
+	if file.readWarnings != nil {
+		repairCellRefs(Worksheet, func(err error) { warn(err) })
+	}
 	if len(Worksheet.Dimension.Ref) > 0 && len(strings.Split(Worksheet.Dimension.Ref, cellRangeChar)) == 2 && rowLimit == NoRowLimit {
 		minCol, _, maxCol, maxRow, err = getMaxMinFromDimensionRef(Worksheet.Dimension.Ref)
+		if err != nil && warn(&ReadError{Err: fmt.Errorf("invalid dimension: %v", err)}) {
+			minCol, _, maxCol, maxRow, err = calculateMaxMinFromWorksheet(Worksheet)
+		}
 	} else {
 		minCol, _, maxCol, maxRow, err = calculateMaxMinFromWorksheet(Worksheet)
 	}
 	if err != nil {
-		panic(err.Error())
+		return nil, nil, 0, 0, &ReadError{Err: fmt.Errorf("invalid dimension: %v", err)}
 	}
 
+	if options.ColLimit > 0 && maxCol >= options.ColLimit {
+		maxCol = options.ColLimit - 1
+	}
 	rowCount = maxRow + 1
 	colCount = maxCol + 1
 	rows = make([]*Row, rowCount)
-	cols = &ColStore{}
-
-	if Worksheet.Cols != nil {
-		// Columns can apply to a range, for convenience we expand the
-		// ranges out into individual column definitions.
-		for _, rawcol := range Worksheet.Cols.Col {
-			col := &Col{
-				Min:          rawcol.Min,
-				Max:          rawcol.Max,
-				Hidden:       rawcol.Hidden,
-				Width:        rawcol.Width,
-				OutlineLevel: rawcol.OutlineLevel,
-				BestFit:      rawcol.BestFit,
-				CustomWidth:  rawcol.CustomWidth,
-				Phonetic:     rawcol.Phonetic,
-				Collapsed:    rawcol.Collapsed,
-			}
-			if file.styles != nil {
-				col.style = file.styles.getStyle(rawcol.Style)
-				col.numFmt, col.parsedNumFmt = file.styles.getNumberFormat(rawcol.Style)
-			}
-			cols.Add(col)
-		}
-	}
+	cols = readColsFromSheet(Worksheet.Cols, file)
 
 	numRows := len(rows)
 	for rowIndex := 0; rowIndex < len(Worksheet.SheetData.Row); rowIndex++ {
@@ -588,9 +772,21 @@ func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLi
 		}
 		// range is not empty and only one range exist
 		if len(rawrow.Spans) != 0 && strings.Count(rawrow.Spans, cellRangeChar) == 1 {
-			row = makeRowFromSpan(rawrow.Spans, sheet)
+			row, err = makeRowFromSpan(rawrow.Spans, sheet)
+			if err != nil {
+				err = &ReadError{Err: fmt.Errorf("invalid spans %q in row %d", rawrow.Spans, rawrow.R)}
+				if warn(err) {
+					row, err = makeRowFromRaw(rawrow, sheet)
+				}
+			}
 		} else {
-			row = makeRowFromRaw(rawrow, sheet)
+			row, err = makeRowFromRaw(rawrow, sheet)
+		}
+		if err != nil {
+			return nil, nil, 0, 0, err
+		}
+		if options.ColLimit > 0 && len(row.Cells) > options.ColLimit {
+			row.Cells = row.Cells[:options.ColLimit]
 		}
 
 		row.Hidden = rawrow.Hidden
@@ -603,9 +799,14 @@ func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLi
 
 		insertColIndex = minCol
 		for _, rawcell := range rawrow.C {
+			ref = rawcell.R
 			h, v, err := Worksheet.MergeCells.getExtent(rawcell.R)
 			if err != nil {
-				panic(err.Error())
+				err = &ReadError{Cell: rawcell.R, Err: fmt.Errorf("invalid merged cell range: %v", err)}
+				if !warn(err) {
+					return nil, nil, 0, 0, err
+				}
+				h, v = 0, 0
 			}
 			x, _, _ := GetCoordsFromCellIDString(rawcell.R)
 
@@ -627,7 +828,18 @@ func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLi
 				cell := row.Cells[cellX]
 				cell.HMerge = h
 				cell.VMerge = v
-				fillCellData(rawcell, reftable, sharedFormulas, cell)
+				if err := fillCellData(rawcell, reftable, sharedFormulas, cell); err != nil {
+					if rawcell.T == "s" && reftable == nil {
+						if !file.warnNoSharedStrings(err, sheet.part, sheet.Name) {
+							return nil, nil, 0, 0, err
+						}
+					} else if !warn(err) {
+						return nil, nil, 0, 0, err
+					}
+				}
+				if options.ValuesOnly {
+					cell.formula = ""
+				}
 				if file.styles != nil {
 					cell.style = file.styles.getStyle(rawcell.S)
 					cell.NumFmt, cell.parsedNumFmt = file.styles.getNumberFormat(rawcell.S)
@@ -640,6 +852,7 @@ func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLi
 				insertColIndex++
 			}
 		}
+		ref = ""
 		if len(rows) > insertRowIndex {
 			rows[insertRowIndex] = row
 		}
@@ -650,7 +863,37 @@ func readRowsFromSheet(Worksheet *xlsxWorksheet, file *File, sheet *Sheet, rowLi
 	for ; insertRowIndex < rowCount; insertRowIndex++ {
 		rows[insertRowIndex] = makeEmptyRow(sheet)
 	}
-	return rows, cols, colCount, rowCount
+	return rows, cols, colCount, rowCount, nil
+}
+
+// readColsFromSheet is an internal helper function that converts the
+// cols element of a XLSXWorksheet into a ColStore.
+func readColsFromSheet(rawcols *xlsxCols, file *File) *ColStore {
+	cols := &ColStore{}
+	if rawcols == nil {
+		return cols
+	}
+	// Columns can apply to a range, for convenience we expand the
+	// ranges out into individual column definitions.
+	for _, rawcol := range rawcols.Col {
+		col := &Col{
+			Min:          rawcol.Min,
+			Max:          rawcol.Max,
+			Hidden:       rawcol.Hidden,
+			Width:        rawcol.Width,
+			OutlineLevel: rawcol.OutlineLevel,
+			BestFit:      rawcol.BestFit,
+			CustomWidth:  rawcol.CustomWidth,
+			Phonetic:     rawcol.Phonetic,
+			Collapsed:    rawcol.Collapsed,
+		}
+		if file.styles != nil {
+			col.style = file.styles.getStyle(rawcol.Style)
+			col.numFmt, col.parsedNumFmt = file.styles.getNumberFormat(rawcol.Style)
+		}
+		cols.Add(col)
+	}
+	return cols
 }
 
 type indexedSheet struct {
@@ -684,54 +927,88 @@ func readSheetViews(xSheetViews xlsxSheetViews) []SheetView {
 // readSheetFromFile is the logic of converting a xlsxSheet struct
 // into a Sheet struct.  This work can be done in parallel and so
 // readSheetsFromZipFile will spawn an instance of this function per
-// sheet and get the results back on the provided channel.
-func readSheetFromFile(sc chan *indexedSheet, index int, rsheet xlsxSheet, fi *File, sheetXMLMap map[string]string, rowLimit int) (errRes error) {
+// sheet and get the results back on the provided channel.  Errors are
+// sent as a ReadError for the sheet, as is any panic that is not
+// already reported for a cell by readRowsFromSheet.
+func readSheetFromFile(sc chan *indexedSheet, index int, rsheet xlsxSheet, fi *File, sheetXMLMap map[string]string, options ReadOptions) (errRes error) {
 	result := &indexedSheet{Index: index, Sheet: nil, Error: nil}
+	var part string
+	if worksheetFile := worksheetFileForSheet(rsheet, fi.worksheets, sheetXMLMap); worksheetFile != nil {
+		part = worksheetFile.Name
+	}
 	defer func() {
 		if e := recover(); e != nil {
-			switch e.(type) {
-			case error:
-				result.Error = e.(error)
-				errRes = e.(error)
-			default:
-				result.Error = errors.New("unexpected error")
-			}
-			// The only thing here, is if one close the channel. but its not the case
-			sc <- result
+			result.Sheet = nil
+			result.Error = &ReadError{Err: fmt.Errorf("unexpected error: %v", e)}
+		}
+		result.Error = wrapReadError(result.Error, part, rsheet.Name)
+		if result.Error != nil && fi.warn(result.Error, part, rsheet.Name) {
+			// In lenient mode the sheet is left out.
+			result.Sheet = nil
+			result.Error = nil
 		}
+		errRes = result.Error
+		// The only thing here, is if one close the channel. but its not the case
+		sc <- result
 	}()
+	result.Sheet = &Sheet{part: part}
+	if err := readSheet(result.Sheet, rsheet, fi, sheetXMLMap, options); err != nil {
+		result.Sheet = nil
+		result.Error = err
+	}
+	return nil
+}
 
-	worksheet, err := getWorksheetFromSheet(rsheet, fi.worksheets, sheetXMLMap, rowLimit)
+// readSheet reads the worksheet of a xlsxSheet into sheet, whose part
+// is the name of the worksheet part.
+func readSheet(sheet *Sheet, rsheet xlsxSheet, fi *File, sheetXMLMap map[string]string, options ReadOptions) error {
+	part := sheet.part
+	worksheet, err := getWorksheetFromSheet(rsheet, fi.worksheets, sheetXMLMap, options.rowLimit())
 	if err != nil {
-		result.Error = err
-		sc <- result
 		return err
 	}
-	sheet := new(Sheet)
 	sheet.File = fi
-	sheet.Rows, sheet.Cols, sheet.MaxCol, sheet.MaxRow = readRowsFromSheet(worksheet, fi, sheet, rowLimit)
+	sheet.Name = rsheet.Name
+	warn := func(err error) bool {
+		return fi.warn(err, part, sheet.Name)
+	}
+	sheet.Rows, sheet.Cols, sheet.MaxCol, sheet.MaxRow, err = readRowsFromSheet(worksheet, fi, sheet, options)
+	if err != nil {
+		return err
+	}
 	sheet.Hidden = rsheet.State == sheetStateHidden || rsheet.State == sheetStateVeryHidden
 	sheet.SheetViews = readSheetViews(worksheet.SheetViews)
+	sheet.Protection = readSheetProtection(worksheet.SheetProtection)
+
 	if worksheet.AutoFilter != nil {
 		autoFilterBounds := strings.Split(worksheet.AutoFilter.Ref, ":")
+		if len(autoFilterBounds) == 1 {
+			autoFilterBounds = append(autoFilterBounds, autoFilterBounds[0])
+		}
 		sheet.AutoFilter = &AutoFilter{autoFilterBounds[0], autoFilterBounds[1]}
 	}
 
-	// Convert xlsxHyperlinks to Hyperlinks
-	if worksheet.Hyperlinks != nil {
-
-		worksheetRelsFile := fi.worksheetRels["sheet"+rsheet.SheetId]
-		worksheetRels := new(xlsxWorksheetRels)
-		rc, err := worksheetRelsFile.Open()
+	var worksheetRels *xlsxWorksheetRels
+	if worksheetRelsFile := worksheetFileForSheet(rsheet, fi.worksheetRels, sheetXMLMap); worksheetRelsFile != nil {
+		worksheetRels, err = readWorksheetRelsFromZipFile(worksheetRelsFile)
 		if err != nil {
-			return err
+			err = &ReadError{Part: worksheetRelsFile.Name, Err: err}
+			if !warn(err) {
+				return err
+			}
+			worksheetRels = nil
 		}
-		decoder := xml.NewDecoder(rc)
-		err = decoder.Decode(worksheetRels)
-		if err != nil {
+	}
+
+	// Convert xlsxHyperlinks to Hyperlinks
+	hyperlinks := worksheet.Hyperlinks != nil && !options.SkipHyperlinks && !options.ValuesOnly
+	if hyperlinks && worksheetRels == nil {
+		// In lenient mode the hyperlinks are left out.
+		err = errors.New("sheets relations file has no relations for the relation id present in the hyperlink")
+		if !warn(err) {
 			return err
 		}
-
+	} else if hyperlinks {
 		for _, xlsxLink := range worksheet.Hyperlinks.HyperLinks {
 			newHyperLink := Hyperlink{}
 
@@ -739,12 +1016,19 @@ func readSheetFromFile(sc chan *indexedSheet, index int, rsheet xlsxSheet, fi *F
 			for _, rel := range worksheetRels.Relationships {
 				if rel.Id == xlsxLink.RelationshipId {
 					newHyperLink.Link = rel.Target
+					// The relation is needed to write the
+					// hyperlink back.
+					sheet.addRelation(RelationshipTypeHyperlink, rel.Target, rel.TargetMode)
 					relationPresent = true
 					break
 				}
 			}
 			if !relationPresent {
-				return errors.New("sheets relations file has no relations for the relation id present in the hyperlink")
+				err = &ReadError{Cell: xlsxLink.Reference, Err: errors.New("sheets relations file has no relations for the relation id present in the hyperlink")}
+				if !warn(err) {
+					return err
+				}
+				continue
 			}
 
 			if xlsxLink.Tooltip != "" {
@@ -755,10 +1039,17 @@ func readSheetFromFile(sc chan *indexedSheet, index int, rsheet xlsxSheet, fi *F
 			}
 			cellRef := xlsxLink.Reference
 			x, y, err := GetCoordsFromCellIDString(cellRef)
-			if err != nil {
-				return err
+			if err != nil || x < 0 || y < 0 {
+				err = &ReadError{Cell: cellRef, Err: errors.New("invalid hyperlink cell reference")}
+				if !warn(err) {
+					return err
+				}
+				continue
 			}
-			cell := sheet.Row(y).Cells[x]
+			if !options.readsCell(x, y) {
+				continue
+			}
+			cell := sheet.Cell(y, x)
 			cell.Hyperlink = newHyperLink
 		}
 	}
@@ -767,22 +1058,42 @@ func readSheetFromFile(sc chan *indexedSheet, index int, rsheet xlsxSheet, fi *F
 	sheet.SheetFormat.DefaultRowHeight = worksheet.SheetFormatPr.DefaultRowHeight
 	sheet.SheetFormat.OutlineLevelCol = worksheet.SheetFormatPr.OutlineLevelCol
 	sheet.SheetFormat.OutlineLevelRow = worksheet.SheetFormatPr.OutlineLevelRow
+	if worksheetFile := worksheetFileForSheet(rsheet, fi.worksheets, sheetXMLMap); worksheetFile != nil {
+		sheet.original, err = readOriginalSheet(worksheetFile, worksheet, worksheetRels)
+		if err != nil && !warn(err) {
+			return err
+		}
+	}
+	if options.ValuesOnly {
+		return nil
+	}
 	if nil != worksheet.DataValidations {
 		for _, dd := range worksheet.DataValidations.DataValidation {
 			sheet.AddDataValidation(dd)
 		}
 
 	}
+	sheet.ConditionalFormats = readConditionalFormatsFromSheet(worksheet, fi.styles)
+	err = readCommentsFromZipFile(sheet, worksheetRels, fi.parts, options)
+	if err != nil && !warn(err) {
+		return err
+	}
+	err = readPicturesFromZipFile(sheet, worksheetRels, fi.parts)
+	if err != nil && !warn(err) {
+		return err
+	}
+	err = readTablesFromZipFile(sheet, worksheetRels, fi.parts)
+	if err != nil && !warn(err) {
+		return err
+	}
 
-	result.Sheet = sheet
-	sc <- result
 	return nil
 }
 
 // readSheetsFromZipFile is an internal helper function that loops
 // over the Worksheets defined in the XSLXWorkbook and loads them into
 // Sheet objects stored in the Sheets slice of a xlsx.File struct.
-func readSheetsFromZipFile(f *zip.File, file *File, sheetXMLMap map[string]string, rowLimit int) (map[string]*Sheet, []*Sheet, error) {
+func readSheetsFromZipFile(f *zip.File, file *File, sheetXMLMap map[string]string, options ReadOptions) (map[string]*Sheet, []*Sheet, error) {
 	var workbook *xlsxWorkbook
 	var err error
 	var rc io.ReadCloser
@@ -791,14 +1102,15 @@ func readSheetsFromZipFile(f *zip.File, file *File, sheetXMLMap map[string]strin
 	workbook = new(xlsxWorkbook)
 	rc, err = f.Open()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &ReadError{Part: f.Name, Err: err}
 	}
 	decoder = xml.NewDecoder(rc)
 	err = decoder.Decode(workbook)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &ReadError{Part: f.Name, Err: err}
 	}
 	file.Date1904 = workbook.WorkbookPr.Date1904
+	file.Protection = readWorkbookProtection(workbook.WorkbookProtection)
 
 	for entryNum := range workbook.DefinedNames.DefinedName {
 		file.DefinedNames = append(file.DefinedNames, &workbook.DefinedNames.DefinedName[entryNum])
@@ -808,42 +1120,112 @@ func readSheetsFromZipFile(f *zip.File, file *File, sheetXMLMap map[string]strin
 	// Notably this excludes chartsheets don't right now
 	var workbookSheets []xlsxSheet
 	for _, sheet := range workbook.Sheets.Sheet {
+		if !options.readsSheet(sheet.Name) {
+			continue
+		}
 		if f := worksheetFileForSheet(sheet, file.worksheets, sheetXMLMap); f != nil {
 			workbookSheets = append(workbookSheets, sheet)
 		}
 	}
+	for _, name := range options.Sheets {
+		found := false
+		for _, sheet := range workbookSheets {
+			found = found || sheet.Name == name
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("sheet %q not found in XLSX file", name)
+		}
+	}
 	sheetCount = len(workbookSheets)
 	sheetsByName := make(map[string]*Sheet, sheetCount)
 	sheets := make([]*Sheet, sheetCount)
-	sheetChan := make(chan *indexedSheet, sheetCount)
-
-	go func() {
-		defer close(sheetChan)
-		err = nil
+	if options.Lazy {
 		for i, rawsheet := range workbookSheets {
-			if err := readSheetFromFile(sheetChan, i, rawsheet, file, sheetXMLMap, rowLimit); err != nil {
-				return
-			}
+			sheets[i] = newLazySheet(rawsheet, file, sheetXMLMap, options)
+			sheetsByName[rawsheet.Name] = sheets[i]
 		}
-	}()
+		return sheetsByName, sheets, nil
+	}
+	// The sheets are read by a pool of workers, which take them in
+	// order.  Once a sheet has failed, the sheets after it are no
+	// longer started, but those before it are still read, so the
+	// error that is returned is always that of the first sheet that
+	// can't be read.
+	workers := options.workers()
+	if workers > sheetCount {
+		workers = sheetCount
+	}
+	indexes := make(chan int, sheetCount)
+	for i := range workbookSheets {
+		indexes <- i
+	}
+	close(indexes)
+	sheetChan := make(chan *indexedSheet, sheetCount)
+	var failedMutex sync.Mutex
+	failed := sheetCount
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				failedMutex.Lock()
+				skip := i > failed
+				failedMutex.Unlock()
+				if skip {
+					continue
+				}
+				if err := readSheetFromFile(sheetChan, i, workbookSheets[i], file, sheetXMLMap, options); err != nil {
+					failedMutex.Lock()
+					if i < failed {
+						failed = i
+					}
+					failedMutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(sheetChan)
 
-	for j := 0; j < sheetCount; j++ {
-		sheet := <-sheetChan
-		if sheet.Error != nil {
+	results := make([]*indexedSheet, sheetCount)
+	for sheet := range sheetChan {
+		results[sheet.Index] = sheet
+	}
+	for _, sheet := range results {
+		if sheet != nil && sheet.Error != nil {
 			return nil, nil, sheet.Error
 		}
-		sheetName := workbookSheets[sheet.Index].Name
+	}
+	for i, sheet := range results {
+		if sheet.Sheet == nil {
+			// Skipped in lenient mode.
+			continue
+		}
+		sheetName := workbookSheets[i].Name
 		sheetsByName[sheetName] = sheet.Sheet
 		sheet.Sheet.Name = sheetName
-		sheets[sheet.Index] = sheet.Sheet
+		sheets[i] = sheet.Sheet
+	}
+	if file.readWarnings != nil {
+		// Close the gaps left by skipped sheets.
+		read := sheets[:0]
+		for _, sheet := range sheets {
+			if sheet != nil {
+				read = append(read, sheet)
+			}
+		}
+		sheets = read
+		file.readWarnings.sortBySheet(workbookSheets)
 	}
 	return sheetsByName, sheets, nil
 }
 
 // readSharedStringsFromZipFile() is an internal helper function to
 // extract a reference table from the sharedStrings.xml file within
-// the XLSX zip file.
-func readSharedStringsFromZipFile(f *zip.File) (*RefTable, error) {
+// the XLSX zip file.  The theme is used to resolve the colors of rich
+// text, and may be nil.
+func readSharedStringsFromZipFile(f *zip.File, theme *theme) (*RefTable, error) {
 	var sst *xlsxSST
 	var error error
 	var rc io.ReadCloser
@@ -858,15 +1240,15 @@ func readSharedStringsFromZipFile(f *zip.File) (*RefTable, error) {
 	}
 	rc, error = f.Open()
 	if error != nil {
-		return nil, error
+		return nil, &ReadError{Part: f.Name, Err: error}
 	}
 	sst = new(xlsxSST)
 	decoder = xml.NewDecoder(rc)
 	error = decoder.Decode(sst)
 	if error != nil {
-		return nil, error
+		return nil, &ReadError{Part: f.Name, Err: error}
 	}
-	reftable = MakeSharedStringRefTable(sst)
+	reftable = makeSharedStringRefTable(sst, theme)
 	return reftable, nil
 }
 
@@ -880,13 +1262,13 @@ func readStylesFromZipFile(f *zip.File, theme *theme) (*xlsxStyleSheet, error) {
 	var decoder *xml.Decoder
 	rc, error = f.Open()
 	if error != nil {
-		return nil, error
+		return nil, &ReadError{Part: f.Name, Err: error}
 	}
 	style = newXlsxStyleSheet(theme)
 	decoder = xml.NewDecoder(rc)
 	error = decoder.Decode(style)
 	if error != nil {
-		return nil, error
+		return nil, &ReadError{Part: f.Name, Err: error}
 	}
 	buildNumFmtRefTable(style)
 	return style, nil
@@ -905,13 +1287,13 @@ func buildNumFmtRefTable(style *xlsxStyleSheet) {
 func readThemeFromZipFile(f *zip.File) (*theme, error) {
 	rc, err := f.Open()
 	if err != nil {
-		return nil, err
+		return nil, &ReadError{Part: f.Name, Err: err}
 	}
 
 	var themeXml xlsxTheme
 	err = xml.NewDecoder(rc).Decode(&themeXml)
 	if err != nil {
-		return nil, err
+		return nil, &ReadError{Part: f.Name, Err: err}
 	}
 
 	return newTheme(themeXml), nil
@@ -928,10 +1310,14 @@ func (w *WorkBookRels) MakeXLSXWorkbookRels() xlsxWorkbookRels {
 		if err != nil {
 			panic(err.Error())
 		}
+		relType := "http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet"
+		if strings.HasPrefix(v, "chartsheets/") {
+			relType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/chartsheet"
+		}
 		xWorkbookRels.Relationships[index-1] = xlsxWorkbookRelation{
 			Id:     k,
 			Target: v,
-			Type:   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet"}
+			Type:   relType}
 	}
 
 	relCount++
@@ -958,6 +1344,34 @@ func (w *WorkBookRels) MakeXLSXWorkbookRels() xlsxWorkbookRels {
 	return xWorkbookRels
 }
 
+// readWorksheetRelsFromZipFile is an internal helper function to read
+// the relationships of a worksheet from its .rels file.
+func readWorksheetRelsFromZipFile(f *zip.File) (*xlsxWorksheetRels, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	worksheetRels := new(xlsxWorksheetRels)
+	decoder := xml.NewDecoder(rc)
+	err = decoder.Decode(worksheetRels)
+	if err != nil {
+		return nil, err
+	}
+	return worksheetRels, nil
+}
+
+// relationshipTargetPartName returns the name of the part that the
+// target of a relationship refers to.  Targets are relative to dir,
+// the directory of the part that the relationship belongs to, unless
+// they start with a "/".
+func relationshipTargetPartName(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return target[1:]
+	}
+	return path.Join(dir, target)
+}
+
 // readWorkbookRelationsFromZipFile is an internal helper function to
 // extract a map of relationship ID strings to the name of the
 // worksheet.xml file they refer to.  The resulting map can be used to
@@ -971,13 +1385,13 @@ func readWorkbookRelationsFromZipFile(workbookRels *zip.File) (WorkBookRels, err
 
 	rc, err = workbookRels.Open()
 	if err != nil {
-		return nil, err
+		return nil, &ReadError{Part: workbookRels.Name, Err: err}
 	}
 	decoder = xml.NewDecoder(rc)
 	wbRelationships = new(xlsxWorkbookRels)
 	err = decoder.Decode(wbRelationships)
 	if err != nil {
-		return nil, err
+		return nil, &ReadError{Part: workbookRels.Name, Err: err}
 	}
 	sheetXMLMap = make(WorkBookRels)
 	for _, rel := range wbRelationships.Relationships {
@@ -992,22 +1406,25 @@ func readWorkbookRelationsFromZipFile(workbookRels *zip.File) (WorkBookRels, err
 // ReadZip() takes a pointer to a zip.ReadCloser and returns a
 // xlsx.File struct populated with its contents.  In most cases
 // ReadZip is not used directly, but is called internally by OpenFile.
-func ReadZip(f *zip.ReadCloser) (*File, error) {
-	return ReadZipWithRowLimit(f, NoRowLimit)
+func ReadZip(f *zip.ReadCloser, options ...FileOption) (*File, error) {
+	defer f.Close()
+	// The sheets can't be read lazily, as f is closed.
+	options = append(options, func(o *ReadOptions) { o.Lazy = false })
+	return ReadZipReader(&f.Reader, options...)
 }
 
 // ReadZipWithRowLimit() takes a pointer to a zip.ReadCloser and returns a
 // xlsx.File struct populated with its contents.  In most cases
 // ReadZip is not used directly, but is called internally by OpenFile.
 func ReadZipWithRowLimit(f *zip.ReadCloser, rowLimit int) (*File, error) {
-	defer f.Close()
-	return ReadZipReaderWithRowLimit(&f.Reader, rowLimit)
+	return ReadZip(f, RowLimit(rowLimit))
 }
 
 // ReadZipReader() can be used to read an XLSX in memory without
 // touching the filesystem.
-func ReadZipReader(r *zip.Reader) (*File, error) {
-	return ReadZipReaderWithRowLimit(r, NoRowLimit)
+func ReadZipReader(r *zip.Reader, options ...FileOption) (*File, error) {
+	file, _, err := readZipReader(r, readOptions(options))
+	return file, err
 }
 
 // ReadZipReaderWithRowLimit() can be used to read an XLSX in memory without
@@ -1015,6 +1432,17 @@ func ReadZipReader(r *zip.Reader) (*File, error) {
 // rowLimit is the number of rows that should be read from the file. If rowLimit is -1, no limit is applied.
 // You can specify this with the constant NoRowLimit.
 func ReadZipReaderWithRowLimit(r *zip.Reader, rowLimit int) (*File, error) {
+	return ReadZipReader(r, RowLimit(rowLimit))
+}
+
+// ReadZipReaderWithOptions is like ReadZipReader, but reads the file
+// as the options say.  The problems that are worked around in lenient
+// mode are returned as warnings along with the File.
+func ReadZipReaderWithOptions(r *zip.Reader, options ReadOptions) (*File, []*ReadError, error) {
+	return readZipReader(r, options)
+}
+
+func readZipReader(r *zip.Reader, options ReadOptions) (*File, []*ReadError, error) {
 	var err error
 	var file *File
 	var reftable *RefTable
@@ -1030,12 +1458,19 @@ func ReadZipReaderWithRowLimit(r *zip.Reader, rowLimit int) (*File, error) {
 	var workbookRels *zip.File
 	var worksheets map[string]*zip.File
 	var worksheetRels map[string]*zip.File
+	var parts map[string]*zip.File
 
 	file = NewFile()
+	if options.Lenient {
+		file.readWarnings = new(readWarnings)
+		defer func() { file.readWarnings = nil }()
+	}
 	// file.numFmtRefTable = make(map[int]xlsxNumFmt, 1)
 	worksheets = make(map[string]*zip.File, len(r.File))
 	worksheetRels = make(map[string]*zip.File, len(r.File))
+	parts = make(map[string]*zip.File, len(r.File))
 	for _, v = range r.File {
+		parts[v.Name] = v
 		switch v.Name {
 		case "xl/sharedStrings.xml":
 			sharedStrings = v
@@ -1060,51 +1495,63 @@ func ReadZipReaderWithRowLimit(r *zip.Reader, rowLimit int) (*File, error) {
 		}
 	}
 	if workbookRels == nil {
-		return nil, fmt.Errorf("xl/_rels/workbook.xml.rels not found in input xlsx.")
+		return nil, nil, fmt.Errorf("xl/_rels/workbook.xml.rels not found in input xlsx.")
 	}
 	sheetXMLMap, err = readWorkbookRelationsFromZipFile(workbookRels)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(worksheets) == 0 {
-		return nil, fmt.Errorf("Input xlsx contains no worksheets.")
+		return nil, nil, fmt.Errorf("Input xlsx contains no worksheets.")
 	}
 	file.worksheets = worksheets
 	file.worksheetRels = worksheetRels
-	reftable, err = readSharedStringsFromZipFile(sharedStrings)
-	if err != nil {
-		return nil, err
+	file.parts = parts
+	file.original, err = readOriginalWorkbook(parts)
+	if err != nil && !file.warn(err, "", "") {
+		return nil, nil, err
 	}
-	file.referenceTable = reftable
+	file.PassThrough = true
 	if themeFile != nil {
 		theme, err := readThemeFromZipFile(themeFile)
-		if err != nil {
-			return nil, err
+		if err != nil && !file.warn(err, "", "") {
+			return nil, nil, err
 		}
 
 		file.theme = theme
 	}
-	if styles != nil {
+	reftable, err = readSharedStringsFromZipFile(sharedStrings, file.theme)
+	if err != nil {
+		if !file.warn(err, "", "") {
+			return nil, nil, err
+		}
+		file.readWarnings.brokenSharedStrings = true
+	}
+	file.referenceTable = reftable
+	if styles != nil && !options.SkipStyles && !options.ValuesOnly {
 		style, err = readStylesFromZipFile(styles, file.theme)
-		if err != nil {
-			return nil, err
+		if err != nil && !file.warn(err, "", "") {
+			return nil, nil, err
 		}
 
 		file.styles = style
 	}
-	sheetsByName, sheets, err = readSheetsFromZipFile(workbook, file, sheetXMLMap, rowLimit)
+	sheetsByName, sheets, err = readSheetsFromZipFile(workbook, file, sheetXMLMap, options)
 	//sheetRelsByName, sheetRels, err = readSheetRelationsFromZipFile()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if sheets == nil {
+	if len(sheets) == 0 {
 		readerErr := new(XLSXReaderError)
 		readerErr.Err = "No sheets found in XLSX File"
-		return nil, readerErr
+		return nil, nil, readerErr
 	}
 	file.Sheet = sheetsByName
 	file.Sheets = sheets
-	return file, nil
+	if file.readWarnings != nil {
+		return file, file.readWarnings.warnings, nil
+	}
+	return file, nil, nil
 }
 
 // truncateSheetXML will take in a reader to an XML sheet file and will return a reader that will read an equivalent
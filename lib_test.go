@@ -279,19 +279,23 @@ func (l *LibSuite) TestMakeRowFromSpan(c *C) {
 	var row *Row
 	var length int
 	var sheet *Sheet
+	var err error
 	sheet = new(Sheet)
 	rangeString = "1:3"
-	row = makeRowFromSpan(rangeString, sheet)
+	row, err = makeRowFromSpan(rangeString, sheet)
+	c.Assert(err, IsNil)
 	length = len(row.Cells)
 	c.Assert(length, Equals, 3)
 	c.Assert(row.Sheet, Equals, sheet)
 	rangeString = "5:7" // Note - we ignore lower bound!
-	row = makeRowFromSpan(rangeString, sheet)
+	row, err = makeRowFromSpan(rangeString, sheet)
+	c.Assert(err, IsNil)
 	length = len(row.Cells)
 	c.Assert(length, Equals, 7)
 	c.Assert(row.Sheet, Equals, sheet)
 	rangeString = "1:1"
-	row = makeRowFromSpan(rangeString, sheet)
+	row, err = makeRowFromSpan(rangeString, sheet)
+	c.Assert(err, IsNil)
 	length = len(row.Cells)
 	c.Assert(length, Equals, 1)
 	c.Assert(row.Sheet, Equals, sheet)
@@ -359,7 +363,8 @@ func (l *LibSuite) TestReadRowsFromSheet(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, cols, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, cols, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 2)
 	c.Assert(maxCols, Equals, 2)
 	row := rows[0]
@@ -437,7 +442,8 @@ func TestReadRowsFromSheetWithMergeCells(t *testing.T) {
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	worksheet.mapMergeCells()
 	sheet := new(Sheet)
-	rows, _, _, _ := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, _, _, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, qt.IsNil)
 	row := rows[0] //
 	cell1 := row.Cells[0]
 	c.Assert(cell1.HMerge, qt.Equals, 1)
@@ -514,7 +520,8 @@ func (l *LibSuite) TestReadRowsFromSheetBadR(c *C) {
 	sheet := new(Sheet)
 	// Discarding all return values; this test is a regression for
 	// a panic due to an "index out of range."
-	readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	_, _, _, _, err = readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 }
 
 func (l *LibSuite) TestReadRowsFromSheetWithLeadingEmptyRows(c *C) {
@@ -560,7 +567,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithLeadingEmptyRows(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, _, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 5)
 	c.Assert(maxCols, Equals, 1)
 
@@ -626,7 +634,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithLeadingEmptyCols(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, cols, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, cols, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 2)
 	c.Assert(maxCols, Equals, 4)
 
@@ -765,7 +774,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithEmptyCells(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, cols, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, cols, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 3)
 	c.Assert(maxCols, Equals, 3)
 
@@ -807,7 +817,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithTrailingEmptyCells(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, _, maxCol, maxRow := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCol, maxRow, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxCol, Equals, 4)
 	c.Assert(maxRow, Equals, 8)
 
@@ -917,7 +928,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithMultipleSpans(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, _, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 2)
 	c.Assert(maxCols, Equals, 4)
 	row := rows[0]
@@ -992,7 +1004,8 @@ func (l *LibSuite) TestReadRowsFromSheetWithMultipleTypes(c *C) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, _, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxRows, Equals, 1)
 	c.Assert(maxCols, Equals, 6)
 	row := rows[0]
@@ -1066,7 +1079,8 @@ func TestReadRowsFromSheetWithHiddenColumn(t *testing.T) {
 	file := new(File)
 	file.referenceTable = MakeSharedStringRefTable(sst)
 	sheet := new(Sheet)
-	rows, _, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, qt.IsNil)
 	c.Assert(maxRows, qt.Equals, 1)
 	c.Assert(maxCols, qt.Equals, 2)
 	row := rows[0]
@@ -1097,13 +1111,15 @@ func (l *LibSuite) TestReadRowFromRaw(c *C) {
 	var rawRow xlsxRow
 	var cell xlsxC
 	var row *Row
+	var err error
 
 	rawRow = xlsxRow{}
 	cell = xlsxC{R: "A1"}
 	cell = xlsxC{R: "A2"}
 	rawRow.C = append(rawRow.C, cell)
 	sheet := new(Sheet)
-	row = makeRowFromRaw(rawRow, sheet)
+	row, err = makeRowFromRaw(rawRow, sheet)
+	c.Assert(err, IsNil)
 	c.Assert(row, NotNil)
 	c.Assert(row.Cells, HasLen, 1)
 	c.Assert(row.Sheet, Equals, sheet)
@@ -1115,6 +1131,7 @@ func (l *LibSuite) TestReadRowFromRawWithMissingCells(c *C) {
 	var rawRow xlsxRow
 	var cell xlsxC
 	var row *Row
+	var err error
 
 	rawRow = xlsxRow{}
 	cell = xlsxC{R: "A1"}
@@ -1122,7 +1139,8 @@ func (l *LibSuite) TestReadRowFromRawWithMissingCells(c *C) {
 	cell = xlsxC{R: "E1"}
 	rawRow.C = append(rawRow.C, cell)
 	sheet := new(Sheet)
-	row = makeRowFromRaw(rawRow, sheet)
+	row, err = makeRowFromRaw(rawRow, sheet)
+	c.Assert(err, IsNil)
 	c.Assert(row, NotNil)
 	c.Assert(row.Cells, HasLen, 5)
 	c.Assert(row.Sheet, Equals, sheet)
@@ -1133,6 +1151,7 @@ func (l *LibSuite) TestReadRowFromRawWithPartialCoordinates(c *C) {
 	var rawRow xlsxRow
 	var cell xlsxC
 	var row *Row
+	var err error
 
 	rawRow = xlsxRow{}
 	cell = xlsxC{R: "A1"}
@@ -1144,7 +1163,8 @@ func (l *LibSuite) TestReadRowFromRawWithPartialCoordinates(c *C) {
 	cell = xlsxC{}
 	rawRow.C = append(rawRow.C, cell)
 	sheet := new(Sheet)
-	row = makeRowFromRaw(rawRow, sheet)
+	row, err = makeRowFromRaw(rawRow, sheet)
+	c.Assert(err, IsNil)
 	c.Assert(row, NotNil)
 	c.Assert(row.Cells, HasLen, 27)
 	c.Assert(row.Sheet, Equals, sheet)
@@ -1202,7 +1222,8 @@ func (l *LibSuite) TestSharedFormulas(c *C) {
 
 	file := new(File)
 	sheet := new(Sheet)
-	rows, _, maxCols, maxRows := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, maxCols, maxRows, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	c.Assert(maxCols, Equals, 3)
 	c.Assert(maxRows, Equals, 2)
 
@@ -1343,7 +1364,8 @@ func (l *LibSuite) TestRowNotOverwrittenWhenFollowedByEmptyRow(c *C) {
 	file.referenceTable = MakeSharedStringRefTable(sst)
 
 	sheet := new(Sheet)
-	rows, _, _, _ := readRowsFromSheet(worksheet, file, sheet, NoRowLimit)
+	rows, _, _, _, err := readRowsFromSheet(worksheet, file, sheet, ReadOptions{})
+	c.Assert(err, IsNil)
 	cells := rows[3].Cells
 
 	c.Assert(cells, HasLen, 1)
@@ -1710,3 +1732,229 @@ func TestFuzzCrashers(t *testing.T) {
 		}
 	}
 }
+
+func TestReadError(t *testing.T) {
+	c := qt.New(t)
+
+	// ordersParts returns the parts of a file with a sheet called
+	// Orders, whose cell D17 holds the first shared string.
+	ordersParts := func(c *qt.C) map[string]string {
+		file := NewFile()
+		sheet, err := file.AddSheet("Orders")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(16, 3).SetString("Widget")
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		return parts
+	}
+	readError := func(c *qt.C, parts map[string]string) *ReadError {
+		_, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.Not(qt.IsNil))
+		readErr, ok := err.(*ReadError)
+		c.Assert(ok, qt.Equals, true, qt.Commentf("%T: %v", err, err))
+		return readErr
+	}
+
+	c.Run("SharedStringIndex", func(c *qt.C) {
+		parts := ordersParts(c)
+		sheetXML := parts["xl/worksheets/sheet1.xml"]
+		c.Assert(sheetXML, qt.Contains, `<c r="D17" t="s"><v>0</v></c>`)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(sheetXML, `<c r="D17" t="s"><v>0</v></c>`, `<c r="D17" t="s"><v>12</v></c>`, 1)
+
+		readErr := readError(c, parts)
+		c.Assert(readErr.Error(), qt.Equals, "Sheet 'Orders' cell D17: invalid shared string index 12")
+		c.Assert(readErr.Part, qt.Equals, "xl/worksheets/sheet1.xml")
+		c.Assert(readErr.Sheet, qt.Equals, "Orders")
+		c.Assert(readErr.Cell, qt.Equals, "D17")
+		c.Assert(readErr.Unwrap(), qt.ErrorMatches, "invalid shared string index 12")
+
+		// The stream reader reports the same error.
+		data := zipParts(c, parts)
+		sr, err := NewStreamReader(bytes.NewReader(data), int64(len(data)))
+		c.Assert(err, qt.IsNil)
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		for err == nil {
+			_, err = sr.Read()
+		}
+		c.Assert(err, qt.ErrorMatches, "Sheet 'Orders' cell D17: invalid shared string index 12")
+		c.Assert(err.(*ReadError).Part, qt.Equals, "xl/worksheets/sheet1.xml")
+	})
+
+	c.Run("CellType", func(c *qt.C) {
+		parts := ordersParts(c)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `<c r="D17" t="s">`, `<c r="D17" t="x">`, 1)
+		c.Assert(readError(c, parts).Error(), qt.Equals, `Sheet 'Orders' cell D17: invalid cell type "x"`)
+	})
+
+	c.Run("CellReference", func(c *qt.C) {
+		parts := ordersParts(c)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `<c r="D17"`, `<c r="D"`, 1)
+		c.Assert(readError(c, parts).Error(), qt.Equals, `Sheet 'Orders' cell D: invalid cell reference`)
+	})
+
+	c.Run("Spans", func(c *qt.C) {
+		parts := ordersParts(c)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `<row r="17"`, `<row r="17" spans="1:-2"`, 1)
+		c.Assert(readError(c, parts).Error(), qt.Equals, `Sheet 'Orders': invalid spans "1:-2" in row 17`)
+	})
+
+	c.Run("MergeCellRange", func(c *qt.C) {
+		parts := ordersParts(c)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `</sheetData>`, `</sheetData><mergeCells count="1"><mergeCell ref="D17"/></mergeCells>`, 1)
+		c.Assert(readError(c, parts).Error(), qt.Equals, `Sheet 'Orders' cell D17: invalid merged cell range: invalid range "D17"`)
+	})
+
+	c.Run("StyleCount", func(c *qt.C) {
+		// A count that is larger than the list of styles is not an
+		// error, and the cells that refer past the list have the
+		// default style.
+		parts := ordersParts(c)
+		parts["xl/styles.xml"] = strings.Replace(parts["xl/styles.xml"], `<cellXfs count="1">`, `<cellXfs count="60">`, 1)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `<c r="D17"`, `<c r="D17" s="50"`, 1)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		cell := file.Sheet["Orders"].Cell(16, 3)
+		c.Assert(cell.Value, qt.Equals, "Widget")
+		c.Assert(cell.GetStyle().Font.Name, qt.Equals, "")
+	})
+
+	c.Run("Panic", func(c *qt.C) {
+		// A panic while a cell is read, which the checks are there
+		// to prevent, is still reported for the cell.
+		worksheet := &xlsxWorksheet{}
+		worksheet.SheetData.Row = []xlsxRow{{R: 1, C: []xlsxC{{R: "B1", V: "1"}}}}
+		file := NewFile()
+		file.styles = &xlsxStyleSheet{CellXfs: xlsxCellXfs{Count: 1, Xf: []xlsxXf{{}}}}
+		rows, _, _, _, err := readRowsFromSheet(worksheet, file, &Sheet{File: file}, ReadOptions{})
+		c.Assert(rows, qt.IsNil)
+		readErr, ok := err.(*ReadError)
+		c.Assert(ok, qt.Equals, true, qt.Commentf("%T: %v", err, err))
+		c.Assert(readErr.Cell, qt.Equals, "B1")
+		c.Assert(readErr.Error(), qt.Matches, `cell B1: unexpected error: .*`)
+	})
+
+	c.Run("SheetXML", func(c *qt.C) {
+		parts := ordersParts(c)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `</sheetData>`, ``, 1)
+		readErr := readError(c, parts)
+		c.Assert(readErr.Error(), qt.Matches, `Sheet 'Orders': XML syntax error .*`)
+		_, ok := readErr.Unwrap().(*xml.SyntaxError)
+		c.Assert(ok, qt.Equals, true)
+	})
+
+	c.Run("Parts", func(c *qt.C) {
+		for _, part := range []string{"xl/styles.xml", "xl/sharedStrings.xml", "xl/theme/theme1.xml", "xl/_rels/workbook.xml.rels"} {
+			parts := ordersParts(c)
+			parts[part] = `<broken`
+			readErr := readError(c, parts)
+			c.Assert(readErr.Part, qt.Equals, part)
+			c.Assert(readErr.Sheet, qt.Equals, "")
+			c.Assert(readErr.Error(), qt.Matches, part+`: XML syntax error .*`)
+		}
+	})
+}
+
+func TestReadLenient(t *testing.T) {
+	c := qt.New(t)
+
+	// damagedParts returns the parts of a file with two sheets, whose
+	// second sheet, styles and cells are damaged.
+	damagedParts := func(c *qt.C) map[string]string {
+		file := NewFile()
+		orders, err := file.AddSheet("Orders")
+		c.Assert(err, qt.IsNil)
+		orders.Cell(0, 0).SetInt(1)
+		orders.Cell(0, 1).SetInt(2)
+		orders.Cell(0, 2).SetInt(3)
+		orders.Cell(16, 3).SetString("Widget")
+		customers, err := file.AddSheet("Customers")
+		c.Assert(err, qt.IsNil)
+		customers.Cell(0, 0).SetString("Müller GmbH")
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+
+		sheetXML := parts["xl/worksheets/sheet1.xml"]
+		for old, new := range map[string]string{
+			`<c r="B1"`:                     `<c r="B"`,
+			`<c r="C1"`:                     `<c r="C1" t="x"`,
+			`<c r="D17" t="s"><v>0</v></c>`: `<c r="D17" t="s"><v>12</v></c>`,
+		} {
+			c.Assert(sheetXML, qt.Contains, old)
+			sheetXML = strings.Replace(sheetXML, old, new, 1)
+		}
+		parts["xl/worksheets/sheet1.xml"] = sheetXML
+		parts["xl/worksheets/sheet2.xml"] = strings.Replace(parts["xl/worksheets/sheet2.xml"], `</sheetData>`, ``, 1)
+		parts["xl/styles.xml"] = `<broken`
+		return parts
+	}
+
+	c.Run("Damaged", func(c *qt.C) {
+		data := zipParts(c, damagedParts(c))
+		_, err := OpenBinary(data)
+		c.Assert(err, qt.Not(qt.IsNil))
+
+		file, warnings, err := OpenBinaryWithOptions(data, ReadOptions{Lenient: true})
+		c.Assert(err, qt.IsNil)
+		var messages []string
+		for _, warning := range warnings {
+			messages = append(messages, warning.Error())
+		}
+		c.Assert(messages, qt.HasLen, 5)
+		c.Assert(messages[0], qt.Matches, `xl/styles.xml: XML syntax error .*`)
+		c.Assert(messages[1:4], qt.DeepEquals, []string{
+			`Sheet 'Orders' cell B: invalid cell reference, using B1`,
+			`Sheet 'Orders' cell C1: invalid cell type "x"`,
+			`Sheet 'Orders' cell D17: invalid shared string index 12`,
+		})
+		c.Assert(messages[4], qt.Matches, `Sheet 'Customers': XML syntax error .*`)
+		c.Assert(warnings[1].Part, qt.Equals, "xl/worksheets/sheet1.xml")
+		c.Assert(warnings[4].Part, qt.Equals, "xl/worksheets/sheet2.xml")
+
+		// The damaged sheet is left out, and the damaged cells are
+		// read as well as they can be.
+		c.Assert(file.Sheets, qt.HasLen, 1)
+		c.Assert(file.Sheet["Customers"], qt.IsNil)
+		sheet := file.Sheet["Orders"]
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "2")
+		c.Assert(sheet.Cell(0, 2).Value, qt.Equals, "3")
+		c.Assert(sheet.Cell(0, 2).Type(), qt.Equals, CellTypeString)
+		c.Assert(sheet.Cell(16, 3).Value, qt.Equals, "")
+		c.Assert(sheet.Cell(16, 3).Type(), qt.Equals, CellTypeString)
+
+		// What was read can be written again.
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+		file, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 1)
+		c.Assert(file.Sheet["Orders"].Cell(0, 1).Value, qt.Equals, "2")
+	})
+
+	c.Run("SharedStrings", func(c *qt.C) {
+		parts := damagedParts(c)
+		delete(parts, "xl/styles.xml")
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `<c r="D17" t="s"><v>12</v></c>`, `<c r="D17" t="s"><v>0</v></c><c r="E17" t="s"><v>0</v></c>`, 1)
+		parts["xl/sharedStrings.xml"] = `<broken`
+		file, warnings, err := OpenBinaryWithOptions(zipParts(c, parts), ReadOptions{Lenient: true})
+		c.Assert(err, qt.IsNil)
+		// The shared strings are reported once, and not again for
+		// each cell that uses them.
+		var sharedStrings int
+		for _, warning := range warnings {
+			c.Assert(warning.Cell, qt.Not(qt.Matches), "[DE]17")
+			if warning.Part == "xl/sharedStrings.xml" {
+				sharedStrings++
+			}
+		}
+		c.Assert(sharedStrings, qt.Equals, 1)
+		c.Assert(file.Sheet["Orders"].Cell(16, 3).Value, qt.Equals, "")
+		c.Assert(file.Sheet["Orders"].Cell(16, 4).Value, qt.Equals, "")
+	})
+
+	c.Run("NoSheets", func(c *qt.C) {
+		parts := damagedParts(c)
+		parts["xl/worksheets/sheet1.xml"] = `<broken`
+		_, _, err := OpenBinaryWithOptions(zipParts(c, parts), ReadOptions{Lenient: true})
+		c.Assert(err, qt.ErrorMatches, "No sheets found in XLSX File")
+	})
+}
@@ -0,0 +1,204 @@
+package xlsx
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Locale describes how numbers and dates are shown in a language and
+// region. Number formats name a locale with its LCID, as in
+// [$€-407]#,##0.00 or [$-411]yyyy/mm/dd, and the locale then supplies
+// the separators and the names of months and days.
+type Locale struct {
+	// Name is the language tag of the locale, such as "de-DE".
+	Name string
+	// LCID is the Windows locale identifier, such as 0x407.
+	LCID int
+
+	DecimalSeparator   string
+	ThousandsSeparator string
+	CurrencySymbol     string
+	// CurrencyAfter is true when the currency symbol follows the
+	// number, separated by a space.
+	CurrencyAfter bool
+
+	MonthNames         [12]string
+	MonthAbbreviations [12]string
+	// DayNames and DayAbbreviations start with Sunday.
+	DayNames         [7]string
+	DayAbbreviations [7]string
+	AM, PM           string
+
+	// LongDateFormat and LongTimeFormat replace the system date and
+	// time formats [$-F800] and [$-F400].
+	LongDateFormat string
+	LongTimeFormat string
+}
+
+// CurrencyFormat returns a number format for amounts of the
+// locale's currency, with the given number of decimals. The format
+// names the locale, so it is shown the same way in any locale.
+func (l *Locale) CurrencyFormat(decimals int) string {
+	format := "#,##0"
+	if decimals > 0 {
+		format += "." + strings.Repeat("0", decimals)
+	}
+	currency := fmt.Sprintf("[$%s-%X]", l.CurrencySymbol, l.LCID)
+	if l.CurrencyAfter {
+		return format + " " + currency
+	}
+	return currency + format
+}
+
+var (
+	localesMutex   sync.RWMutex
+	localesByLCID  = make(map[int]*Locale)
+	localesByName  = make(map[string]*Locale)
+	englishMonths  = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+	englishDays    = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	germanMonths   = [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}
+	germanMonthAbb = [12]string{"Jan", "Feb", "Mrz", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"}
+	germanDays     = [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"}
+	germanDayAbb   = [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"}
+	japaneseMonths = [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"}
+)
+
+// LocaleEnUS is the locale that is used when neither the number format
+// nor the caller names one.
+var LocaleEnUS = &Locale{
+	Name:               "en-US",
+	LCID:               0x409,
+	DecimalSeparator:   ".",
+	ThousandsSeparator: ",",
+	CurrencySymbol:     "$",
+	MonthNames:         englishMonths,
+	MonthAbbreviations: abbreviate12(englishMonths),
+	DayNames:           englishDays,
+	DayAbbreviations:   abbreviate7(englishDays),
+	AM:                 "AM",
+	PM:                 "PM",
+	LongDateFormat:     "dddd, mmmm d, yyyy",
+	LongTimeFormat:     "h:mm:ss AM/PM",
+}
+
+func init() {
+	german := func(name string, lcid int) *Locale {
+		return &Locale{
+			Name:               name,
+			LCID:               lcid,
+			DecimalSeparator:   ",",
+			ThousandsSeparator: ".",
+			CurrencySymbol:     "€",
+			CurrencyAfter:      true,
+			MonthNames:         germanMonths,
+			MonthAbbreviations: germanMonthAbb,
+			DayNames:           germanDays,
+			DayAbbreviations:   germanDayAbb,
+			AM:                 "AM",
+			PM:                 "PM",
+			LongDateFormat:     "dddd, d. mmmm yyyy",
+			LongTimeFormat:     "hh:mm:ss",
+		}
+	}
+	austrian := german("de-AT", 0xC07)
+	austrian.MonthNames[0] = "Jänner"
+	austrian.MonthAbbreviations[0] = "Jän"
+	swiss := german("de-CH", 0x807)
+	swiss.DecimalSeparator = "."
+	swiss.ThousandsSeparator = "’"
+	swiss.CurrencySymbol = "CHF"
+
+	for _, locale := range []*Locale{
+		LocaleEnUS,
+		{
+			Name:               "en-GB",
+			LCID:               0x809,
+			DecimalSeparator:   ".",
+			ThousandsSeparator: ",",
+			CurrencySymbol:     "£",
+			MonthNames:         englishMonths,
+			MonthAbbreviations: abbreviate12(englishMonths),
+			DayNames:           englishDays,
+			DayAbbreviations:   abbreviate7(englishDays),
+			AM:                 "AM",
+			PM:                 "PM",
+			LongDateFormat:     "dd mmmm yyyy",
+			LongTimeFormat:     "hh:mm:ss",
+		},
+		german("de-DE", 0x407),
+		austrian,
+		swiss,
+		{
+			Name:               "fr-FR",
+			LCID:               0x40C,
+			DecimalSeparator:   ",",
+			ThousandsSeparator: "\u00a0",
+			CurrencySymbol:     "€",
+			CurrencyAfter:      true,
+			MonthNames:         [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+			MonthAbbreviations: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+			DayNames:           [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+			DayAbbreviations:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+			AM:                 "AM",
+			PM:                 "PM",
+			LongDateFormat:     "dddd d mmmm yyyy",
+			LongTimeFormat:     "hh:mm:ss",
+		},
+		{
+			Name:               "ja-JP",
+			LCID:               0x411,
+			DecimalSeparator:   ".",
+			ThousandsSeparator: ",",
+			CurrencySymbol:     "¥",
+			MonthNames:         japaneseMonths,
+			MonthAbbreviations: japaneseMonths,
+			DayNames:           [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+			DayAbbreviations:   [7]string{"日", "月", "火", "水", "木", "金", "土"},
+			AM:                 "午前",
+			PM:                 "午後",
+			LongDateFormat:     `yyyy"年"m"月"d"日"`,
+			LongTimeFormat:     "h:mm:ss",
+		},
+	} {
+		RegisterLocale(locale)
+	}
+}
+
+// RegisterLocale adds a locale to the registry, or replaces the
+// locale with the same LCID or name.
+func RegisterLocale(locale *Locale) {
+	localesMutex.Lock()
+	defer localesMutex.Unlock()
+	localesByLCID[locale.LCID] = locale
+	localesByName[strings.ToLower(locale.Name)] = locale
+}
+
+// LocaleByLCID returns the registered locale with an LCID, or nil.
+func LocaleByLCID(lcid int) *Locale {
+	localesMutex.RLock()
+	defer localesMutex.RUnlock()
+	return localesByLCID[lcid]
+}
+
+// LocaleByName returns the registered locale with a language tag such
+// as "ja-JP", or nil. Tags are compared case insensitively.
+func LocaleByName(name string) *Locale {
+	localesMutex.RLock()
+	defer localesMutex.RUnlock()
+	return localesByName[strings.ToLower(name)]
+}
+
+func abbreviate12(names [12]string) (abbreviations [12]string) {
+	for i, name := range names {
+		abbreviations[i] = name[:3]
+	}
+	return abbreviations
+}
+
+func abbreviate7(names [7]string) (abbreviations [7]string) {
+	for i, name := range names {
+		abbreviations[i] = name[:3]
+	}
+	return abbreviations
+}
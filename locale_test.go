@@ -0,0 +1,98 @@
+package xlsx
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLocale(t *testing.T) {
+	c := qt.New(t)
+
+	format := func(c *qt.C, numFmt, value string, locale *Locale) string {
+		cell := &Cell{cellType: CellTypeNumeric, NumFmt: numFmt, Value: value}
+		formatted, err := cell.FormattedValueWithLocale(locale)
+		c.Assert(err, qt.IsNil)
+		return formatted
+	}
+
+	c.Run("Numbers", func(c *qt.C) {
+		german := LocaleByName("de-DE")
+		japanese := LocaleByName("ja-JP")
+		c.Assert(format(c, "#,##0.00", "1234567.891", german), qt.Equals, "1.234.567,89")
+		c.Assert(format(c, "#,##0.00", "1234567.891", japanese), qt.Equals, "1,234,567.89")
+		c.Assert(format(c, "#,##0.00", "1234567.891", nil), qt.Equals, "1,234,567.89")
+		c.Assert(format(c, "0.0%", "0.125", german), qt.Equals, "12,5%")
+		c.Assert(format(c, "0.00E+00", "12345", german), qt.Equals, "1,23E+04")
+		c.Assert(format(c, "# ?/?", "-1.5", german), qt.Equals, "-1 1/2")
+		c.Assert(format(c, "General", "1.5", german), qt.Equals, "1,5")
+		c.Assert(format(c, "#,##0", "1234567", LocaleByName("fr-FR")), qt.Equals, "1 234 567")
+		c.Assert(format(c, "#,##0.00", "1234567.5", LocaleByName("de-CH")), qt.Equals, "1’234’567.50")
+	})
+
+	c.Run("FormatLCID", func(c *qt.C) {
+		// The locale named by the format wins over the caller's.
+		c.Assert(format(c, "#,##0.00 [$€-407]", "1234.5", nil), qt.Equals, "1.234,50 €")
+		c.Assert(format(c, "[$¥-411]#,##0", "1234.5", LocaleByName("de-DE")), qt.Equals, "¥1,235")
+		c.Assert(format(c, "[$-407]mmmm", "45000", LocaleByName("ja-JP")), qt.Equals, "März")
+		// Calendar bits in the upper bytes are ignored.
+		c.Assert(format(c, "[$-10407]mmmm", "45000", nil), qt.Equals, "März")
+		// Unknown locales fall back to the caller's.
+		c.Assert(format(c, "[$-9999]#,##0.0", "1234.5", LocaleByName("de-DE")), qt.Equals, "1.234,5")
+	})
+
+	c.Run("Dates", func(c *qt.C) {
+		german := LocaleByName("de-DE")
+		japanese := LocaleByName("ja-JP")
+		// 45000 is Wednesday, 15 March 2023.
+		c.Assert(format(c, "dddd, d. mmmm yyyy", "45000", german), qt.Equals, "Mittwoch, 15. März 2023")
+		c.Assert(format(c, "ddd d mmm", "45000", german), qt.Equals, "Mi 15 Mrz")
+		c.Assert(format(c, "mmmmm", "45000", german), qt.Equals, "M")
+		c.Assert(format(c, "dddd mmmm", "45000", japanese), qt.Equals, "水曜日 3月")
+		c.Assert(format(c, "ddd mmmmm", "45000", japanese), qt.Equals, "水 3")
+		c.Assert(format(c, "h:mm AM/PM", "45000.75", japanese), qt.Equals, "6:00 午後")
+		c.Assert(format(c, `yyyy"年"m"月"d"日"(aaa)`, "45000", japanese), qt.Equals, "2023年3月15日(水)")
+		c.Assert(format(c, "[$-411]aaaa", "45000", nil), qt.Equals, "水曜日")
+		c.Assert(format(c, "AAAA", "45000", german), qt.Equals, "Mittwoch")
+		c.Assert(format(c, "h:mm am/pm", "45000.75", german), qt.Equals, "6:00 pm")
+		c.Assert(format(c, "mm:ss.00", "0.00001", german), qt.Equals, "00:00,86")
+		c.Assert(format(c, "mmmm", "44941", LocaleByName("de-AT")), qt.Equals, "Jänner")
+	})
+
+	c.Run("SystemFormats", func(c *qt.C) {
+		const date = "[$-F800]dddd, mmmm dd, yyyy"
+		c.Assert(format(c, date, "45000", nil), qt.Equals, "Wednesday, March 15, 2023")
+		c.Assert(format(c, date, "45000", LocaleByName("de-DE")), qt.Equals, "Mittwoch, 15. März 2023")
+		c.Assert(format(c, date, "45000", LocaleByName("ja-JP")), qt.Equals, "2023年3月15日")
+		c.Assert(format(c, "[$-x-sysdate]dddd, mmmm dd, yyyy", "45000", LocaleByName("en-GB")), qt.Equals, "15 March 2023")
+
+		const time = "[$-F400]h:mm:ss AM/PM"
+		c.Assert(format(c, time, "45000.75", nil), qt.Equals, "6:00:00 PM")
+		c.Assert(format(c, time, "45000.75", LocaleByName("de-DE")), qt.Equals, "18:00:00")
+		c.Assert(format(c, "[$-x-systime]h:mm:ss AM/PM", "45000.75", LocaleByName("ja-JP")), qt.Equals, "18:00:00")
+	})
+
+	c.Run("Registry", func(c *qt.C) {
+		c.Assert(LocaleByLCID(0x409), qt.Equals, LocaleEnUS)
+		c.Assert(LocaleByName("EN-us"), qt.Equals, LocaleEnUS)
+		c.Assert(LocaleByLCID(0x411).Name, qt.Equals, "ja-JP")
+		c.Assert(LocaleByName("xx-XX"), qt.IsNil)
+
+		dutch := *LocaleByName("de-DE")
+		dutch.Name = "nl-NL"
+		dutch.LCID = 0x413
+		dutch.MonthNames[2] = "maart"
+		dutch.CurrencyAfter = false
+		RegisterLocale(&dutch)
+		c.Assert(LocaleByName("nl-nl"), qt.Equals, &dutch)
+		c.Assert(format(c, "[$-413]mmmm", "45000", nil), qt.Equals, "maart")
+		c.Assert(format(c, dutch.CurrencyFormat(2), "-1234.5", nil), qt.Equals, "-€1.234,50")
+	})
+
+	c.Run("CurrencyFormat", func(c *qt.C) {
+		c.Assert(LocaleEnUS.CurrencyFormat(2), qt.Equals, "[$$-409]#,##0.00")
+		c.Assert(LocaleByName("de-DE").CurrencyFormat(2), qt.Equals, "#,##0.00 [$€-407]")
+		c.Assert(LocaleByName("ja-JP").CurrencyFormat(0), qt.Equals, "[$¥-411]#,##0")
+		c.Assert(format(c, LocaleByName("de-DE").CurrencyFormat(2), "1234.5", nil), qt.Equals, "1.234,50 €")
+	})
+}
@@ -0,0 +1,702 @@
+package xlsx
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotSlice        = errors.New("argument must be a slice of structs or struct pointers")
+	errNotSlicePointer = errors.New("argument must be a pointer to a slice of structs or struct pointers")
+	errMissingColumn   = errors.New("required column is missing")
+	errRequiredValue   = errors.New("required value is empty")
+)
+
+// XLSXStyler is the interface implemented by types that choose the
+// style of their own cells in Sheet.Marshal. XLSXStyle is called with
+// the header of each column, and returns nil for the style that is
+// given by the tags of the field.
+type XLSXStyler interface {
+	XLSXStyle(column string) *Style
+}
+
+// CellError records an error converting a single cell in Sheet.Marshal
+// or Sheet.Unmarshal.
+type CellError struct {
+	// Row and Col are the zero based coordinates of the cell. Col is
+	// -1 for a column that is missing.
+	Row, Col int
+	// Column is the header of the column.
+	Column string
+	Err    error
+}
+
+func (e *CellError) Error() string {
+	if e.Col < 0 {
+		return fmt.Sprintf("column %s: %v", e.Column, e.Err)
+	}
+	return fmt.Sprintf("cell %s (%s): %v", GetCellIDStringFromCoords(e.Col, e.Row), e.Column, e.Err)
+}
+
+// CellErrors is the list of errors returned by Sheet.Marshal and
+// Sheet.Unmarshal, in the order of the rows and columns.
+type CellErrors []*CellError
+
+func (e CellErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e[0], len(e)-1)
+}
+
+func (e CellErrors) sort() {
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].Row != e[j].Row {
+			return e[i].Row < e[j].Row
+		}
+		return e[i].Col < e[j].Col
+	})
+}
+
+// marshalField is a struct field that is stored in a column, as
+// described by its xlsx tag.
+type marshalField struct {
+	index     []int
+	name      string
+	column    int
+	format    string
+	width     float64
+	required  bool
+	omitEmpty bool
+	style     *Style
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	marshalFieldsCache sync.Map // map[reflect.Type][]*marshalField
+)
+
+// marshalFields returns the fields of a struct type that are stored in
+// columns. The fields of embedded structs are included.
+func marshalFields(t reflect.Type) ([]*marshalField, error) {
+	if fields, ok := marshalFieldsCache.Load(t); ok {
+		return fields.([]*marshalField), nil
+	}
+	var fields []*marshalField
+	var walk func(t reflect.Type, index []int) error
+	walk = func(t reflect.Type, index []int) error {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("xlsx")
+			if tag == "-" || sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			fieldIndex := append(append([]int{}, index...), i)
+			if sf.Anonymous && tag == "" {
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct && ft != timeType {
+					if err := walk(ft, fieldIndex); err != nil {
+						return err
+					}
+					continue
+				}
+				if sf.PkgPath != "" {
+					continue
+				}
+			}
+			field, err := parseMarshalTag(sf, tag)
+			if err != nil {
+				return err
+			}
+			field.index = fieldIndex
+			fields = append(fields, field)
+		}
+		return nil
+	}
+	if err := walk(t, nil); err != nil {
+		return nil, err
+	}
+	marshalFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func parseMarshalTag(sf reflect.StructField, tag string) (*marshalField, error) {
+	field := &marshalField{name: sf.Name, column: -1}
+	var style *Style
+	newStyle := func() *Style {
+		if style == nil {
+			style = NewStyle()
+		}
+		return style
+	}
+	inFormat := false
+	for _, option := range strings.Split(tag, ",") {
+		option = strings.TrimSpace(option)
+		key, value := option, ""
+		if i := strings.IndexByte(option, '='); i != -1 {
+			key, value = option[:i], option[i+1:]
+		}
+		if !marshalTagOptions[key] && inFormat {
+			// Number formats may contain commas.
+			field.format += "," + option
+			continue
+		}
+		inFormat = key == "format"
+		var err error
+		switch key {
+		case "":
+		case "name":
+			field.name = value
+		case "format":
+			field.format = value
+		case "width":
+			field.width, err = strconv.ParseFloat(value, 64)
+		case "required":
+			field.required = true
+		case "omitempty":
+			field.omitEmpty = true
+		case "bold":
+			newStyle().Font.Bold = true
+			style.ApplyFont = true
+		case "italic":
+			newStyle().Font.Italic = true
+			style.ApplyFont = true
+		case "wrap":
+			newStyle().Alignment.WrapText = true
+			style.ApplyAlignment = true
+		case "align":
+			switch value {
+			case "left", "center", "right":
+			default:
+				return nil, fmt.Errorf("invalid tag on field %s: unknown alignment %q", sf.Name, value)
+			}
+			newStyle().Alignment.Horizontal = value
+			style.ApplyAlignment = true
+		default:
+			field.column, err = strconv.Atoi(option)
+			if err != nil || field.column < 0 {
+				return nil, fmt.Errorf("invalid tag on field %s: unknown option %q", sf.Name, option)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag on field %s: %v", sf.Name, err)
+		}
+	}
+	field.style = style
+	return field, nil
+}
+
+var marshalTagOptions = map[string]bool{
+	"name": true, "format": true, "width": true, "required": true, "omitempty": true,
+	"bold": true, "italic": true, "wrap": true, "align": true,
+}
+
+// sliceElemType returns the struct type of the elements of a slice of
+// structs or struct pointers.
+func sliceElemType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem, elem.Kind() == reflect.Struct
+}
+
+// headerColumns returns the column of each header in the first row of
+// the sheet.
+func (s *Sheet) headerColumns() map[string]int {
+	columns := make(map[string]int)
+	if len(s.Rows) == 0 || s.Rows[0] == nil {
+		return columns
+	}
+	for i, cell := range s.Rows[0].Cells {
+		name := strings.ToLower(strings.TrimSpace(cell.Value))
+		if _, ok := columns[name]; name != "" && !ok {
+			columns[name] = i
+		}
+	}
+	return columns
+}
+
+// Marshal writes a slice of structs to the sheet, one row for each
+// element. Each field is stored in the column with its header, which
+// is taken from the xlsx tag of the field or from its name. If the
+// sheet is empty, a header row is written first; otherwise the first
+// row is the header row, the rows are appended below the existing
+// ones, and headers that are missing are added to it.
+//
+// Fields are described by tags such as
+//
+//	xlsx:"name=Invoice Date,format=yyyy-mm-dd,required"
+//
+// with the options:
+//
+//	name=Header   the header of the column; the field name by default
+//	N             the zero based index of the column, as in ReadStruct
+//	format=F      the number format of the cells
+//	width=W       the width of the column
+//	required      Unmarshal reports missing columns and empty cells
+//	omitempty     Marshal leaves the cell empty for a zero value
+//	bold, italic, wrap
+//	align=A       the horizontal alignment: left, center or right
+//
+// Commas after format= belong to the number format, so the column
+// index is given before it. A tag of "-" leaves the field out, as do
+// unexported fields, and the fields of embedded structs are included.
+//
+// Strings, numbers, bools, time.Time, the sql.Null types, pointers to
+// these and types that implement encoding.TextMarshaler are supported.
+// A nil pointer leaves the cell empty. Errors are collected for each
+// cell and returned together as CellErrors.
+func (s *Sheet) Marshal(slice interface{}) error {
+	s.load()
+	v := reflect.ValueOf(slice)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return errNotSlice
+	}
+	elemType, ok := sliceElemType(v.Type())
+	if !ok {
+		return errNotSlice
+	}
+	fields, err := marshalFields(elemType)
+	if err != nil {
+		return err
+	}
+
+	columns := s.headerColumns()
+	headerWidth := 0
+	if len(s.Rows) > 0 && s.Rows[0] != nil {
+		headerWidth = len(s.Rows[0].Cells)
+	}
+	fieldColumns := make([]int, len(fields))
+	// The fields are shared by every call, so the cells are given
+	// copies of the styles of their tags, which belong to this sheet.
+	fieldStyles := make([]*Style, len(fields))
+	for i, field := range fields {
+		if field.style != nil {
+			style := *field.style
+			fieldStyles[i] = &style
+		}
+		col, ok := field.column, field.column != -1
+		if !ok {
+			col, ok = columns[strings.ToLower(field.name)]
+		}
+		if !ok {
+			col = headerWidth
+		}
+		if col >= headerWidth {
+			headerWidth = col + 1
+		}
+		if s.Cell(0, col).Value == "" {
+			s.Cell(0, col).SetString(field.name)
+		}
+		columns[strings.ToLower(field.name)] = col
+		fieldColumns[i] = col
+		if field.width > 0 && s.Cols != nil {
+			s.SetColWidth(col+1, col+1, field.width)
+		}
+	}
+
+	var errs CellErrors
+	for i := 0; i < v.Len(); i++ {
+		rowIndex := len(s.Rows)
+		row := s.AddRow()
+		elem := v.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		var styler XLSXStyler
+		if elem.CanAddr() {
+			styler, _ = elem.Addr().Interface().(XLSXStyler)
+		} else {
+			styler, _ = elem.Interface().(XLSXStyler)
+		}
+		for j, field := range fields {
+			for len(row.Cells) <= fieldColumns[j] {
+				row.AddCell()
+			}
+			cell := row.Cells[fieldColumns[j]]
+			fieldV, ok := fieldByIndex(elem, field.index)
+			if ok {
+				if err := field.marshal(cell, fieldV); err != nil {
+					errs = append(errs, &CellError{Row: rowIndex, Col: fieldColumns[j], Column: field.name, Err: err})
+				}
+			}
+			style := fieldStyles[j]
+			if styler != nil {
+				if st := styler.XLSXStyle(field.name); st != nil {
+					style = st
+				}
+			}
+			if style != nil {
+				cell.SetStyle(style)
+			}
+		}
+	}
+	if errs != nil {
+		errs.sort()
+		return errs
+	}
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns false
+// instead of panicking for a nil embedded struct pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func (field *marshalField) marshal(cell *Cell, v reflect.Value) error {
+	if field.omitEmpty && isEmptyValue(v) {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch t := v.Interface().(type) {
+	case time.Time:
+		options := DefaultDateTimeOptions
+		if field.format != "" {
+			options.ExcelTimeFormat = field.format
+		}
+		if cell.Row != nil && cell.Row.Sheet != nil && cell.Row.Sheet.File != nil {
+			cell.date1904 = cell.Row.Sheet.File.Date1904
+		}
+		cell.SetDateWithOptions(t, options)
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) || v.CanAddr() && reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		if !v.Type().Implements(textMarshalerType) {
+			v = v.Addr()
+		}
+		return field.marshalText(cell, v)
+	}
+	if v.Kind() == reflect.Struct {
+		// The sql.Null types keep their value in the first field, and
+		// whether it is set in the second.
+		if v.NumField() == 2 && v.Field(1).Kind() == reflect.Bool && v.Type().Field(1).Name == "Valid" {
+			if !v.Field(1).Bool() {
+				return nil
+			}
+			return field.marshal(cell, v.Field(0))
+		}
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		cell.SetString(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cell.SetInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cell.SetNumeric(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32:
+		cell.SetValue(float32(v.Float()))
+	case reflect.Float64:
+		cell.SetFloat(v.Float())
+	case reflect.Bool:
+		cell.SetBool(v.Bool())
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	if field.format != "" {
+		cell.SetFormat(field.format)
+	}
+	return nil
+}
+
+func (field *marshalField) marshalText(cell *Cell, v reflect.Value) error {
+	text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return err
+	}
+	cell.SetString(string(text))
+	return nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+	}
+	return false
+}
+
+// Unmarshal reads the rows of the sheet into a slice of structs, which
+// ptr points to. The first row is the header row, and each field is
+// read from the column whose header matches the xlsx tag of the field
+// or its name, ignoring case. Empty rows are skipped, and each of the
+// other rows is appended to the slice.
+//
+// A conversion that fails leaves the field unset, and the remaining
+// cells are still read; the errors are returned together as
+// CellErrors. Columns that are required but missing are reported for
+// the header row.
+func (s *Sheet) Unmarshal(ptr interface{}) error {
+	s.load()
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return errNotSlicePointer
+	}
+	slice := v.Elem()
+	elemType, ok := sliceElemType(slice.Type())
+	if !ok {
+		return errNotSlicePointer
+	}
+	fields, err := marshalFields(elemType)
+	if err != nil {
+		return err
+	}
+
+	var errs CellErrors
+	columns := s.headerColumns()
+	fieldColumns := make([]int, len(fields))
+	for i, field := range fields {
+		col, ok := field.column, field.column != -1
+		if !ok {
+			col, ok = columns[strings.ToLower(field.name)]
+		}
+		if !ok {
+			col = -1
+			if field.required {
+				errs = append(errs, &CellError{Row: 0, Col: -1, Column: field.name, Err: errMissingColumn})
+			}
+		}
+		fieldColumns[i] = col
+	}
+	if errs != nil {
+		return errs
+	}
+
+	date1904 := s.File != nil && s.File.Date1904
+	for rowIndex := 1; rowIndex < len(s.Rows); rowIndex++ {
+		row := s.Rows[rowIndex]
+		if row == nil || isEmptyRow(row) {
+			continue
+		}
+		elem := reflect.New(elemType).Elem()
+		for i, field := range fields {
+			var cell *Cell
+			if col := fieldColumns[i]; col != -1 && col < len(row.Cells) {
+				cell = row.Cells[col]
+			}
+			if cell == nil || cell.Value == "" && cell.formula == "" {
+				if field.required {
+					errs = append(errs, &CellError{Row: rowIndex, Col: fieldColumns[i], Column: field.name, Err: errRequiredValue})
+				}
+				continue
+			}
+			fieldV := fieldByIndexAlloc(elem, field.index)
+			if err := unmarshalCell(cell, fieldV, date1904); err != nil {
+				errs = append(errs, &CellError{Row: rowIndex, Col: fieldColumns[i], Column: field.name, Err: err})
+			}
+		}
+		if slice.Type().Elem().Kind() == reflect.Ptr {
+			elem = elem.Addr()
+		}
+		slice = reflect.Append(slice, elem)
+	}
+	v.Elem().Set(slice)
+	if errs != nil {
+		errs.sort()
+		return errs
+	}
+	return nil
+}
+
+func isEmptyRow(row *Row) bool {
+	for _, cell := range row.Cells {
+		if cell.Value != "" || cell.formula != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates
+// embedded struct pointers that are nil.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func unmarshalCell(cell *Cell, v reflect.Value, date1904 bool) error {
+	if v.Kind() == reflect.Ptr {
+		value := reflect.New(v.Type().Elem())
+		if err := unmarshalCell(cell, value.Elem(), date1904); err != nil {
+			return err
+		}
+		v.Set(value)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := cellTime(cell, date1904)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		text, err := cell.FormattedValue()
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	if v.Kind() == reflect.Struct {
+		if v.NumField() == 2 && v.Field(1).Kind() == reflect.Bool && v.Type().Field(1).Name == "Valid" {
+			if err := unmarshalCell(cell, v.Field(0), date1904); err != nil {
+				return err
+			}
+			v.Field(1).SetBool(true)
+			return nil
+		}
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		value, err := cell.FormattedValue()
+		if err != nil {
+			return err
+		}
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(cell.Value), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(cell.Value), 64)
+			if ferr != nil || f != float64(int64(f)) {
+				return fmt.Errorf("cannot read %q as %s", cell.Value, v.Type())
+			}
+			n = int64(f)
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("%s overflows %s", cell.Value, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(cell.Value), 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(cell.Value), 64)
+			if ferr != nil || f < 0 || f != float64(uint64(f)) {
+				return fmt.Errorf("cannot read %q as %s", cell.Value, v.Type())
+			}
+			n = uint64(f)
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("%s overflows %s", cell.Value, v.Type())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(cell.Value), 64)
+		if err != nil {
+			return fmt.Errorf("cannot read %q as %s", cell.Value, v.Type())
+		}
+		if v.OverflowFloat(f) {
+			return fmt.Errorf("%s overflows %s", cell.Value, v.Type())
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := cellBool(cell)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// cellBool reads a bool from a boolean or numeric cell, or from the
+// text true, false, yes or no.
+func cellBool(cell *Cell) (bool, error) {
+	switch cell.cellType {
+	case CellTypeBool, CellTypeNumeric:
+		return cell.Bool(), nil
+	}
+	switch strings.ToLower(strings.TrimSpace(cell.Value)) {
+	case "1", "true", "yes", "y":
+		return true, nil
+	case "0", "false", "no", "n":
+		return false, nil
+	}
+	return false, fmt.Errorf("cannot read %q as bool", cell.Value)
+}
+
+// cellTime reads a time from a numeric cell, or from a string in the
+// RFC 3339 or ISO 8601 date format.
+func cellTime(cell *Cell, date1904 bool) (time.Time, error) {
+	if cell.cellType != CellTypeString && cell.cellType != CellTypeInline {
+		if t, err := cell.GetTime(date1904); err == nil {
+			return t, nil
+		}
+	}
+	value := strings.TrimSpace(cell.Value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot read %q as a time", cell.Value)
+}
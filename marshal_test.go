@@ -0,0 +1,257 @@
+package xlsx
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+type marshalAddress struct {
+	City string `xlsx:"name=City"`
+}
+
+type marshalCode string
+
+func (m marshalCode) MarshalText() ([]byte, error) {
+	if m == "" {
+		return nil, fmt.Errorf("empty code")
+	}
+	return []byte(strings.ToUpper(string(m))), nil
+}
+
+func (m *marshalCode) UnmarshalText(text []byte) error {
+	*m = marshalCode(strings.ToLower(string(text)))
+	return nil
+}
+
+type marshalInvoice struct {
+	Number   int       `xlsx:"name=Invoice,required"`
+	Date     time.Time `xlsx:"name=Invoice Date,format=yyyy-mm-dd,width=14"`
+	Customer string    `xlsx:"name=Customer,bold"`
+	Amount   float64   `xlsx:"name=Amount,format=#,##0.00,align=right"`
+	Paid     bool
+	Discount *float64 `xlsx:"name=Discount,format=0%"`
+	Note     sql.NullString
+	Code     marshalCode `xlsx:"name=Code,omitempty"`
+	Ignored  string      `xlsx:"-"`
+	internal string
+	marshalAddress
+}
+
+func (i *marshalInvoice) XLSXStyle(column string) *Style {
+	if column == "Amount" && i.Amount < 0 {
+		style := NewStyle()
+		style.Font.Color = RGB_Dark_Red
+		style.ApplyFont = true
+		return style
+	}
+	return nil
+}
+
+func TestMarshal(t *testing.T) {
+	c := qt.New(t)
+
+	discount := 0.1
+	invoices := []marshalInvoice{
+		{
+			Number:         1001,
+			Date:           time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC),
+			Customer:       "Müller GmbH",
+			Amount:         1234.5,
+			Paid:           true,
+			Discount:       &discount,
+			Note:           sql.NullString{String: "urgent", Valid: true},
+			Code:           "abc",
+			Ignored:        "ignored",
+			marshalAddress: marshalAddress{City: "Berlin"},
+		},
+		{
+			Number:   1002,
+			Date:     time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC),
+			Customer: "ヤマダ",
+			Amount:   -20,
+		},
+	}
+
+	newSheet := func(c *qt.C) *Sheet {
+		sheet, err := NewFile().AddSheet("Invoices")
+		c.Assert(err, qt.IsNil)
+		return sheet
+	}
+
+	c.Run("Marshal", func(c *qt.C) {
+		sheet := newSheet(c)
+		c.Assert(sheet.Marshal(invoices), qt.IsNil)
+		c.Assert(sheet.Rows, qt.HasLen, 3)
+
+		var header []string
+		for _, cell := range sheet.Rows[0].Cells {
+			header = append(header, cell.Value)
+		}
+		c.Assert(header, qt.DeepEquals, []string{"Invoice", "Invoice Date", "Customer", "Amount", "Paid", "Discount", "Note", "Code", "City"})
+
+		row := sheet.Rows[1]
+		value := func(col int) string {
+			formatted, err := row.Cells[col].FormattedValue()
+			c.Assert(err, qt.IsNil)
+			return formatted
+		}
+		c.Assert(value(0), qt.Equals, "1001")
+		c.Assert(value(1), qt.Equals, "2019-11-04")
+		c.Assert(value(2), qt.Equals, "Müller GmbH")
+		c.Assert(value(3), qt.Equals, "1,234.50")
+		c.Assert(row.Cells[4].Type(), qt.Equals, CellTypeBool)
+		c.Assert(value(5), qt.Equals, "10%")
+		c.Assert(value(6), qt.Equals, "urgent")
+		c.Assert(value(7), qt.Equals, "ABC")
+		c.Assert(value(8), qt.Equals, "Berlin")
+		c.Assert(sheet.Rows[2].Cells[5].Value, qt.Equals, "")
+		c.Assert(sheet.Rows[2].Cells[7].Value, qt.Equals, "")
+
+		c.Assert(row.Cells[2].GetStyle().Font.Bold, qt.Equals, true)
+		c.Assert(row.Cells[3].GetStyle().Alignment.Horizontal, qt.Equals, "right")
+		c.Assert(sheet.Rows[2].Cells[3].GetStyle().Font.Color, qt.Equals, RGB_Dark_Red)
+		c.Assert(sheet.Col(1).Width, qt.Equals, 14.0)
+
+		// The styles of the tags aren't shared with other sheets.
+		other := newSheet(c)
+		c.Assert(other.Marshal(invoices), qt.IsNil)
+		c.Assert(other.Rows[1].Cells[2].GetStyle(), qt.Not(qt.Equals), row.Cells[2].GetStyle())
+		row.Cells[2].GetStyle().Font.Italic = true
+		c.Assert(other.Rows[1].Cells[2].GetStyle().Font.Italic, qt.Equals, false)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		sheet := newSheet(c)
+		c.Assert(sheet.Marshal(&invoices), qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(sheet.File.Write(&buf), qt.IsNil)
+		file, err := OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+
+		var read []*marshalInvoice
+		c.Assert(file.Sheet["Invoices"].Unmarshal(&read), qt.IsNil)
+		c.Assert(read, qt.HasLen, 2)
+		c.Assert(read[0].Number, qt.Equals, 1001)
+		c.Assert(read[0].Date.Equal(invoices[0].Date), qt.Equals, true)
+		c.Assert(read[0].Customer, qt.Equals, "Müller GmbH")
+		c.Assert(read[0].Amount, qt.Equals, 1234.5)
+		c.Assert(read[0].Paid, qt.Equals, true)
+		c.Assert(*read[0].Discount, qt.Equals, 0.1)
+		c.Assert(read[0].Note, qt.Equals, sql.NullString{String: "urgent", Valid: true})
+		c.Assert(read[0].Code, qt.Equals, marshalCode("abc"))
+		c.Assert(read[0].City, qt.Equals, "Berlin")
+		c.Assert(read[0].Ignored, qt.Equals, "")
+		c.Assert(read[1].Discount, qt.IsNil)
+		c.Assert(read[1].Note.Valid, qt.Equals, false)
+		c.Assert(read[1].Amount, qt.Equals, -20.0)
+	})
+
+	c.Run("ExistingHeader", func(c *qt.C) {
+		sheet := newSheet(c)
+		// Columns are matched by header, whatever their order.
+		for i, name := range []string{"City", "customer", "Invoice", "Extra"} {
+			sheet.Cell(0, i).SetString(name)
+		}
+		c.Assert(sheet.Marshal(invoices[:1]), qt.IsNil)
+		c.Assert(sheet.Rows, qt.HasLen, 2)
+		c.Assert(sheet.Cell(1, 0).Value, qt.Equals, "Berlin")
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "Müller GmbH")
+		c.Assert(sheet.Cell(1, 2).Value, qt.Equals, "1001")
+		c.Assert(sheet.Cell(1, 3).Value, qt.Equals, "")
+		c.Assert(sheet.Cell(0, 4).Value, qt.Equals, "Invoice Date")
+		c.Assert(sheet.Cell(0, 9).Value, qt.Equals, "Code")
+	})
+
+	c.Run("UnmarshalErrors", func(c *qt.C) {
+		sheet := newSheet(c)
+		for i, row := range [][]string{
+			{"Invoice", "Amount", "Paid", "Invoice Date", "Unknown"},
+			{"1", "12.5", "yes", "2019-11-04", "x"},
+			{"", "", "", "", ""},
+			{"two", "3", "maybe", "2019-11-04T10:00:00Z", ""},
+			{"", "abc", "no", "someday", ""},
+			{"4.5", "", "", "", ""},
+		} {
+			for j, value := range row {
+				sheet.Cell(i, j).SetString(value)
+			}
+		}
+		var read []marshalInvoice
+		err := sheet.Unmarshal(&read)
+		c.Assert(err, qt.ErrorMatches, `cell A4 \(Invoice\): cannot read "two" as int \(and 5 more errors\)`)
+		errs, ok := err.(CellErrors)
+		c.Assert(ok, qt.Equals, true)
+		var messages []string
+		for _, e := range errs {
+			messages = append(messages, e.Error())
+		}
+		c.Assert(messages, qt.DeepEquals, []string{
+			`cell A4 (Invoice): cannot read "two" as int`,
+			`cell C4 (Paid): cannot read "maybe" as bool`,
+			`cell A5 (Invoice): required value is empty`,
+			`cell B5 (Amount): cannot read "abc" as float64`,
+			`cell D5 (Invoice Date): cannot read "someday" as a time`,
+			`cell A6 (Invoice): cannot read "4.5" as int`,
+		})
+		c.Assert(errs[0].Row, qt.Equals, 3)
+		c.Assert(errs[0].Col, qt.Equals, 0)
+
+		// The rows are still read, without the values that failed.
+		c.Assert(read, qt.HasLen, 4)
+		c.Assert(read[0].Number, qt.Equals, 1)
+		c.Assert(read[0].Amount, qt.Equals, 12.5)
+		c.Assert(read[0].Paid, qt.Equals, true)
+		c.Assert(read[0].Date, qt.Equals, time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC))
+		c.Assert(read[1].Amount, qt.Equals, 3.0)
+		c.Assert(read[1].Date, qt.Equals, time.Date(2019, 11, 4, 10, 0, 0, 0, time.UTC))
+		c.Assert(read[2].Paid, qt.Equals, false)
+	})
+
+	c.Run("MissingColumn", func(c *qt.C) {
+		sheet := newSheet(c)
+		sheet.Cell(0, 0).SetString("Customer")
+		sheet.Cell(1, 0).SetString("Müller GmbH")
+		var read []marshalInvoice
+		c.Assert(sheet.Unmarshal(&read), qt.ErrorMatches, `column Invoice: required column is missing`)
+		c.Assert(read, qt.HasLen, 0)
+	})
+
+	c.Run("PositionalTags", func(c *qt.C) {
+		type positional struct {
+			Name  string `xlsx:"1"`
+			Count int8   `xlsx:"0,name=Count"`
+		}
+		sheet := newSheet(c)
+		c.Assert(sheet.Marshal([]positional{{Name: "a", Count: 1}}), qt.IsNil)
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "Count")
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "Name")
+		c.Assert(sheet.Cell(1, 1).Value, qt.Equals, "a")
+
+		sheet.Cell(2, 0).SetInt(300)
+		sheet.Cell(2, 1).SetString("b")
+		var read []positional
+		c.Assert(sheet.Unmarshal(&read), qt.ErrorMatches, `cell A3 \(Count\): 300 overflows int8`)
+		c.Assert(read, qt.DeepEquals, []positional{{Name: "a", Count: 1}, {Name: "b"}})
+	})
+
+	c.Run("InvalidArguments", func(c *qt.C) {
+		sheet := newSheet(c)
+		c.Assert(sheet.Marshal(nil), qt.Equals, errNotSlice)
+		c.Assert(sheet.Marshal([]int{1}), qt.Equals, errNotSlice)
+		var read []marshalInvoice
+		c.Assert(sheet.Unmarshal(read), qt.Equals, errNotSlicePointer)
+		c.Assert(sheet.Marshal([]struct {
+			A string `xlsx:"align=middle"`
+		}{}), qt.ErrorMatches, `invalid tag on field A: unknown alignment "middle"`)
+		c.Assert(sheet.Marshal([]struct {
+			A string `xlsx:"optional"`
+		}{}), qt.ErrorMatches, `invalid tag on field A: unknown option "optional"`)
+		c.Assert(sheet.Marshal([]struct{ C marshalCode }{{}}), qt.ErrorMatches, `cell A2 \(C\): empty code`)
+	})
+}
@@ -0,0 +1,830 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// When a File is read its PassThrough flag is set, and the parts and
+// XML elements of the file that the library doesn't model, such as
+// pivot tables, VBA projects, printer settings, shapes on drawings and
+// extension lists, are kept so that they can be written back as they
+// were.  Only the parts and elements that the library does model are
+// rewritten from the File.
+
+// rawXMLElement is an element of a rawXMLDocument, held as the
+// offsets of its text.
+type rawXMLElement struct {
+	name       string
+	start, end int
+}
+
+// rawXMLDocument is an XML document held as text, along with where its
+// root element and the children of the root are in the text.
+type rawXMLDocument struct {
+	text string
+	// root spans just the start tag of the root element, and
+	// rootEnd is the offset of its end tag.
+	root     rawXMLElement
+	rootEnd  int
+	children []rawXMLElement
+}
+
+// readRawXMLDocument finds the root element of the XML document in
+// text, and the children of the root.
+func readRawXMLDocument(text string) (*rawXMLDocument, error) {
+	doc := &rawXMLDocument{text: text, rootEnd: -1}
+	decoder := xml.NewDecoder(strings.NewReader(text))
+	depth := 0
+	childStart := 0
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch depth {
+			case 0:
+				doc.root = rawXMLElement{name: t.Name.Local, start: start, end: int(decoder.InputOffset())}
+			case 1:
+				childStart = start
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+			switch depth {
+			case 0:
+				doc.rootEnd = start
+			case 1:
+				doc.children = append(doc.children, rawXMLElement{name: t.Name.Local, start: childStart, end: int(decoder.InputOffset())})
+			}
+		}
+	}
+	if doc.rootEnd < 0 {
+		return nil, fmt.Errorf("XML document has no root element")
+	}
+	return doc, nil
+}
+
+// element returns the text of e.
+func (d *rawXMLDocument) element(e rawXMLElement) string {
+	return d.text[e.start:e.end]
+}
+
+// without returns the document without the children of the root that
+// are in names, so that only what is needed of it is kept.
+func (d *rawXMLDocument) without(names map[string]bool) (*rawXMLDocument, error) {
+	var text strings.Builder
+	text.WriteString(d.text[:d.root.end])
+	for _, child := range d.children {
+		if !names[child.name] {
+			text.WriteString(d.element(child))
+		}
+	}
+	text.WriteString(d.text[d.rootEnd:])
+	return readRawXMLDocument(text.String())
+}
+
+var relationshipsPrefixRegexp = regexp.MustCompile(`xmlns:([\w.-]+)="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`)
+
+// relationshipPrefixes returns the namespace prefixes that the
+// document uses for attributes that refer to relationships, such as
+// r:id.
+func (d *rawXMLDocument) relationshipPrefixes() []string {
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, match := range relationshipsPrefixRegexp.FindAllStringSubmatch(d.text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			prefixes = append(prefixes, match[1])
+		}
+	}
+	return prefixes
+}
+
+// rewriteRelationshipIDs calls rewrite with each relationship id that
+// the XML text refers to, and replaces the id with the result.
+func rewriteRelationshipIDs(text string, prefixes []string, rewrite func(id string) string) string {
+	for _, prefix := range prefixes {
+		re := regexp.MustCompile(`(\s` + regexp.QuoteMeta(prefix) + `:[A-Za-z]+=")([^"]*)"`)
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			parts := re.FindStringSubmatch(match)
+			return parts[1] + rewrite(parts[2]) + `"`
+		})
+	}
+	return text
+}
+
+// mergeRootAttrs adds the attributes of the root start tag
+// originalRoot, such as namespace declarations and mc:Ignorable, that
+// the root of the marshalled document lacks.
+func mergeRootAttrs(marshalled *rawXMLDocument, originalRoot string) string {
+	token, err := xml.NewDecoder(strings.NewReader(originalRoot)).RawToken()
+	if err != nil {
+		return marshalled.text
+	}
+	start, ok := token.(xml.StartElement)
+	if !ok {
+		return marshalled.text
+	}
+	root := marshalled.element(marshalled.root)
+	var extra string
+	for _, attr := range start.Attr {
+		name := attr.Name.Local
+		if attr.Name.Space != "" {
+			name = attr.Name.Space + ":" + name
+		}
+		if strings.Contains(root, " "+name+"=") {
+			continue
+		}
+		extra += fmt.Sprintf(` %s="%s"`, name, escapeXMLAttr(attr.Value))
+	}
+	end := marshalled.root.end - 1
+	if strings.HasSuffix(root, "/>") {
+		end--
+	}
+	return marshalled.text[:end] + extra + marshalled.text[end:]
+}
+
+// xmlElementPolicy says which of the children of the root element of
+// a part are written from the model, and where the ones that are kept
+// from the original part belong.
+type xmlElementPolicy struct {
+	// order is the order of the children in the schema.
+	order []string
+	// owned children are always written from the model.
+	owned map[string]bool
+	// replaced children are written by the model with default
+	// values, so they give way to the original part's, if any.
+	replaced map[string]bool
+}
+
+func newElementSet(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+var worksheetElementPolicy = xmlElementPolicy{
+	order: []string{"sheetPr", "dimension", "sheetViews", "sheetFormatPr", "cols", "sheetData",
+		"sheetCalcPr", "sheetProtection", "protectedRanges", "scenarios", "autoFilter", "sortState",
+		"dataConsolidate", "customSheetViews", "mergeCells", "phoneticPr", "conditionalFormatting",
+		"dataValidations", "hyperlinks", "printOptions", "pageMargins", "pageSetup", "headerFooter",
+		"rowBreaks", "colBreaks", "customProperties", "cellWatches", "ignoredErrors", "smartTags",
+		"drawing", "legacyDrawing", "legacyDrawingHF", "drawingHF", "picture", "oleObjects",
+		"controls", "webPublishItems", "tableParts", "extLst"},
+	owned: newElementSet("dimension", "sheetViews", "sheetFormatPr", "cols", "sheetData",
+		"sheetProtection", "autoFilter", "mergeCells", "conditionalFormatting", "dataValidations", "hyperlinks",
+		"drawing", "legacyDrawing", "tableParts"),
+	replaced: newElementSet("sheetPr", "printOptions", "pageMargins", "pageSetup", "headerFooter"),
+}
+
+var tableElementPolicy = xmlElementPolicy{
+	order: []string{"autoFilter", "sortState", "tableColumns", "tableStyleInfo", "extLst"},
+	owned: newElementSet("autoFilter", "tableColumns", "tableStyleInfo"),
+}
+
+var workbookElementPolicy = xmlElementPolicy{
+	order: []string{"fileVersion", "fileSharing", "workbookPr", "workbookProtection", "bookViews",
+		"sheets", "functionGroups", "externalReferences", "definedNames", "calcPr", "oleSize",
+		"customWorkbookViews", "pivotCaches", "smartTagPr", "smartTagTypes", "webPublishing",
+		"fileRecoveryPr", "webPublishObjects", "extLst"},
+	owned:    newElementSet("workbookProtection", "bookViews", "sheets"),
+	replaced: newElementSet("fileVersion", "workbookPr", "definedNames", "calcPr"),
+}
+
+// merge returns the marshalled part with the children of the original
+// part that the model doesn't hold merged into it.  ids maps the
+// relationship ids of the original part to those of the marshalled
+// one.
+func (p xmlElementPolicy) merge(marshalled string, original *rawXMLDocument, ids map[string]string) (string, error) {
+	doc, err := readRawXMLDocument(marshalled)
+	if err != nil {
+		return "", err
+	}
+	rank := func(name string) int {
+		for i, n := range p.order {
+			if n == name {
+				return i
+			}
+		}
+		return -1
+	}
+	type item struct {
+		rank int
+		text string
+	}
+	var items []item
+	for _, child := range doc.children {
+		if p.replaced[child.name] {
+			continue
+		}
+		items = append(items, item{rank(child.name), doc.element(child)})
+	}
+	prefixes := original.relationshipPrefixes()
+	lastRank := -1
+	for _, child := range original.children {
+		r := rank(child.name)
+		if r < 0 {
+			// Elements that aren't in the schema, such as
+			// mc:AlternateContent, stay after the element
+			// they followed.
+			r = lastRank
+		} else {
+			lastRank = r
+		}
+		if p.owned[child.name] {
+			continue
+		}
+		text := rewriteRelationshipIDs(original.element(child), prefixes, func(id string) string {
+			if newID, ok := ids[id]; ok {
+				return newID
+			}
+			return id
+		})
+		i := 0
+		for i < len(items) && items[i].rank <= r {
+			i++
+		}
+		items = append(items[:i], append([]item{{r, text}}, items[i:]...)...)
+	}
+	var merged strings.Builder
+	merged.WriteString(doc.text[:doc.root.end])
+	for _, item := range items {
+		merged.WriteString(item.text)
+	}
+	merged.WriteString(doc.text[doc.rootEnd:])
+	doc, err = readRawXMLDocument(merged.String())
+	if err != nil {
+		return "", err
+	}
+	return mergeRootAttrs(doc, original.element(original.root)), nil
+}
+
+// relsPartName returns the name of the part that holds the
+// relationships of the part called name.
+func relsPartName(name string) string {
+	dir, file := path.Split(name)
+	return dir + "_rels/" + file + ".rels"
+}
+
+// relativePartTarget returns the target of a relationship from a part
+// in fromDir to the part called partName.
+func relativePartTarget(fromDir, partName string) string {
+	if fromDir == "" {
+		return partName
+	}
+	from := strings.Split(fromDir, "/")
+	to := strings.Split(partName, "/")
+	common := 0
+	for common < len(from) && common < len(to)-1 && from[common] == to[common] {
+		common++
+	}
+	return strings.Repeat("../", len(from)-common) + strings.Join(to[common:], "/")
+}
+
+// originalRelations returns the relationships of a part in dir for
+// which keep returns true, with the targets of relationships within
+// the file changed to the names of the parts they refer to.
+func originalRelations(rels *xlsxWorksheetRels, dir string, keep func(rel xlsxWorksheetRelation) bool) []xlsxWorksheetRelation {
+	if rels == nil {
+		return nil
+	}
+	var kept []xlsxWorksheetRelation
+	for _, rel := range rels.Relationships {
+		if !keep(rel) {
+			continue
+		}
+		if rel.TargetMode != RelationshipTargetModeExternal {
+			rel.Target = relationshipTargetPartName(dir, rel.Target)
+		}
+		kept = append(kept, rel)
+	}
+	return kept
+}
+
+// originalWorkbook holds what the library doesn't model of the
+// workbook part of a file that was read.
+type originalWorkbook struct {
+	doc  *rawXMLDocument
+	rels []xlsxWorksheetRelation
+	// parts holds the parts of the file that the library doesn't
+	// read, as the file may be closed by the time it is written.
+	parts map[string]string
+	// sheets are the sheets that aren't worksheets, such as chart
+	// sheets, along with where they were among the sheets.
+	sheets []originalWorkbookSheet
+}
+
+type originalWorkbookSheet struct {
+	index int
+	sheet xlsxSheet
+}
+
+// The types of the workbook's relationships to the parts that the
+// library writes itself.
+var ownedWorkbookRelationshipTypes = newElementSet(
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet",
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings",
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles",
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme",
+	// The calculation chain would be out of date.
+	"http://schemas.openxmlformats.org/officeDocument/2006/relationships/calcChain",
+)
+
+// The parts that are always written from the model.
+var modelledPartRegexp = regexp.MustCompile(`^xl/(workbook\.xml|worksheets/[^/]*\.xml|sharedStrings\.xml|styles\.xml|calcChain\.xml)$`)
+
+// readOriginalWorkbook keeps what the library doesn't model of the
+// workbook part of the file, and the parts of the file that it doesn't
+// read.
+func readOriginalWorkbook(parts map[string]*zip.File) (*originalWorkbook, error) {
+	data, err := readZipPart(parts, "xl/workbook.xml")
+	if err != nil {
+		return nil, &ReadError{Part: "xl/workbook.xml", Err: err}
+	}
+	doc, err := readRawXMLDocument(string(data))
+	if err != nil {
+		return nil, &ReadError{Part: "xl/workbook.xml", Err: err}
+	}
+	original := &originalWorkbook{doc: doc, parts: make(map[string]string)}
+	for name := range parts {
+		if modelledPartRegexp.MatchString(name) || strings.HasSuffix(name, "/") {
+			continue
+		}
+		part, err := readZipPart(parts, name)
+		if err != nil {
+			return nil, &ReadError{Part: name, Err: err}
+		}
+		original.parts[name] = string(part)
+	}
+	workbook := new(xlsxWorkbook)
+	if err := xml.Unmarshal(data, workbook); err != nil {
+		return nil, &ReadError{Part: "xl/workbook.xml", Err: err}
+	}
+	rels, err := readWorksheetRelsFromZipFile(parts["xl/_rels/workbook.xml.rels"])
+	if err != nil {
+		return nil, &ReadError{Part: "xl/_rels/workbook.xml.rels", Err: err}
+	}
+	original.rels = originalRelations(rels, "xl", func(rel xlsxWorksheetRelation) bool {
+		return !ownedWorkbookRelationshipTypes[string(rel.Type)]
+	})
+	for i, sheet := range workbook.Sheets.Sheet {
+		for _, rel := range original.rels {
+			if rel.Id == sheet.Id {
+				original.sheets = append(original.sheets, originalWorkbookSheet{i, sheet})
+			}
+		}
+	}
+	return original, nil
+}
+
+// readRels returns the relationships in the part called name, or nil
+// if there is no such part or it can't be read.
+func (o *originalWorkbook) readRels(name string) *xlsxWorksheetRels {
+	part, ok := o.parts[name]
+	if !ok {
+		return nil
+	}
+	rels := new(xlsxWorksheetRels)
+	if err := xml.Unmarshal([]byte(part), rels); err != nil {
+		return nil
+	}
+	return rels
+}
+
+// relations returns the relationships of the workbook that are kept,
+// numbered from firstID, and a map from their old ids to their new
+// ones.
+func (o *originalWorkbook) relations(firstID int) ([]xlsxWorkbookRelation, map[string]string) {
+	var rels []xlsxWorkbookRelation
+	ids := make(map[string]string)
+	for _, rel := range o.rels {
+		id := "rId" + strconv.Itoa(firstID+len(rels))
+		ids[rel.Id] = id
+		target := rel.Target
+		if rel.TargetMode != RelationshipTargetModeExternal {
+			target = relativePartTarget("xl", target)
+		}
+		rels = append(rels, xlsxWorkbookRelation{Id: id, Target: target, Type: string(rel.Type), TargetMode: string(rel.TargetMode)})
+	}
+	return rels, ids
+}
+
+// addSheets adds the sheets that aren't worksheets back to the
+// workbook, where they were if possible, numbering them from
+// firstSheetID.
+func (o *originalWorkbook) addSheets(workbook *xlsxWorkbook, ids map[string]string, firstSheetID int) {
+	for i, original := range o.sheets {
+		sheet := original.sheet
+		sheet.Id = ids[sheet.Id]
+		sheet.SheetId = strconv.Itoa(firstSheetID + i)
+		sheets := workbook.Sheets.Sheet
+		index := original.index
+		if index > len(sheets) {
+			index = len(sheets)
+		}
+		workbook.Sheets.Sheet = append(sheets[:index], append([]xlsxSheet{sheet}, sheets[index:]...)...)
+	}
+}
+
+// originalSheet holds what the library doesn't model of a worksheet
+// that was read.
+type originalSheet struct {
+	doc  *rawXMLDocument
+	rels []xlsxWorksheetRelation
+	// drawing holds the shapes of the sheet's drawing that aren't
+	// pictures.
+	drawing *originalDrawing
+}
+
+// originalDrawing holds the anchors of a drawing that the library
+// doesn't model, such as shapes and charts.
+type originalDrawing struct {
+	doc      *rawXMLDocument
+	anchors  []string
+	rels     []xlsxWorksheetRelation
+	maxShape int
+}
+
+// readOriginalSheet keeps what the library doesn't model of the
+// worksheet part f.
+func readOriginalSheet(f *zip.File, worksheet *xlsxWorksheet, worksheetRels *xlsxWorksheetRels) (*originalSheet, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var data bytes.Buffer
+	if _, err := io.Copy(&data, rc); err != nil {
+		return nil, err
+	}
+	doc, err := readRawXMLDocument(data.String())
+	if err != nil {
+		return nil, err
+	}
+	// The sheet data can be large, and is written from the model.
+	doc, err = doc.without(worksheetElementPolicy.owned)
+	if err != nil {
+		return nil, err
+	}
+	original := &originalSheet{doc: doc}
+	original.rels = originalRelations(worksheetRels, path.Dir(f.Name), func(rel xlsxWorksheetRelation) bool {
+		switch rel.Type {
+		case RelationshipTypeHyperlink, RelationshipTypeComments, RelationshipTypeDrawing, RelationshipTypeTable:
+			return false
+		case RelationshipTypeVMLDrawing:
+			// The legacy drawing of the comments, rather than
+			// one for pictures in the header or footer.
+			return worksheet.LegacyDrawing == nil || worksheet.LegacyDrawing.RelationshipId != rel.Id
+		}
+		return true
+	})
+	return original, nil
+}
+
+// readOriginalDrawing keeps the anchors of the drawing doc that
+// aren't pictures, which are the ones in anchors that picture is nil
+// for.
+func readOriginalDrawing(doc *rawXMLDocument, anchors []*Picture, drawingRels *xlsxWorksheetRels, dir string) *originalDrawing {
+	if len(doc.children) != len(anchors) {
+		return nil
+	}
+	original := &originalDrawing{doc: doc}
+	prefixes := doc.relationshipPrefixes()
+	ids := make(map[string]bool)
+	for i, child := range doc.children {
+		if anchors[i] != nil {
+			continue
+		}
+		anchor := doc.element(child)
+		original.anchors = append(original.anchors, rewriteRelationshipIDs(anchor, prefixes, func(id string) string {
+			ids[id] = true
+			return id
+		}))
+		for _, match := range shapeIDRegexp.FindAllStringSubmatch(anchor, -1) {
+			if id, _ := strconv.Atoi(match[1]); id > original.maxShape {
+				original.maxShape = id
+			}
+		}
+	}
+	if len(original.anchors) == 0 {
+		return nil
+	}
+	original.rels = originalRelations(drawingRels, dir, func(rel xlsxWorksheetRelation) bool {
+		return ids[rel.Id]
+	})
+	return original
+}
+
+var shapeIDRegexp = regexp.MustCompile(`<[\w]+:cNvPr[^>]*\sid="(\d+)"`)
+
+// addTo adds the anchors to the drawing, along with the relationships
+// that they need.
+func (o *originalDrawing) addTo(drawing *drawingPart) {
+	ids := make(map[string]string)
+	for _, rel := range o.rels {
+		target := rel.Target
+		if rel.TargetMode != RelationshipTargetModeExternal {
+			target = relativePartTarget("xl/drawings", target)
+		}
+		ids[rel.Id] = drawing.addRelation(rel.Type, target, rel.TargetMode)
+	}
+	prefixes := o.doc.relationshipPrefixes()
+	var anchors string
+	for _, anchor := range o.anchors {
+		anchors += rewriteRelationshipIDs(anchor, prefixes, func(id string) string {
+			if newID, ok := ids[id]; ok {
+				return newID
+			}
+			return id
+		})
+	}
+	drawing.anchors = anchors + drawing.anchors
+	if o.maxShape > drawing.shapes+1 {
+		drawing.shapes = o.maxShape - 1
+	}
+	drawing.root = o.doc.element(o.doc.root)
+}
+
+// passThrough reports whether the parts and elements that the library
+// doesn't model should be written from the file that was read.
+func (f *File) passThrough() bool {
+	return f.PassThrough && f.original != nil
+}
+
+// originalParts returns what the library doesn't model of the sheet,
+// if it is to be written.
+func (s *Sheet) originalParts() *originalSheet {
+	if s.File == nil || !s.File.passThrough() {
+		return nil
+	}
+	return s.original
+}
+
+// addOriginalRelations adds the relationships of the sheet that the
+// library doesn't model to rels, and returns them along with a map
+// from their old ids to their new ones.
+func (s *Sheet) addOriginalRelations(rels *xlsxWorksheetRels) (*xlsxWorksheetRels, map[string]string) {
+	original := s.originalParts()
+	if original == nil || len(original.rels) == 0 {
+		return rels, nil
+	}
+	if rels == nil {
+		rels = &xlsxWorksheetRels{XMLName: xml.Name{Local: "Relationships"}}
+	}
+	kept, ids := remapRelations(original.rels, "xl/worksheets", len(rels.Relationships)+1)
+	rels.Relationships = append(rels.Relationships, kept...)
+	return rels, ids
+}
+
+// remapRelations returns relationships kept from a file that was read
+// for a part in dir, numbered from firstID, and a map from their old
+// ids to their new ones.
+func remapRelations(rels []xlsxWorksheetRelation, dir string, firstID int) ([]xlsxWorksheetRelation, map[string]string) {
+	var remapped []xlsxWorksheetRelation
+	ids := make(map[string]string)
+	for _, rel := range rels {
+		id := "rId" + strconv.Itoa(firstID+len(remapped))
+		ids[rel.Id] = id
+		if rel.TargetMode != RelationshipTargetModeExternal {
+			rel.Target = relativePartTarget(dir, rel.Target)
+		}
+		rel.Id = id
+		remapped = append(remapped, rel)
+	}
+	return remapped, ids
+}
+
+// originalTable holds what the library doesn't model of a table that
+// was read.
+type originalTable struct {
+	doc  *rawXMLDocument
+	rels []xlsxWorksheetRelation
+}
+
+// readOriginalTable keeps what the library doesn't model of the table
+// part called name, whose contents are data.
+func readOriginalTable(data []byte, name string, parts map[string]*zip.File) (*originalTable, error) {
+	doc, err := readRawXMLDocument(string(data))
+	if err != nil {
+		return nil, err
+	}
+	doc, err = doc.without(tableElementPolicy.owned)
+	if err != nil {
+		return nil, err
+	}
+	original := &originalTable{doc: doc}
+	if relsFile, ok := parts[relsPartName(name)]; ok {
+		rels, err := readWorksheetRelsFromZipFile(relsFile)
+		if err != nil {
+			return nil, err
+		}
+		original.rels = originalRelations(rels, path.Dir(name), func(rel xlsxWorksheetRelation) bool {
+			return true
+		})
+	}
+	return original, nil
+}
+
+// originalParts returns what the library doesn't model of the table,
+// if it is to be written.
+func (t *Table) originalParts() *originalTable {
+	if t.Sheet == nil || t.Sheet.File == nil || !t.Sheet.File.passThrough() {
+		return nil
+	}
+	return t.original
+}
+
+// mergeOriginalXML merges the elements of the worksheet that the
+// library doesn't model into the marshalled worksheet.
+func (s *Sheet) mergeOriginalXML(marshalled string, ids map[string]string) (string, error) {
+	original := s.originalParts()
+	if original == nil {
+		return marshalled, nil
+	}
+	return worksheetElementPolicy.merge(marshalled, original.doc, ids)
+}
+
+// partNames picks the names of the parts that are written, so that
+// they don't clash with the parts that are kept from the file that was
+// read.
+type partNames struct {
+	taken map[string]bool
+}
+
+func newPartNames(kept map[string]bool) *partNames {
+	names := &partNames{taken: make(map[string]bool)}
+	for name := range kept {
+		names.taken[name] = true
+	}
+	return names
+}
+
+// pick returns the name that format, which holds a single %d, gives
+// for n, or for the next number after it that gives a free name.
+func (p *partNames) pick(format string, n int) string {
+	for {
+		name := fmt.Sprintf(format, n)
+		if !p.taken[name] {
+			p.taken[name] = true
+			return name
+		}
+		n++
+	}
+}
+
+// originalPartsToKeep returns the names of the parts of the file that
+// was read that the library doesn't model, and which are still
+// referred to by the relationships that are kept.
+func (f *File) originalPartsToKeep() map[string]bool {
+	keep := make(map[string]bool)
+	if !f.passThrough() {
+		return keep
+	}
+	var visit func(name string)
+	visit = func(name string) {
+		if _, ok := f.original.parts[name]; keep[name] || !ok {
+			return
+		}
+		keep[name] = true
+		relsName := relsPartName(name)
+		rels := f.original.readRels(relsName)
+		if rels == nil {
+			return
+		}
+		keep[relsName] = true
+		for _, rel := range originalRelations(rels, path.Dir(name), func(rel xlsxWorksheetRelation) bool {
+			return rel.TargetMode != RelationshipTargetModeExternal
+		}) {
+			visit(rel.Target)
+		}
+	}
+	visitAll := func(rels []xlsxWorksheetRelation) {
+		for _, rel := range rels {
+			if rel.TargetMode != RelationshipTargetModeExternal {
+				visit(rel.Target)
+			}
+		}
+	}
+	visitAll(originalRelations(f.original.readRels("_rels/.rels"), "", func(rel xlsxWorksheetRelation) bool {
+		return rel.Target != "xl/workbook.xml" && rel.Target != "/xl/workbook.xml"
+	}))
+	visitAll(f.original.rels)
+	for _, sheet := range f.Sheets {
+		if sheet.writesOriginal() {
+			visitAll(originalRelations(f.original.readRels(relsPartName(sheet.part)), path.Dir(sheet.part), func(rel xlsxWorksheetRelation) bool {
+				return rel.TargetMode != RelationshipTargetModeExternal
+			}))
+			continue
+		}
+		if original := sheet.originalParts(); original != nil {
+			visitAll(original.rels)
+			if original.drawing != nil {
+				visitAll(original.drawing.rels)
+			}
+		}
+		for _, table := range sheet.Tables {
+			if original := table.originalParts(); original != nil {
+				visitAll(original.rels)
+			}
+		}
+	}
+	return keep
+}
+
+// The parts that are written from the file that was read in place of
+// the ones the library would write.
+var preferredOriginalParts = []string{
+	"_rels/.rels",
+	"docProps/app.xml",
+	"docProps/core.xml",
+	"xl/theme/theme1.xml",
+}
+
+// writeOriginalParts adds the parts of the file that was read that
+// are kept to parts, and their content types to types.
+func (f *File) writeOriginalParts(parts map[string]string, types *xlsxTypes, keep map[string]bool) error {
+	if !f.passThrough() {
+		return nil
+	}
+	for name := range keep {
+		if _, ok := parts[name]; !ok {
+			parts[name] = f.original.parts[name]
+		}
+	}
+	for _, name := range preferredOriginalParts {
+		if part, ok := f.original.parts[name]; ok {
+			parts[name] = part
+		}
+	}
+
+	data, ok := f.original.parts["[Content_Types].xml"]
+	if !ok {
+		// Without the original content types, the parts will
+		// have to do with the defaults.
+		return nil
+	}
+	original := new(xlsxTypes)
+	if err := xml.Unmarshal([]byte(data), original); err != nil {
+		return err
+	}
+	overrides := make(map[string]string)
+	for _, o := range original.Overrides {
+		overrides[o.PartName] = o.ContentType
+	}
+	defaults := make(map[string]string)
+	for _, d := range original.Defaults {
+		defaults[strings.ToLower(d.Extension)] = d.ContentType
+	}
+	// A workbook with macros or a template has a different
+	// content type.
+	for i, o := range types.Overrides {
+		if o.PartName == "/xl/workbook.xml" && overrides[o.PartName] != "" {
+			types.Overrides[i].ContentType = overrides[o.PartName]
+		}
+	}
+
+	hasOverride := make(map[string]bool)
+	for _, o := range types.Overrides {
+		hasOverride[o.PartName] = true
+	}
+	hasDefault := make(map[string]bool)
+	for _, d := range types.Defaults {
+		hasDefault[strings.ToLower(d.Extension)] = true
+	}
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		partName := "/" + name
+		extension := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+		switch {
+		case hasOverride[partName]:
+		case overrides[partName] != "":
+			types.Overrides = append(types.Overrides, xlsxOverride{PartName: partName, ContentType: overrides[partName]})
+		case hasDefault[extension]:
+		case defaults[extension] != "":
+			types.Defaults = append(types.Defaults, xlsxDefault{Extension: extension, ContentType: defaults[extension]})
+			hasDefault[extension] = true
+		}
+	}
+	return nil
+}
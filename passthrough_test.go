@@ -0,0 +1,395 @@
+package xlsx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"path"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+const passThroughRelsHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+
+// passThroughTestParts returns the parts of a file with things that
+// the library doesn't model: a macro-enabled workbook with a VBA
+// project, a pivot cache, custom XML and a chart sheet, and a
+// worksheet with printer settings, an extension list and a drawing
+// holding a shape and a chart as well as a picture.
+func passThroughTestParts(c *qt.C) map[string]string {
+	logo := makeTestImage(c, 10, 10, encodePNG)
+	rel := func(id, relType, target string) string {
+		return `<Relationship Id="` + id + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/` + relType + `" Target="` + target + `"/>`
+	}
+	return map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Default Extension="png" ContentType="image/png"/>` +
+			`<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.ms-excel.sheet.macroEnabled.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/chartsheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.chartsheet+xml"/>` +
+			`<Override PartName="/xl/drawings/drawing1.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"/>` +
+			`<Override PartName="/xl/drawings/drawing2.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"/>` +
+			`<Override PartName="/xl/charts/chart1.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"/>` +
+			`<Override PartName="/xl/charts/chart2.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"/>` +
+			`<Override PartName="/xl/pivotCache/pivotCacheDefinition1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.pivotCacheDefinition+xml"/>` +
+			`<Override PartName="/xl/pivotCache/pivotCacheRecords1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.pivotCacheRecords+xml"/>` +
+			`<Override PartName="/xl/printerSettings/printerSettings1.bin" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.printerSettings"/>` +
+			`<Override PartName="/xl/calcChain.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.calcChain+xml"/>` +
+			`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+			`</Types>`,
+		"_rels/.rels": passThroughRelsHeader +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+			`</Relationships>`,
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:creator>Template Author</dc:creator></cp:coreProperties>`,
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" mc:Ignorable="x15" xmlns:x15="http://schemas.microsoft.com/office/spreadsheetml/2010/11/main">` +
+			`<fileVersion appName="xl" lastEdited="7"/><workbookPr codeName="ThisWorkbook"/>` +
+			`<bookViews><workbookView activeTab="1"/></bookViews>` +
+			`<sheets><sheet name="Data" sheetId="1" r:id="rId1"/><sheet name="Chart" sheetId="2" r:id="rId2"/></sheets>` +
+			`<definedNames><definedName name="Total">Data!$A$1</definedName></definedNames>` +
+			`<calcPr calcId="162913"/>` +
+			`<pivotCaches><pivotCache cacheId="5" r:id="rId4"/></pivotCaches>` +
+			`<extLst><ext uri="{140A7094-0E35-4892-8432-C4D2E57EDEB5}"><x15:workbookPr chartTrackingRefBase="1"/></ext></extLst>` +
+			`</workbook>`,
+		"xl/_rels/workbook.xml.rels": passThroughRelsHeader +
+			rel("rId1", "worksheet", "worksheets/sheet1.xml") +
+			rel("rId2", "chartsheet", "chartsheets/sheet1.xml") +
+			rel("rId3", "calcChain", "calcChain.xml") +
+			rel("rId4", "pivotCacheDefinition", "pivotCache/pivotCacheDefinition1.xml") +
+			`<Relationship Id="rId5" Type="http://schemas.microsoft.com/office/2006/relationships/vbaProject" Target="vbaProject.bin"/>` +
+			rel("rId6", "customXml", "../customXml/item1.xml") +
+			`</Relationships>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" mc:Ignorable="x14ac" xmlns:x14ac="http://schemas.microsoft.com/office/spreadsheetml/2009/9/ac">` +
+			`<sheetPr codeName="Sheet1"><tabColor rgb="FFFF0000"/></sheetPr><dimension ref="A1"/>` +
+			`<sheetFormatPr defaultRowHeight="15" x14ac:dyDescent="0.25"/>` +
+			`<sheetData><row r="1" x14ac:dyDescent="0.25"><c r="A1"><v>1</v></c></row></sheetData>` +
+			`<pageMargins left="0.5" right="0.5" top="1" bottom="1" header="0.5" footer="0.5"/>` +
+			`<pageSetup paperSize="9" orientation="landscape" r:id="rId2"/>` +
+			`<drawing r:id="rId1"/>` +
+			`<extLst><ext uri="{05C60535-1F16-4fd2-B633-F4F36F0B64E0}"><x14:sparklineGroups xmlns:x14="http://schemas.microsoft.com/office/spreadsheetml/2009/9/main"/></ext></extLst>` +
+			`</worksheet>`,
+		"xl/worksheets/_rels/sheet1.xml.rels": passThroughRelsHeader +
+			rel("rId1", "drawing", "../drawings/drawing1.xml") +
+			rel("rId2", "printerSettings", "../printerSettings/printerSettings1.bin") +
+			`</Relationships>`,
+		"xl/drawings/drawing1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">` +
+			`<xdr:oneCellAnchor><xdr:from><xdr:col>3</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>0</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from><xdr:ext cx="95250" cy="95250"/>` +
+			`<xdr:pic><xdr:nvPicPr><xdr:cNvPr id="2" name="Logo"/><xdr:cNvPicPr/></xdr:nvPicPr>` +
+			`<xdr:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:embed="rId1"/></xdr:blipFill><xdr:spPr/></xdr:pic><xdr:clientData/></xdr:oneCellAnchor>` +
+			`<xdr:twoCellAnchor><xdr:from><xdr:col>0</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>2</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+			`<xdr:to><xdr:col>2</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>4</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:to>` +
+			`<xdr:sp><xdr:nvSpPr><xdr:cNvPr id="7" name="Rectangle 6"/><xdr:cNvSpPr/></xdr:nvSpPr><xdr:spPr/></xdr:sp><xdr:clientData/></xdr:twoCellAnchor>` +
+			`<xdr:twoCellAnchor><xdr:from><xdr:col>4</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>2</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+			`<xdr:to><xdr:col>8</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>10</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:to>` +
+			`<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="3" name="Chart 2"/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>` +
+			`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm><a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart">` +
+			`<c:chart xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:id="rId2"/>` +
+			`</a:graphicData></a:graphic></xdr:graphicFrame><xdr:clientData/></xdr:twoCellAnchor>` +
+			`</xdr:wsDr>`,
+		"xl/drawings/_rels/drawing1.xml.rels": passThroughRelsHeader +
+			rel("rId1", "image", "../media/image1.png") +
+			rel("rId2", "chart", "../charts/chart1.xml") +
+			`</Relationships>`,
+		"xl/media/image1.png":                     string(logo),
+		"xl/charts/chart1.xml":                    `<?xml version="1.0" encoding="UTF-8"?><c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart"/>`,
+		"xl/printerSettings/printerSettings1.bin": "\x00\x01printer\x02",
+		"xl/chartsheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<chartsheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><drawing r:id="rId1"/></chartsheet>`,
+		"xl/chartsheets/_rels/sheet1.xml.rels": passThroughRelsHeader +
+			rel("rId1", "drawing", "../drawings/drawing2.xml") +
+			`</Relationships>`,
+		"xl/drawings/drawing2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing"/>`,
+		"xl/drawings/_rels/drawing2.xml.rels": passThroughRelsHeader +
+			rel("rId1", "chart", "../charts/chart2.xml") +
+			`</Relationships>`,
+		"xl/charts/chart2.xml": `<?xml version="1.0" encoding="UTF-8"?><c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart"/>`,
+		"xl/pivotCache/pivotCacheDefinition1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<pivotCacheDefinition xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:id="rId1"/>`,
+		"xl/pivotCache/_rels/pivotCacheDefinition1.xml.rels": passThroughRelsHeader +
+			rel("rId1", "pivotCacheRecords", "pivotCacheRecords1.xml") +
+			`</Relationships>`,
+		"xl/pivotCache/pivotCacheRecords1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<pivotCacheRecords xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="0"/>`,
+		"xl/calcChain.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<calcChain xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><c r="A1" i="1"/></calcChain>`,
+		"xl/vbaProject.bin":   "\xd0\xcf\x11\xe0vba",
+		"customXml/item1.xml": `<?xml version="1.0" encoding="UTF-8"?><custom xmlns="urn:example:custom"/>`,
+		"xl/unused.xml":       `<?xml version="1.0" encoding="UTF-8"?><unused/>`,
+	}
+}
+
+// relationshipTargets returns the targets of the relationships of the
+// part called name in parts, by type, as part names.
+func relationshipTargets(c *qt.C, parts map[string]string, name string) map[string]map[string]string {
+	rels := new(xlsxWorksheetRels)
+	c.Assert(xml.Unmarshal([]byte(parts[relsPartName(name)]), rels), qt.IsNil)
+	targets := make(map[string]map[string]string)
+	for _, rel := range rels.Relationships {
+		relType := path.Base(string(rel.Type))
+		if targets[relType] == nil {
+			targets[relType] = make(map[string]string)
+		}
+		targets[relType][rel.Id] = relationshipTargetPartName(path.Dir(name), rel.Target)
+	}
+	return targets
+}
+
+// checkPackage checks that the relationships in parts refer to parts
+// that are there, and that each part has a content type.
+func checkPackage(c *qt.C, parts map[string]string) {
+	types := new(xlsxTypes)
+	c.Assert(xml.Unmarshal([]byte(parts["[Content_Types].xml"]), types), qt.IsNil)
+	contentTypes := make(map[string]bool)
+	for _, o := range types.Overrides {
+		contentTypes[o.PartName] = true
+	}
+	for _, d := range types.Defaults {
+		contentTypes["."+d.Extension] = true
+	}
+	for name, part := range parts {
+		if name == "[Content_Types].xml" {
+			continue
+		}
+		c.Assert(contentTypes["/"+name] || contentTypes[path.Ext(name)], qt.Equals, true, qt.Commentf("%s has no content type", name))
+		if !strings.HasSuffix(name, ".rels") {
+			continue
+		}
+		rels := new(xlsxWorksheetRels)
+		c.Assert(xml.Unmarshal([]byte(part), rels), qt.IsNil)
+		dir := strings.TrimSuffix(path.Dir(path.Dir(name)), ".")
+		for _, rel := range rels.Relationships {
+			if rel.TargetMode == RelationshipTargetModeExternal {
+				continue
+			}
+			target := relationshipTargetPartName(dir, rel.Target)
+			_, ok := parts[target]
+			c.Assert(ok, qt.Equals, true, qt.Commentf("%s refers to missing %s", name, target))
+		}
+	}
+}
+
+// assertInOrder checks that each of the strings is in s, after the
+// one before it.
+func assertInOrder(c *qt.C, s string, strs ...string) {
+	offset := 0
+	for _, str := range strs {
+		i := strings.Index(s[offset:], str)
+		c.Assert(i >= 0, qt.Equals, true, qt.Commentf("%q not found in order in %s", str, s))
+		offset += i + len(str)
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	c := qt.New(t)
+
+	openFile := func(c *qt.C) *File {
+		file, err := OpenBinary(zipParts(c, passThroughTestParts(c)))
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.PassThrough, qt.Equals, true)
+		file.Sheet["Data"].Cell(0, 0).SetString("Filled in")
+		return file
+	}
+
+	c.Run("KeepsParts", func(c *qt.C) {
+		original := passThroughTestParts(c)
+		parts, err := openFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+		checkPackage(c, parts)
+
+		for _, name := range []string{
+			"xl/vbaProject.bin",
+			"customXml/item1.xml",
+			"xl/pivotCache/pivotCacheDefinition1.xml",
+			"xl/pivotCache/_rels/pivotCacheDefinition1.xml.rels",
+			"xl/pivotCache/pivotCacheRecords1.xml",
+			"xl/printerSettings/printerSettings1.bin",
+			"xl/chartsheets/sheet1.xml",
+			"xl/chartsheets/_rels/sheet1.xml.rels",
+			"xl/drawings/drawing2.xml",
+			"xl/charts/chart1.xml",
+			"xl/charts/chart2.xml",
+			"docProps/core.xml",
+		} {
+			c.Assert(parts[name], qt.Equals, original[name], qt.Commentf(name))
+		}
+		// Parts nothing refers to any more are dropped, as is the
+		// calculation chain, which would be out of date.
+		for _, name := range []string{"xl/unused.xml", "xl/calcChain.xml"} {
+			_, ok := parts[name]
+			c.Assert(ok, qt.Equals, false, qt.Commentf(name))
+		}
+
+		types := parts["[Content_Types].xml"]
+		c.Assert(types, qt.Contains, `<Override PartName="/xl/workbook.xml" ContentType="application/vnd.ms-excel.sheet.macroEnabled.main+xml">`)
+		c.Assert(types, qt.Contains, `<Override PartName="/xl/printerSettings/printerSettings1.bin" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.printerSettings">`)
+		c.Assert(types, qt.Contains, `<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject">`)
+		c.Assert(types, qt.Not(qt.Contains), "calcChain")
+	})
+
+	c.Run("MergesWorkbook", func(c *qt.C) {
+		parts, err := openFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+		workbook := parts["xl/workbook.xml"]
+		c.Assert(workbook, qt.Contains, `mc:Ignorable="x15"`)
+		c.Assert(workbook, qt.Contains, `xmlns:x15="http://schemas.microsoft.com/office/spreadsheetml/2010/11/main"`)
+		assertInOrder(c, workbook,
+			`<fileVersion appName="xl" lastEdited="7"/>`,
+			`<workbookPr codeName="ThisWorkbook"/>`,
+			`<sheets><sheet name="Data" sheetId="1" r:id="rId1"`,
+			`<sheet name="Chart" sheetId="2" r:id="rId5"`,
+			`</sheets><definedNames><definedName name="Total">Data!$A$1</definedName></definedNames>`,
+			`<calcPr calcId="162913"/>`,
+			`<pivotCaches><pivotCache cacheId="5" r:id="rId6"/></pivotCaches>`,
+			`<extLst>`)
+
+		targets := relationshipTargets(c, parts, "xl/workbook.xml")
+		c.Assert(targets["chartsheet"]["rId5"], qt.Equals, "xl/chartsheets/sheet1.xml")
+		c.Assert(targets["pivotCacheDefinition"]["rId6"], qt.Equals, "xl/pivotCache/pivotCacheDefinition1.xml")
+		c.Assert(targets["vbaProject"]["rId7"], qt.Equals, "xl/vbaProject.bin")
+		c.Assert(targets["customXml"]["rId8"], qt.Equals, "customXml/item1.xml")
+		c.Assert(targets["calcChain"], qt.HasLen, 0)
+	})
+
+	c.Run("MergesWorksheet", func(c *qt.C) {
+		parts, err := openFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+		worksheet := parts["xl/worksheets/sheet1.xml"]
+		c.Assert(worksheet, qt.Contains, `mc:Ignorable="x14ac"`)
+		c.Assert(worksheet, qt.Contains, `xmlns:x14ac="http://schemas.microsoft.com/office/spreadsheetml/2009/9/ac"`)
+		c.Assert(worksheet, qt.Not(qt.Contains), `<printOptions`)
+		c.Assert(worksheet, qt.Not(qt.Contains), `<headerFooter`)
+		assertInOrder(c, worksheet,
+			`<sheetPr codeName="Sheet1"><tabColor rgb="FFFF0000"/></sheetPr>`,
+			`<sheetData><row r="1"><c r="A1" t="s"><v>0</v></c></row></sheetData>`,
+			`<pageMargins left="0.5" right="0.5" top="1" bottom="1" header="0.5" footer="0.5"/>`,
+			`<pageSetup paperSize="9" orientation="landscape" r:id="rId2"/>`,
+			`<drawing r:id="rId1">`,
+			`<extLst><ext uri="{05C60535-1F16-4fd2-B633-F4F36F0B64E0}">`)
+
+		targets := relationshipTargets(c, parts, "xl/worksheets/sheet1.xml")
+		c.Assert(targets["drawing"]["rId1"], qt.Equals, "xl/drawings/drawing1.xml")
+		c.Assert(targets["printerSettings"]["rId2"], qt.Equals, "xl/printerSettings/printerSettings1.bin")
+	})
+
+	c.Run("MergesDrawing", func(c *qt.C) {
+		parts, err := openFile(c).MarshallParts()
+		c.Assert(err, qt.IsNil)
+		drawing := parts["xl/drawings/drawing1.xml"]
+		// The shape and the chart come first, and the picture,
+		// which is written from the model, gets a new id.
+		assertInOrder(c, drawing,
+			`<xdr:cNvPr id="7" name="Rectangle 6"/>`,
+			`<xdr:cNvPr id="3" name="Chart 2"/>`,
+			`r:id="rId1"/>`,
+			`<xdr:cNvPr id="8" name="Logo"`,
+			`<a:blip r:embed="rId2"/>`)
+
+		targets := relationshipTargets(c, parts, "xl/drawings/drawing1.xml")
+		c.Assert(targets["chart"]["rId1"], qt.Equals, "xl/charts/chart1.xml")
+		c.Assert(targets["image"]["rId2"], qt.Equals, "xl/media/image1.png")
+	})
+
+	c.Run("AvoidsKeptPartNames", func(c *qt.C) {
+		file := openFile(c)
+		sheet := file.Sheet["Data"]
+		chart := &Chart{Type: ChartTypeColumn, Series: []ChartSeries{{Values: "Data!A1:A1"}}}
+		_, err := file.AddChartSheet("More", chart)
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(1, 0).SetString("Note")
+		sheet.Cell(1, 0).SetComment("Editor", "A comment")
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		checkPackage(c, parts)
+
+		targets := relationshipTargets(c, parts, "xl/workbook.xml")
+		c.Assert(targets["chartsheet"]["rId2"], qt.Equals, "xl/chartsheets/sheet2.xml")
+		targets = relationshipTargets(c, parts, "xl/chartsheets/sheet2.xml")
+		c.Assert(targets["drawing"]["rId1"], qt.Equals, "xl/drawings/drawing3.xml")
+		targets = relationshipTargets(c, parts, "xl/drawings/drawing3.xml")
+		c.Assert(targets["chart"]["rId1"], qt.Equals, "xl/charts/chart3.xml")
+		c.Assert(parts["xl/chartsheets/sheet1.xml"], qt.Equals, passThroughTestParts(c)["xl/chartsheets/sheet1.xml"])
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		file := openFile(c)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+
+		file, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheet["Data"].Cell(0, 0).Value, qt.Equals, "Filled in")
+		c.Assert(file.Sheet["Data"].Pictures, qt.HasLen, 1)
+		again, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		for _, name := range []string{
+			"xl/workbook.xml",
+			"xl/_rels/workbook.xml.rels",
+			"xl/worksheets/_rels/sheet1.xml.rels",
+			"xl/drawings/drawing1.xml",
+			"[Content_Types].xml",
+		} {
+			c.Assert(again[name], qt.Equals, parts[name], qt.Commentf(name))
+		}
+		c.Assert(again["xl/worksheets/sheet1.xml"], qt.Contains, `<sheetPr codeName="Sheet1"><tabColor rgb="FFFF0000"/></sheetPr>`)
+		c.Assert(again["xl/worksheets/sheet1.xml"], qt.Contains, `<pageSetup paperSize="9" orientation="landscape" r:id="rId2"/>`)
+	})
+
+	c.Run("TestDocs", func(c *qt.C) {
+		for _, name := range []string{
+			"testdocs/testfile.xlsx",
+			"testdocs/file_with_hyperlinks.xlsx",
+			"testdocs/original.xlsx",
+		} {
+			file, err := OpenFile(name)
+			c.Assert(err, qt.IsNil)
+			parts, err := file.MarshallParts()
+			c.Assert(err, qt.IsNil, qt.Commentf(name))
+			checkPackage(c, parts)
+		}
+	})
+
+	c.Run("Off", func(c *qt.C) {
+		file := openFile(c)
+		file.PassThrough = false
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		checkPackage(c, parts)
+		for _, name := range []string{"xl/vbaProject.bin", "xl/chartsheets/sheet1.xml", "customXml/item1.xml"} {
+			_, ok := parts[name]
+			c.Assert(ok, qt.Equals, false, qt.Commentf(name))
+		}
+		c.Assert(parts["docProps/core.xml"], qt.Equals, TEMPLATE_DOCPROPS_CORE)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Not(qt.Contains), "tabColor")
+		c.Assert(parts["xl/drawings/drawing1.xml"], qt.Not(qt.Contains), "Rectangle")
+	})
+}
+
+func TestRelativePartTarget(t *testing.T) {
+	c := qt.New(t)
+	for _, test := range []struct {
+		dir, partName, target string
+	}{
+		{"xl", "xl/worksheets/sheet1.xml", "worksheets/sheet1.xml"},
+		{"xl/worksheets", "xl/comments1.xml", "../comments1.xml"},
+		{"xl/worksheets", "xl/drawings/drawing1.xml", "../drawings/drawing1.xml"},
+		{"xl", "customXml/item1.xml", "../customXml/item1.xml"},
+		{"", "docProps/core.xml", "docProps/core.xml"},
+	} {
+		target := relativePartTarget(test.dir, test.partName)
+		c.Assert(target, qt.Equals, test.target)
+		c.Assert(relationshipTargetPartName(test.dir, target), qt.Equals, test.partName)
+	}
+}
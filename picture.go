@@ -0,0 +1,487 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register the GIF format with image.DecodeConfig
+	_ "image/jpeg" // Register the JPEG format with image.DecodeConfig
+	_ "image/png"  // Register the PNG format with image.DecodeConfig
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Drawings measure positions and sizes in English Metric Units, of
+// which there are 9525 to a pixel.
+const emusPerPixel = 9525
+
+// PictureAnchor determines how a picture moves and resizes when the
+// cells beneath it do.
+type PictureAnchor int
+
+const (
+	// PictureAnchorTwoCell ties both the top left and the bottom
+	// right corner of the picture to the cells beneath them, so the
+	// picture moves and resizes with the cells.
+	PictureAnchorTwoCell PictureAnchor = iota
+	// PictureAnchorOneCell ties the top left corner of the picture
+	// to the cell beneath it, so the picture moves with the cell but
+	// keeps its size.
+	PictureAnchorOneCell
+)
+
+// The content types of the image formats that pictures are likely to
+// be in, keyed by file extension.
+var pictureContentTypes = map[string]string{
+	"bmp":  "image/bmp",
+	"emf":  "image/x-emf",
+	"gif":  "image/gif",
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"wmf":  "image/x-wmf",
+}
+
+// PictureOptions describe how a picture is placed on a sheet.
+type PictureOptions struct {
+	Anchor PictureAnchor
+	// OffsetX and OffsetY move the picture right and down from the
+	// top left corner of its cell, in pixels.
+	OffsetX int
+	OffsetY int
+	// Width and Height are the size of the picture in pixels.  If
+	// both are zero the picture is shown at its own size, if only
+	// one is zero it is scaled to keep the picture's proportions.
+	Width  int
+	Height int
+	// Name and Description are shown in Excel's selection pane and
+	// as the alternative text of the picture.
+	Name        string
+	Description string
+}
+
+// Picture is an image placed on a Sheet.
+type Picture struct {
+	// Cell is the cell, for example "B2", that the top left corner
+	// of the picture is in.
+	Cell string
+	Data []byte
+	// Format is the file extension of the image format, for
+	// example "png" or "jpeg".
+	Format  string
+	Options PictureOptions
+}
+
+// AddPicture places the PNG, JPEG or GIF image in imageData on the
+// sheet, with its top left corner in anchorCell, for example "B2".
+// opts may be nil, in which case the picture is shown at its own size
+// with a two cell anchor.
+func (s *Sheet) AddPicture(anchorCell string, imageData []byte, opts *PictureOptions) (*Picture, error) {
+	s.load()
+	if _, _, err := GetCoordsFromCellIDString(anchorCell); err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("AddPicture: %s", err)
+	}
+	picture := &Picture{Cell: anchorCell, Data: imageData, Format: format}
+	if opts != nil {
+		picture.Options = *opts
+	}
+	options := &picture.Options
+	switch {
+	case config.Width == 0 || config.Height == 0:
+	case options.Width == 0 && options.Height == 0:
+		options.Width = config.Width
+		options.Height = config.Height
+	case options.Width == 0:
+		options.Width = config.Width * options.Height / config.Height
+	case options.Height == 0:
+		options.Height = config.Height * options.Width / config.Width
+	}
+	if options.Width <= 0 || options.Height <= 0 {
+		return nil, fmt.Errorf("AddPicture: invalid picture size %dx%d", options.Width, options.Height)
+	}
+	s.Pictures = append(s.Pictures, picture)
+	return picture, nil
+}
+
+// colWidthPixels returns the width of the column with the given
+// (zero based) index in pixels, as Excel would show it with its
+// default font.
+func (s *Sheet) colWidthPixels(col int) int {
+	width := s.SheetFormat.DefaultColWidth
+	if width == 0 {
+		width = 9.140625
+	}
+	if s.Cols != nil {
+		if c := s.Cols.FindColByIndex(col + 1); c != nil {
+			if c.Hidden {
+				return 0
+			}
+			if c.Width > 0 {
+				width = c.Width
+			}
+		}
+	}
+	// Column widths are in characters of the default font, each
+	// of which is 7 pixels wide, including the cell's padding.
+	return int((256*width + 18) / 256 * 7)
+}
+
+// rowHeightPixels returns the height of the row with the given (zero
+// based) index in pixels.
+func (s *Sheet) rowHeightPixels(row int) int {
+	height := s.SheetFormat.DefaultRowHeight
+	if height == 0 {
+		// The default that makeXLSXSheet writes
+		height = 12.85
+	}
+	if row < len(s.Rows) && s.Rows[row] != nil {
+		if s.Rows[row].Hidden {
+			return 0
+		}
+		if s.Rows[row].Height > 0 {
+			height = s.Rows[row].Height
+		}
+	}
+	// Row heights are in points, 72 of which make 96 pixels.
+	return int(height*96/72 + 0.5)
+}
+
+// moveByPixels returns the column or row, and the offset into it,
+// that lies offset pixels on from the start of index.
+func moveByPixels(index, offset, maxIndex int, size func(int) int) (int, int) {
+	for index < maxIndex {
+		s := size(index)
+		if offset < s {
+			break
+		}
+		offset -= s
+		index++
+	}
+	return index, offset
+}
+
+// drawingMarker returns the from or to element of an anchor in a
+// drawing for the position x, y pixels from the top left corner of
+// the cell at col, row.
+func (s *Sheet) drawingMarker(col, row, x, y int) string {
+	col, x = moveByPixels(col, x, formulaMaxCol, s.colWidthPixels)
+	row, y = moveByPixels(row, y, formulaMaxRow, s.rowHeightPixels)
+	return fmt.Sprintf(`<xdr:col>%d</xdr:col><xdr:colOff>%d</xdr:colOff><xdr:row>%d</xdr:row><xdr:rowOff>%d</xdr:rowOff>`,
+		col, x*emusPerPixel, row, y*emusPerPixel)
+}
+
+// escapeXMLAttr escapes s for use as the value of an attribute.
+func escapeXMLAttr(s string) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(s))
+	return escaped.String()
+}
+
+// drawingPart collects the anchors and relationships of the drawing
+// part of a sheet as it is built.
+type drawingPart struct {
+	anchors string
+	rels    []xlsxWorksheetRelation
+	shapes  int
+	// root is the start tag of the root element of the drawing that
+	// was read, whose namespaces the anchors kept from it may use.
+	root string
+}
+
+// addRelation adds a relationship to the drawing, and returns its id.
+func (d *drawingPart) addRelation(relType RelationshipType, target string, targetMode RelationshipTargetMode) string {
+	for _, rel := range d.rels {
+		if rel.Type == relType && rel.Target == target && rel.TargetMode == targetMode {
+			return rel.Id
+		}
+	}
+	id := "rId" + strconv.Itoa(len(d.rels)+1)
+	d.rels = append(d.rels, xlsxWorksheetRelation{Id: id, Type: relType, Target: target, TargetMode: targetMode})
+	return id
+}
+
+// addAnchor adds an anchor holding content, for example a picture, at
+// the given position and size in pixels to the drawing.
+func (d *drawingPart) addAnchor(s *Sheet, cell string, anchor PictureAnchor, x, y, width, height int, content string) {
+	col, row, _ := GetCoordsFromCellIDString(cell)
+	from := s.drawingMarker(col, row, x, y)
+	if anchor == PictureAnchorOneCell {
+		d.anchors += fmt.Sprintf(`<xdr:oneCellAnchor><xdr:from>%s</xdr:from><xdr:ext cx="%d" cy="%d"/>%s<xdr:clientData/></xdr:oneCellAnchor>`,
+			from, width*emusPerPixel, height*emusPerPixel, content)
+		return
+	}
+	to := s.drawingMarker(col, row, x+width, y+height)
+	d.anchors += fmt.Sprintf(`<xdr:twoCellAnchor><xdr:from>%s</xdr:from><xdr:to>%s</xdr:to>%s<xdr:clientData/></xdr:twoCellAnchor>`,
+		from, to, content)
+}
+
+// nextShapeID returns a new id for a shape in the drawing.
+func (d *drawingPart) nextShapeID() int {
+	d.shapes++
+	return d.shapes + 1
+}
+
+func (d *drawingPart) marshal() string {
+	drawing := xml.Header + `<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		d.anchors + `</xdr:wsDr>`
+	if d.root == "" {
+		return drawing
+	}
+	doc, err := readRawXMLDocument(drawing)
+	if err != nil {
+		return drawing
+	}
+	return mergeRootAttrs(doc, d.root)
+}
+
+func (d *drawingPart) makeXLSXRels() *xlsxWorksheetRels {
+	return &xlsxWorksheetRels{XMLName: xml.Name{Local: "Relationships"}, Relationships: d.rels}
+}
+
+// mediaParts names the images of a workbook as they are written, so
+// that an image used more than once is only stored once.
+type mediaParts struct {
+	partNames *partNames
+	names     map[string]string
+	parts     map[string]string
+}
+
+func newMediaParts(partNames *partNames) *mediaParts {
+	return &mediaParts{partNames: partNames, names: make(map[string]string), parts: make(map[string]string)}
+}
+
+// add returns the name of the part holding the image data, adding it
+// if it isn't there yet.
+func (m *mediaParts) add(data []byte, format string) string {
+	key := format + ":" + string(data)
+	if name, ok := m.names[key]; ok {
+		return name
+	}
+	name := m.partNames.pick("xl/media/image%d."+format, len(m.names)+1)
+	m.names[key] = name
+	m.parts[name] = string(data)
+	return name
+}
+
+// write adds the images to parts, and their content types to types.
+func (m *mediaParts) write(parts map[string]string, types *xlsxTypes) {
+	extensions := make(map[string]bool)
+	for name, data := range m.parts {
+		parts[name] = data
+		extensions[strings.TrimPrefix(path.Ext(name), ".")] = true
+	}
+	for _, d := range types.Defaults {
+		delete(extensions, d.Extension)
+	}
+	for extension := range extensions {
+		contentType, ok := pictureContentTypes[strings.ToLower(extension)]
+		if !ok {
+			contentType = "application/octet-stream"
+		}
+		types.Defaults = append(types.Defaults, xlsxDefault{Extension: extension, ContentType: contentType})
+	}
+}
+
+// makeDrawingPart returns the drawing part for the pictures and charts
+// on the sheet, or nil if it has none, adding the images to media and
+// the charts to charts.
+func (s *Sheet) makeDrawingPart(media *mediaParts, charts *chartParts) *drawingPart {
+	var original *originalDrawing
+	if o := s.originalParts(); o != nil {
+		original = o.drawing
+	}
+	if len(s.Pictures) == 0 && len(s.Charts) == 0 && original == nil {
+		return nil
+	}
+	drawing := &drawingPart{}
+	if original != nil {
+		original.addTo(drawing)
+	}
+	for _, picture := range s.Pictures {
+		options := picture.Options
+		target := "../" + strings.TrimPrefix(media.add(picture.Data, picture.Format), "xl/")
+		relId := drawing.addRelation(RelationshipTypeImage, target, "")
+		id := drawing.nextShapeID()
+		name := options.Name
+		if name == "" {
+			name = fmt.Sprintf("Picture %d", id-1)
+		}
+		pic := fmt.Sprintf(`<xdr:pic><xdr:nvPicPr><xdr:cNvPr id="%d" name="%s" descr="%s"/><xdr:cNvPicPr><a:picLocks noChangeAspect="1"/></xdr:cNvPicPr></xdr:nvPicPr>`+
+			`<xdr:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></xdr:blipFill>`+
+			`<xdr:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></xdr:spPr></xdr:pic>`,
+			id, escapeXMLAttr(name), escapeXMLAttr(options.Description), relId,
+			options.Width*emusPerPixel, options.Height*emusPerPixel)
+		drawing.addAnchor(s, picture.Cell, options.Anchor, options.OffsetX, options.OffsetY, options.Width, options.Height, pic)
+	}
+	for _, chart := range s.Charts {
+		options := chart.Options
+		target := "../" + strings.TrimPrefix(charts.add(chart), "xl/")
+		relId := drawing.addRelation(RelationshipTypeChart, target, "")
+		id := drawing.nextShapeID()
+		name := options.Name
+		if name == "" {
+			name = fmt.Sprintf("Chart %d", id-1)
+		}
+		frame := chartGraphicFrame(id, name, options.Description, relId)
+		drawing.addAnchor(s, chart.Cell, options.Anchor, options.OffsetX, options.OffsetY, options.Width, options.Height, frame)
+	}
+	return drawing
+}
+
+// setDrawingRelation replaces the sheet's relation to a drawing part
+// with one to the drawing called drawingPartName, or just removes it
+// if the sheet has no drawing and the name is empty.
+func (s *Sheet) setDrawingRelation(drawingPartName string) {
+	s.removeRelations(RelationshipTypeDrawing)
+	if drawingPartName != "" {
+		s.addRelation(RelationshipTypeDrawing, relativePartTarget("xl/worksheets", drawingPartName), "")
+	}
+}
+
+// makeDrawing points the worksheet at its drawing, if it has one.
+func (s *Sheet) makeDrawing(worksheet *xlsxWorksheet, relations *xlsxWorksheetRels) {
+	if relations == nil {
+		return
+	}
+	for _, rel := range relations.Relationships {
+		if rel.Type == RelationshipTypeDrawing {
+			worksheet.Drawing = &xlsxDrawing{RelationshipId: rel.Id}
+			return
+		}
+	}
+}
+
+// readZipPart returns the contents of the part called name.
+func readZipPart(parts map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := parts[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in input xlsx", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// readPicturesFromZipFile reads the pictures from the drawing that the
+// worksheet relations refer to, if there is one.  Other kinds of
+// drawing, such as shapes, are only kept to be written back as they
+// were.
+func readPicturesFromZipFile(sheet *Sheet, worksheetRels *xlsxWorksheetRels, parts map[string]*zip.File) error {
+	if worksheetRels == nil {
+		return nil
+	}
+	for _, rel := range worksheetRels.Relationships {
+		if rel.Type != RelationshipTypeDrawing {
+			continue
+		}
+		drawingPartName := relationshipTargetPartName("xl/worksheets", rel.Target)
+		data, err := readZipPart(parts, drawingPartName)
+		if err != nil {
+			return &ReadError{Part: drawingPartName, Err: err}
+		}
+		drawing := new(xlsxWsDr)
+		err = xml.Unmarshal(data, drawing)
+		if err != nil {
+			return &ReadError{Part: drawingPartName, Err: err}
+		}
+		dir, file := path.Split(drawingPartName)
+		var drawingRels *xlsxWorksheetRels
+		if relsFile, ok := parts[dir+"_rels/"+file+".rels"]; ok {
+			drawingRels, err = readWorksheetRelsFromZipFile(relsFile)
+			if err != nil {
+				return &ReadError{Part: relsFile.Name, Err: err}
+			}
+		}
+		pictures := make([]*Picture, len(drawing.Anchors))
+		if drawingRels != nil {
+			for i, anchor := range drawing.Anchors {
+				pictures[i], err = anchor.picture(sheet, drawingRels, path.Dir(drawingPartName), parts)
+				if err != nil {
+					return wrapReadError(err, drawingPartName, "")
+				}
+				if pictures[i] != nil {
+					sheet.Pictures = append(sheet.Pictures, pictures[i])
+				}
+			}
+		}
+		if sheet.original != nil {
+			doc, err := readRawXMLDocument(string(data))
+			if err != nil {
+				return &ReadError{Part: drawingPartName, Err: err}
+			}
+			sheet.original.drawing = readOriginalDrawing(doc, pictures, drawingRels, path.Dir(drawingPartName))
+		}
+	}
+	return nil
+}
+
+// picture returns the picture held by the anchor, or nil if it holds
+// something else or its image isn't embedded in the file.
+func (a *xlsxDrawingAnchor) picture(sheet *Sheet, drawingRels *xlsxWorksheetRels, dir string, parts map[string]*zip.File) (*Picture, error) {
+	if a.Pic == nil || a.From == nil {
+		return nil, nil
+	}
+	var mediaPartName string
+	for _, rel := range drawingRels.Relationships {
+		if rel.Id == a.Pic.BlipFill.Blip.Embed && rel.TargetMode != RelationshipTargetModeExternal {
+			mediaPartName = relationshipTargetPartName(dir, rel.Target)
+		}
+	}
+	if _, ok := parts[mediaPartName]; !ok {
+		return nil, nil
+	}
+	data, err := readZipPart(parts, mediaPartName)
+	if err != nil {
+		return nil, err
+	}
+	picture := &Picture{
+		Cell:   GetCellIDStringFromCoords(a.From.Col, a.From.Row),
+		Data:   data,
+		Format: strings.ToLower(strings.TrimPrefix(path.Ext(mediaPartName), ".")),
+		Options: PictureOptions{
+			OffsetX:     a.From.ColOff / emusPerPixel,
+			OffsetY:     a.From.RowOff / emusPerPixel,
+			Width:       a.Pic.SpPr.Xfrm.Ext.Cx / emusPerPixel,
+			Height:      a.Pic.SpPr.Xfrm.Ext.Cy / emusPerPixel,
+			Name:        a.Pic.NvPicPr.CNvPr.Name,
+			Description: a.Pic.NvPicPr.CNvPr.Descr,
+		},
+	}
+	switch a.XMLName.Local {
+	case "oneCellAnchor":
+		picture.Options.Anchor = PictureAnchorOneCell
+		if a.Ext != nil && picture.Options.Width == 0 && picture.Options.Height == 0 {
+			picture.Options.Width = a.Ext.Cx / emusPerPixel
+			picture.Options.Height = a.Ext.Cy / emusPerPixel
+		}
+	case "twoCellAnchor":
+		picture.Options.Anchor = PictureAnchorTwoCell
+		if a.To != nil && picture.Options.Width == 0 && picture.Options.Height == 0 {
+			picture.Options.Width = a.To.ColOff/emusPerPixel - picture.Options.OffsetX
+			for col := a.From.Col; col < a.To.Col; col++ {
+				picture.Options.Width += sheet.colWidthPixels(col)
+			}
+			picture.Options.Height = a.To.RowOff/emusPerPixel - picture.Options.OffsetY
+			for row := a.From.Row; row < a.To.Row; row++ {
+				picture.Options.Height += sheet.rowHeightPixels(row)
+			}
+		}
+	default:
+		// Absolute anchors aren't tied to a cell at all.
+		return nil, nil
+	}
+	return picture, nil
+}
@@ -0,0 +1,182 @@
+package xlsx
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// makeTestImage returns a width by height image encoded with encode.
+func makeTestImage(c *qt.C, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, x*height/width, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	c.Assert(encode(&buf, img), qt.IsNil)
+	return buf.Bytes()
+}
+
+func encodePNG(buf *bytes.Buffer, img image.Image) error {
+	return png.Encode(buf, img)
+}
+
+func encodeJPEG(buf *bytes.Buffer, img image.Image) error {
+	return jpeg.Encode(buf, img, nil)
+}
+
+func TestPicture(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("AddPicture", func(c *qt.C) {
+		sheet, err := NewFile().AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		logo := makeTestImage(c, 100, 50, encodePNG)
+
+		picture, err := sheet.AddPicture("B2", logo, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(picture.Format, qt.Equals, "png")
+		c.Assert(picture.Options, qt.Equals, PictureOptions{Width: 100, Height: 50})
+
+		picture, err = sheet.AddPicture("B2", logo, &PictureOptions{Height: 25, Anchor: PictureAnchorOneCell})
+		c.Assert(err, qt.IsNil)
+		c.Assert(picture.Options, qt.Equals, PictureOptions{Width: 50, Height: 25, Anchor: PictureAnchorOneCell})
+
+		picture, err = sheet.AddPicture("B2", makeTestImage(c, 10, 10, encodeJPEG), &PictureOptions{Width: 30})
+		c.Assert(err, qt.IsNil)
+		c.Assert(picture.Format, qt.Equals, "jpeg")
+		c.Assert(picture.Options, qt.Equals, PictureOptions{Width: 30, Height: 30})
+		c.Assert(sheet.Pictures, qt.HasLen, 3)
+
+		_, err = sheet.AddPicture("B2", []byte("not an image"), nil)
+		c.Assert(err, qt.ErrorMatches, "AddPicture: image: unknown format")
+		_, err = sheet.AddPicture("B", logo, nil)
+		c.Assert(err, qt.Not(qt.IsNil))
+		c.Assert(sheet.Pictures, qt.HasLen, 3)
+	})
+
+	makePictureFile := func(c *qt.C) (*File, []byte) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		logo := makeTestImage(c, 100, 50, encodePNG)
+		_, err = sheet.AddPicture("B2", logo, &PictureOptions{Name: "Logo", Description: `Our "logo"`})
+		c.Assert(err, qt.IsNil)
+		_, err = sheet.AddPicture("D10", logo, &PictureOptions{Anchor: PictureAnchorOneCell, OffsetX: 5, OffsetY: 6, Width: 20})
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetComment("Reviewer", "Nice logo")
+		sheet, err = file.AddSheet("Sheet2")
+		c.Assert(err, qt.IsNil)
+		sheet.SetColWidth(1, 1, 10)
+		_, err = sheet.AddPicture("A1", logo, &PictureOptions{OffsetX: 100})
+		c.Assert(err, qt.IsNil)
+		return file, logo
+	}
+
+	c.Run("Write", func(c *qt.C) {
+		file, logo := makePictureFile(c)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+
+		// The logo is used three times, but only stored once.
+		c.Assert(parts["xl/media/image1.png"], qt.Equals, string(logo))
+		_, ok := parts["xl/media/image2.png"]
+		c.Assert(ok, qt.Equals, false)
+
+		drawing := parts["xl/drawings/drawing1.xml"]
+		c.Assert(drawing, qt.Contains, `<xdr:twoCellAnchor>`+
+			`<xdr:from><xdr:col>1</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>`+
+			`<xdr:to><xdr:col>2</xdr:col><xdr:colOff>342900</xdr:colOff><xdr:row>3</xdr:row><xdr:rowOff>152400</xdr:rowOff></xdr:to>`+
+			`<xdr:pic><xdr:nvPicPr><xdr:cNvPr id="2" name="Logo" descr="Our &#34;logo&#34;"/>`)
+		c.Assert(drawing, qt.Contains, `<a:blip r:embed="rId1"/>`)
+		c.Assert(drawing, qt.Contains, `<a:ext cx="952500" cy="476250"/>`)
+		c.Assert(drawing, qt.Contains, `<xdr:oneCellAnchor>`+
+			`<xdr:from><xdr:col>3</xdr:col><xdr:colOff>47625</xdr:colOff><xdr:row>9</xdr:row><xdr:rowOff>57150</xdr:rowOff></xdr:from>`+
+			`<xdr:ext cx="190500" cy="95250"/>`+
+			`<xdr:pic><xdr:nvPicPr><xdr:cNvPr id="3" name="Picture 2" descr=""/>`)
+		c.Assert(parts["xl/drawings/_rels/drawing1.xml.rels"], qt.Equals, `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="../media/image1.png"></Relationship>`+
+			`</Relationships>`)
+
+		// Column A of the second sheet is only 70 pixels wide, so the offset
+		// takes the picture past it.
+		c.Assert(parts["xl/drawings/drawing2.xml"], qt.Contains,
+			`<xdr:from><xdr:col>1</xdr:col><xdr:colOff>285750</xdr:colOff>`)
+
+		c.Assert(parts["xl/worksheets/_rels/sheet1.xml.rels"], qt.Contains,
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing" Target="../drawings/drawing1.xml"></Relationship>`)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<drawing r:id="rId3"></drawing><legacyDrawing r:id="rId2"></legacyDrawing>`)
+		c.Assert(parts["xl/worksheets/sheet2.xml"], qt.Contains, `<drawing r:id="rId1"></drawing></worksheet>`)
+
+		contentTypes := parts["[Content_Types].xml"]
+		c.Assert(contentTypes, qt.Contains, `<Override PartName="/xl/drawings/drawing1.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"></Override>`)
+		c.Assert(contentTypes, qt.Contains, `<Default Extension="png" ContentType="image/png"></Default>`)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		file, logo := makePictureFile(c)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+
+		file, err = OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		pictures := file.Sheet["Sheet1"].Pictures
+		c.Assert(pictures, qt.HasLen, 2)
+		c.Assert(pictures[0].Cell, qt.Equals, "B2")
+		c.Assert(pictures[0].Data, qt.DeepEquals, logo)
+		c.Assert(pictures[0].Format, qt.Equals, "png")
+		c.Assert(pictures[0].Options, qt.Equals, PictureOptions{Width: 100, Height: 50, Name: "Logo", Description: `Our "logo"`})
+		c.Assert(pictures[1].Cell, qt.Equals, "D10")
+		c.Assert(pictures[1].Options, qt.Equals, PictureOptions{
+			Anchor: PictureAnchorOneCell, OffsetX: 5, OffsetY: 6, Width: 20, Height: 10, Name: "Picture 2"})
+		pictures = file.Sheet["Sheet2"].Pictures
+		c.Assert(pictures, qt.HasLen, 1)
+		c.Assert(pictures[0].Cell, qt.Equals, "B1")
+		c.Assert(pictures[0].Options.OffsetX, qt.Equals, 30)
+
+		// Saving again keeps the pictures.
+		parts2, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts2["xl/media/image1.png"], qt.Equals, string(logo))
+		c.Assert(parts2["xl/drawings/drawing1.xml"], qt.Equals, parts["xl/drawings/drawing1.xml"])
+	})
+
+	c.Run("ReadWithoutShapeSize", func(c *qt.C) {
+		logo := makeTestImage(c, 10, 10, encodePNG)
+		file, err := OpenBinary(zipParts(c, map[string]string{
+			"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+			"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+			"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheetFormatPr defaultRowHeight="15"/><sheetData/><drawing r:id="rId1"/></worksheet>`,
+			"xl/worksheets/_rels/sheet1.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing" Target="../drawings/drawing1.xml"/></Relationships>`,
+			"xl/drawings/drawing1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">` +
+				`<xdr:twoCellAnchor editAs="oneCell"><xdr:from><xdr:col>0</xdr:col><xdr:colOff>95250</xdr:colOff><xdr:row>0</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+				`<xdr:to><xdr:col>2</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>95250</xdr:rowOff></xdr:to>` +
+				`<xdr:pic><xdr:nvPicPr><xdr:cNvPr id="2" name="Picture 1"/><xdr:cNvPicPr/></xdr:nvPicPr>` +
+				`<xdr:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:embed="rId1"/></xdr:blipFill><xdr:spPr/></xdr:pic><xdr:clientData/></xdr:twoCellAnchor>` +
+				`<xdr:twoCellAnchor><xdr:from><xdr:col>0</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>0</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+				`<xdr:to><xdr:col>1</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>1</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:to>` +
+				`<xdr:sp><xdr:nvSpPr><xdr:cNvPr id="3" name="Rectangle 2"/><xdr:cNvSpPr/></xdr:nvSpPr><xdr:spPr/></xdr:sp><xdr:clientData/></xdr:twoCellAnchor>` +
+				`</xdr:wsDr>`,
+			"xl/drawings/_rels/drawing1.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="../media/photo.PNG"/></Relationships>`,
+			"xl/media/photo.PNG": string(logo),
+		}))
+		c.Assert(err, qt.IsNil)
+		pictures := file.Sheet["Sheet1"].Pictures
+		c.Assert(pictures, qt.HasLen, 1)
+		c.Assert(pictures[0].Format, qt.Equals, "png")
+		c.Assert(pictures[0].Options, qt.Equals, PictureOptions{
+			OffsetX: 10, Width: 2*64 - 10, Height: 20 + 10, Name: "Picture 1"})
+	})
+}
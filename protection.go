@@ -0,0 +1,370 @@
+package xlsx
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// DefaultProtectionSpinCount is the number of times a protection
+	// password is rehashed.  It's the count that Excel uses.
+	DefaultProtectionSpinCount = 100000
+
+	protectionAlgorithmName = "SHA-512"
+	protectionSaltSize      = 16
+)
+
+var protectionHashes = map[string]func() hash.Hash{
+	"SHA-1":   sha1.New,
+	"SHA-256": sha256.New,
+	"SHA-384": sha512.New384,
+	"SHA-512": sha512.New,
+}
+
+// protectionPassword holds a protection password hashed the way Excel
+// does it, along with the legacy 16 bit hash that older readers
+// understand.  The password itself is never kept.
+type protectionPassword struct {
+	algorithmName string
+	hashValue     string
+	saltValue     string
+	spinCount     int
+	legacy        string
+}
+
+// newProtectionPassword hashes the password with a fresh random salt.
+// An empty password gives an empty protectionPassword, so that
+// protection can be removed without one.
+func newProtectionPassword(password string) (protectionPassword, error) {
+	if password == "" {
+		return protectionPassword{}, nil
+	}
+	salt := make([]byte, protectionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return protectionPassword{}, fmt.Errorf("cannot make password salt: %v", err)
+	}
+	hashValue, err := hashProtectionPassword(password, protectionAlgorithmName, salt, DefaultProtectionSpinCount)
+	if err != nil {
+		return protectionPassword{}, err
+	}
+	return protectionPassword{
+		algorithmName: protectionAlgorithmName,
+		hashValue:     base64.StdEncoding.EncodeToString(hashValue),
+		saltValue:     base64.StdEncoding.EncodeToString(salt),
+		spinCount:     DefaultProtectionSpinCount,
+		legacy:        legacyPasswordHash(password),
+	}, nil
+}
+
+// isSet returns true if the protection has a password.
+func (p protectionPassword) isSet() bool {
+	return p.hashValue != "" || p.legacy != ""
+}
+
+// check returns true if password is the one that was hashed.  The
+// salted hash is used when there is one, and the legacy hash
+// otherwise.
+func (p protectionPassword) check(password string) bool {
+	if !p.isSet() {
+		return password == ""
+	}
+	if p.hashValue == "" {
+		return strings.EqualFold(p.legacy, legacyPasswordHash(password))
+	}
+	salt, err := base64.StdEncoding.DecodeString(p.saltValue)
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(p.hashValue)
+	if err != nil {
+		return false
+	}
+	got, err := hashProtectionPassword(password, p.algorithmName, salt, p.spinCount)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// hashProtectionPassword hashes the salt followed by the UTF-16LE
+// password, and then rehashes the result followed by the iteration
+// number spinCount times, as ISO/IEC 29500 describes for sheet and
+// workbook protection.
+func hashProtectionPassword(password, algorithmName string, salt []byte, spinCount int) ([]byte, error) {
+	newHash, ok := protectionHashes[algorithmName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithmName)
+	}
+	h := newHash()
+	h.Write(salt)
+	for _, u := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(u), byte(u >> 8)})
+	}
+	sum := h.Sum(nil)
+	iterator := make([]byte, 4)
+	for i := 0; i < spinCount; i++ {
+		binary.LittleEndian.PutUint32(iterator, uint32(i))
+		h.Reset()
+		h.Write(sum)
+		h.Write(iterator)
+		sum = h.Sum(sum[:0])
+	}
+	return sum, nil
+}
+
+// legacyPasswordHash returns the 16 bit hash of the password that
+// Excel used before salted hashes, as four hex digits.
+func legacyPasswordHash(password string) string {
+	var hash uint16
+	chars := []rune(password)
+	for i := len(chars) - 1; i >= 0; i-- {
+		hash = rotateLegacyPasswordHash(hash) ^ uint16(chars[i]&0xff)
+	}
+	hash = rotateLegacyPasswordHash(hash) ^ uint16(len(chars)) ^ 0xCE4B
+	return fmt.Sprintf("%04X", hash)
+}
+
+func rotateLegacyPasswordHash(hash uint16) uint16 {
+	return ((hash >> 14) & 0x01) | ((hash << 1) & 0x7fff)
+}
+
+// SheetProtection holds the protection of a Sheet.  While a sheet is
+// protected its locked cells can't be changed, and the formulas of
+// its hidden cells aren't shown (see Style.Protection).  The Allow
+// fields say what users may still do.
+type SheetProtection struct {
+	AllowSelectLockedCells   bool
+	AllowSelectUnlockedCells bool
+	AllowFormatCells         bool
+	AllowFormatColumns       bool
+	AllowFormatRows          bool
+	AllowInsertColumns       bool
+	AllowInsertRows          bool
+	AllowInsertHyperlinks    bool
+	AllowDeleteColumns       bool
+	AllowDeleteRows          bool
+	AllowSort                bool
+	AllowAutoFilter          bool
+	AllowPivotTables         bool
+	AllowEditObjects         bool
+	AllowEditScenarios       bool
+	password                 protectionPassword
+}
+
+// NewSheetProtection returns the SheetProtection that Excel offers by
+// default: cells may be selected, and nothing else is allowed.
+func NewSheetProtection() *SheetProtection {
+	return &SheetProtection{
+		AllowSelectLockedCells:   true,
+		AllowSelectUnlockedCells: true,
+	}
+}
+
+// SetPassword sets the password needed to unprotect the sheet.  An
+// empty password removes it.
+func (p *SheetProtection) SetPassword(password string) error {
+	hashed, err := newProtectionPassword(password)
+	if err != nil {
+		return err
+	}
+	p.password = hashed
+	return nil
+}
+
+// HasPassword returns true if a password is needed to unprotect the
+// sheet.
+func (p *SheetProtection) HasPassword() bool {
+	return p.password.isSet()
+}
+
+// CheckPassword returns true if password unprotects the sheet.
+func (p *SheetProtection) CheckPassword(password string) bool {
+	return p.password.check(password)
+}
+
+// Protect protects the sheet with the default SheetProtection and the
+// given password, which may be empty.  The returned SheetProtection
+// can be changed to allow more.
+func (s *Sheet) Protect(password string) (*SheetProtection, error) {
+	s.load()
+	protection := NewSheetProtection()
+	if err := protection.SetPassword(password); err != nil {
+		return nil, err
+	}
+	s.Protection = protection
+	return protection, nil
+}
+
+// Unprotect removes the protection of the sheet.
+func (s *Sheet) Unprotect() {
+	s.load()
+	s.Protection = nil
+}
+
+func (s *Sheet) makeSheetProtection(worksheet *xlsxWorksheet) {
+	p := s.Protection
+	if p == nil {
+		return
+	}
+	protect := func(allow bool) *bool {
+		protected := !allow
+		return &protected
+	}
+	worksheet.SheetProtection = &xlsxSheetProtection{
+		AlgorithmName:       p.password.algorithmName,
+		HashValue:           p.password.hashValue,
+		SaltValue:           p.password.saltValue,
+		SpinCount:           p.password.spinCount,
+		Password:            p.password.legacy,
+		Sheet:               true,
+		Objects:             protect(p.AllowEditObjects),
+		Scenarios:           protect(p.AllowEditScenarios),
+		FormatCells:         protect(p.AllowFormatCells),
+		FormatColumns:       protect(p.AllowFormatColumns),
+		FormatRows:          protect(p.AllowFormatRows),
+		InsertColumns:       protect(p.AllowInsertColumns),
+		InsertRows:          protect(p.AllowInsertRows),
+		InsertHyperlinks:    protect(p.AllowInsertHyperlinks),
+		DeleteColumns:       protect(p.AllowDeleteColumns),
+		DeleteRows:          protect(p.AllowDeleteRows),
+		SelectLockedCells:   protect(p.AllowSelectLockedCells),
+		Sort:                protect(p.AllowSort),
+		AutoFilter:          protect(p.AllowAutoFilter),
+		PivotTables:         protect(p.AllowPivotTables),
+		SelectUnlockedCells: protect(p.AllowSelectUnlockedCells),
+	}
+}
+
+// readSheetProtection returns the SheetProtection of a worksheet, or
+// nil if the worksheet isn't protected.  Attributes that are missing
+// take the defaults of the schema.
+func readSheetProtection(xp *xlsxSheetProtection) *SheetProtection {
+	if xp == nil || !xp.Sheet {
+		return nil
+	}
+	allow := func(protected *bool, protectedByDefault bool) bool {
+		if protected == nil {
+			return !protectedByDefault
+		}
+		return !*protected
+	}
+	return &SheetProtection{
+		AllowSelectLockedCells:   allow(xp.SelectLockedCells, false),
+		AllowSelectUnlockedCells: allow(xp.SelectUnlockedCells, false),
+		AllowFormatCells:         allow(xp.FormatCells, true),
+		AllowFormatColumns:       allow(xp.FormatColumns, true),
+		AllowFormatRows:          allow(xp.FormatRows, true),
+		AllowInsertColumns:       allow(xp.InsertColumns, true),
+		AllowInsertRows:          allow(xp.InsertRows, true),
+		AllowInsertHyperlinks:    allow(xp.InsertHyperlinks, true),
+		AllowDeleteColumns:       allow(xp.DeleteColumns, true),
+		AllowDeleteRows:          allow(xp.DeleteRows, true),
+		AllowSort:                allow(xp.Sort, true),
+		AllowAutoFilter:          allow(xp.AutoFilter, true),
+		AllowPivotTables:         allow(xp.PivotTables, true),
+		AllowEditObjects:         allow(xp.Objects, false),
+		AllowEditScenarios:       allow(xp.Scenarios, false),
+		password: protectionPassword{
+			algorithmName: xp.AlgorithmName,
+			hashValue:     xp.HashValue,
+			saltValue:     xp.SaltValue,
+			spinCount:     xp.SpinCount,
+			legacy:        xp.Password,
+		},
+	}
+}
+
+// WorkbookProtection holds the protection of a File.  While the
+// structure of a workbook is locked its sheets can't be added,
+// deleted, renamed, moved, hidden or unhidden.  Locking the windows
+// keeps them from being moved or resized.
+type WorkbookProtection struct {
+	LockStructure bool
+	LockWindows   bool
+	password      protectionPassword
+}
+
+// SetPassword sets the password needed to unprotect the workbook.  An
+// empty password removes it.
+func (p *WorkbookProtection) SetPassword(password string) error {
+	hashed, err := newProtectionPassword(password)
+	if err != nil {
+		return err
+	}
+	p.password = hashed
+	return nil
+}
+
+// HasPassword returns true if a password is needed to unprotect the
+// workbook.
+func (p *WorkbookProtection) HasPassword() bool {
+	return p.password.isSet()
+}
+
+// CheckPassword returns true if password unprotects the workbook.
+func (p *WorkbookProtection) CheckPassword(password string) bool {
+	return p.password.check(password)
+}
+
+// Protect locks the structure of the workbook with the given
+// password, which may be empty.
+func (f *File) Protect(password string) (*WorkbookProtection, error) {
+	protection := &WorkbookProtection{LockStructure: true}
+	if err := protection.SetPassword(password); err != nil {
+		return nil, err
+	}
+	f.Protection = protection
+	return protection, nil
+}
+
+// Unprotect removes the protection of the workbook.
+func (f *File) Unprotect() {
+	f.Protection = nil
+}
+
+func (f *File) makeWorkbookProtection() xlsxWorkbookProtection {
+	p := f.Protection
+	if p == nil {
+		return xlsxWorkbookProtection{}
+	}
+	return xlsxWorkbookProtection{
+		WorkbookAlgorithmName: p.password.algorithmName,
+		WorkbookHashValue:     p.password.hashValue,
+		WorkbookSaltValue:     p.password.saltValue,
+		WorkbookSpinCount:     p.password.spinCount,
+		WorkbookPassword:      p.password.legacy,
+		LockStructure:         p.LockStructure,
+		LockWindows:           p.LockWindows,
+	}
+}
+
+// readWorkbookProtection returns the WorkbookProtection of a
+// workbook, or nil if the workbook isn't protected.  Workbooks that
+// aren't protected may still have an empty workbookProtection
+// element.
+func readWorkbookProtection(xp xlsxWorkbookProtection) *WorkbookProtection {
+	protection := &WorkbookProtection{
+		LockStructure: xp.LockStructure,
+		LockWindows:   xp.LockWindows,
+		password: protectionPassword{
+			algorithmName: xp.WorkbookAlgorithmName,
+			hashValue:     xp.WorkbookHashValue,
+			saltValue:     xp.WorkbookSaltValue,
+			spinCount:     xp.WorkbookSpinCount,
+			legacy:        xp.WorkbookPassword,
+		},
+	}
+	if !protection.LockStructure && !protection.LockWindows && !protection.HasPassword() {
+		return nil
+	}
+	return protection
+}
@@ -0,0 +1,230 @@
+package xlsx
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProtection(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("LegacyPasswordHash", func(c *qt.C) {
+		c.Assert(legacyPasswordHash("secret"), qt.Equals, "DAA7")
+		c.Assert(legacyPasswordHash("password"), qt.Equals, "83AF")
+		c.Assert(legacyPasswordHash(""), qt.Equals, "CE4B")
+	})
+
+	c.Run("HashProtectionPassword", func(c *qt.C) {
+		salt := []byte("0123456789abcdef")
+		// The salt is followed by the password in UTF-16LE ...
+		h0 := sha512.Sum512(append(append([]byte{}, salt...), 'p', 0, 'w', 0, 0xe9, 0))
+		got, err := hashProtectionPassword("pwé", "SHA-512", salt, 0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, h0[:])
+		// ... and each spin hashes the last hash followed by the
+		// little endian iteration number.
+		h1 := sha512.Sum512(append(h0[:], 0, 0, 0, 0))
+		h2 := sha512.Sum512(append(h1[:], 1, 0, 0, 0))
+		got, err = hashProtectionPassword("pwé", "SHA-512", salt, 2)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, h2[:])
+
+		_, err = hashProtectionPassword("pw", "RIPEMD-128", salt, 1)
+		c.Assert(err, qt.ErrorMatches, `unsupported password hash algorithm "RIPEMD-128"`)
+	})
+
+	c.Run("SheetPassword", func(c *qt.C) {
+		sheet, err := NewFile().AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		protection, err := sheet.Protect("secret")
+		c.Assert(err, qt.IsNil)
+		c.Assert(sheet.Protection, qt.Equals, protection)
+		c.Assert(protection.HasPassword(), qt.Equals, true)
+		c.Assert(protection.CheckPassword("secret"), qt.Equals, true)
+		c.Assert(protection.CheckPassword("Secret"), qt.Equals, false)
+		c.Assert(protection.CheckPassword(""), qt.Equals, false)
+		c.Assert(protection.password.algorithmName, qt.Equals, "SHA-512")
+		c.Assert(protection.password.spinCount, qt.Equals, DefaultProtectionSpinCount)
+		c.Assert(protection.password.legacy, qt.Equals, "DAA7")
+		salt, err := base64.StdEncoding.DecodeString(protection.password.saltValue)
+		c.Assert(err, qt.IsNil)
+		c.Assert(salt, qt.HasLen, 16)
+
+		// Every password gets its own salt.
+		other := NewSheetProtection()
+		c.Assert(other.SetPassword("secret"), qt.IsNil)
+		c.Assert(other.password.saltValue, qt.Not(qt.Equals), protection.password.saltValue)
+		c.Assert(other.password.hashValue, qt.Not(qt.Equals), protection.password.hashValue)
+
+		c.Assert(protection.SetPassword(""), qt.IsNil)
+		c.Assert(protection.HasPassword(), qt.Equals, false)
+		c.Assert(protection.CheckPassword(""), qt.Equals, true)
+
+		sheet.Unprotect()
+		c.Assert(sheet.Protection, qt.IsNil)
+	})
+
+	c.Run("MarshalSheet", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetInt(1)
+		protection, err := sheet.Protect("")
+		c.Assert(err, qt.IsNil)
+		protection.AllowFormatColumns = true
+		protection.AllowSelectLockedCells = false
+
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		assertInOrder(c, parts["xl/worksheets/sheet1.xml"],
+			`</sheetData>`,
+			`<sheetProtection sheet="true" objects="true" scenarios="true" formatCells="true" formatColumns="false" formatRows="true" `+
+				`insertColumns="true" insertRows="true" insertHyperlinks="true" deleteColumns="true" deleteRows="true" `+
+				`selectLockedCells="true" sort="true" autoFilter="true" pivotTables="true" selectUnlockedCells="false"></sheetProtection>`,
+			`<printOptions`)
+	})
+
+	c.Run("MarshalWorkbook", func(c *qt.C) {
+		file := NewFile()
+		_, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		protection, err := file.Protect("password")
+		c.Assert(err, qt.IsNil)
+		protection.LockWindows = true
+
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		workbook := parts["xl/workbook.xml"]
+		assertInOrder(c, workbook,
+			`<workbookPr `,
+			`<workbookProtection workbookAlgorithmName="SHA-512" workbookHashValue="`+protection.password.hashValue+
+				`" workbookSaltValue="`+protection.password.saltValue+
+				`" workbookSpinCount="100000" workbookPassword="83AF" lockStructure="true" lockWindows="true"></workbookProtection>`,
+			`<bookViews>`)
+
+		file.Unprotect()
+		parts, err = file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/workbook.xml"], qt.Contains, `<workbookProtection></workbookProtection>`)
+	})
+
+	c.Run("CellProtection", func(c *qt.C) {
+		style := NewStyle()
+		c.Assert(style.Protection, qt.Equals, Protection{Locked: true})
+		_, _, _, xf := style.makeXLSXStyleElements()
+		c.Assert(xf.ApplyProtection, qt.Equals, false)
+		c.Assert(xf.Protection, qt.IsNil)
+
+		style.ApplyProtection = true
+		style.Protection = Protection{Locked: false, Hidden: true}
+		_, _, _, xf = style.makeXLSXStyleElements()
+		c.Assert(xf.ApplyProtection, qt.Equals, true)
+		c.Assert(xf.Protection.Marshal(), qt.Equals, `<protection locked="0" hidden="1"/>`)
+
+		locked := xlsxXf{Protection: &xlsxProtection{}}
+		c.Assert(locked.Equals(xf), qt.Equals, false)
+		c.Assert(locked.Equals(xlsxXf{}), qt.Equals, false)
+		isLocked := true
+		c.Assert(locked.Protection.Equals(xlsxProtection{Locked: &isLocked}), qt.Equals, true)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Template")
+		c.Assert(err, qt.IsNil)
+		input := NewStyle()
+		input.ApplyProtection = true
+		input.Protection.Locked = false
+		formula := NewStyle()
+		formula.ApplyProtection = true
+		formula.Protection.Hidden = true
+		for i := 0; i < 3; i++ {
+			cell := sheet.Cell(i, 0)
+			cell.SetInt(i)
+			cell.SetStyle(input)
+			cell = sheet.Cell(i, 1)
+			cell.SetFormula("A1*2")
+			cell.SetStyle(formula)
+		}
+		protection, err := sheet.Protect("partner")
+		c.Assert(err, qt.IsNil)
+		protection.AllowFormatColumns = true
+		protection.AllowSort = true
+		_, err = file.Protect("owner")
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+		file, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(file.Protection, qt.Not(qt.IsNil))
+		c.Assert(file.Protection.LockStructure, qt.Equals, true)
+		c.Assert(file.Protection.LockWindows, qt.Equals, false)
+		c.Assert(file.Protection.CheckPassword("owner"), qt.Equals, true)
+		c.Assert(file.Protection.CheckPassword("partner"), qt.Equals, false)
+
+		sheet = file.Sheet["Template"]
+		got := sheet.Protection
+		c.Assert(got, qt.Not(qt.IsNil))
+		c.Assert(got.CheckPassword("partner"), qt.Equals, true)
+		c.Assert(got.CheckPassword("owner"), qt.Equals, false)
+		want := *protection
+		want.password = got.password
+		c.Assert(*got, qt.Equals, want)
+
+		c.Assert(sheet.Cell(1, 0).GetStyle().ApplyProtection, qt.Equals, true)
+		c.Assert(sheet.Cell(1, 0).GetStyle().Protection, qt.Equals, Protection{Locked: false})
+		c.Assert(sheet.Cell(1, 1).GetStyle().Protection, qt.Equals, Protection{Locked: true, Hidden: true})
+	})
+
+	c.Run("ReadDefaults", func(c *qt.C) {
+		parts := passThroughTestParts(c)
+		parts["xl/workbook.xml"] = strings.Replace(parts["xl/workbook.xml"], `<bookViews>`,
+			`<workbookProtection lockStructure="1" workbookPassword="83AF"/><bookViews>`, 1)
+		parts["xl/worksheets/sheet1.xml"] = strings.Replace(parts["xl/worksheets/sheet1.xml"], `</sheetData>`,
+			`</sheetData><sheetProtection password="DAA7" sheet="1" objects="1" formatRows="0"/>`, 1)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(file.Protection, qt.Not(qt.IsNil))
+		c.Assert(file.Protection.LockStructure, qt.Equals, true)
+		c.Assert(file.Protection.CheckPassword("password"), qt.Equals, true)
+
+		protection := file.Sheet["Data"].Protection
+		c.Assert(protection, qt.Not(qt.IsNil))
+		c.Assert(protection.CheckPassword("secret"), qt.Equals, true)
+		c.Assert(protection.CheckPassword("password"), qt.Equals, false)
+		want := SheetProtection{
+			AllowSelectLockedCells:   true,
+			AllowSelectUnlockedCells: true,
+			AllowFormatRows:          true,
+			AllowEditScenarios:       true,
+			password:                 protection.password,
+		}
+		c.Assert(*protection, qt.Equals, want)
+
+		// The model owns the protection elements, so unprotecting
+		// removes them from the written file.
+		file.Unprotect()
+		file.Sheet["Data"].Unprotect()
+		written, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(written["xl/worksheets/sheet1.xml"], qt.Not(qt.Contains), `sheetProtection`)
+		c.Assert(written["xl/workbook.xml"], qt.Not(qt.Contains), `lockStructure`)
+	})
+
+	c.Run("Unprotected", func(c *qt.C) {
+		file, err := OpenFile("./testdocs/testfile.xlsx")
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Protection, qt.IsNil)
+		for _, sheet := range file.Sheets {
+			c.Assert(sheet.Protection, qt.IsNil)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package xlsx
+
+import "runtime"
+
+// ReadOptions control how an XLSX file is read.  The zero value reads
+// the whole file.
+type ReadOptions struct {
+	// RowLimit is the number of rows that are read from each sheet.
+	// If it is zero, or NoRowLimit, all rows are read.  A file that
+	// is saved after it has been read with a row limit is truncated
+	// to the rows that were read.
+	RowLimit int
+	// ColLimit is the number of columns that are read from each
+	// sheet.  If it is zero, all columns are read.
+	ColLimit int
+	// Sheets names the sheets that are read.  The other sheets are
+	// not parsed at all, and are left out of the File.  If it is
+	// empty, all sheets are read.  A file that is saved after it has
+	// been read with only some of its sheets loses the others, and
+	// Lazy should be used instead to write them back as they were.
+	Sheets []string
+	// SkipStyles leaves out the styles, so that all cells have the
+	// default style and the General number format.
+	SkipStyles bool
+	// SkipHyperlinks leaves out the hyperlinks of cells.
+	SkipHyperlinks bool
+	// ValuesOnly reads just the values and types of cells.  Styles,
+	// formulas, hyperlinks, comments, pictures, tables, data
+	// validations and conditional formats are left out.
+	ValuesOnly bool
+	// Lazy leaves each sheet to be read when it is first used, rather
+	// than when the file is opened.  Sheets that are never used are
+	// written back as they were.  See Sheet.Load.
+	Lazy bool
+	// Workers is the number of sheets that are read at the same
+	// time.  If it is zero, it is the number of CPUs that Go uses,
+	// runtime.GOMAXPROCS(0).  The sheets are the same whatever the
+	// number of workers, and so is the error returned if any of them
+	// can't be read, which is that of the first such sheet.
+	Workers int
+	// Lenient reads as much as it can of a damaged file, instead of
+	// failing on the first problem.  A sheet that can't be read is
+	// left out, a broken styles or shared strings part is replaced
+	// by defaults, cell references that can't be parsed are repaired
+	// from the position of the cell, and shared string indexes that
+	// are out of range give empty cells.  Each problem is returned as
+	// a warning.
+	Lenient bool
+}
+
+// rowLimit returns the row limit in the form used while reading.
+func (o ReadOptions) rowLimit() int {
+	if o.RowLimit <= 0 {
+		return NoRowLimit
+	}
+	return o.RowLimit
+}
+
+// workers returns the number of sheets that are read at the same time.
+func (o ReadOptions) workers() int {
+	if o.Workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return o.Workers
+}
+
+// readsCell returns true if the cell at column x and row y is within
+// the row and column limits.
+func (o ReadOptions) readsCell(x, y int) bool {
+	return (o.RowLimit <= 0 || y < o.RowLimit) && (o.ColLimit <= 0 || x < o.ColLimit)
+}
+
+// readsSheet returns true if a sheet of the given name is read.
+func (o ReadOptions) readsSheet(name string) bool {
+	if len(o.Sheets) == 0 {
+		return true
+	}
+	for _, sheet := range o.Sheets {
+		if sheet == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FileOption changes how OpenFile and the functions like it read a
+// file.  For example:
+//
+//	file, err := xlsx.OpenFile("report.xlsx", xlsx.RowLimit(100), xlsx.OnlySheets("Data"))
+type FileOption func(*ReadOptions)
+
+// RowLimit reads only the first n rows of each sheet.
+func RowLimit(n int) FileOption {
+	return func(o *ReadOptions) {
+		o.RowLimit = n
+	}
+}
+
+// ColLimit reads only the first n columns of each sheet.
+func ColLimit(n int) FileOption {
+	return func(o *ReadOptions) {
+		o.ColLimit = n
+	}
+}
+
+// OnlySheets reads only the sheets with the given names.  The other
+// sheets are not parsed, and are left out of the file when it is saved;
+// see ReadOptions.Sheets.
+func OnlySheets(names ...string) FileOption {
+	return func(o *ReadOptions) {
+		o.Sheets = append(o.Sheets, names...)
+	}
+}
+
+// SkipStyles leaves out the styles of cells.
+func SkipStyles() FileOption {
+	return func(o *ReadOptions) {
+		o.SkipStyles = true
+	}
+}
+
+// SkipHyperlinks leaves out the hyperlinks of cells.
+func SkipHyperlinks() FileOption {
+	return func(o *ReadOptions) {
+		o.SkipHyperlinks = true
+	}
+}
+
+// ValuesOnly reads just the values and types of cells.
+func ValuesOnly() FileOption {
+	return func(o *ReadOptions) {
+		o.ValuesOnly = true
+	}
+}
+
+// Lazy reads each sheet when it is first used.
+func Lazy() FileOption {
+	return func(o *ReadOptions) {
+		o.Lazy = true
+	}
+}
+
+// Workers reads up to n sheets at the same time.
+func Workers(n int) FileOption {
+	return func(o *ReadOptions) {
+		o.Workers = n
+	}
+}
+
+// readOptions returns the ReadOptions that options make.
+func readOptions(options []FileOption) ReadOptions {
+	var o ReadOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
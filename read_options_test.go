@@ -0,0 +1,245 @@
+package xlsx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReadOptions(t *testing.T) {
+	c := qt.New(t)
+
+	// optionsParts returns the parts of a file with the sheets
+	// Summary and Data, whose second sheet is broken.
+	optionsParts := func(c *qt.C) map[string]string {
+		file := NewFile()
+		data, err := file.AddSheet("Data")
+		c.Assert(err, qt.IsNil)
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				data.Cell(y, x).SetInt(y*10 + x)
+			}
+		}
+		data.Cell(0, 0).SetFloatWithFormat(1.5, "0.00")
+		data.Cell(0, 0).Comment = Comment{Author: "Ann", Text: "Check this"}
+		data.Cell(0, 1).SetHyperlink("https://example.com/", "example", "")
+		data.Cell(1, 1).SetFormula("A1*2")
+		_, err = file.AddSheet("Broken")
+		c.Assert(err, qt.IsNil)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		parts["xl/worksheets/sheet2.xml"] = `<broken`
+		return parts
+	}
+	open := func(c *qt.C, options ...FileOption) *Sheet {
+		file, err := OpenBinary(zipParts(c, optionsParts(c)), append(options, OnlySheets("Data"))...)
+		c.Assert(err, qt.IsNil)
+		return file.Sheet["Data"]
+	}
+
+	c.Run("OnlySheets", func(c *qt.C) {
+		data := zipParts(c, optionsParts(c))
+		_, err := OpenBinary(data)
+		c.Assert(err, qt.ErrorMatches, `Sheet 'Broken': XML syntax error .*`)
+
+		// The broken sheet isn't parsed.
+		file, err := OpenBinary(data, OnlySheets("Data"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 1)
+		c.Assert(file.Sheets[0].Name, qt.Equals, "Data")
+		c.Assert(file.Sheet["Broken"], qt.IsNil)
+
+		// A file with sheets left out is written without them.
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+		file, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		c.Assert(file.Sheets, qt.HasLen, 1)
+		c.Assert(file.Sheet["Data"].Cell(3, 3).Value, qt.Equals, "33")
+
+		_, err = OpenBinary(data, OnlySheets("Data", "Missing"))
+		c.Assert(err, qt.ErrorMatches, `sheet "Missing" not found in XLSX file`)
+	})
+
+	c.Run("Default", func(c *qt.C) {
+		sheet := open(c)
+		c.Assert(sheet.MaxRow, qt.Equals, 4)
+		c.Assert(sheet.MaxCol, qt.Equals, 4)
+		c.Assert(sheet.Cell(0, 0).NumFmt, qt.Equals, "0.00")
+		c.Assert(sheet.Cell(0, 0).Comment.Text, qt.Equals, "Check this")
+		c.Assert(sheet.Cell(0, 1).Hyperlink.Link, qt.Equals, "https://example.com/")
+		c.Assert(sheet.Cell(1, 1).Formula(), qt.Equals, "A1*2")
+	})
+
+	c.Run("Limits", func(c *qt.C) {
+		sheet := open(c, RowLimit(2), ColLimit(3))
+		c.Assert(sheet.MaxRow, qt.Equals, 2)
+		c.Assert(sheet.MaxCol, qt.Equals, 3)
+		c.Assert(sheet.Rows, qt.HasLen, 2)
+		for _, row := range sheet.Rows {
+			c.Assert(row.Cells, qt.HasLen, 3)
+		}
+		c.Assert(sheet.Cell(1, 2).Value, qt.Equals, "12")
+	})
+
+	c.Run("SkipStyles", func(c *qt.C) {
+		sheet := open(c, SkipStyles())
+		c.Assert(sheet.File.styles, qt.IsNil)
+		c.Assert(sheet.Cell(0, 0).NumFmt, qt.Equals, "")
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "1.5")
+		c.Assert(sheet.Cell(0, 1).Hyperlink.Link, qt.Equals, "https://example.com/")
+	})
+
+	c.Run("SkipHyperlinks", func(c *qt.C) {
+		sheet := open(c, SkipHyperlinks())
+		c.Assert(sheet.Cell(0, 1).Hyperlink, qt.Equals, Hyperlink{})
+		c.Assert(sheet.Cell(0, 0).Comment.Text, qt.Equals, "Check this")
+	})
+
+	c.Run("ValuesOnly", func(c *qt.C) {
+		sheet := open(c, ValuesOnly())
+		cell := sheet.Cell(1, 2)
+		c.Assert(cell.Value, qt.Equals, "12")
+		c.Assert(cell.Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(sheet.Cell(1, 1).Formula(), qt.Equals, "")
+		c.Assert(sheet.Cell(0, 0).NumFmt, qt.Equals, "")
+		c.Assert(sheet.Cell(0, 0).Comment, qt.Equals, Comment{})
+		c.Assert(sheet.Cell(0, 1).Hyperlink, qt.Equals, Hyperlink{})
+	})
+
+	c.Run("WithRowLimit", func(c *qt.C) {
+		file, err := OpenBinaryWithRowLimit(zipParts(c, optionsParts(c)), 1)
+		c.Assert(err, qt.ErrorMatches, `Sheet 'Broken': .*`)
+		c.Assert(file, qt.IsNil)
+		c.Assert(readOptions([]FileOption{RowLimit(NoRowLimit)}).rowLimit(), qt.Equals, NoRowLimit)
+		c.Assert(readOptions([]FileOption{RowLimit(3), OnlySheets("a"), OnlySheets("b")}), qt.DeepEquals, ReadOptions{RowLimit: 3, Sheets: []string{"a", "b"}})
+	})
+}
+
+// manySheetsParts returns the parts of a file with the given number of
+// sheets, each of rows rows of text, numbers in several formats and
+// styles.
+func manySheetsParts(sheets, rows int) (map[string]string, error) {
+	file := NewFile()
+	bold := NewStyle()
+	bold.Font.Bold = true
+	bold.ApplyFont = true
+	formats := []string{"0.00", "#,##0", "0%", "yyyy-mm-dd"}
+	for i := 0; i < sheets; i++ {
+		sheet, err := file.AddSheet(fmt.Sprintf("Sheet%d", i+1))
+		if err != nil {
+			return nil, err
+		}
+		for y := 0; y < rows; y++ {
+			sheet.Cell(y, 0).SetString(fmt.Sprintf("Item %d", y))
+			sheet.Cell(y, 1).SetString(fmt.Sprintf("Sheet %d row %d", i, y))
+			for x := 2; x < 8; x++ {
+				sheet.Cell(y, x).SetFloatWithFormat(float64(i*rows+y)+float64(x)/10, formats[(i+x)%len(formats)])
+			}
+			sheet.Cell(y, 8).SetFormula(fmt.Sprintf("C%d*2", y+1))
+			if y%10 == 0 {
+				sheet.Cell(y, 0).SetStyle(bold)
+			}
+		}
+	}
+	return file.MarshallParts()
+}
+
+func TestReadWorkers(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("SameSheets", func(c *qt.C) {
+		parts, err := manySheetsParts(8, 50)
+		c.Assert(err, qt.IsNil)
+		data := zipParts(c, parts)
+		read := func(workers int) *File {
+			file, err := OpenBinary(data, Workers(workers))
+			c.Assert(err, qt.IsNil)
+			return file
+		}
+		want := read(1)
+		for _, workers := range []int{0, 2, 8, 20} {
+			file := read(workers)
+			c.Assert(file.Sheets, qt.HasLen, 8)
+			for i, sheet := range file.Sheets {
+				c.Assert(sheet.Name, qt.Equals, want.Sheets[i].Name)
+				c.Assert(sheet.MaxRow, qt.Equals, 50)
+				for y, row := range sheet.Rows {
+					for x, cell := range row.Cells {
+						wantCell := want.Sheets[i].Cell(y, x)
+						c.Assert(cell.Value, qt.Equals, wantCell.Value)
+						c.Assert(cell.NumFmt, qt.Equals, wantCell.NumFmt)
+						c.Assert(cell.Formula(), qt.Equals, wantCell.Formula())
+						c.Assert(cell.GetStyle().Font.Bold, qt.Equals, wantCell.GetStyle().Font.Bold)
+					}
+				}
+			}
+		}
+	})
+
+	c.Run("FirstError", func(c *qt.C) {
+		parts, err := manySheetsParts(8, 5)
+		c.Assert(err, qt.IsNil)
+		parts["xl/worksheets/sheet3.xml"] = `<broken`
+		parts["xl/worksheets/sheet6.xml"] = `<broken`
+		data := zipParts(c, parts)
+		for _, workers := range []int{1, 2, 8} {
+			for i := 0; i < 5; i++ {
+				_, err := OpenBinary(data, Workers(workers))
+				c.Assert(err, qt.ErrorMatches, `Sheet 'Sheet3': XML syntax error .*`)
+			}
+		}
+	})
+
+	c.Run("LenientWarnings", func(c *qt.C) {
+		parts, err := manySheetsParts(8, 5)
+		c.Assert(err, qt.IsNil)
+		for i := 2; i <= 8; i++ {
+			name := fmt.Sprintf("xl/worksheets/sheet%d.xml", i)
+			parts[name] = strings.Replace(parts[name], `<c r="B2"`, `<c r="B"`, 1)
+		}
+		delete(parts, "xl/sharedStrings.xml")
+		data := zipParts(c, parts)
+		for i := 0; i < 5; i++ {
+			_, warnings, err := OpenBinaryWithOptions(data, ReadOptions{Lenient: true, Workers: 8})
+			c.Assert(err, qt.IsNil)
+			var messages []string
+			for _, warning := range warnings {
+				messages = append(messages, warning.Error())
+			}
+			// The warnings come in the order of the sheets, and a
+			// missing shared strings table is reported once.
+			c.Assert(messages, qt.HasLen, 8)
+			c.Assert(messages[0], qt.Matches, `Sheet 'Sheet1' cell A1: invalid shared string index 0`)
+			for j, message := range messages[1:] {
+				c.Assert(message, qt.Matches, fmt.Sprintf(`Sheet 'Sheet%d' cell B: invalid cell reference, using B2`, j+2))
+			}
+		}
+	})
+}
+
+func BenchmarkReadSheets(b *testing.B) {
+	parts, err := manySheetsParts(16, 1000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := qt.New(b)
+	data := zipParts(c, parts)
+	for _, workers := range []int{1, 2, 4, 0} {
+		name := fmt.Sprintf("Workers%d", workers)
+		if workers == 0 {
+			name = "WorkersDefault"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := OpenBinary(data, Workers(workers)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
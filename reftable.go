@@ -1,15 +1,28 @@
 package xlsx
 
+import (
+	"encoding/xml"
+	"sync"
+)
+
+// A RefTable may be read from several goroutines at once, for example
+// while the sheets of a file are read, and it is safe to add strings
+// to it meanwhile.
 type RefTable struct {
-	indexedStrings []string
-	knownStrings   map[string]int
-	isWrite        bool
+	mutex           sync.RWMutex // protects the following
+	indexedStrings  []string
+	knownStrings    map[string]int
+	indexedRichText map[int]RichText
+	knownRichText   map[string]int
+	isWrite         bool
 }
 
 // NewSharedStringRefTable() creates a new, empty RefTable.
 func NewSharedStringRefTable() *RefTable {
 	rt := RefTable{}
 	rt.knownStrings = make(map[string]int)
+	rt.indexedRichText = make(map[int]RichText)
+	rt.knownRichText = make(map[string]int)
 	return &rt
 }
 
@@ -18,15 +31,17 @@ func NewSharedStringRefTable() *RefTable {
 // by numeric index - this is the model used within XLSX worksheet (a
 // numeric reference is stored to a shared cell value).
 func MakeSharedStringRefTable(source *xlsxSST) *RefTable {
+	return makeSharedStringRefTable(source, nil)
+}
+
+// makeSharedStringRefTable does the work of MakeSharedStringRefTable,
+// using theme to resolve the theme colors of rich text.
+func makeSharedStringRefTable(source *xlsxSST, theme *theme) *RefTable {
 	reftable := NewSharedStringRefTable()
 	reftable.isWrite = false
 	for _, si := range source.SI {
 		if len(si.R) > 0 {
-			newString := ""
-			for j := 0; j < len(si.R); j++ {
-				newString = newString + si.R[j].T
-			}
-			reftable.AddString(newString)
+			reftable.AddRichText(readRichText(si.R, theme))
 		} else {
 			reftable.AddString(si.T)
 		}
@@ -37,12 +52,18 @@ func MakeSharedStringRefTable(source *xlsxSST) *RefTable {
 // makeXlsxSST() takes a RefTable and returns and
 // equivalent xlsxSST representation.
 func (rt *RefTable) makeXLSXSST() xlsxSST {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
 	sst := xlsxSST{}
 	sst.Count = len(rt.indexedStrings)
 	sst.UniqueCount = sst.Count
-	for _, ref := range rt.indexedStrings {
+	for index, ref := range rt.indexedStrings {
 		si := xlsxSI{}
-		si.T = ref
+		if richText, ok := rt.indexedRichText[index]; ok {
+			si.R = richText.makeXLSXRuns()
+		} else {
+			si.T = ref
+		}
 		sst.SI = append(sst.SI, si)
 	}
 	return sst
@@ -53,6 +74,8 @@ func (rt *RefTable) makeXLSXSST() xlsxSST {
 // order).  This function only exists to provide clarity of purpose
 // via it's name.
 func (rt *RefTable) ResolveSharedString(index int) string {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
 	return rt.indexedStrings[index]
 }
 
@@ -60,6 +83,8 @@ func (rt *RefTable) ResolveSharedString(index int) string {
 // numeric index.  If the string already exists then it simply returns
 // the existing index.
 func (rt *RefTable) AddString(str string) int {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
 	if rt.isWrite {
 		index, ok := rt.knownStrings[str]
 		if ok {
@@ -72,6 +97,57 @@ func (rt *RefTable) AddString(str string) int {
 	return index
 }
 
+// ResolveSharedRichText looks up the rich text at the given index of
+// the reference table.  It returns nil if the string at that index is
+// plain text.
+func (rt *RefTable) ResolveSharedRichText(index int) RichText {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+	return rt.indexedRichText[index]
+}
+
+// AddRichText adds rich text to the reference table and returns it's
+// numeric index.  As with AddString, if the same rich text, with the
+// same fonts, already exists then it returns the existing index.
+func (rt *RefTable) AddRichText(richText RichText) int {
+	key, _ := xml.Marshal(xlsxSI{R: richText.makeXLSXRuns()})
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	if rt.isWrite {
+		index, ok := rt.knownRichText[string(key)]
+		if ok {
+			return index
+		}
+	}
+	rt.indexedStrings = append(rt.indexedStrings, richText.String())
+	index := len(rt.indexedStrings) - 1
+	rt.indexedRichText[index] = richText
+	rt.knownRichText[string(key)] = index
+	return index
+}
+
+// writeCopy returns a RefTable for writing that holds the strings of
+// rt at the same indexes, so that cells that refer to them by index
+// can be written as they were.  rt may be nil.
+func (rt *RefTable) writeCopy() *RefTable {
+	c := NewSharedStringRefTable()
+	if rt != nil {
+		rt.mutex.RLock()
+		defer rt.mutex.RUnlock()
+		for index, str := range rt.indexedStrings {
+			if richText, ok := rt.indexedRichText[index]; ok {
+				c.AddRichText(richText)
+			} else {
+				c.AddString(str)
+			}
+		}
+	}
+	c.isWrite = true
+	return c
+}
+
 func (rt *RefTable) Length() int {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
 	return len(rt.indexedStrings)
 }
@@ -123,3 +123,37 @@ func (s *RefTableSuite) TestRefTableWriteAddString(c *C) {
 	c.Assert(index2, Equals, 0)
 	c.Assert(refTable.ResolveSharedString(0), Equals, "Foo")
 }
+
+func (s *RefTableSuite) TestRefTableWriteAddRichText(c *C) {
+	refTable := NewSharedStringRefTable()
+	refTable.isWrite = true
+	bold := RichText{{Font: &Font{Bold: true}, Text: "Foo"}, {Text: " bar"}}
+	index1 := refTable.AddRichText(bold)
+	index2 := refTable.AddString("Foo bar")
+	index3 := refTable.AddRichText(RichText{{Font: &Font{Bold: true}, Text: "Foo"}, {Text: " bar"}})
+	index4 := refTable.AddRichText(RichText{{Font: &Font{Italic: true}, Text: "Foo"}, {Text: " bar"}})
+	c.Assert(index1, Equals, 0)
+	c.Assert(index2, Equals, 1)
+	c.Assert(index3, Equals, 0)
+	c.Assert(index4, Equals, 2)
+	c.Assert(refTable.ResolveSharedString(0), Equals, "Foo bar")
+	c.Assert(refTable.ResolveSharedRichText(0), DeepEquals, bold)
+	c.Assert(refTable.ResolveSharedRichText(1), IsNil)
+}
+
+func (s *RefTableSuite) TestMarshalRichTextSST(c *C) {
+	refTable := NewSharedStringRefTable()
+	refTable.AddRichText(RichText{
+		{Font: &Font{Bold: true, Color: "FFFF0000", Size: 12, Name: "Calibri"}, Text: "Foo"},
+		{Text: " bar"},
+	})
+	sst := refTable.makeXLSXSST()
+
+	body, err := xml.Marshal(sst)
+	c.Assert(err, IsNil)
+	expectedXLSXSST := `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="1" uniqueCount="1"><si>` +
+		`<r><rPr><rFont val="Calibri"></rFont><b></b><color rgb="FFFF0000"></color><sz val="12"></sz></rPr><t>Foo</t></r>` +
+		`<r><t xml:space="preserve"> bar</t></r>` +
+		`</si></sst>`
+	c.Assert(string(body), Equals, expectedXLSXSST)
+}
@@ -0,0 +1,128 @@
+package xlsx
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RichTextRun is a run of text within a RichText, all in the same
+// font.  A run with a nil Font is shown in the font of the cell.
+type RichTextRun struct {
+	Font *Font
+	Text string
+}
+
+// RichText is a string made up of runs of text with different fonts,
+// for example a cell value with one word in bold.
+type RichText []RichTextRun
+
+// String returns the plain text of the rich text, without any of the
+// formatting.
+func (rt RichText) String() string {
+	var text strings.Builder
+	for _, run := range rt {
+		text.WriteString(run.Text)
+	}
+	return text.String()
+}
+
+// SetRichText sets the cell's value to the given rich text.  The
+// plain text of the value is available as the cell's Value.
+func (c *Cell) SetRichText(rt RichText) {
+	c.SetString(rt.String())
+	c.richText = rt
+}
+
+// RichText returns the runs of rich text of the cell, or nil if the
+// cell's value is plain text.
+func (c *Cell) RichText() RichText {
+	if c.richText == nil || c.Value != c.richText.String() {
+		return nil
+	}
+	if c.cellType != CellTypeString && c.cellType != CellTypeInline {
+		return nil
+	}
+	return c.richText
+}
+
+// makeXLSXRuns converts rich text to its XML representation.
+func (rt RichText) makeXLSXRuns() []xlsxR {
+	runs := make([]xlsxR, 0, len(rt))
+	for _, run := range rt {
+		r := xlsxR{T: xlsxT{Text: run.Text}}
+		if strings.TrimSpace(run.Text) != run.Text {
+			r.T.Space = "preserve"
+		}
+		if font := run.Font; font != nil {
+			r.RPr = &xlsxRPr{}
+			if font.Name != "" {
+				r.RPr.RFont = &xlsxVal{Val: font.Name}
+			}
+			if font.Charset > 0 {
+				r.RPr.Charset = &xlsxVal{Val: strconv.Itoa(font.Charset)}
+			}
+			if font.Family > 0 {
+				r.RPr.Family = &xlsxVal{Val: strconv.Itoa(font.Family)}
+			}
+			if font.Bold {
+				r.RPr.B = &xlsxVal{}
+			}
+			if font.Italic {
+				r.RPr.I = &xlsxVal{}
+			}
+			if font.Color != "" {
+				r.RPr.Color = &xlsxColor{RGB: font.Color}
+			}
+			if font.Size > 0 {
+				r.RPr.Sz = &xlsxVal{Val: strconv.Itoa(font.Size)}
+			}
+			if font.Underline {
+				r.RPr.U = &xlsxVal{}
+			}
+		}
+		runs = append(runs, r)
+	}
+	return runs
+}
+
+// readRichText converts the XML representation of rich text to a
+// RichText.  Theme colors are resolved with theme, which may be nil.
+func readRichText(runs []xlsxR, theme *theme) RichText {
+	isSet := func(v *xlsxVal) bool {
+		return v != nil && v.Val != "0" && v.Val != "false" && v.Val != "none"
+	}
+	rt := make(RichText, 0, len(runs))
+	for _, r := range runs {
+		run := RichTextRun{Text: r.T.Text}
+		if rPr := r.RPr; rPr != nil {
+			font := &Font{}
+			if rPr.RFont != nil {
+				font.Name = rPr.RFont.Val
+			}
+			if rPr.Charset != nil {
+				font.Charset, _ = strconv.Atoi(rPr.Charset.Val)
+			}
+			if rPr.Family != nil {
+				font.Family, _ = strconv.Atoi(rPr.Family.Val)
+			}
+			font.Bold = isSet(rPr.B)
+			font.Italic = isSet(rPr.I)
+			if rPr.Color != nil {
+				font.Color = rPr.Color.RGB
+				if rPr.Color.Theme != nil && theme != nil {
+					font.Color = theme.themeColor(int64(*rPr.Color.Theme), rPr.Color.Tint)
+				}
+			}
+			if rPr.Sz != nil {
+				// Sizes can have fractions, such as 10.5, but
+				// Font only holds whole points.
+				size, _ := strconv.ParseFloat(rPr.Sz.Val, 64)
+				font.Size = int(size)
+			}
+			font.Underline = isSet(rPr.U)
+			run.Font = font
+		}
+		rt = append(rt, run)
+	}
+	return rt
+}
@@ -0,0 +1,93 @@
+package xlsx
+
+import (
+	"encoding/xml"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRichText(t *testing.T) {
+	c := qt.New(t)
+
+	mixed := RichText{
+		{Text: "Total: "},
+		{Font: &Font{Bold: true, Color: RGB_Dark_Red, Size: 12, Name: "Arial"}, Text: "42"},
+		{Font: &Font{Italic: true, Underline: true}, Text: " (estimated)"},
+	}
+
+	c.Run("String", func(c *qt.C) {
+		c.Assert(mixed.String(), qt.Equals, "Total: 42 (estimated)")
+		c.Assert(RichText(nil).String(), qt.Equals, "")
+	})
+
+	c.Run("SetRichText", func(c *qt.C) {
+		sheet, err := NewFile().AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		richCell := sheet.Cell(0, 0)
+		c.Assert(richCell.RichText(), qt.IsNil)
+
+		richCell.SetRichText(mixed)
+		c.Assert(richCell.Value, qt.Equals, "Total: 42 (estimated)")
+		c.Assert(richCell.Type(), qt.Equals, CellTypeString)
+		c.Assert(richCell.RichText(), qt.DeepEquals, mixed)
+
+		// Setting any other value drops the rich text.
+		richCell.SetString("Total: 42 (estimated)")
+		c.Assert(richCell.RichText(), qt.IsNil)
+		richCell.SetRichText(mixed)
+		richCell.SetInt(42)
+		c.Assert(richCell.RichText(), qt.IsNil)
+		richCell.SetRichText(mixed)
+		richCell.Value = "changed"
+		c.Assert(richCell.RichText(), qt.IsNil)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetRichText(mixed)
+		sheet.Cell(1, 0).SetRichText(mixed)
+		sheet.Cell(2, 0).SetString("Total: 42 (estimated)")
+
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(parts["xl/sharedStrings.xml"], qt.Contains, `count="2" uniqueCount="2"`)
+		c.Assert(parts["xl/sharedStrings.xml"], qt.Contains, `<si><t>Total: 42 (estimated)</t></si>`)
+
+		file, err = OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		sheet = file.Sheet["Sheet1"]
+		for row := 0; row < 2; row++ {
+			cell := sheet.Cell(row, 0)
+			c.Assert(cell.Value, qt.Equals, "Total: 42 (estimated)")
+			richText := cell.RichText()
+			c.Assert(richText, qt.HasLen, 3)
+			c.Assert(richText[0], qt.DeepEquals, RichTextRun{Text: "Total: "})
+			c.Assert(*richText[1].Font, qt.Equals, *mixed[1].Font)
+			c.Assert(*richText[2].Font, qt.Equals, *mixed[2].Font)
+			c.Assert(richText[2].Text, qt.Equals, " (estimated)")
+		}
+		c.Assert(sheet.Cell(2, 0).RichText(), qt.IsNil)
+	})
+
+	c.Run("ReadThemeColors", func(c *qt.C) {
+		var themeXml xlsxTheme
+		err := xml.Unmarshal([]byte(TEMPLATE_XL_THEME_THEME), &themeXml)
+		c.Assert(err, qt.IsNil)
+		theme := newTheme(themeXml)
+		accent1 := 4
+
+		sst := new(xlsxSST)
+		sst.SI = []xlsxSI{{R: []xlsxR{{
+			RPr: &xlsxRPr{Color: &xlsxColor{Theme: &accent1}, Sz: &xlsxVal{Val: "10.5"}, B: &xlsxVal{Val: "0"}},
+			T:   xlsxT{Text: "accent"},
+		}}}}
+		richText := makeSharedStringRefTable(sst, theme).ResolveSharedRichText(0)
+		c.Assert(richText, qt.HasLen, 1)
+		c.Assert(richText[0].Text, qt.Equals, "accent")
+		c.Assert(*richText[0].Font, qt.Equals, Font{Color: theme.themeColor(4, 0), Size: 10})
+		c.Assert(richText[0].Font.Color, qt.Not(qt.Equals), "")
+	})
+}
@@ -10,19 +10,27 @@ import (
 // Sheet is a high level structure intended to provide user access to
 // the contents of a particular sheet within an XLSX file.
 type Sheet struct {
-	Name            string
-	File            *File
-	Rows            []*Row
-	Cols            *ColStore
-	MaxRow          int
-	MaxCol          int
-	Hidden          bool
-	Selected        bool
-	SheetViews      []SheetView
-	SheetFormat     SheetFormat
-	AutoFilter      *AutoFilter
-	Relations       []Relation
-	DataValidations []*xlsxDataValidation
+	Name               string
+	File               *File
+	Rows               []*Row
+	Cols               *ColStore
+	MaxRow             int
+	MaxCol             int
+	Hidden             bool
+	Selected           bool
+	SheetViews         []SheetView
+	SheetFormat        SheetFormat
+	AutoFilter         *AutoFilter
+	Relations          []Relation
+	DataValidations    []*xlsxDataValidation
+	ConditionalFormats []*ConditionalFormat
+	Pictures           []*Picture
+	Charts             []*Chart
+	Tables             []*Table
+	Protection         *SheetProtection
+	original           *originalSheet
+	part               string // the worksheet part the sheet was read from
+	lazy               *lazySheet
 }
 
 type SheetView struct {
@@ -77,8 +85,28 @@ func (s *Sheet) addRelation(relType RelationshipType, target string, targetMode
 	s.Relations = append(s.Relations, newRel)
 }
 
+// removeRelations removes all of the sheet's relations of the given
+// types.
+func (s *Sheet) removeRelations(relTypes ...RelationshipType) {
+	relations := s.Relations[:0]
+	for _, rel := range s.Relations {
+		keep := true
+		for _, relType := range relTypes {
+			if rel.Type == relType {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			relations = append(relations, rel)
+		}
+	}
+	s.Relations = relations
+}
+
 // Add a new Row to a Sheet
 func (s *Sheet) AddRow() *Row {
+	s.load()
 	row := &Row{Sheet: s}
 	s.Rows = append(s.Rows, row)
 	if len(s.Rows) > s.MaxRow {
@@ -87,8 +115,10 @@ func (s *Sheet) AddRow() *Row {
 	return row
 }
 
-// Add a new Row to a Sheet at a specific index
+// Add a new Row to a Sheet at a specific index.  Only the rows are
+// moved; InsertRows moves the references to them as well.
 func (s *Sheet) AddRowAtIndex(index int) (*Row, error) {
+	s.load()
 	if index < 0 || index > len(s.Rows) {
 		return nil, errors.New("AddRowAtIndex: index out of bounds")
 	}
@@ -107,11 +137,14 @@ func (s *Sheet) AddRowAtIndex(index int) (*Row, error) {
 
 // Add a DataValidation to a range of cells
 func (s *Sheet) AddDataValidation(dv *xlsxDataValidation) {
+	s.load()
 	s.DataValidations = append(s.DataValidations, dv)
 }
 
-// Removes a row at a specific index
+// Removes a row at a specific index.  Only the rows are moved;
+// DeleteRows moves the references to them as well.
 func (s *Sheet) RemoveRowAtIndex(index int) error {
+	s.load()
 	if index < 0 || index >= len(s.Rows) {
 		return errors.New("RemoveRowAtIndex: index out of bounds")
 	}
@@ -134,12 +167,30 @@ func (s *Sheet) maybeAddRow(rowCount int) {
 
 // Make sure we always have as many Rows as we do cells.
 func (s *Sheet) Row(idx int) *Row {
+	s.load()
 	s.maybeAddRow(idx + 1)
 	return s.Rows[idx]
 }
 
+// extent returns the number of rows and columns that the sheet uses.
+// MaxRow and MaxCol are only kept up to date by some of the ways that
+// cells are added, so the rows themselves are counted as well.
+func (s *Sheet) extent() (rows, cols int) {
+	rows, cols = s.MaxRow, s.MaxCol
+	if len(s.Rows) > rows {
+		rows = len(s.Rows)
+	}
+	for _, row := range s.Rows {
+		if row != nil && len(row.Cells) > cols {
+			cols = len(row.Cells)
+		}
+	}
+	return rows, cols
+}
+
 // Return the Col that applies to this Column index, or return nil if no such Col exists
 func (s *Sheet) Col(idx int) *Col {
+	s.load()
 	if s.Cols == nil {
 		panic("trying to use uninitialised ColStore")
 	}
@@ -156,6 +207,7 @@ func (s *Sheet) Col(idx int) *Col {
 // ... would set the variable "cell" to contain a Cell struct
 // containing the data from the field "A1" on the spreadsheet.
 func (s *Sheet) Cell(row, col int) *Cell {
+	s.load()
 
 	// If the user requests a row beyond what we have, then extend.
 	for len(s.Rows) <= row {
@@ -180,6 +232,7 @@ func (s *Sheet) SetColParameters(col *Col) {
 }
 
 func (s *Sheet) setCol(min, max int, setter func(col *Col)) {
+	s.load()
 	if s.Cols == nil {
 		panic("trying to use uninitialised ColStore")
 	}
@@ -413,7 +466,9 @@ func (s *Sheet) makeRows(worksheet *xlsxWorksheet, styles *xlsxStyleSheet, refTa
 				// This is what Excel does as well.
 				fallthrough
 			case CellTypeString:
-				if len(cell.Value) > 0 {
+				if richText := cell.RichText(); richText != nil {
+					xC.V = strconv.Itoa(refTable.AddRichText(richText))
+				} else if len(cell.Value) > 0 {
 					xC.V = strconv.Itoa(refTable.AddString(cell.Value))
 				}
 				xC.T = "s"
@@ -533,8 +588,13 @@ func (s *Sheet) makeXLSXSheet(refTable *RefTable, styles *xlsxStyleSheet, relati
 	s.makeSheetView(worksheet)
 	s.makeSheetFormatPr(worksheet)
 	maxLevelCol := s.makeCols(worksheet, styles)
+	s.makeConditionalFormatting(worksheet, styles)
 	s.makeDataValidations(worksheet)
 	s.makeRows(worksheet, styles, refTable, relations, maxLevelCol)
+	s.makeDrawing(worksheet, relations)
+	s.makeLegacyDrawing(worksheet, relations)
+	s.makeTableParts(worksheet, relations)
+	s.makeSheetProtection(worksheet)
 
 	return worksheet
 }
@@ -0,0 +1,637 @@
+package xlsx
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// InsertRows inserts count empty rows into the sheet before the row
+// with the zero based index at, and moves everything that refers to
+// the rows below, across the whole file, as Excel does: merged cells,
+// hyperlinks to cells, data validations, conditional formats, tables,
+// the autofilter, pictures, charts, defined names and the references
+// in formulas.  Ranges that span the new rows grow to include them.
+// As formulas in any sheet may refer to this one, every sheet of the
+// file is read.
+func (s *Sheet) InsertRows(at, count int) error {
+	return s.shiftCells("InsertRows", count, refShift{at: at, count: count})
+}
+
+// DeleteRows deletes count rows from the sheet, starting with the row
+// with the zero based index at, and moves everything that refers to
+// the rows below, as InsertRows does.  Ranges that include deleted rows
+// shrink, and references to nothing but deleted cells become #REF!.
+func (s *Sheet) DeleteRows(at, count int) error {
+	return s.shiftCells("DeleteRows", count, refShift{at: at, count: -count})
+}
+
+// InsertCols inserts count empty columns into the sheet before the
+// column with the zero based index at, moving the columns to its right
+// along with their widths and styles, and everything that refers to
+// them, as InsertRows does.  Tables that span the new columns get
+// columns of their own.
+func (s *Sheet) InsertCols(at, count int) error {
+	return s.shiftCells("InsertCols", count, refShift{cols: true, at: at, count: count})
+}
+
+// DeleteCols deletes count columns from the sheet, starting with the
+// column with the zero based index at, and moves everything that refers
+// to the columns to their right, as DeleteRows does.
+func (s *Sheet) DeleteCols(at, count int) error {
+	return s.shiftCells("DeleteCols", count, refShift{cols: true, at: at, count: -count})
+}
+
+// shiftCells inserts or deletes rows or columns of the sheet and moves
+// the references to them.  count is the number of rows or columns that
+// op was asked to insert or delete, which must be positive.
+func (s *Sheet) shiftCells(op string, count int, sh refShift) error {
+	maxIndex := formulaMaxRow
+	if sh.cols {
+		maxIndex = formulaMaxCol
+	}
+	switch {
+	case count <= 0:
+		return fmt.Errorf("%s: count must be positive", op)
+	case sh.at < 0 || sh.at > maxIndex:
+		return fmt.Errorf("%s: index out of bounds", op)
+	}
+	if err := s.Load(); err != nil {
+		return err
+	}
+	sheets := []*Sheet{s}
+	if s.File != nil {
+		sheets = s.File.Sheets
+		for _, sheet := range sheets {
+			if err := sheet.Load(); err != nil {
+				return err
+			}
+		}
+	}
+	s.MaxRow, s.MaxCol = s.extent()
+	used := s.MaxRow
+	if sh.cols {
+		used = s.MaxCol
+	}
+	if sh.count > 0 && used > sh.at && used+sh.count > maxIndex+1 {
+		return fmt.Errorf("%s: cells would be moved off the sheet", op)
+	}
+
+	merges := s.takeMerges()
+	if sh.cols {
+		for _, row := range s.Rows {
+			if row != nil {
+				row.Cells = shiftCellSlice(row, sh)
+			}
+		}
+		s.MaxCol = sh.extent(s.MaxCol)
+		s.shiftCols(sh)
+	} else {
+		s.Rows = shiftRowSlice(s, sh)
+		s.MaxRow = sh.extent(s.MaxRow)
+	}
+	s.putMerges(merges, sh)
+	s.shiftTables(sh)
+	s.DataValidations = shiftDataValidations(s.DataValidations, sh)
+	formats := s.ConditionalFormats[:0]
+	for _, cf := range s.ConditionalFormats {
+		if cf.Ref = sh.refs(cf.Ref); cf.Ref != "" {
+			formats = append(formats, cf)
+		}
+	}
+	s.ConditionalFormats = formats
+	if s.AutoFilter != nil {
+		ref := sh.ref(s.AutoFilter.TopLeftCell + cellRangeChar + s.AutoFilter.BottomRightCell)
+		if ref == formulaErrorRef {
+			s.AutoFilter = nil
+		} else {
+			corners := strings.Split(ref, cellRangeChar)
+			s.AutoFilter.TopLeftCell, s.AutoFilter.BottomRightCell = corners[0], corners[len(corners)-1]
+		}
+	}
+	for _, picture := range s.Pictures {
+		picture.Cell = sh.cell(picture.Cell)
+	}
+	for _, chart := range s.Charts {
+		chart.Cell = sh.cell(chart.Cell)
+	}
+	for _, view := range s.SheetViews {
+		if view.Pane != nil && view.Pane.TopLeftCell != "" {
+			view.Pane.TopLeftCell = sh.cell(view.Pane.TopLeftCell)
+		}
+	}
+
+	// Formulas on any sheet can refer to this one.
+	for _, sheet := range sheets {
+		sheet.shiftFormulas(sh, s.Name)
+	}
+	if s.File != nil {
+		for _, chartSheet := range s.File.ChartSheets {
+			if chartSheet.Chart != nil {
+				chartSheet.Chart.shiftSeries(sh, s.Name, chartSheet.Name)
+			}
+		}
+		for _, dn := range s.File.DefinedNames {
+			dn.Data = sh.formula(dn.Data, s.Name, "")
+		}
+	}
+	return nil
+}
+
+// shiftRowSlice returns the rows of the sheet with sh applied.
+func shiftRowSlice(s *Sheet, sh refShift) []*Row {
+	if sh.at >= len(s.Rows) {
+		return s.Rows
+	}
+	if sh.count < 0 {
+		end := sh.at - sh.count
+		if end > len(s.Rows) {
+			end = len(s.Rows)
+		}
+		return append(s.Rows[:sh.at], s.Rows[end:]...)
+	}
+	rows := make([]*Row, 0, len(s.Rows)+sh.count)
+	rows = append(rows, s.Rows[:sh.at]...)
+	for i := 0; i < sh.count; i++ {
+		rows = append(rows, &Row{Sheet: s})
+	}
+	return append(rows, s.Rows[sh.at:]...)
+}
+
+// shiftCellSlice returns the cells of the row with sh applied.
+func shiftCellSlice(row *Row, sh refShift) []*Cell {
+	if sh.at >= len(row.Cells) {
+		return row.Cells
+	}
+	if sh.count < 0 {
+		end := sh.at - sh.count
+		if end > len(row.Cells) {
+			end = len(row.Cells)
+		}
+		return append(row.Cells[:sh.at], row.Cells[end:]...)
+	}
+	cells := make([]*Cell, 0, len(row.Cells)+sh.count)
+	cells = append(cells, row.Cells[:sh.at]...)
+	for i := 0; i < sh.count; i++ {
+		cells = append(cells, NewCell(row))
+	}
+	return append(cells, row.Cells[sh.at:]...)
+}
+
+// mergedRange is the range of a merged cell, zero based and inclusive.
+type mergedRange struct {
+	firstRow, firstCol, lastRow, lastCol int
+}
+
+// takeMerges returns the ranges of the merged cells of the sheet, and
+// unmerges them.
+func (s *Sheet) takeMerges() []mergedRange {
+	var merges []mergedRange
+	for y, row := range s.Rows {
+		if row == nil {
+			continue
+		}
+		for x, cell := range row.Cells {
+			if cell == nil || cell.HMerge == 0 && cell.VMerge == 0 {
+				continue
+			}
+			merges = append(merges, mergedRange{y, x, y + cell.VMerge, x + cell.HMerge})
+			cell.HMerge, cell.VMerge = 0, 0
+		}
+	}
+	return merges
+}
+
+// putMerges merges the cells of the ranges with sh applied.  A range
+// whose first row or column is deleted starts at the next one.
+func (s *Sheet) putMerges(merges []mergedRange, sh refShift) {
+	for _, m := range merges {
+		var ok bool
+		if sh.cols {
+			m.firstCol, m.lastCol, ok = sh.span(m.firstCol, m.lastCol)
+		} else {
+			m.firstRow, m.lastRow, ok = sh.span(m.firstRow, m.lastRow)
+		}
+		if !ok {
+			continue
+		}
+		if m.firstRow < len(s.Rows) && s.Rows[m.firstRow] == nil {
+			s.Rows[m.firstRow] = &Row{Sheet: s}
+		}
+		s.Cell(m.firstRow, m.firstCol).Merge(m.lastCol-m.firstCol, m.lastRow-m.firstRow)
+	}
+}
+
+// shiftCols moves the widths and styles of the columns of the sheet.
+func (s *Sheet) shiftCols(sh refShift) {
+	if s.Cols == nil {
+		return
+	}
+	cols := &ColStore{}
+	s.Cols.ForEach(func(_ int, col *Col) {
+		first, last, ok := sh.span(col.Min-1, col.Max-1)
+		if !ok || first > formulaMaxCol {
+			return
+		}
+		if last > formulaMaxCol {
+			last = formulaMaxCol
+		}
+		col.Min, col.Max = first+1, last+1
+		cols.Add(col)
+	})
+	s.Cols = cols
+}
+
+// shiftTables moves the tables of the sheet, and adds or removes their
+// columns.  Tables whose rows or columns are all deleted are removed.
+func (s *Sheet) shiftTables(sh refShift) {
+	tables := s.Tables[:0]
+	for _, table := range s.Tables {
+		minCol, minRow, maxCol, maxRow, err := table.bounds()
+		if err != nil {
+			tables = append(tables, table)
+			continue
+		}
+		var ok bool
+		if !sh.cols {
+			if minRow, maxRow, ok = sh.span(minRow, maxRow); ok {
+				table.Ref = cellRangeRef(minCol, minRow, maxCol, maxRow)
+				tables = append(tables, table)
+			}
+			continue
+		}
+		first, last, ok := sh.span(minCol, maxCol)
+		if !ok {
+			continue
+		}
+		if sh.count > 0 && sh.at > minCol && sh.at <= maxCol {
+			added := make([]TableColumn, sh.count)
+			for i := range added {
+				name := ""
+				for n := len(table.Columns) + 1; name == "" || table.ColumnIndex(name) >= 0; n++ {
+					name = "Column" + strconv.Itoa(n)
+				}
+				added[i] = TableColumn{Name: name}
+				table.Columns = append(table.Columns, added[i])
+				if table.ShowHeaderRow {
+					s.Cell(minRow, sh.at+i).SetString(name)
+				}
+			}
+			table.Columns = table.Columns[:len(table.Columns)-sh.count]
+			i := sh.at - minCol
+			table.Columns = append(table.Columns[:i], append(added, table.Columns[i:]...)...)
+		} else if sh.count < 0 {
+			lo, hi := sh.at, sh.at-sh.count-1
+			if lo < minCol {
+				lo = minCol
+			}
+			if hi > maxCol {
+				hi = maxCol
+			}
+			if lo <= hi {
+				table.Columns = append(table.Columns[:lo-minCol], table.Columns[hi-minCol+1:]...)
+			}
+		}
+		table.Ref = cellRangeRef(first, minRow, last, maxRow)
+		tables = append(tables, table)
+	}
+	s.Tables = tables
+}
+
+// shiftDataValidations returns the data validations with the ranges
+// they apply to moved by sh, leaving out those with no range left.
+func shiftDataValidations(validations []*xlsxDataValidation, sh refShift) []*xlsxDataValidation {
+	kept := validations[:0]
+	for _, dv := range validations {
+		if dv.Sqref = sh.refs(dv.Sqref); dv.Sqref != "" {
+			kept = append(kept, dv)
+		}
+	}
+	return kept
+}
+
+// shiftFormulas moves the references to cells of the sheet called
+// sheetName in the formulas of this sheet, and in the data validations,
+// conditional formats, hyperlinks and charts that hold formulas.
+func (s *Sheet) shiftFormulas(sh refShift, sheetName string) {
+	for _, row := range s.Rows {
+		if row == nil {
+			continue
+		}
+		for _, cell := range row.Cells {
+			if cell == nil {
+				continue
+			}
+			if cell.formula != "" {
+				cell.formula = sh.formula(cell.formula, sheetName, s.Name)
+			}
+			if dv := cell.DataValidation; dv != nil {
+				dv.Formula1 = sh.formula(dv.Formula1, sheetName, s.Name)
+				dv.Formula2 = sh.formula(dv.Formula2, sheetName, s.Name)
+			}
+			if link := cell.Hyperlink.Link; strings.HasPrefix(link, "#") {
+				cell.Hyperlink.Link = "#" + sh.formula(link[1:], sheetName, s.Name)
+			}
+		}
+	}
+	// Hyperlinks are written with the relation whose target is the link.
+	for i, rel := range s.Relations {
+		if rel.Type == RelationshipTypeHyperlink && strings.HasPrefix(rel.Target, "#") {
+			s.Relations[i].Target = "#" + sh.formula(rel.Target[1:], sheetName, s.Name)
+		}
+	}
+	for _, dv := range s.DataValidations {
+		dv.Formula1 = sh.formula(dv.Formula1, sheetName, s.Name)
+		dv.Formula2 = sh.formula(dv.Formula2, sheetName, s.Name)
+	}
+	for _, cf := range s.ConditionalFormats {
+		for _, rule := range cf.Rules {
+			for i, formula := range rule.Formulas {
+				rule.Formulas[i] = sh.formula(formula, sheetName, s.Name)
+			}
+		}
+	}
+	for _, chart := range s.Charts {
+		chart.shiftSeries(sh, sheetName, s.Name)
+	}
+}
+
+// shiftSeries moves the references to cells of the sheet called
+// sheetName in the series of the chart.
+func (c *Chart) shiftSeries(sh refShift, sheetName, current string) {
+	for i := range c.Series {
+		series := &c.Series[i]
+		series.Categories = sh.formula(series.Categories, sheetName, current)
+		series.Values = sh.formula(series.Values, sheetName, current)
+	}
+}
+
+// refShift describes rows or columns that are inserted into a sheet or
+// deleted from it, and moves the references to them.
+type refShift struct {
+	cols bool // columns rather than rows
+	// at is the zero based index of the first row or column that is
+	// inserted or deleted, and count the number that are inserted,
+	// or if it is negative, deleted.
+	at, count int
+	// repeat is the number of rows or columns before at that inserted
+	// ones are copies of.  Ranges that end in them grow to include the
+	// copies, as ranges that span at do.
+	repeat int
+}
+
+// index returns the index that a row or column moves to, and false if
+// it is deleted, in which case the index is that of the row or column
+// that takes its place.
+func (sh refShift) index(i int) (int, bool) {
+	switch {
+	case i < sh.at:
+		return i, true
+	case sh.count > 0:
+		return i + sh.count, true
+	case i < sh.at-sh.count:
+		return sh.at, false
+	}
+	return i + sh.count, true
+}
+
+// span returns the span that the rows or columns from first to last
+// become.  A span grows when rows or columns are inserted inside it,
+// and shrinks when some of its own are deleted; ok is false if all of
+// them are.
+func (sh refShift) span(first, last int) (int, int, bool) {
+	if sh.count > 0 {
+		switch {
+		case first >= sh.at:
+			return first + sh.count, last + sh.count, true
+		case last >= sh.at-sh.repeat:
+			return first, last + sh.count, true
+		}
+		return first, last, true
+	}
+	first, _ = sh.index(first)
+	last, ok := sh.index(last)
+	if !ok {
+		last = sh.at - 1
+	}
+	if last < first {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// extent returns the number of rows or columns that a sheet that uses n
+// of them uses once sh is applied.
+func (sh refShift) extent(n int) int {
+	if n <= sh.at {
+		return n
+	}
+	if n+sh.count < sh.at {
+		return sh.at
+	}
+	return n + sh.count
+}
+
+// cell returns the reference to the cell that a cell moves to, or if it
+// is deleted, the cell that takes its place.
+func (sh refShift) cell(ref string) string {
+	x, y, err := GetCoordsFromCellIDString(ref)
+	if err != nil {
+		return ref
+	}
+	if sh.cols {
+		if x, _ = sh.index(x); x > formulaMaxCol {
+			x = formulaMaxCol
+		}
+	} else if y, _ = sh.index(y); y > formulaMaxRow {
+		y = formulaMaxRow
+	}
+	return GetCellIDStringFromCoords(x, y)
+}
+
+// refCornerRegexp matches a corner of a reference: the column, the
+// row, or both, each of which may be fixed with a $.
+var refCornerRegexp = regexp.MustCompile(`^(\$?)([A-Za-z]{0,3})(\$?)([0-9]*)$`)
+
+// ref returns a reference such as "A1", "$B$2:C3", "A:C" or "2:5" with
+// sh applied, or #REF! if all of the cells it refers to are deleted.
+// Whole columns don't move when rows are inserted or deleted, nor whole
+// rows when columns are.
+func (sh refShift) ref(ref string) string {
+	corners := strings.SplitN(ref, cellRangeChar, 2)
+	parts := make([][]string, len(corners))
+	for i, corner := range corners {
+		m := refCornerRegexp.FindStringSubmatch(corner)
+		if m == nil || m[2] == "" && m[4] == "" {
+			return ref
+		}
+		parts[i] = m
+	}
+	first, last := parts[0], parts[len(parts)-1]
+	part, maxIndex := 4, formulaMaxRow
+	if sh.cols {
+		part, maxIndex = 2, formulaMaxCol
+	}
+	if first[part] == "" || last[part] == "" {
+		return ref
+	}
+	from, to, ok := sh.span(refIndex(first[part], sh.cols), refIndex(last[part], sh.cols))
+	if !ok || from > maxIndex {
+		return formulaErrorRef
+	}
+	if len(parts) == 1 {
+		// A single cell doesn't grow.
+		to = from
+	}
+	if to > maxIndex {
+		to = maxIndex
+	}
+	first[part] = refIndexString(from, sh.cols)
+	last[part] = refIndexString(to, sh.cols)
+	for i, m := range parts {
+		corners[i] = m[1] + strings.ToUpper(m[2]) + m[3] + m[4]
+	}
+	return strings.Join(corners, cellRangeChar)
+}
+
+// refIndex returns the zero based index of the column letters or the
+// row number s.
+func refIndex(s string, col bool) int {
+	if col {
+		return ColLettersToIndex(strings.ToUpper(s))
+	}
+	n, _ := strconv.Atoi(s)
+	return n - 1
+}
+
+func refIndexString(i int, col bool) string {
+	if col {
+		return ColIndexToLetters(i)
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// refs applies sh to a space separated list of references, such as the
+// sqref of a data validation, leaving out those to deleted cells and
+// writing ranges of a single cell as the cell.  Single cells are ranges
+// here, which grow with repeated rows or columns.
+func (sh refShift) refs(refs string) string {
+	var kept []string
+	for _, ref := range strings.Fields(refs) {
+		if !strings.Contains(ref, cellRangeChar) {
+			ref += cellRangeChar + ref
+		}
+		ref = sh.ref(ref)
+		if ref == formulaErrorRef {
+			continue
+		}
+		if corners := strings.Split(ref, cellRangeChar); len(corners) == 2 && corners[0] == corners[1] {
+			ref = corners[0]
+		}
+		kept = append(kept, ref)
+	}
+	return strings.Join(kept, " ")
+}
+
+// formula returns formula with sh applied to its references to the
+// sheet called sheetName.  References without a sheet name are to the
+// sheet called current.
+func (sh refShift) formula(formula, sheetName, current string) string {
+	return rewriteFormulaRefs(formula, func(prefix, sheet, ref string) string {
+		if sheet == "" {
+			sheet = current
+		}
+		if strings.EqualFold(sheet, sheetName) {
+			ref = sh.ref(ref)
+		}
+		return prefix + ref
+	})
+}
+
+// rewriteFormulaRefs returns formula with each reference to cells
+// replaced by what fn returns for it.  fn is given the sheet name that
+// the reference starts with, if any, as it is written, such as "'My
+// Sheet'!", and as it is meant, and the rest of the reference.  The
+// rest of the formula is kept as it is, and text that isn't understood
+// is skipped over.
+func rewriteFormulaRefs(formula string, fn func(prefix, sheet, ref string) string) string {
+	if formula == "" {
+		return formula
+	}
+	var b strings.Builder
+	s := formula
+	for len(s) > 0 {
+		n := 1
+		switch c := s[0]; {
+		case c == '"':
+			n = formulaStringLength(s)
+		case c == '[' || c == '{':
+			n = formulaBracketLength(s)
+		case c == '#':
+			for _, l := range formulaErrorLiterals {
+				if strings.HasPrefix(strings.ToUpper(s), l) {
+					n = len(l)
+					break
+				}
+			}
+		case strings.IndexByte(" \t\r\n()+-*/^&=<>%,;!:", c) >= 0:
+		default:
+			token, length, err := nextFormulaOperand(s)
+			if err != nil {
+				break
+			}
+			n = length
+			if token.kind != formulaTokenRef {
+				break
+			}
+			prefix := formulaSheetPrefixRegexp.FindString(s)
+			b.WriteString(fn(prefix, token.sheet, s[len(prefix):n]))
+			s = s[n:]
+			continue
+		}
+		b.WriteString(s[:n])
+		s = s[n:]
+	}
+	return b.String()
+}
+
+// formulaStringLength returns the length of the string literal at the
+// start of s, including its quotes.
+func formulaStringLength(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] != '"' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// formulaBracketLength returns the length of the structured reference
+// or array constant at the start of s, up to its closing bracket or
+// brace.
+func formulaBracketLength(s string) int {
+	open, close := s[0], byte(']')
+	if open == '{' {
+		close = '}'
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case '"':
+			i += formulaStringLength(s[i:]) - 1
+		}
+	}
+	return len(s)
+}
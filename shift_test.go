@@ -0,0 +1,199 @@
+package xlsx
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestShift(t *testing.T) {
+	c := qt.New(t)
+
+	// shiftFile returns a file with a sheet of values with a merged
+	// cell, a validation, a conditional format, a table, an autofilter
+	// and a picture, a second sheet with formulas that refer to the
+	// first, and a defined name.
+	shiftFile := func(c *qt.C) (*Sheet, *Sheet) {
+		file := NewFile()
+		data, err := file.AddSheet("My Data")
+		c.Assert(err, qt.IsNil)
+		for y := 0; y < 5; y++ {
+			for x := 0; x < 4; x++ {
+				data.Cell(y, x).SetInt(y*10 + x)
+			}
+		}
+		data.Cell(0, 0).Merge(1, 1)
+		data.Cell(1, 3).Merge(0, 2)
+		data.Cell(4, 0).SetFormula("SUM(A1:A4)+$B$2")
+		data.Cell(4, 1).SetFormula(`"B3"&B3&Other!B3`)
+		data.AddDataValidation(NewDataValidation(1, 1, 3, 1, false))
+		data.AddConditionalFormat("C2:C3 D4", NewExpressionRule("$C2>A$1", nil))
+		_, err = data.AddTable("Values", "A1:C4", []string{"A", "B", "C"}, "", false)
+		c.Assert(err, qt.IsNil)
+		data.AutoFilter = &AutoFilter{TopLeftCell: "A1", BottomRightCell: "C4"}
+		data.Pictures = []*Picture{{Cell: "D3"}}
+
+		other, err := file.AddSheet("Other")
+		c.Assert(err, qt.IsNil)
+		other.Cell(0, 0).SetFormula("'My Data'!B2*2+SUM('my data'!A:A,'My Data'!2:3)")
+		other.Cell(0, 1).SetFormula("B3")
+		other.Cell(0, 2).SetHyperlink("#'My Data'!C3", "Middle", "")
+		file.DefinedNames = append(file.DefinedNames, &xlsxDefinedName{Name: "Middle", Data: "'My Data'!$B$2:$C$3"})
+		return data, other
+	}
+
+	c.Run("InsertRows", func(c *qt.C) {
+		data, other := shiftFile(c)
+		c.Assert(data.InsertRows(2, 2), qt.IsNil)
+		c.Assert(data.MaxRow, qt.Equals, 7)
+		c.Assert(data.Cell(1, 0).Value, qt.Equals, "10")
+		c.Assert(data.Cell(2, 0).Value, qt.Equals, "")
+		c.Assert(data.Cell(4, 0).Value, qt.Equals, "20")
+		c.Assert(data.Rows[2].Sheet, qt.Equals, data)
+		c.Assert(data.Cell(0, 0).VMerge, qt.Equals, 1)
+		c.Assert(data.Cell(1, 3).VMerge, qt.Equals, 4)
+		c.Assert(data.Cell(6, 0).Formula(), qt.Equals, "SUM(A1:A6)+$B$2")
+		c.Assert(data.Cell(6, 1).Formula(), qt.Equals, `"B3"&B5&Other!B3`)
+		c.Assert(data.DataValidations[0].Sqref, qt.Equals, "B2:B6")
+		c.Assert(data.ConditionalFormats[0].Ref, qt.Equals, "C2:C5 D6")
+		c.Assert(data.ConditionalFormats[0].Rules[0].Formulas, qt.DeepEquals, []string{"$C2>A$1"})
+		c.Assert(data.Tables[0].Ref, qt.Equals, "A1:C6")
+		c.Assert(*data.AutoFilter, qt.Equals, AutoFilter{TopLeftCell: "A1", BottomRightCell: "C6"})
+		c.Assert(data.Pictures[0].Cell, qt.Equals, "D5")
+
+		c.Assert(other.Cell(0, 0).Formula(), qt.Equals, "'My Data'!B2*2+SUM('my data'!A:A,'My Data'!2:5)")
+		c.Assert(other.Cell(0, 1).Formula(), qt.Equals, "B3")
+		c.Assert(other.Cell(0, 2).Hyperlink.Link, qt.Equals, "#'My Data'!C5")
+		c.Assert(data.File.DefinedNames[0].Data, qt.Equals, "'My Data'!$B$2:$C$5")
+
+		// The moved references are written.
+		parts, err := data.File.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		read := file.Sheet["My Data"]
+		c.Assert(read.Cell(1, 3).VMerge, qt.Equals, 4)
+		c.Assert(file.Sheet["Other"].Cell(0, 2).Hyperlink.Link, qt.Equals, "#'My Data'!C5")
+		c.Assert(read.Tables[0].Ref, qt.Equals, "A1:C6")
+		c.Assert(*read.AutoFilter, qt.Equals, AutoFilter{TopLeftCell: "A1", BottomRightCell: "C6"})
+	})
+
+	c.Run("DeleteRows", func(c *qt.C) {
+		data, other := shiftFile(c)
+		c.Assert(data.DeleteRows(1, 2), qt.IsNil)
+		c.Assert(data.MaxRow, qt.Equals, 3)
+		c.Assert(data.Cell(1, 0).Value, qt.Equals, "30")
+		c.Assert(data.Cell(0, 0).VMerge, qt.Equals, 0)
+		c.Assert(data.Cell(0, 0).HMerge, qt.Equals, 1)
+		c.Assert(data.Cell(2, 0).Formula(), qt.Equals, "SUM(A1:A2)+#REF!")
+		c.Assert(data.Cell(2, 1).Formula(), qt.Equals, `"B3"&#REF!&Other!B3`)
+		c.Assert(data.DataValidations[0].Sqref, qt.Equals, "B2")
+		c.Assert(data.ConditionalFormats[0].Ref, qt.Equals, "D2")
+		c.Assert(data.Tables[0].Ref, qt.Equals, "A1:C2")
+		c.Assert(data.AutoFilter.BottomRightCell, qt.Equals, "C2")
+		c.Assert(data.Pictures[0].Cell, qt.Equals, "D2")
+
+		c.Assert(other.Cell(0, 0).Formula(), qt.Equals, "'My Data'!#REF!*2+SUM('my data'!A:A,'My Data'!#REF!)")
+		c.Assert(other.Cell(0, 2).Hyperlink.Link, qt.Equals, "#'My Data'!#REF!")
+		c.Assert(data.File.DefinedNames[0].Data, qt.Equals, "'My Data'!#REF!")
+
+		// Validations, formats and tables with nothing left are removed.
+		c.Assert(data.DeleteRows(0, 2), qt.IsNil)
+		c.Assert(data.DataValidations, qt.HasLen, 0)
+		c.Assert(data.ConditionalFormats, qt.HasLen, 0)
+		c.Assert(data.Tables, qt.HasLen, 0)
+		c.Assert(data.AutoFilter, qt.IsNil)
+	})
+
+	c.Run("InsertCols", func(c *qt.C) {
+		data, other := shiftFile(c)
+		data.Cols.Add(&Col{Min: 2, Max: 3, Width: 20})
+		c.Assert(data.InsertCols(1, 1), qt.IsNil)
+		c.Assert(data.MaxCol, qt.Equals, 5)
+		c.Assert(data.Cell(1, 0).Value, qt.Equals, "10")
+		c.Assert(data.Cell(1, 1).Value, qt.Equals, "")
+		c.Assert(data.Cell(1, 2).Value, qt.Equals, "11")
+		c.Assert(data.Cell(0, 0).HMerge, qt.Equals, 2)
+		c.Assert(data.Col(1), qt.IsNil)
+		c.Assert(data.Col(2).Width, qt.Equals, 20.0)
+		c.Assert(data.Col(3).Width, qt.Equals, 20.0)
+		c.Assert(data.Cell(4, 0).Formula(), qt.Equals, "SUM(A1:A4)+$C$2")
+		c.Assert(data.ConditionalFormats[0].Ref, qt.Equals, "D2:D3 E4")
+		c.Assert(data.ConditionalFormats[0].Rules[0].Formulas, qt.DeepEquals, []string{"$D2>A$1"})
+		c.Assert(data.Pictures[0].Cell, qt.Equals, "E3")
+
+		// The table gets a column of its own.
+		table := data.Tables[0]
+		c.Assert(table.Ref, qt.Equals, "A1:D4")
+		names := []string{}
+		for _, column := range table.Columns {
+			names = append(names, column.Name)
+		}
+		c.Assert(names, qt.DeepEquals, []string{"A", "Column4", "B", "C"})
+		c.Assert(data.Cell(0, 1).Value, qt.Equals, "Column4")
+
+		c.Assert(other.Cell(0, 0).Formula(), qt.Equals, "'My Data'!C2*2+SUM('my data'!A:A,'My Data'!2:3)")
+		c.Assert(data.File.DefinedNames[0].Data, qt.Equals, "'My Data'!$C$2:$D$3")
+	})
+
+	c.Run("DeleteCols", func(c *qt.C) {
+		data, other := shiftFile(c)
+		c.Assert(data.DeleteCols(0, 1), qt.IsNil)
+		c.Assert(data.MaxCol, qt.Equals, 3)
+		c.Assert(data.Cell(1, 0).Value, qt.Equals, "11")
+		c.Assert(data.Cell(0, 0).HMerge, qt.Equals, 0)
+		c.Assert(data.Cell(0, 0).VMerge, qt.Equals, 1)
+		c.Assert(data.Cell(4, 0).Formula(), qt.Equals, `"B3"&A3&Other!B3`)
+		c.Assert(data.ConditionalFormats[0].Rules[0].Formulas, qt.DeepEquals, []string{"$B2>#REF!"})
+		c.Assert(data.Tables[0].Ref, qt.Equals, "A1:B4")
+		c.Assert(data.Tables[0].Columns, qt.HasLen, 2)
+		c.Assert(data.Tables[0].Columns[0].Name, qt.Equals, "B")
+		c.Assert(other.Cell(0, 0).Formula(), qt.Equals, "'My Data'!A2*2+SUM('my data'!#REF!,'My Data'!2:3)")
+	})
+
+	c.Run("Refs", func(c *qt.C) {
+		insert := refShift{at: 2, count: 3}
+		remove := refShift{cols: true, at: 1, count: -2}
+		for _, test := range []struct {
+			sh       refShift
+			ref, out string
+		}{
+			{insert, "A2", "A2"},
+			{insert, "a3", "A6"},
+			{insert, "$A$3:B$10", "$A$6:B$13"},
+			{insert, "B:C", "B:C"},
+			{insert, "1:4", "1:7"},
+			{insert, "A1048573:A1048575", "A1048576:A1048576"},
+			{insert, "A1048575", "#REF!"},
+			{insert, "XFD1", "XFD1"},
+			{insert, "Name", "Name"},
+			{remove, "A1", "A1"},
+			{remove, "B1", "#REF!"},
+			{remove, "A1:C1", "A1:A1"},
+			{remove, "B1:E1", "B1:C1"},
+			{remove, "2:3", "2:3"},
+			{remove, "$D:$D", "$B:$B"},
+		} {
+			c.Assert(test.sh.ref(test.ref), qt.Equals, test.out, qt.Commentf("%+v", test))
+		}
+
+		// Ranges that end in repeated rows grow to include the copies.
+		repeat := refShift{at: 3, count: 2, repeat: 1}
+		c.Assert(repeat.formula("SUM(B3:B3)+SUM(B1:B3)+B3+B4", "Sheet1", "Sheet1"), qt.Equals, "SUM(B3:B5)+SUM(B1:B5)+B3+B6")
+		c.Assert(repeat.refs("C3 C2 C4:D4"), qt.Equals, "C3:C5 C2 C6:D6")
+		c.Assert(remove.formula(`IF([@Qty]>C1,{1,"C1"},D1)&Sheet2!C1`, "Sheet1", "Sheet1"), qt.Equals, `IF([@Qty]>#REF!,{1,"C1"},B1)&Sheet2!C1`)
+	})
+
+	c.Run("Errors", func(c *qt.C) {
+		data, _ := shiftFile(c)
+		c.Assert(data.InsertRows(0, 0), qt.ErrorMatches, `InsertRows: count must be positive`)
+		c.Assert(data.InsertRows(0, -2), qt.ErrorMatches, `InsertRows: count must be positive`)
+		c.Assert(data.DeleteRows(0, -1), qt.ErrorMatches, `DeleteRows: count must be positive`)
+		c.Assert(data.InsertCols(0, -1), qt.ErrorMatches, `InsertCols: count must be positive`)
+		c.Assert(data.DeleteCols(0, -1), qt.ErrorMatches, `DeleteCols: count must be positive`)
+		c.Assert(data.Cell(1, 1).Value, qt.Equals, "11")
+		c.Assert(data.DeleteCols(-1, 1), qt.ErrorMatches, `DeleteCols: index out of bounds`)
+		c.Assert(data.InsertCols(0, 16381), qt.ErrorMatches, `InsertCols: cells would be moved off the sheet`)
+		c.Assert(data.InsertRows(10, 1048000), qt.IsNil)
+	})
+}
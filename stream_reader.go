@@ -0,0 +1,458 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// StreamReader is the reading counterpart of StreamFile.  It walks
+// through a worksheet with an xml.Decoder and hands back one Row at a
+// time, so that arbitrarily large sheets can be read in constant
+// memory.  Shared strings and styles are only loaded once a cell
+// actually refers to them.
+//
+// Directions:
+// 1. Create a StreamReader with NewStreamReader() or NewStreamReaderForPath().
+// 2. Call NextSheet() to move to the first sheet.  Sheets are visited in workbook order.
+// 3. Call Read() until it returns io.EOF, which marks the end of the current sheet.
+// 4. Call NextSheet() to proceed to the next sheet, or Close() to finish.
+//
+// Because rows are handed back as soon as they are decoded, anything
+// that is stored after the sheetData element (merged cells,
+// hyperlinks, data validations) is not available to a StreamReader.
+type StreamReader struct {
+	file          *File
+	closer        io.Closer
+	workbook      *xlsxWorkbook
+	sheetXMLMap   map[string]string
+	sheets        []xlsxSheet
+	sharedStrings *zip.File
+	styles        *zip.File
+	stylesLoaded  bool
+	currentSheet  *streamReaderSheet
+	err           error
+}
+
+type streamReaderSheet struct {
+	// index is the position of the sheet in StreamReader.sheets, which starts at 0
+	index int
+	sheet *Sheet
+	// part is the name of the worksheet part in the zip file
+	part string
+	rc   io.ReadCloser
+	// The decoder positioned somewhere inside this sheet's XML
+	decoder *xml.Decoder
+	// The number of rows that have been returned so far
+	rowCount int
+	// A row that has been decoded, but is waiting for the empty rows
+	// that precede it to be returned first
+	pending        *Row
+	pendingIndex   int
+	sharedFormulas map[int]sharedFormula
+	done           bool
+}
+
+var (
+	MissingSharedStringsError = errors.New("cell refers to a shared string, but the file has no shared strings table")
+)
+
+// NewStreamReaderForPath opens the XLSX file with the given name and
+// returns a StreamReader for it.  The file stays open until Close is
+// called.
+func NewStreamReaderForPath(path string) (*StreamReader, error) {
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	sr, err := newStreamReader(&z.Reader)
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+	sr.closer = z
+	return sr, nil
+}
+
+// NewStreamReader returns a StreamReader for the XLSX file that can
+// be read from r, which is size bytes long.
+func NewStreamReader(r io.ReaderAt, size int64) (*StreamReader, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamReader(z)
+}
+
+func newStreamReader(r *zip.Reader) (*StreamReader, error) {
+	var workbook, workbookRels, themeFile *zip.File
+	sr := &StreamReader{file: NewFile()}
+	worksheets := make(map[string]*zip.File, len(r.File))
+	for _, v := range r.File {
+		switch v.Name {
+		case "xl/sharedStrings.xml":
+			sr.sharedStrings = v
+		case "xl/workbook.xml":
+			workbook = v
+		case "xl/_rels/workbook.xml.rels":
+			workbookRels = v
+		case "xl/styles.xml":
+			sr.styles = v
+		case "xl/theme/theme1.xml":
+			themeFile = v
+		default:
+			if len(v.Name) > 17 && v.Name[0:13] == "xl/worksheets" && v.Name[len(v.Name)-5:] != ".rels" {
+				worksheets[v.Name[14:len(v.Name)-4]] = v
+			}
+		}
+	}
+	if workbookRels == nil {
+		return nil, fmt.Errorf("xl/_rels/workbook.xml.rels not found in input xlsx.")
+	}
+	if workbook == nil {
+		return nil, fmt.Errorf("xl/workbook.xml not found in input xlsx.")
+	}
+	sheetXMLMap, err := readWorkbookRelationsFromZipFile(workbookRels)
+	if err != nil {
+		return nil, err
+	}
+	sr.sheetXMLMap = sheetXMLMap
+	sr.file.worksheets = worksheets
+
+	rc, err := workbook.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	sr.workbook = new(xlsxWorkbook)
+	if err := xml.NewDecoder(rc).Decode(sr.workbook); err != nil {
+		return nil, err
+	}
+	sr.file.Date1904 = sr.workbook.WorkbookPr.Date1904
+
+	// As with ReadZipReader, chartsheets and other sheets without
+	// a worksheet part are skipped.
+	for _, sheet := range sr.workbook.Sheets.Sheet {
+		if worksheetFileForSheet(sheet, worksheets, sheetXMLMap) != nil {
+			sr.sheets = append(sr.sheets, sheet)
+		}
+	}
+	if len(sr.sheets) == 0 {
+		return nil, fmt.Errorf("Input xlsx contains no worksheets.")
+	}
+
+	if themeFile != nil {
+		theme, err := readThemeFromZipFile(themeFile)
+		if err != nil {
+			return nil, err
+		}
+		sr.file.theme = theme
+	}
+	return sr, nil
+}
+
+// SheetNames returns the names of the sheets that can be read, in the
+// order that NextSheet will visit them.
+func (sr *StreamReader) SheetNames() []string {
+	names := make([]string, len(sr.sheets))
+	for i, sheet := range sr.sheets {
+		names[i] = sheet.Name
+	}
+	return names
+}
+
+// Sheet returns the Sheet that is currently being read.  The Sheet
+// carries the sheet level metadata (name, columns, format) but its
+// Rows are never populated, those are only available through Read.
+func (sr *StreamReader) Sheet() *Sheet {
+	if sr.currentSheet == nil {
+		return nil
+	}
+	return sr.currentSheet.sheet
+}
+
+// NextSheet will switch to the next sheet. Sheets are visited in the
+// same order they are listed in the workbook. Once you leave a sheet,
+// you cannot return to it.
+func (sr *StreamReader) NextSheet() error {
+	if sr.err != nil {
+		return sr.err
+	}
+	index := 0
+	if sr.currentSheet != nil {
+		index = sr.currentSheet.index + 1
+		if index >= len(sr.sheets) {
+			sr.err = AlreadyOnLastSheetError
+			return AlreadyOnLastSheetError
+		}
+		sr.currentSheet.rc.Close()
+		sr.currentSheet = nil
+	}
+	rawSheet := sr.sheets[index]
+	f := worksheetFileForSheet(rawSheet, sr.file.worksheets, sr.sheetXMLMap)
+	rc, err := f.Open()
+	if err != nil {
+		sr.err = wrapReadError(err, f.Name, rawSheet.Name)
+		return sr.err
+	}
+	sheet := &Sheet{
+		Name:   rawSheet.Name,
+		File:   sr.file,
+		Cols:   &ColStore{},
+		Hidden: rawSheet.State == sheetStateHidden || rawSheet.State == sheetStateVeryHidden,
+	}
+	sr.currentSheet = &streamReaderSheet{
+		index:          index,
+		sheet:          sheet,
+		part:           f.Name,
+		rc:             rc,
+		decoder:        xml.NewDecoder(rc),
+		sharedFormulas: map[int]sharedFormula{},
+	}
+	if err := sr.readSheetPrologue(); err != nil {
+		sr.err = wrapReadError(err, f.Name, rawSheet.Name)
+		return sr.err
+	}
+	return nil
+}
+
+// readSheetPrologue advances the decoder of the current sheet to the
+// start of its sheetData element, picking up the sheet level metadata
+// that is stored before the rows.
+func (sr *StreamReader) readSheetPrologue() error {
+	ss := sr.currentSheet
+	for {
+		token, err := ss.decoder.Token()
+		if err == io.EOF {
+			ss.done = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "sheetViews":
+			var sheetViews xlsxSheetViews
+			if err := ss.decoder.DecodeElement(&sheetViews, &start); err != nil {
+				return err
+			}
+			ss.sheet.SheetViews = readSheetViews(sheetViews)
+		case "sheetFormatPr":
+			var sheetFormatPr xlsxSheetFormatPr
+			if err := ss.decoder.DecodeElement(&sheetFormatPr, &start); err != nil {
+				return err
+			}
+			ss.sheet.SheetFormat.DefaultColWidth = sheetFormatPr.DefaultColWidth
+			ss.sheet.SheetFormat.DefaultRowHeight = sheetFormatPr.DefaultRowHeight
+			ss.sheet.SheetFormat.OutlineLevelCol = sheetFormatPr.OutlineLevelCol
+			ss.sheet.SheetFormat.OutlineLevelRow = sheetFormatPr.OutlineLevelRow
+		case "cols":
+			var rawcols xlsxCols
+			if err := ss.decoder.DecodeElement(&rawcols, &start); err != nil {
+				return err
+			}
+			if _, err := sr.loadStyles(); err != nil {
+				return err
+			}
+			ss.sheet.Cols = readColsFromSheet(&rawcols, sr.file)
+		case "sheetData":
+			return nil
+		}
+	}
+}
+
+// Read returns the next Row of the current sheet.  When there are no
+// rows left in the sheet, Read returns nil and io.EOF.  Rows that are
+// omitted from the file are returned as empty rows, so the number of
+// rows read so far is always the zero based index of the next row.
+func (sr *StreamReader) Read() (*Row, error) {
+	if sr.err != nil {
+		return nil, sr.err
+	}
+	if sr.currentSheet == nil {
+		return nil, NoCurrentSheetError
+	}
+	row, err := sr.read()
+	if err != nil && err != io.EOF {
+		sr.err = wrapReadError(err, sr.currentSheet.part, sr.currentSheet.sheet.Name)
+		return row, sr.err
+	}
+	return row, err
+}
+
+func (sr *StreamReader) read() (row *Row, err error) {
+	ss := sr.currentSheet
+	defer func() {
+		if e := recover(); e != nil {
+			switch e := e.(type) {
+			case error:
+				err = e
+			default:
+				err = fmt.Errorf("unexpected error: %v", e)
+			}
+			row = nil
+		}
+	}()
+
+	if ss.pending != nil {
+		if ss.rowCount < ss.pendingIndex {
+			ss.rowCount++
+			return makeEmptyRow(ss.sheet), nil
+		}
+		row = ss.pending
+		ss.pending = nil
+		ss.rowCount++
+		return row, nil
+	}
+	if ss.done {
+		return nil, io.EOF
+	}
+
+	for {
+		token, err := ss.decoder.Token()
+		if err == io.EOF {
+			ss.done = true
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "row" {
+				var rawrow xlsxRow
+				if err := ss.decoder.DecodeElement(&rawrow, &t); err != nil {
+					return nil, err
+				}
+				row, err := sr.makeRow(rawrow)
+				if err != nil {
+					return nil, err
+				}
+				rowIndex := ss.rowCount
+				if rawrow.R > 0 {
+					rowIndex = rawrow.R - 1
+				}
+				// Some spreadsheets will omit blank rows from
+				// the stored data
+				if rowIndex > ss.rowCount {
+					ss.pending = row
+					ss.pendingIndex = rowIndex
+					ss.rowCount++
+					return makeEmptyRow(ss.sheet), nil
+				}
+				ss.rowCount++
+				return row, nil
+			}
+		case xml.EndElement:
+			if t.Name.Local == "sheetData" {
+				ss.done = true
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+// makeRow converts a single xlsxRow into a Row, populated with Cells.
+func (sr *StreamReader) makeRow(rawrow xlsxRow) (*Row, error) {
+	ss := sr.currentSheet
+	row := &Row{
+		Sheet:        ss.sheet,
+		Hidden:       rawrow.Hidden,
+		OutlineLevel: rawrow.OutlineLevel,
+		isCustom:     rawrow.CustomHeight,
+	}
+	if height, err := strconv.ParseFloat(rawrow.Ht, 64); err == nil {
+		row.Height = height
+	}
+	for _, rawcell := range rawrow.C {
+		x := len(row.Cells)
+		if rawcell.R != "" {
+			var err error
+			x, _, err = GetCoordsFromCellIDString(rawcell.R)
+			if err != nil {
+				return nil, &ReadError{Cell: rawcell.R, Err: errors.New("invalid cell reference")}
+			}
+		}
+		// Some spreadsheets will omit blank cells from the data.
+		for len(row.Cells) <= x {
+			row.AddCell()
+		}
+		cell := row.Cells[x]
+		if rawcell.T == "s" && sr.file.referenceTable == nil {
+			if err := sr.loadSharedStrings(); err != nil {
+				return nil, err
+			}
+		}
+		if err := fillCellData(rawcell, sr.file.referenceTable, ss.sharedFormulas, cell); err != nil {
+			return nil, err
+		}
+		hasStyles, err := sr.loadStyles()
+		if err != nil {
+			return nil, err
+		}
+		if hasStyles {
+			cell.style = sr.file.styles.getStyle(rawcell.S)
+			cell.NumFmt, cell.parsedNumFmt = sr.file.styles.getNumberFormat(rawcell.S)
+		}
+		cell.date1904 = sr.file.Date1904
+		// Cell is considered hidden if the row or the column of this cell is hidden
+		col := ss.sheet.Cols.FindColByIndex(x + 1)
+		cell.Hidden = rawrow.Hidden || (col != nil && col.Hidden)
+	}
+	return row, nil
+}
+
+// loadSharedStrings reads the shared strings table the first time a
+// cell refers to it.
+func (sr *StreamReader) loadSharedStrings() error {
+	if sr.sharedStrings == nil {
+		return MissingSharedStringsError
+	}
+	reftable, err := readSharedStringsFromZipFile(sr.sharedStrings, sr.file.theme)
+	if err != nil {
+		return err
+	}
+	sr.file.referenceTable = reftable
+	return nil
+}
+
+// loadStyles reads the style sheet the first time it is needed, and
+// reports whether the file has one.
+func (sr *StreamReader) loadStyles() (bool, error) {
+	if !sr.stylesLoaded {
+		sr.stylesLoaded = true
+		if sr.styles != nil {
+			style, err := readStylesFromZipFile(sr.styles, sr.file.theme)
+			if err != nil {
+				return false, err
+			}
+			sr.file.styles = style
+		}
+	}
+	return sr.file.styles != nil, nil
+}
+
+// Error reports any error that has occurred during a previous Read or NextSheet.
+func (sr *StreamReader) Error() error {
+	return sr.err
+}
+
+// Close closes the StreamReader, and the underlying file if it was
+// opened with NewStreamReaderForPath.
+func (sr *StreamReader) Close() error {
+	if sr.currentSheet != nil {
+		sr.currentSheet.rc.Close()
+		sr.currentSheet = nil
+	}
+	if sr.closer != nil {
+		return sr.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// readAllWithStreamReader reads every sheet through a StreamReader
+// and returns the formatted values in the same shape as File.ToSlice.
+func readAllWithStreamReader(c *qt.C, sr *StreamReader) [][][]string {
+	output := [][][]string{}
+	for range sr.SheetNames() {
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		s := [][]string{}
+		for {
+			row, err := sr.Read()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, qt.IsNil)
+			r := []string{}
+			for _, cell := range row.Cells {
+				str, err := cell.FormattedValue()
+				c.Assert(err, qt.IsNil)
+				r = append(r, str)
+			}
+			s = append(s, r)
+		}
+		output = append(output, s)
+	}
+	return output
+}
+
+// zipParts packs a map of part names to XML content, as returned by
+// File.MarshallParts, into the bytes of an XLSX file.
+func zipParts(c *qt.C, parts map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, part := range parts {
+		fw, err := w.Create(name)
+		c.Assert(err, qt.IsNil)
+		_, err = fw.Write([]byte(part))
+		c.Assert(err, qt.IsNil)
+	}
+	c.Assert(w.Close(), qt.IsNil)
+	return buf.Bytes()
+}
+
+func TestStreamReader(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("MatchesOpenFile", func(c *qt.C) {
+		for _, path := range []string{
+			"./testdocs/testfile.xlsx",
+			"./testdocs/empty_rows.xlsx",
+			"./testdocs/inlineStrings.xlsx",
+			"./testdocs/testFileToSlice.xlsx",
+		} {
+			expected, err := FileToSlice(path)
+			c.Assert(err, qt.IsNil)
+
+			sr, err := NewStreamReaderForPath(path)
+			c.Assert(err, qt.IsNil)
+			actual := readAllWithStreamReader(c, sr)
+			c.Assert(sr.Close(), qt.IsNil)
+
+			c.Assert(len(actual), qt.Equals, len(expected))
+			for i := range expected {
+				// OpenFile pads a sheet out to its dimension,
+				// the stream reader stops at the last row.
+				c.Assert(len(actual[i]) <= len(expected[i]), qt.Equals, true)
+				for j := range actual[i] {
+					for k := range actual[i][j] {
+						c.Assert(actual[i][j][k], qt.Equals, expected[i][j][k], qt.Commentf("%s sheet %d cell %d,%d", path, i, k, j))
+					}
+				}
+			}
+		}
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		f := NewFile()
+		sheet, err := f.AddSheet("Data")
+		c.Assert(err, qt.IsNil)
+		sheet.SetColWidth(1, 1, 20)
+		sheet.Cell(0, 0).SetString("Name")
+		sheet.Cell(0, 1).SetString("Amount")
+		sheet.Cell(0, 2).SetString("Paid")
+		sheet.Cell(1, 0).SetString("Foo")
+		sheet.Cell(1, 1).SetFloat(12.5)
+		sheet.Cell(1, 2).SetBool(true)
+		// Leave row 3 and column B on row 4 empty.
+		sheet.Cell(3, 0).SetString("Bar")
+		sheet.Cell(3, 2).SetDate(time.Date(2019, 12, 17, 0, 0, 0, 0, time.UTC))
+		sheet.Cell(3, 3).SetFormula("B2*2")
+		_, err = f.AddSheet("Empty")
+		c.Assert(err, qt.IsNil)
+
+		var buf bytes.Buffer
+		c.Assert(f.Write(&buf), qt.IsNil)
+
+		sr, err := NewStreamReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		c.Assert(err, qt.IsNil)
+		defer sr.Close()
+		c.Assert(sr.SheetNames(), qt.DeepEquals, []string{"Data", "Empty"})
+
+		_, err = sr.Read()
+		c.Assert(err, qt.Equals, NoCurrentSheetError)
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		c.Assert(sr.Sheet().Name, qt.Equals, "Data")
+		c.Assert(sr.Sheet().Col(0).Width, qt.Equals, 20.0)
+
+		var rows []*Row
+		for {
+			row, err := sr.Read()
+			if err == io.EOF {
+				break
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(row.Sheet, qt.Equals, sr.Sheet())
+			rows = append(rows, row)
+		}
+		c.Assert(rows, qt.HasLen, 4)
+		c.Assert(rows[0].Cells, qt.HasLen, 3)
+		c.Assert(rows[1].Cells[0].Value, qt.Equals, "Foo")
+		c.Assert(rows[1].Cells[1].Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(rows[1].Cells[1].Value, qt.Equals, "12.5")
+		c.Assert(rows[1].Cells[2].Bool(), qt.Equals, true)
+		c.Assert(rows[2].Cells, qt.HasLen, 0)
+		c.Assert(rows[3].Cells, qt.HasLen, 4)
+		c.Assert(rows[3].Cells[1].Value, qt.Equals, "")
+		c.Assert(rows[3].Cells[2].IsTime(), qt.Equals, true)
+		date, err := rows[3].Cells[2].GetTime(false)
+		c.Assert(err, qt.IsNil)
+		c.Assert(date, qt.DeepEquals, time.Date(2019, 12, 17, 0, 0, 0, 0, time.UTC))
+		c.Assert(rows[3].Cells[3].Formula(), qt.Equals, "B2*2")
+
+		// Reading past the end keeps returning io.EOF
+		_, err = sr.Read()
+		c.Assert(err, qt.Equals, io.EOF)
+
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		c.Assert(sr.Sheet().Name, qt.Equals, "Empty")
+		_, err = sr.Read()
+		c.Assert(err, qt.Equals, io.EOF)
+
+		c.Assert(sr.NextSheet(), qt.Equals, AlreadyOnLastSheetError)
+		c.Assert(sr.Error(), qt.Equals, AlreadyOnLastSheetError)
+	})
+
+	c.Run("SkipsChartsheets", func(c *qt.C) {
+		sr, err := NewStreamReaderForPath("./testdocs/testchartsheet.xlsx")
+		c.Assert(err, qt.IsNil)
+		defer sr.Close()
+		file, err := OpenFile("./testdocs/testchartsheet.xlsx")
+		c.Assert(err, qt.IsNil)
+		c.Assert(sr.SheetNames(), qt.HasLen, len(file.Sheets))
+	})
+
+	c.Run("BadSharedStringIndex", func(c *qt.C) {
+		f := NewFile()
+		sheet, err := f.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetString("Foo")
+		parts, err := f.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		parts["xl/worksheets/sheet1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData><row r="1"><c r="A1" t="s"><v>7</v></c></row></sheetData></worksheet>`
+		buf := zipParts(c, parts)
+
+		sr, err := NewStreamReader(bytes.NewReader(buf), int64(len(buf)))
+		c.Assert(err, qt.IsNil)
+		c.Assert(sr.NextSheet(), qt.IsNil)
+		_, err = sr.Read()
+		c.Assert(err, qt.Not(qt.IsNil))
+		c.Assert(err, qt.Not(qt.Equals), io.EOF)
+		// The error is sticky.
+		_, err2 := sr.Read()
+		c.Assert(err2, qt.Equals, err)
+	})
+}
@@ -28,7 +28,8 @@ var (
 	StreamStyleItalicInteger     StreamStyle
 	StreamStyleUnderlinedInteger StreamStyle
 
-	StreamStyleDefaultDate StreamStyle
+	StreamStyleDefaultDate     StreamStyle
+	StreamStyleDefaultDateTime StreamStyle
 
 	StreamStyleDefaultDecimal StreamStyle
 )
@@ -72,6 +73,7 @@ func init() {
 	StreamStyleUnderlinedInteger = MakeIntegerStyle(FontUnderlined, DefaultFill(), DefaultAlignment(), DefaultBorder())
 
 	StreamStyleDefaultDate = MakeDateStyle(DefaultFont(), DefaultFill(), DefaultAlignment(), DefaultBorder())
+	StreamStyleDefaultDateTime = MakeStyle(DateTimeFormat_d_m_yy_h_mm, DefaultFont(), DefaultFill(), DefaultAlignment(), DefaultBorder())
 
 	StreamStyleDefaultDecimal = MakeDecimalStyle(DefaultFont(), DefaultFill(), DefaultAlignment(), DefaultBorder())
 
@@ -35,17 +35,20 @@ type Style struct {
 	ApplyFill       bool
 	ApplyFont       bool
 	ApplyAlignment  bool
+	ApplyProtection bool
 	Alignment       Alignment
+	Protection      Protection
 	NamedStyleIndex *int
 }
 
 // Return a new Style structure initialised with the default values.
 func NewStyle() *Style {
 	return &Style{
-		Alignment: *DefaultAlignment(),
-		Border:    *DefaultBorder(),
-		Fill:      *DefaultFill(),
-		Font:      *DefaultFont(),
+		Alignment:  *DefaultAlignment(),
+		Border:     *DefaultBorder(),
+		Fill:       *DefaultFill(),
+		Font:       *DefaultFont(),
+		Protection: *DefaultProtection(),
 	}
 }
 
@@ -101,6 +104,11 @@ func (style *Style) makeXLSXStyleElements() (xFont xlsxFont, xFill xlsxFill, xBo
 	xCellXf.ApplyFill = style.ApplyFill
 	xCellXf.ApplyFont = style.ApplyFont
 	xCellXf.ApplyAlignment = style.ApplyAlignment
+	xCellXf.ApplyProtection = style.ApplyProtection
+	if style.ApplyProtection {
+		locked := style.Protection.Locked
+		xCellXf.Protection = &xlsxProtection{Locked: &locked, Hidden: style.Protection.Hidden}
+	}
 	if style.NamedStyleIndex != nil {
 		xCellXf.XfId = style.NamedStyleIndex
 	}
@@ -174,6 +182,13 @@ type Alignment struct {
 	WrapText     bool
 }
 
+// Protection says whether a cell is locked, and whether its formula
+// is hidden, once the sheet it is on is protected.
+type Protection struct {
+	Locked bool
+	Hidden bool
+}
+
 var defaultFontSize = 12
 var defaultFontName = "Verdana"
 
@@ -201,3 +216,9 @@ func DefaultAlignment() *Alignment {
 		Vertical:   "bottom",
 	}
 }
+
+// DefaultProtection returns the Protection that Excel gives a cell
+// when none is set: locked, with its formula visible.
+func DefaultProtection() *Protection {
+	return &Protection{Locked: true}
+}
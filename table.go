@@ -0,0 +1,438 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TableColumn is a column of a Table.
+type TableColumn struct {
+	// Name is the column's header, which formulas use to refer to
+	// the column, as in Table1[Name].
+	Name string
+	// TotalsRowLabel is shown under the column in the totals row.
+	TotalsRowLabel string
+	// TotalsRowFunction summarises the column in the totals row, it
+	// is one of "sum", "average", "count", "countNums", "max", "min",
+	// "stdDev" or "var".  Set it with Table.SetTotalsRowFunction,
+	// which also sets the formula of the cell in the totals row.
+	TotalsRowFunction string
+	// CalculatedColumnFormula is the formula that Excel fills new
+	// rows of the column with.
+	CalculatedColumnFormula string
+}
+
+// Table is a range of a sheet that Excel treats as a table (a "list
+// object"): it has a header row naming its columns, can be filtered
+// and sorted, and is formatted with a table style.
+type Table struct {
+	Sheet *Sheet
+	// Name is how formulas refer to the table.  Table names are
+	// unique within a workbook, regardless of case.
+	Name string
+	// Ref is the range of the table including its header and totals
+	// rows, for example "A1:C10".
+	Ref     string
+	Columns []TableColumn
+	// StyleName is the name of the table style, for example
+	// "TableStyleMedium2".
+	StyleName         string
+	ShowHeaderRow     bool
+	ShowTotals        bool
+	ShowFirstColumn   bool
+	ShowLastColumn    bool
+	ShowRowStripes    bool
+	ShowColumnStripes bool
+	original          *originalTable
+}
+
+// The SUBTOTAL function numbers that give the totals row functions of
+// table columns, ignoring the rows hidden by filters.
+var tableTotalsRowFunctions = map[string]int{
+	"average":   101,
+	"countNums": 102,
+	"count":     103,
+	"max":       104,
+	"min":       105,
+	"stdDev":    107,
+	"sum":       109,
+	"var":       110,
+}
+
+var (
+	tableNameRegexp       = regexp.MustCompile(`^[\p{L}_\\][\p{L}\p{N}_.\\]*$`)
+	tableNameA1Regexp     = regexp.MustCompile(`^[A-Za-z]{1,3}[0-9]+$`)
+	tableNameR1C1Regexp   = regexp.MustCompile(`^([Rr][0-9]*)?([Cc][0-9]*)?$`)
+	tableColumnNameEscape = strings.NewReplacer("'", "''", "[", "'[", "]", "']", "#", "'#")
+)
+
+// checkTableName returns an error if name can't be the name of a table,
+// because Excel would take it for something else.
+func checkTableName(name string) error {
+	switch {
+	case len(name) > 255:
+		return fmt.Errorf("table name %q is longer than 255 characters", name)
+	case !tableNameRegexp.MatchString(name):
+		return fmt.Errorf("table name %q must start with a letter or underscore, and contain only letters, digits, underscores and full stops", name)
+	case tableNameA1Regexp.MatchString(name), tableNameR1C1Regexp.MatchString(name):
+		return fmt.Errorf("table name %q looks like a cell reference", name)
+	}
+	return nil
+}
+
+// AddTable makes the range ref, for example "A1:C10", of the sheet
+// into a table called name, with a header row of the columns and,
+// if showTotals is set, a totals row.  The header cells are set to
+// the column names, as Excel needs them to match, and the data rows
+// follow the header row.  styleName is the name of the table style,
+// for example "TableStyleMedium2", or empty for a plain table.
+func (s *Sheet) AddTable(name, ref string, columns []string, styleName string, showTotals bool) (*Table, error) {
+	s.load()
+	if err := checkTableName(name); err != nil {
+		return nil, fmt.Errorf("AddTable: %s", err)
+	}
+	if s.File != nil && s.File.Table(name) != nil || s.File == nil && s.table(name) != nil {
+		return nil, fmt.Errorf("AddTable: a table called %q already exists", name)
+	}
+	table := &Table{
+		Sheet:          s,
+		Name:           name,
+		Ref:            strings.Replace(ref, "$", "", -1),
+		StyleName:      styleName,
+		ShowHeaderRow:  true,
+		ShowTotals:     showTotals,
+		ShowRowStripes: true,
+	}
+	minCol, minRow, maxCol, maxRow, err := table.bounds()
+	if err != nil {
+		return nil, fmt.Errorf("AddTable: %s", err)
+	}
+	if len(columns) != maxCol-minCol+1 {
+		return nil, fmt.Errorf("AddTable: %d columns given for the %d columns of %s", len(columns), maxCol-minCol+1, ref)
+	}
+	minRows := 2
+	if showTotals {
+		minRows++
+	}
+	if maxRow-minRow+1 < minRows {
+		return nil, fmt.Errorf("AddTable: %s has no room for a data row", ref)
+	}
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		if column == "" {
+			return nil, fmt.Errorf("AddTable: empty column name")
+		}
+		if seen[strings.ToLower(column)] {
+			return nil, fmt.Errorf("AddTable: column name %q is used more than once", column)
+		}
+		seen[strings.ToLower(column)] = true
+		table.Columns = append(table.Columns, TableColumn{Name: column})
+	}
+	for _, other := range s.Tables {
+		otherMinCol, otherMinRow, otherMaxCol, otherMaxRow, err := other.bounds()
+		if err != nil {
+			continue
+		}
+		if minCol <= otherMaxCol && otherMinCol <= maxCol && minRow <= otherMaxRow && otherMinRow <= maxRow {
+			return nil, fmt.Errorf("AddTable: %s overlaps table %q", ref, other.Name)
+		}
+	}
+	for i, column := range columns {
+		s.Cell(minRow, minCol+i).SetString(column)
+	}
+	s.Tables = append(s.Tables, table)
+	return table, nil
+}
+
+// Table returns the table called name, regardless of case, from any
+// of the sheets of the file, or nil if there is no such table.
+func (f *File) Table(name string) *Table {
+	for _, sheet := range f.Sheets {
+		sheet.load()
+		if table := sheet.table(name); table != nil {
+			return table
+		}
+	}
+	return nil
+}
+
+// table returns the sheet's table called name, regardless of case.
+func (s *Sheet) table(name string) *Table {
+	for _, table := range s.Tables {
+		if strings.EqualFold(table.Name, name) {
+			return table
+		}
+	}
+	return nil
+}
+
+// bounds returns the coordinates of the corners of the table.
+func (t *Table) bounds() (minCol, minRow, maxCol, maxRow int, err error) {
+	if strings.Count(t.Ref, cellRangeChar) != 1 {
+		return -1, -1, -1, -1, fmt.Errorf("invalid table range %q", t.Ref)
+	}
+	minCol, minRow, maxCol, maxRow, err = getMaxMinFromDimensionRef(t.Ref)
+	if err != nil {
+		return -1, -1, -1, -1, err
+	}
+	if minCol > maxCol || minRow > maxRow {
+		return -1, -1, -1, -1, fmt.Errorf("invalid table range %q", t.Ref)
+	}
+	return minCol, minRow, maxCol, maxRow, nil
+}
+
+// ColumnIndex returns the index within the table of the column called
+// name, regardless of case, or -1 if there is no such column.
+func (t *Table) ColumnIndex(name string) int {
+	for i, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Cells returns the cells of the data rows of the column called name,
+// leaving out the header and totals rows.
+func (t *Table) Cells(column string) ([]*Cell, error) {
+	i := t.ColumnIndex(column)
+	if i < 0 {
+		return nil, fmt.Errorf("table %q has no column %q", t.Name, column)
+	}
+	minCol, minRow, _, maxRow, err := t.bounds()
+	if err != nil {
+		return nil, err
+	}
+	if t.ShowHeaderRow {
+		minRow++
+	}
+	if t.ShowTotals {
+		maxRow--
+	}
+	var cells []*Cell
+	for row := minRow; row <= maxRow; row++ {
+		cells = append(cells, t.Sheet.Cell(row, minCol+i))
+	}
+	return cells, nil
+}
+
+// totalsCell returns the cell of the totals row under the column
+// called name.
+func (t *Table) totalsCell(name string) (*Cell, int, error) {
+	if !t.ShowTotals {
+		return nil, -1, fmt.Errorf("table %q has no totals row", t.Name)
+	}
+	i := t.ColumnIndex(name)
+	if i < 0 {
+		return nil, -1, fmt.Errorf("table %q has no column %q", t.Name, name)
+	}
+	minCol, _, _, maxRow, err := t.bounds()
+	if err != nil {
+		return nil, -1, err
+	}
+	return t.Sheet.Cell(maxRow, minCol+i), i, nil
+}
+
+// SetTotalsRowFunction sets the function, for example "sum", that
+// summarises the column called name in the totals row, along with the
+// formula of the cell under the column there.  The function "none"
+// clears them.
+func (t *Table) SetTotalsRowFunction(name, function string) error {
+	cell, i, err := t.totalsCell(name)
+	if err != nil {
+		return err
+	}
+	if function == "none" {
+		t.Columns[i].TotalsRowFunction = ""
+		cell.SetString("")
+		return nil
+	}
+	number, ok := tableTotalsRowFunctions[function]
+	if !ok {
+		return fmt.Errorf("unknown totals row function %q", function)
+	}
+	t.Columns[i].TotalsRowFunction = function
+	t.Columns[i].TotalsRowLabel = ""
+	cell.SetFormula(fmt.Sprintf("SUBTOTAL(%d,%s[%s])", number, t.Name, tableColumnNameEscape.Replace(t.Columns[i].Name)))
+	return nil
+}
+
+// SetTotalsRowLabel sets the label shown under the column called
+// name in the totals row, in place of a function.
+func (t *Table) SetTotalsRowLabel(name, label string) error {
+	cell, i, err := t.totalsCell(name)
+	if err != nil {
+		return err
+	}
+	t.Columns[i].TotalsRowFunction = ""
+	t.Columns[i].TotalsRowLabel = label
+	cell.SetString(label)
+	return nil
+}
+
+// makeXLSXTable returns the XML representation of the table, which
+// is numbered id within the workbook.
+func (t *Table) makeXLSXTable(id int) *xlsxTable {
+	table := &xlsxTable{
+		Id:          id,
+		Name:        t.Name,
+		DisplayName: t.Name,
+		Ref:         t.Ref,
+		TableStyleInfo: &xlsxTableStyleInfo{
+			Name:              t.StyleName,
+			ShowFirstColumn:   t.ShowFirstColumn,
+			ShowLastColumn:    t.ShowLastColumn,
+			ShowRowStripes:    t.ShowRowStripes,
+			ShowColumnStripes: t.ShowColumnStripes,
+		},
+	}
+	minCol, minRow, maxCol, maxRow, err := t.bounds()
+	if t.ShowTotals {
+		table.TotalsRowCount = 1
+		maxRow--
+	} else {
+		totalsRowShown := false
+		table.TotalsRowShown = &totalsRowShown
+	}
+	if !t.ShowHeaderRow {
+		headerRowCount := 0
+		table.HeaderRowCount = &headerRowCount
+	} else if err == nil {
+		table.AutoFilter = &xlsxAutoFilter{
+			Ref: GetCellIDStringFromCoords(minCol, minRow) + cellRangeChar + GetCellIDStringFromCoords(maxCol, maxRow)}
+	}
+	table.TableColumns.Count = len(t.Columns)
+	for i, column := range t.Columns {
+		table.TableColumns.TableColumn = append(table.TableColumns.TableColumn, xlsxTableColumn{
+			Id:                      i + 1,
+			Name:                    column.Name,
+			TotalsRowLabel:          column.TotalsRowLabel,
+			TotalsRowFunction:       column.TotalsRowFunction,
+			CalculatedColumnFormula: column.CalculatedColumnFormula,
+		})
+	}
+	return table
+}
+
+// write adds the table part, called partName, to parts and its
+// content type to types.  The table is numbered id within the
+// workbook.
+func (t *Table) write(parts map[string]string, types *xlsxTypes, partName string, id int) error {
+	body, err := xml.Marshal(t.makeXLSXTable(id))
+	if err != nil {
+		return err
+	}
+	table := xml.Header + string(body)
+	if original := t.originalParts(); original != nil {
+		rels, ids := remapRelations(original.rels, path.Dir(partName), 1)
+		table, err = tableElementPolicy.merge(table, original.doc, ids)
+		if err != nil {
+			return err
+		}
+		if len(rels) > 0 {
+			body, err := xml.Marshal(&xlsxWorksheetRels{Relationships: rels})
+			if err != nil {
+				return err
+			}
+			parts[relsPartName(partName)] = xml.Header + string(body)
+		}
+	}
+	parts[partName] = table
+	types.Overrides = append(
+		types.Overrides,
+		xlsxOverride{
+			PartName:    "/" + partName,
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml"})
+	return nil
+}
+
+// setTableRelations replaces the sheet's relations to table parts
+// with ones to the parts with the given names.
+func (s *Sheet) setTableRelations(tablePartNames []string) {
+	s.removeRelations(RelationshipTypeTable)
+	for _, name := range tablePartNames {
+		s.addRelation(RelationshipTypeTable, relativePartTarget("xl/worksheets", name), "")
+	}
+}
+
+// makeTableParts points the worksheet at its tables, if it has any.
+func (s *Sheet) makeTableParts(worksheet *xlsxWorksheet, relations *xlsxWorksheetRels) {
+	if relations == nil {
+		return
+	}
+	for _, rel := range relations.Relationships {
+		if rel.Type == RelationshipTypeTable {
+			if worksheet.TableParts == nil {
+				worksheet.TableParts = &xlsxTableParts{}
+			}
+			worksheet.TableParts.TablePart = append(worksheet.TableParts.TablePart, xlsxTablePart{RelationshipId: rel.Id})
+			worksheet.TableParts.Count++
+		}
+	}
+}
+
+// readTable converts a table from its XML representation.
+func readTable(sheet *Sheet, xTable *xlsxTable) *Table {
+	table := &Table{
+		Sheet:         sheet,
+		Name:          xTable.DisplayName,
+		Ref:           xTable.Ref,
+		ShowHeaderRow: xTable.HeaderRowCount == nil || *xTable.HeaderRowCount != 0,
+		ShowTotals:    xTable.TotalsRowCount > 0,
+	}
+	if table.Name == "" {
+		table.Name = xTable.Name
+	}
+	if style := xTable.TableStyleInfo; style != nil {
+		table.StyleName = style.Name
+		table.ShowFirstColumn = style.ShowFirstColumn
+		table.ShowLastColumn = style.ShowLastColumn
+		table.ShowRowStripes = style.ShowRowStripes
+		table.ShowColumnStripes = style.ShowColumnStripes
+	}
+	for _, column := range xTable.TableColumns.TableColumn {
+		table.Columns = append(table.Columns, TableColumn{
+			Name:                    column.Name,
+			TotalsRowLabel:          column.TotalsRowLabel,
+			TotalsRowFunction:       column.TotalsRowFunction,
+			CalculatedColumnFormula: column.CalculatedColumnFormula,
+		})
+	}
+	return table
+}
+
+// readTablesFromZipFile reads the tables that the worksheet relations
+// refer to.
+func readTablesFromZipFile(sheet *Sheet, worksheetRels *xlsxWorksheetRels, parts map[string]*zip.File) error {
+	if worksheetRels == nil {
+		return nil
+	}
+	for _, rel := range worksheetRels.Relationships {
+		if rel.Type != RelationshipTypeTable {
+			continue
+		}
+		tablePartName := relationshipTargetPartName("xl/worksheets", rel.Target)
+		data, err := readZipPart(parts, tablePartName)
+		if err != nil {
+			return &ReadError{Part: tablePartName, Err: err}
+		}
+		xTable := new(xlsxTable)
+		err = xml.Unmarshal(data, xTable)
+		if err != nil {
+			return &ReadError{Part: tablePartName, Err: err}
+		}
+		table := readTable(sheet, xTable)
+		if sheet.original != nil {
+			table.original, err = readOriginalTable(data, tablePartName, parts)
+			if err != nil {
+				return wrapReadError(err, tablePartName, "")
+			}
+		}
+		sheet.Tables = append(sheet.Tables, table)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package xlsx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTable(t *testing.T) {
+	c := qt.New(t)
+
+	makeSalesFile := func(c *qt.C) (*File, *Table) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sales")
+		c.Assert(err, qt.IsNil)
+		for i, region := range []string{"North", "South", "East"} {
+			sheet.Cell(i+1, 0).SetString(region)
+			sheet.Cell(i+1, 1).SetInt(100 * (i + 1))
+		}
+		table, err := sheet.AddTable("Sales", "A1:B5", []string{"Region", "Amount"}, "TableStyleMedium2", true)
+		c.Assert(err, qt.IsNil)
+		return file, table
+	}
+
+	c.Run("AddTable", func(c *qt.C) {
+		file, table := makeSalesFile(c)
+		sheet := file.Sheet["Sales"]
+		c.Assert(sheet.Tables, qt.HasLen, 1)
+		c.Assert(sheet.Tables[0], qt.Equals, table)
+		c.Assert(table.Sheet, qt.Equals, sheet)
+		c.Assert(table.Columns, qt.HasLen, 2)
+		c.Assert(table.Columns[1], qt.Equals, TableColumn{Name: "Amount"})
+		c.Assert(table.ShowHeaderRow, qt.Equals, true)
+		c.Assert(table.ShowRowStripes, qt.Equals, true)
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "Region")
+		c.Assert(sheet.Cell(0, 1).Value, qt.Equals, "Amount")
+		c.Assert(file.Table("SALES"), qt.Equals, table)
+		c.Assert(file.Table("Other"), qt.IsNil)
+	})
+
+	c.Run("AddTableErrors", func(c *qt.C) {
+		file, _ := makeSalesFile(c)
+		other, err := file.AddSheet("Other")
+		c.Assert(err, qt.IsNil)
+		sheet := file.Sheet["Sales"]
+		for _, test := range []struct {
+			sheet   *Sheet
+			name    string
+			ref     string
+			columns []string
+			totals  bool
+			err     string
+		}{
+			{other, "", "A1:A2", []string{"A"}, false, `AddTable: table name "" must start with .*`},
+			{other, "2020", "A1:A2", []string{"A"}, false, `AddTable: table name "2020" must start with .*`},
+			{other, "Has Space", "A1:A2", []string{"A"}, false, `AddTable: table name "Has Space" must start with .*`},
+			{other, "AB12", "A1:A2", []string{"A"}, false, `AddTable: table name "AB12" looks like a cell reference`},
+			{other, "R1C1", "A1:A2", []string{"A"}, false, `AddTable: table name "R1C1" looks like a cell reference`},
+			{other, "c", "A1:A2", []string{"A"}, false, `AddTable: table name "c" looks like a cell reference`},
+			{other, "sales", "A1:A2", []string{"A"}, false, `AddTable: a table called "sales" already exists`},
+			{other, "T", "A1", []string{"A"}, false, `AddTable: invalid table range "A1"`},
+			{other, "T", "B2:A1", []string{"A"}, false, `AddTable: invalid table range "B2:A1"`},
+			{other, "T", "A1:B2", []string{"A"}, false, `AddTable: 1 columns given for the 2 columns of A1:B2`},
+			{other, "T", "A1:A1", []string{"A"}, false, `AddTable: A1:A1 has no room for a data row`},
+			{other, "T", "A1:A2", []string{"A"}, true, `AddTable: A1:A2 has no room for a data row`},
+			{other, "T", "A1:B2", []string{"A", "a"}, false, `AddTable: column name "a" is used more than once`},
+			{other, "T", "A1:B2", []string{"A", ""}, false, `AddTable: empty column name`},
+			{sheet, "T", "B5:C6", []string{"A", "B"}, false, `AddTable: B5:C6 overlaps table "Sales"`},
+		} {
+			_, err := test.sheet.AddTable(test.name, test.ref, test.columns, "", test.totals)
+			c.Assert(err, qt.ErrorMatches, test.err)
+		}
+		c.Assert(other.Tables, qt.HasLen, 0)
+	})
+
+	c.Run("Cells", func(c *qt.C) {
+		_, table := makeSalesFile(c)
+		cells, err := table.Cells("amount")
+		c.Assert(err, qt.IsNil)
+		var values []string
+		for _, cell := range cells {
+			values = append(values, cell.Value)
+		}
+		c.Assert(values, qt.DeepEquals, []string{"100", "200", "300"})
+		_, err = table.Cells("Cost")
+		c.Assert(err, qt.ErrorMatches, `table "Sales" has no column "Cost"`)
+	})
+
+	c.Run("TotalsRow", func(c *qt.C) {
+		file, table := makeSalesFile(c)
+		sheet := file.Sheet["Sales"]
+		c.Assert(table.SetTotalsRowLabel("Region", "Total"), qt.IsNil)
+		c.Assert(table.SetTotalsRowFunction("Amount", "sum"), qt.IsNil)
+		c.Assert(table.Columns[0].TotalsRowLabel, qt.Equals, "Total")
+		c.Assert(table.Columns[1].TotalsRowFunction, qt.Equals, "sum")
+		c.Assert(sheet.Cell(4, 0).Value, qt.Equals, "Total")
+		c.Assert(sheet.Cell(4, 1).Formula(), qt.Equals, "SUBTOTAL(109,Sales[Amount])")
+
+		c.Assert(table.SetTotalsRowFunction("Amount", "none"), qt.IsNil)
+		c.Assert(table.Columns[1].TotalsRowFunction, qt.Equals, "")
+		c.Assert(sheet.Cell(4, 1).Formula(), qt.Equals, "")
+
+		c.Assert(table.SetTotalsRowFunction("Amount", "median"), qt.ErrorMatches, `unknown totals row function "median"`)
+		c.Assert(table.SetTotalsRowFunction("Cost", "sum"), qt.ErrorMatches, `table "Sales" has no column "Cost"`)
+		table.ShowTotals = false
+		c.Assert(table.SetTotalsRowLabel("Region", "Total"), qt.ErrorMatches, `table "Sales" has no totals row`)
+	})
+
+	c.Run("EscapesColumnNames", func(c *qt.C) {
+		file := NewFile()
+		sheet, err := file.AddSheet("Sheet1")
+		c.Assert(err, qt.IsNil)
+		table, err := sheet.AddTable("Prices", "A1:A3", []string{"Price [#]"}, "", true)
+		c.Assert(err, qt.IsNil)
+		c.Assert(table.SetTotalsRowFunction("Price [#]", "max"), qt.IsNil)
+		c.Assert(sheet.Cell(2, 0).Formula(), qt.Equals, "SUBTOTAL(104,Prices[Price '['#']])")
+	})
+
+	c.Run("Marshal", func(c *qt.C) {
+		_, table := makeSalesFile(c)
+		c.Assert(table.SetTotalsRowLabel("Region", "Total"), qt.IsNil)
+		c.Assert(table.SetTotalsRowFunction("Amount", "sum"), qt.IsNil)
+		body, err := xml.Marshal(table.makeXLSXTable(3))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(body), qt.Equals, `<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="3" name="Sales" displayName="Sales" ref="A1:B5" totalsRowCount="1">`+
+			`<autoFilter ref="A1:B4"></autoFilter>`+
+			`<tableColumns count="2"><tableColumn id="1" name="Region" totalsRowLabel="Total"></tableColumn><tableColumn id="2" name="Amount" totalsRowFunction="sum"></tableColumn></tableColumns>`+
+			`<tableStyleInfo name="TableStyleMedium2" showFirstColumn="false" showLastColumn="false" showRowStripes="true" showColumnStripes="false"></tableStyleInfo>`+
+			`</table>`)
+
+		table.ShowTotals = false
+		table.ShowHeaderRow = false
+		table.StyleName = ""
+		body, err = xml.Marshal(table.makeXLSXTable(1))
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(body), qt.Contains, `ref="A1:B5" headerRowCount="0" totalsRowShown="false"><tableColumns`)
+		c.Assert(string(body), qt.Contains, `<tableStyleInfo showFirstColumn="false"`)
+	})
+
+	c.Run("Write", func(c *qt.C) {
+		file, _ := makeSalesFile(c)
+		other, err := file.AddSheet("Other")
+		c.Assert(err, qt.IsNil)
+		_, err = other.AddTable("Other", "C3:D4", []string{"X", "Y"}, "", false)
+		c.Assert(err, qt.IsNil)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		checkPackage(c, parts)
+
+		c.Assert(parts["xl/tables/table1.xml"], qt.Contains, `id="1" name="Sales"`)
+		c.Assert(parts["xl/tables/table2.xml"], qt.Contains, `id="2" name="Other"`)
+		c.Assert(parts["xl/worksheets/sheet1.xml"], qt.Contains, `<tableParts count="1"><tablePart r:id="rId1"></tablePart></tableParts></worksheet>`)
+		c.Assert(relationshipTargets(c, parts, "xl/worksheets/sheet1.xml")["table"]["rId1"], qt.Equals, "xl/tables/table1.xml")
+		c.Assert(relationshipTargets(c, parts, "xl/worksheets/sheet2.xml")["table"]["rId1"], qt.Equals, "xl/tables/table2.xml")
+		c.Assert(parts["[Content_Types].xml"], qt.Contains, `<Override PartName="/xl/tables/table2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.table+xml">`)
+	})
+
+	c.Run("RoundTrip", func(c *qt.C) {
+		file, table := makeSalesFile(c)
+		c.Assert(table.SetTotalsRowFunction("Amount", "average"), qt.IsNil)
+		parts, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		var buf bytes.Buffer
+		c.Assert(file.Write(&buf), qt.IsNil)
+
+		file, err = OpenBinary(buf.Bytes())
+		c.Assert(err, qt.IsNil)
+		table = file.Table("Sales")
+		c.Assert(table, qt.Not(qt.IsNil))
+		c.Assert(table.Sheet, qt.Equals, file.Sheet["Sales"])
+		c.Assert(table.Ref, qt.Equals, "A1:B5")
+		c.Assert(table.StyleName, qt.Equals, "TableStyleMedium2")
+		c.Assert(table.ShowHeaderRow, qt.Equals, true)
+		c.Assert(table.ShowTotals, qt.Equals, true)
+		c.Assert(table.ShowRowStripes, qt.Equals, true)
+		c.Assert(table.Columns, qt.DeepEquals, []TableColumn{{Name: "Region"}, {Name: "Amount", TotalsRowFunction: "average"}})
+		cells, err := table.Cells("Region")
+		c.Assert(err, qt.IsNil)
+		c.Assert(cells, qt.HasLen, 3)
+		c.Assert(cells[2].Value, qt.Equals, "East")
+
+		again, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		c.Assert(again["xl/tables/table1.xml"], qt.Equals, parts["xl/tables/table1.xml"])
+	})
+
+	c.Run("PassThrough", func(c *qt.C) {
+		parts := passThroughTestParts(c)
+		parts["xl/worksheets/sheet1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>Name</t></is></c></row></sheetData>` +
+			`<tableParts count="1"><tablePart r:id="rId1"/></tableParts></worksheet>`
+		parts["xl/worksheets/_rels/sheet1.xml.rels"] = passThroughRelsHeader +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/table" Target="../tables/table1.xml"/>` +
+			`</Relationships>`
+		parts["xl/tables/table1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<table xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" id="4" name="Query" displayName="Query" ref="A1:A3" tableType="queryTable" connectionId="1" totalsRowShown="0">` +
+			`<autoFilter ref="A1:A3"/><sortState ref="A2:A3"><sortCondition ref="A2:A3"/></sortState>` +
+			`<tableColumns count="1"><tableColumn id="1" name="Name" queryTableFieldId="1"/></tableColumns>` +
+			`<tableStyleInfo name="TableStyleLight1" showFirstColumn="0" showLastColumn="0" showRowStripes="1" showColumnStripes="0"/></table>`
+		parts["xl/tables/_rels/table1.xml.rels"] = passThroughRelsHeader +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/queryTable" Target="../queryTables/queryTable1.xml"/>` +
+			`</Relationships>`
+		parts["xl/queryTables/queryTable1.xml"] = `<?xml version="1.0" encoding="UTF-8"?>
+<queryTable xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" name="Query" connectionId="1"/>`
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		table := file.Table("query")
+		c.Assert(table, qt.Not(qt.IsNil))
+		c.Assert(table.Columns, qt.DeepEquals, []TableColumn{{Name: "Name"}})
+		c.Assert(table.StyleName, qt.Equals, "TableStyleLight1")
+
+		written, err := file.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		checkPackage(c, written)
+		c.Assert(written["xl/queryTables/queryTable1.xml"], qt.Equals, parts["xl/queryTables/queryTable1.xml"])
+		c.Assert(written["xl/tables/table1.xml"], qt.Contains, `tableType="queryTable" connectionId="1"`)
+		assertInOrder(c, written["xl/tables/table1.xml"],
+			`<autoFilter ref="A1:A3">`,
+			`<sortState ref="A2:A3"><sortCondition ref="A2:A3"/></sortState>`,
+			`<tableColumns count="1">`)
+		c.Assert(relationshipTargets(c, written, "xl/tables/table1.xml")["queryTable"]["rId1"], qt.Equals, "xl/queryTables/queryTable1.xml")
+		c.Assert(relationshipTargets(c, written, "xl/worksheets/sheet1.xml")["table"], qt.HasLen, 1)
+	})
+}
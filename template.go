@@ -0,0 +1,450 @@
+package xlsx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templatePlaceholder matches the placeholders in the text of a cell,
+// such as {{customer.name}}.
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// templateRange matches the placeholder that marks the first row of a
+// range of rows that is repeated for each element of a slice.
+var templateRange = regexp.MustCompile(`^range\s+(\S+)$`)
+
+// ExecuteTemplate fills the placeholders in every sheet of the file
+// with values from data, as Sheet.ExecuteTemplate does.
+func (f *File) ExecuteTemplate(data interface{}) error {
+	for _, sheet := range f.Sheets {
+		if err := sheet.ExecuteTemplate(data); err != nil {
+			return fmt.Errorf("sheet '%s': %v", sheet.Name, err)
+		}
+	}
+	return nil
+}
+
+// ExecuteTemplate fills the placeholders in the cells of the sheet with
+// values from data, which is a map with string keys or a struct, or a
+// pointer to one.
+//
+// A placeholder such as {{customer.name}} is replaced by the value at
+// that path in data.  Each part of the path is a key of a map, the name
+// of an exported field of a struct, ignoring case, or the index of an
+// element of a slice.  A cell that holds nothing but a placeholder
+// takes the type of the value, so that numbers, booleans and times stay
+// numbers, booleans and dates; otherwise the value is written into the
+// text of the cell.  Either way, the cell keeps its style and number
+// format.  A nil value leaves the placeholder empty, and a path that
+// doesn't lead to a value is an error.
+//
+// A range of rows is repeated for each element of a slice when its
+// first row has a cell with {{range items}} and its last row, which
+// can be the same row, has a cell with {{end}}.  In the rows of each
+// element, paths are looked up in the element first, and then in data,
+// and {{.}} is the element itself.  Ranges can't be nested.  The copies
+// are inserted below the range, or if the slice is empty, the range is
+// deleted, as Sheet.InsertRows and Sheet.DeleteRows do: everything in
+// the file that refers to the rows below moves with them, and ranges,
+// such as those of a SUM below the range, that end in it grow to
+// include the copies.  References in the formulas of the copies that
+// aren't fixed with a $ move with them, as in Excel.
+func (s *Sheet) ExecuteTemplate(data interface{}) error {
+	if err := s.Load(); err != nil {
+		return err
+	}
+	root := reflect.ValueOf(data)
+	for y := 0; y < len(s.Rows); y++ {
+		path, ok, err := s.templateRangeStart(y)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := s.fillTemplateRow(y, root); err != nil {
+				return err
+			}
+			continue
+		}
+		end, err := s.templateRangeEnd(y)
+		if err != nil {
+			return err
+		}
+		items, ok := lookupTemplateValue([]reflect.Value{root}, path)
+		if !ok {
+			return fmt.Errorf("row %d: no value for %s", y+1, path)
+		}
+		items = indirectTemplateValue(items)
+		n := 0
+		switch {
+		case !items.IsValid():
+		case items.Kind() == reflect.Slice || items.Kind() == reflect.Array:
+			n = items.Len()
+		default:
+			return fmt.Errorf("row %d: %s is not a slice", y+1, path)
+		}
+		if err := s.repeatRows(y, end, n); err != nil {
+			return fmt.Errorf("row %d: %v", y+1, err)
+		}
+		h := end - y + 1
+		for i := 0; i < n; i++ {
+			element := items.Index(i)
+			for dy := 0; dy < h; dy++ {
+				if err := s.fillTemplateRow(y+i*h+dy, element, root); err != nil {
+					return err
+				}
+			}
+		}
+		y += n*h - 1
+	}
+	return nil
+}
+
+// templateRangeStart returns the path of the slice if row y starts a
+// range of repeated rows, and removes the placeholder that marks it.
+func (s *Sheet) templateRangeStart(y int) (string, bool, error) {
+	path, found := "", false
+	err := s.removeTemplateMarkers(y, func(name string) (bool, error) {
+		m := templateRange.FindStringSubmatch(name)
+		if m == nil {
+			return false, nil
+		}
+		if found {
+			return false, errors.New("more than one {{range}} in a row")
+		}
+		path, found = m[1], true
+		return true, nil
+	})
+	return path, found, err
+}
+
+// templateRangeEnd returns the last row of the range of repeated rows
+// that starts at row y, and removes the placeholder that marks it.
+func (s *Sheet) templateRangeEnd(y int) (int, error) {
+	for end := y; end < len(s.Rows); end++ {
+		found := false
+		err := s.removeTemplateMarkers(end, func(name string) (bool, error) {
+			if name == "end" {
+				found = true
+				return true, nil
+			}
+			if end > y && templateRange.MatchString(name) {
+				return false, errors.New("{{range}} can't be nested")
+			}
+			return false, nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			return end, nil
+		}
+	}
+	return 0, fmt.Errorf("row %d: {{range}} without {{end}}", y+1)
+}
+
+// removeTemplateMarkers removes the placeholders of row y for which
+// marker returns true.
+func (s *Sheet) removeTemplateMarkers(y int, marker func(name string) (bool, error)) error {
+	row := s.Rows[y]
+	if row == nil {
+		return nil
+	}
+	for x, cell := range row.Cells {
+		if !isTemplateCell(cell) {
+			continue
+		}
+		var err error
+		value := templatePlaceholder.ReplaceAllStringFunc(cell.Value, func(placeholder string) string {
+			name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+			remove, e := marker(name)
+			if e != nil && err == nil {
+				err = fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, y), e)
+			}
+			if remove {
+				return ""
+			}
+			return placeholder
+		})
+		if err != nil {
+			return err
+		}
+		if value != cell.Value {
+			cell.Value = strings.TrimSpace(value)
+			cell.richText = nil
+		}
+	}
+	return nil
+}
+
+// fillTemplateRow fills the placeholders of row y with values looked
+// up in each of scopes in turn.
+func (s *Sheet) fillTemplateRow(y int, scopes ...reflect.Value) error {
+	row := s.Rows[y]
+	if row == nil {
+		return nil
+	}
+	for x, cell := range row.Cells {
+		if !isTemplateCell(cell) {
+			continue
+		}
+		if err := fillTemplateCell(cell, scopes); err != nil {
+			return fmt.Errorf("cell %s: %v", GetCellIDStringFromCoords(x, y), err)
+		}
+	}
+	return nil
+}
+
+// isTemplateCell returns true if the cell is text that could hold
+// placeholders.
+func isTemplateCell(cell *Cell) bool {
+	if cell == nil || cell.formula != "" {
+		return false
+	}
+	return (cell.cellType == CellTypeString || cell.cellType == CellTypeInline) && strings.Contains(cell.Value, "{{")
+}
+
+func fillTemplateCell(cell *Cell, scopes []reflect.Value) error {
+	matches := templatePlaceholder.FindAllStringSubmatchIndex(cell.Value, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	var values []reflect.Value
+	for _, m := range matches {
+		path := cell.Value[m[2]:m[3]]
+		if path == "end" || templateRange.MatchString(path) {
+			return fmt.Errorf("{{%s}} outside of the first or last row of a range", path)
+		}
+		v, ok := lookupTemplateValue(scopes, path)
+		if !ok {
+			return fmt.Errorf("no value for %s", path)
+		}
+		values = append(values, indirectTemplateValue(v))
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(cell.Value) {
+		setTemplateValue(cell, values[0])
+		return nil
+	}
+	var text strings.Builder
+	start := 0
+	for i, m := range matches {
+		text.WriteString(cell.Value[start:m[0]])
+		text.WriteString(templateText(values[i]))
+		start = m[1]
+	}
+	text.WriteString(cell.Value[start:])
+	cell.Value = text.String()
+	cell.richText = nil
+	return nil
+}
+
+// lookupTemplateValue returns the value at path in the first of scopes
+// that has it.  The path "." is the first scope itself.
+func lookupTemplateValue(scopes []reflect.Value, path string) (reflect.Value, bool) {
+	if path == "." {
+		return scopes[0], true
+	}
+	for _, scope := range scopes {
+		if v, ok := templateValueAt(scope, strings.Split(path, ".")); ok {
+			return v, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// templateValueAt returns the value at the path of keys, field names
+// and indexes in v.  A nil map, pointer or interface on the way is an
+// empty value.
+func templateValueAt(v reflect.Value, path []string) (reflect.Value, bool) {
+	for _, part := range path {
+		v = indirectTemplateValue(v)
+		if !v.IsValid() {
+			return v, true
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			v = v.MapIndex(reflect.ValueOf(part).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return v, false
+			}
+		case reflect.Struct:
+			field, ok := v.Type().FieldByNameFunc(func(name string) bool {
+				return strings.EqualFold(name, part)
+			})
+			if !ok || field.PkgPath != "" {
+				return reflect.Value{}, false
+			}
+			v = v.FieldByIndex(field.Index)
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= v.Len() {
+				return reflect.Value{}, false
+			}
+			v = v.Index(i)
+		default:
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+// indirectTemplateValue returns the value that v points to, or the
+// invalid value if it is nil.
+func indirectTemplateValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// setTemplateValue sets the value of a cell to v, with the type of v,
+// keeping the number format of the cell.
+func setTemplateValue(cell *Cell, v reflect.Value) {
+	numFmt := cell.NumFmt
+	switch {
+	case !v.IsValid():
+		cell.SetString("")
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		if numFmt == "" || numFmt == builtInNumFmt[builtInNumFmtIndex_GENERAL] {
+			numFmt = DefaultDateTimeFormat
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+				numFmt = DefaultDateFormat
+			}
+		}
+		cell.SetDateTimeWithFormat(TimeToExcelTime(t, cell.date1904), numFmt)
+	case v.Kind() == reflect.Bool:
+		cell.SetBool(v.Bool())
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		cell.SetNumeric(strconv.FormatInt(v.Int(), 10))
+		cell.NumFmt = numFmt
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uintptr:
+		cell.SetNumeric(strconv.FormatUint(v.Uint(), 10))
+		cell.NumFmt = numFmt
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		cell.SetNumeric(strconv.FormatFloat(v.Float(), 'f', -1, 64))
+		cell.NumFmt = numFmt
+	default:
+		cell.SetString(templateText(v))
+	}
+}
+
+// templateText returns v as it is written into the text of a cell.
+func templateText(v reflect.Value) string {
+	switch {
+	case !v.IsValid():
+		return ""
+	case v.Type() == timeType:
+		return jsonTime(v.Interface().(time.Time))
+	case v.CanInterface():
+		return fmt.Sprint(v.Interface())
+	}
+	return ""
+}
+
+// repeatRows makes n copies of the rows from first to last, in place
+// of the rows themselves.  The copies are inserted below the rows, which
+// moves the rows below and everything that refers to them, and ranges
+// that end in the rows grow to include the copies.  If n is 0, the rows
+// are deleted.
+func (s *Sheet) repeatRows(first, last, n int) error {
+	h := last - first + 1
+	if n == 0 {
+		return s.shiftCells("ExecuteTemplate", h, refShift{at: first, count: -h})
+	}
+	// Merged cells that start in the rows are repeated with them rather
+	// than grow, and end with them, so that the copies don't overlap.
+	var merges []mergedRange
+	for y := first; y <= last; y++ {
+		row := s.Rows[y]
+		if row == nil {
+			continue
+		}
+		for x, cell := range row.Cells {
+			if cell == nil || cell.HMerge == 0 && cell.VMerge == 0 {
+				continue
+			}
+			bottom := y + cell.VMerge
+			if bottom > last {
+				bottom = last
+			}
+			merges = append(merges, mergedRange{y - first, x, bottom - first, x + cell.HMerge})
+			cell.HMerge, cell.VMerge = 0, 0
+		}
+	}
+	if n > 1 {
+		if err := s.shiftCells("ExecuteTemplate", (n-1)*h, refShift{at: last + 1, count: (n - 1) * h, repeat: h}); err != nil {
+			return err
+		}
+	}
+	for i := 1; i < n; i++ {
+		for dy := 0; dy < h; dy++ {
+			s.Rows[first+i*h+dy] = copyRow(s.Rows[first+dy], i*h)
+		}
+	}
+	for i := 0; i < n; i++ {
+		for _, m := range merges {
+			s.Cell(first+i*h+m.firstRow, m.firstCol).Merge(m.lastCol-m.firstCol, m.lastRow-m.firstRow)
+		}
+	}
+	return nil
+}
+
+// copyRow returns a copy of a row, with copies of its cells, that is put
+// dy rows below it.  As in Excel, references in formulas that aren't
+// fixed with a $ move with the copy.
+func copyRow(row *Row, dy int) *Row {
+	if row == nil {
+		return nil
+	}
+	rowCopy := *row
+	rowCopy.Cells = make([]*Cell, len(row.Cells))
+	for x, cell := range row.Cells {
+		if cell == nil {
+			continue
+		}
+		cellCopy := *cell
+		cellCopy.Row = &rowCopy
+		cellCopy.formula = moveFormula(cell.formula, 0, dy)
+		if cell.DataValidation != nil {
+			dv := *cell.DataValidation
+			cellCopy.DataValidation = &dv
+		}
+		rowCopy.Cells[x] = &cellCopy
+	}
+	return &rowCopy
+}
+
+// parseCellRange returns the zero based coordinates of the corners of a
+// range such as "A1:B2", or of a single cell.
+func parseCellRange(ref string) (x1, y1, x2, y2 int, err error) {
+	corners := strings.SplitN(ref, cellRangeChar, 2)
+	if x1, y1, err = GetCoordsFromCellIDString(corners[0]); err != nil {
+		return
+	}
+	x2, y2 = x1, y1
+	if len(corners) == 2 {
+		x2, y2, err = GetCoordsFromCellIDString(corners[1])
+	}
+	return
+}
+
+// cellRangeRef returns a reference to the range from x1, y1 to x2, y2,
+// or to the single cell if they are the same.
+func cellRangeRef(x1, y1, x2, y2 int) string {
+	if x1 == x2 && y1 == y2 {
+		return GetCellIDStringFromCoords(x1, y1)
+	}
+	return GetCellIDStringFromCoords(x1, y1) + cellRangeChar + GetCellIDStringFromCoords(x2, y2)
+}
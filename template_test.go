@@ -0,0 +1,214 @@
+package xlsx
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+type templateCustomer struct {
+	Name    string
+	Address *struct{ City string }
+}
+
+type templateItem struct {
+	Name  string
+	Qty   int
+	Price float64
+	Paid  bool
+}
+
+func TestTemplate(t *testing.T) {
+	c := qt.New(t)
+
+	// invoiceTemplate returns a sheet with placeholders, a row that is
+	// repeated for each item, and merged cells, validations, a
+	// conditional format, a table and an autofilter around it.
+	invoiceTemplate := func(c *qt.C) *Sheet {
+		sheet, err := NewFile().AddSheet("Invoice")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetString("Invoice for {{ customer.name }} in {{customer.address.city}}")
+		sheet.Cell(0, 0).Merge(2, 0)
+		sheet.Cell(0, 3).SetString("{{date}}")
+		sheet.Cell(0, 3).NumFmt = "yyyy-mm-dd"
+		for x, header := range []string{"Item", "Qty", "Price", "Paid", "Notes"} {
+			sheet.Cell(1, x).SetString(header)
+		}
+		sheet.Cell(1, 4).Merge(0, 1)
+		sheet.Cell(2, 0).SetString("{{range items}}{{name}}")
+		sheet.Cell(2, 1).SetString("{{qty}}")
+		sheet.Cell(2, 2).SetString("{{price}}")
+		sheet.Cell(2, 2).NumFmt = "#,##0.00"
+		sheet.Cell(2, 3).SetString("{{paid}}{{end}}")
+		sheet.Cell(2, 3).DataValidation = NewDataValidation(2, 3, 2, 3, true)
+		sheet.Cell(4, 0).SetString("Total")
+		bold := NewStyle()
+		bold.Font.Bold = true
+		sheet.Cell(4, 2).SetString("{{total}}")
+		sheet.Cell(4, 2).SetStyle(bold)
+		sheet.Cell(5, 0).SetString("Thanks, {{customer.name}}!")
+		sheet.Cell(5, 0).Merge(1, 1)
+		sheet.AddDataValidation(NewDataValidation(2, 1, 2, 1, false))
+		sheet.AddDataValidation(NewDataValidation(5, 0, 5, 0, false))
+		sheet.AddConditionalFormat("C3 C5", NewDataBarRule("FF638EC6"))
+		_, err = sheet.AddTable("Items", "A2:D3", []string{"Item", "Qty", "Price", "Paid"}, "", false)
+		c.Assert(err, qt.IsNil)
+		sheet.AutoFilter = &AutoFilter{TopLeftCell: "A2", BottomRightCell: "D3"}
+		return sheet
+	}
+	data := func(items []templateItem) map[string]interface{} {
+		return map[string]interface{}{
+			"customer": &templateCustomer{Name: "Ann", Address: &struct{ City string }{"Berlin"}},
+			"date":     time.Date(2019, 11, 4, 0, 0, 0, 0, time.UTC),
+			"items":    items,
+			"total":    12.5,
+		}
+	}
+	items := []templateItem{
+		{Name: "Widget", Qty: 2, Price: 2.5, Paid: true},
+		{Name: "Gadget", Qty: 1, Price: 5},
+		{Name: "Gizmo", Qty: 1, Price: 2.5, Paid: true},
+	}
+
+	c.Run("Fill", func(c *qt.C) {
+		sheet := invoiceTemplate(c)
+		c.Assert(sheet.ExecuteTemplate(data(items)), qt.IsNil)
+		c.Assert(sheet.Cell(0, 0).Value, qt.Equals, "Invoice for Ann in Berlin")
+		c.Assert(sheet.Cell(0, 0).HMerge, qt.Equals, 2)
+		date := sheet.Cell(0, 3)
+		c.Assert(date.Type(), qt.Equals, CellTypeNumeric)
+		c.Assert(date.NumFmt, qt.Equals, "yyyy-mm-dd")
+		c.Assert(date.String(), qt.Equals, "2019-11-04")
+
+		c.Assert(sheet.MaxRow, qt.Equals, 8)
+		for i, item := range items {
+			y := 2 + i
+			c.Assert(sheet.Cell(y, 0).Value, qt.Equals, item.Name)
+			c.Assert(sheet.Cell(y, 1).Type(), qt.Equals, CellTypeNumeric)
+			qty, err := sheet.Cell(y, 1).Int()
+			c.Assert(err, qt.IsNil)
+			c.Assert(qty, qt.Equals, item.Qty)
+			price, err := sheet.Cell(y, 2).Float()
+			c.Assert(err, qt.IsNil)
+			c.Assert(price, qt.Equals, item.Price)
+			c.Assert(sheet.Cell(y, 2).NumFmt, qt.Equals, "#,##0.00")
+			c.Assert(sheet.Cell(y, 3).Type(), qt.Equals, CellTypeBool)
+			c.Assert(sheet.Cell(y, 3).Bool(), qt.Equals, item.Paid)
+			c.Assert(sheet.Cell(y, 3).DataValidation.Sqref, qt.Equals, "D3")
+			c.Assert(sheet.Rows[y].Cells[0].Row, qt.Equals, sheet.Rows[y])
+		}
+		c.Assert(sheet.Cell(2, 3).DataValidation, qt.Not(qt.Equals), sheet.Cell(3, 3).DataValidation)
+		c.Assert(sheet.Cell(1, 4).VMerge, qt.Equals, 3)
+
+		c.Assert(sheet.Cell(6, 0).Value, qt.Equals, "Total")
+		c.Assert(sheet.Cell(6, 2).Value, qt.Equals, "12.5")
+		c.Assert(sheet.Cell(6, 2).GetStyle().Font.Bold, qt.Equals, true)
+		c.Assert(sheet.Cell(7, 0).Value, qt.Equals, "Thanks, Ann!")
+		c.Assert(sheet.Cell(7, 0).VMerge, qt.Equals, 1)
+
+		c.Assert(sheet.DataValidations, qt.HasLen, 2)
+		c.Assert(sheet.DataValidations[0].Sqref, qt.Equals, "B3:B5")
+		c.Assert(sheet.DataValidations[1].Sqref, qt.Equals, "A8")
+		c.Assert(sheet.ConditionalFormats[0].Ref, qt.Equals, "C3:C5 C7")
+		c.Assert(sheet.Tables[0].Ref, qt.Equals, "A2:D5")
+		c.Assert(*sheet.AutoFilter, qt.Equals, AutoFilter{TopLeftCell: "A2", BottomRightCell: "D5"})
+
+		// The filled sheet is written with the moved references.
+		parts, err := sheet.File.MarshallParts()
+		c.Assert(err, qt.IsNil)
+		file, err := OpenBinary(zipParts(c, parts))
+		c.Assert(err, qt.IsNil)
+		read := file.Sheet["Invoice"]
+		c.Assert(read.Cell(4, 0).Value, qt.Equals, "Gizmo")
+		c.Assert(read.Cell(1, 4).VMerge, qt.Equals, 3)
+		c.Assert(read.Cell(7, 0).VMerge, qt.Equals, 1)
+		c.Assert(*read.AutoFilter, qt.Equals, AutoFilter{TopLeftCell: "A2", BottomRightCell: "D5"})
+		c.Assert(read.Tables[0].Ref, qt.Equals, "A2:D5")
+	})
+
+	c.Run("EmptySlice", func(c *qt.C) {
+		sheet := invoiceTemplate(c)
+		c.Assert(sheet.ExecuteTemplate(data(nil)), qt.IsNil)
+		c.Assert(sheet.MaxRow, qt.Equals, 5)
+		c.Assert(sheet.Cell(3, 0).Value, qt.Equals, "Total")
+		c.Assert(sheet.Cell(4, 0).Value, qt.Equals, "Thanks, Ann!")
+		c.Assert(sheet.Cell(1, 4).VMerge, qt.Equals, 0)
+		c.Assert(sheet.DataValidations, qt.HasLen, 1)
+		c.Assert(sheet.DataValidations[0].Sqref, qt.Equals, "A5")
+		c.Assert(sheet.ConditionalFormats[0].Ref, qt.Equals, "C4")
+		c.Assert(sheet.Tables[0].Ref, qt.Equals, "A2:D2")
+		c.Assert(*sheet.AutoFilter, qt.Equals, AutoFilter{TopLeftCell: "A2", BottomRightCell: "D2"})
+	})
+
+	c.Run("References", func(c *qt.C) {
+		sheet := invoiceTemplate(c)
+		sheet.Cell(2, 5).SetFormula("B3*C3")
+		sheet.Cell(4, 1).SetFormula("SUM(B3:B3)")
+		sheet.Pictures = []*Picture{{Cell: "A6"}}
+		chart := &Chart{Type: ChartTypeBar, Series: []ChartSeries{{Values: "Invoice!$B$3:$B$3"}}}
+		c.Assert(sheet.AddChart("G5", chart, nil), qt.IsNil)
+		file := sheet.File
+		other, err := file.AddSheet("Other")
+		c.Assert(err, qt.IsNil)
+		other.Cell(0, 0).SetFormula("Invoice!C5/COUNT(Invoice!B3:B3)")
+		other.Cell(0, 1).SetHyperlink("#Invoice!C5", "Total", "")
+		file.DefinedNames = append(file.DefinedNames, &xlsxDefinedName{Name: "Total", Data: "Invoice!$C$5"})
+
+		c.Assert(sheet.ExecuteTemplate(data(items)), qt.IsNil)
+		c.Assert(sheet.Cell(6, 1).Formula(), qt.Equals, "SUM(B3:B5)")
+		for y := 2; y <= 4; y++ {
+			c.Assert(sheet.Cell(y, 5).Formula(), qt.Equals, fmt.Sprintf("B%d*C%d", y+1, y+1))
+		}
+		c.Assert(sheet.Pictures[0].Cell, qt.Equals, "A8")
+		c.Assert(chart.Cell, qt.Equals, "G7")
+		c.Assert(chart.Series[0].Values, qt.Equals, "Invoice!$B$3:$B$5")
+		c.Assert(other.Cell(0, 0).Formula(), qt.Equals, "Invoice!C7/COUNT(Invoice!B3:B5)")
+		c.Assert(other.Cell(0, 1).Hyperlink.Link, qt.Equals, "#Invoice!C7")
+		c.Assert(file.DefinedNames[0].Data, qt.Equals, "Invoice!$C$7")
+	})
+
+	c.Run("Struct", func(c *qt.C) {
+		sheet, err := NewFile().AddSheet("Lines")
+		c.Assert(err, qt.IsNil)
+		sheet.Cell(0, 0).SetString("{{range Lines}}{{.}}")
+		sheet.Cell(1, 0).SetString("{{ Lines.1 }} of {{Count}}{{end}}")
+		sheet.Cell(2, 0).SetString("{{missing}}")
+		type lines struct {
+			Lines   []string
+			Count   uint
+			Missing *int
+		}
+		c.Assert(sheet.ExecuteTemplate(&lines{Lines: []string{"a", "b"}, Count: 2}), qt.IsNil)
+		values := []string{}
+		for _, row := range sheet.Rows {
+			values = append(values, row.Cells[0].Value)
+		}
+		c.Assert(values, qt.DeepEquals, []string{"a", "b of 2", "b", "b of 2", ""})
+		c.Assert(sheet.Cell(4, 0).Type(), qt.Equals, CellTypeString)
+	})
+
+	c.Run("Errors", func(c *qt.C) {
+		for _, test := range []struct {
+			cells []string
+			err   string
+		}{
+			{[]string{"{{nobody.name}}"}, `cell A1: no value for nobody.name`},
+			{[]string{"{{customer.Address.Street}}"}, `cell A1: no value for customer.Address.Street`},
+			{[]string{"{{range items}}", "{{name}}"}, `row 1: {{range}} without {{end}}`},
+			{[]string{"{{range items}}", "{{range items}}{{end}}"}, `cell A2: {{range}} can't be nested`},
+			{[]string{"{{range total}}{{end}}"}, `row 1: total is not a slice`},
+			{[]string{"{{end}}"}, `cell A1: {{end}} outside of the first or last row of a range`},
+		} {
+			file := NewFile()
+			sheet, err := file.AddSheet("Errors")
+			c.Assert(err, qt.IsNil)
+			for y, value := range test.cells {
+				sheet.Cell(y, 0).SetString(value)
+			}
+			c.Assert(file.ExecuteTemplate(data(items)), qt.ErrorMatches, `sheet 'Errors': `+regexp.QuoteMeta(test.err))
+		}
+	})
+}
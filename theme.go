@@ -29,7 +29,7 @@ func newTheme(themeXml xlsxTheme) *theme {
 
 func (t *theme) themeColor(index int64, tint float64) string {
 	baseColor := t.colors[index]
-	if tint == 0 {
+	if tint == 0 || len(baseColor) != 6 {
 		return "FF" + baseColor
 	} else {
 		r, _ := strconv.ParseInt(baseColor[0:2], 16, 64)
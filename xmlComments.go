@@ -0,0 +1,35 @@
+package xlsx
+
+import (
+	"encoding/xml"
+)
+
+// xlsxComments directly maps the comments element from the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxComments struct {
+	XMLName     xml.Name          `xml:"http://schemas.openxmlformats.org/spreadsheetml/2006/main comments"`
+	Authors     []string          `xml:"authors>author"`
+	CommentList []xlsxCommentItem `xml:"commentList>comment"`
+}
+
+// xlsxCommentItem directly maps the comment element from the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxCommentItem struct {
+	Ref      string `xml:"ref,attr"`
+	AuthorId int    `xml:"authorId,attr"`
+	Text     xlsxSI `xml:"text"`
+}
+
+// text returns the plain text of the comment, joining the runs of
+// rich text together.
+func (c *xlsxCommentItem) text() string {
+	text := c.Text.T
+	for _, r := range c.Text.R {
+		text += r.T.Text
+	}
+	return text
+}
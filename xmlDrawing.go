@@ -0,0 +1,67 @@
+package xlsx
+
+import (
+	"encoding/xml"
+)
+
+// xlsxWsDr directly maps the wsDr element in the namespace
+// http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxWsDr struct {
+	XMLName xml.Name            `xml:"http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing wsDr"`
+	Anchors []xlsxDrawingAnchor `xml:",any"`
+}
+
+// xlsxDrawingAnchor maps the oneCellAnchor, twoCellAnchor and
+// absoluteAnchor elements in the namespace
+// http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxDrawingAnchor struct {
+	XMLName xml.Name
+	From    *xlsxDrawingMarker `xml:"from"`
+	To      *xlsxDrawingMarker `xml:"to"`
+	Ext     *xlsxDrawingExt    `xml:"ext"`
+	Pic     *xlsxDrawingPic    `xml:"pic"`
+}
+
+// xlsxDrawingMarker maps the from and to elements in the namespace
+// http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing
+type xlsxDrawingMarker struct {
+	Col    int `xml:"col"`
+	ColOff int `xml:"colOff"`
+	Row    int `xml:"row"`
+	RowOff int `xml:"rowOff"`
+}
+
+// xlsxDrawingExt maps the ext element in the namespaces
+// http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing
+// and http://schemas.openxmlformats.org/drawingml/2006/main
+type xlsxDrawingExt struct {
+	Cx int `xml:"cx,attr"`
+	Cy int `xml:"cy,attr"`
+}
+
+// xlsxDrawingPic directly maps the pic element in the namespace
+// http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxDrawingPic struct {
+	NvPicPr struct {
+		CNvPr struct {
+			Name  string `xml:"name,attr"`
+			Descr string `xml:"descr,attr"`
+		} `xml:"cNvPr"`
+	} `xml:"nvPicPr"`
+	BlipFill struct {
+		Blip struct {
+			Embed string `xml:"embed,attr"`
+		} `xml:"blip"`
+	} `xml:"blipFill"`
+	SpPr struct {
+		Xfrm struct {
+			Ext xlsxDrawingExt `xml:"ext"`
+		} `xml:"xfrm"`
+	} `xml:"spPr"`
+}
@@ -23,10 +23,49 @@ type xlsxSI struct {
 	R []xlsxR `xml:"r"`
 }
 
+// MarshalXML writes the si element either as plain text, or as runs
+// of rich text if it has any.  Excel won't accept both at once.
+func (si xlsxSI) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(si.R) == 0 {
+		return e.EncodeElement(struct {
+			T string `xml:"t"`
+		}{si.T}, start)
+	}
+	return e.EncodeElement(struct {
+		R []xlsxR `xml:"r"`
+	}{si.R}, start)
+}
+
 // xlsxR directly maps the r element from the namespace
 // http://schemas.openxmlformats.org/spreadsheetml/2006/main -
 // currently I have not checked this for completeness - it does as
 // much as I need.
 type xlsxR struct {
-	T string `xml:"t"`
+	RPr *xlsxRPr `xml:"rPr,omitempty"`
+	T   xlsxT    `xml:"t"`
+}
+
+// xlsxT directly maps the t element from the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.  Runs of rich text often start or end with a
+// space, which must be preserved.
+type xlsxT struct {
+	Space string `xml:"http://www.w3.org/XML/1998/namespace space,attr,omitempty"`
+	Text  string `xml:",chardata"`
+}
+
+// xlsxRPr directly maps the rPr element from the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxRPr struct {
+	RFont   *xlsxVal   `xml:"rFont,omitempty"`
+	Charset *xlsxVal   `xml:"charset,omitempty"`
+	Family  *xlsxVal   `xml:"family,omitempty"`
+	B       *xlsxVal   `xml:"b,omitempty"`
+	I       *xlsxVal   `xml:"i,omitempty"`
+	Color   *xlsxColor `xml:"color,omitempty"`
+	Sz      *xlsxVal   `xml:"sz,omitempty"`
+	U       *xlsxVal   `xml:"u,omitempty"`
 }
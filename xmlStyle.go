@@ -49,7 +49,7 @@ var builtInNumFmt = map[int]string{
 	39: "#,##0.00;(#,##0.00)",
 	40: "#,##0.00;[red](#,##0.00)",
 	41: `_(* #,##0_);_(* \(#,##0\);_(* "-"_);_(@_)`,
-	42: `_("$"* #,##0_);_("$* \(#,##0\);_("$"* "-"_);_(@_)`,
+	42: `_("$"* #,##0_);_("$"* \(#,##0\);_("$"* "-"_);_(@_)`,
 	43: `_(* #,##0.00_);_(* \(#,##0.00\);_(* "-"??_);_(@_)`,
 	44: `_("$"* #,##0.00_);_("$"* \(#,##0.00\);_("$"* "-"??_);_(@_)`,
 	45: "mm:ss",
@@ -155,6 +155,7 @@ func (styles *xlsxStyleSheet) reset() {
 	styles.CellXfs = xlsxCellXfs{Count: 1, Xf: []xlsxXf{{}}}
 	styles.NumFmts = &xlsxNumFmts{}
 	styles.numFmtRefTable = nil
+	styles.DXfs = xlsxDXFs{}
 }
 
 //
@@ -163,8 +164,15 @@ func (styles *xlsxStyleSheet) populateStyleFromXf(style *Style, xf xlsxXf) {
 	style.ApplyFill = xf.ApplyFill
 	style.ApplyFont = xf.ApplyFont
 	style.ApplyAlignment = xf.ApplyAlignment
+	style.ApplyProtection = xf.ApplyProtection
 
-	if xf.BorderId > -1 && xf.BorderId < styles.Borders.Count {
+	style.Protection = *DefaultProtection()
+	if xf.Protection != nil {
+		style.Protection.Locked = xf.Protection.isLocked()
+		style.Protection.Hidden = xf.Protection.Hidden
+	}
+
+	if xf.BorderId > -1 && xf.BorderId < len(styles.Borders.Border) {
 		var border xlsxBorder
 		border = styles.Borders.Border[xf.BorderId]
 		style.Border.Left = border.Left.Style
@@ -177,14 +185,14 @@ func (styles *xlsxStyleSheet) populateStyleFromXf(style *Style, xf xlsxXf) {
 		style.Border.BottomColor = border.Bottom.Color.RGB
 	}
 
-	if xf.FillId > -1 && xf.FillId < styles.Fills.Count {
+	if xf.FillId > -1 && xf.FillId < len(styles.Fills.Fill) {
 		xFill := styles.Fills.Fill[xf.FillId]
 		style.Fill.PatternType = xFill.PatternFill.PatternType
 		style.Fill.FgColor = styles.argbValue(xFill.PatternFill.FgColor)
 		style.Fill.BgColor = styles.argbValue(xFill.PatternFill.BgColor)
 	}
 
-	if xf.FontId > -1 && xf.FontId < styles.Fonts.Count {
+	if xf.FontId > -1 && xf.FontId < len(styles.Fonts.Font) {
 		xfont := styles.Fonts.Font[xf.FontId]
 		style.Font.Size, _ = strconv.Atoi(xfont.Sz.Val)
 		style.Font.Name = xfont.Name.Val
@@ -230,8 +238,7 @@ func (styles *xlsxStyleSheet) getStyle(styleIndex int) *Style {
 
 	style = &Style{}
 
-	xfCount := styles.CellXfs.Count
-	if styleIndex > -1 && xfCount > 0 && styleIndex < xfCount {
+	if styleIndex > -1 && styleIndex < len(styles.CellXfs.Xf) {
 		xf := styles.CellXfs.Xf[styleIndex]
 		styles.populateStyleFromXf(style, xf)
 		if xf.XfId != nil && styles.CellStyleXfs != nil && *xf.XfId < len(styles.CellStyleXfs.Xf) {
@@ -241,6 +248,7 @@ func (styles *xlsxStyleSheet) getStyle(styleIndex int) *Style {
 			style.ApplyFill = style.ApplyFill || namedStyleXf.ApplyFill
 			style.ApplyFont = style.ApplyFont || namedStyleXf.ApplyFont
 			style.ApplyAlignment = style.ApplyAlignment || namedStyleXf.ApplyAlignment
+			style.ApplyProtection = style.ApplyProtection || namedStyleXf.ApplyProtection
 		}
 
 		if xf.Alignment.Vertical != "" {
@@ -257,10 +265,10 @@ func (styles *xlsxStyleSheet) getStyle(styleIndex int) *Style {
 }
 
 func (styles *xlsxStyleSheet) argbValue(color xlsxColor) string {
-	if color.Theme != nil && styles.theme != nil {
+	if color.Theme != nil && styles.theme != nil && *color.Theme >= 0 && *color.Theme < len(styles.theme.colors) {
 		return styles.theme.themeColor(int64(*color.Theme), color.Tint)
 	}
-	if color.Indexed != nil && styles.Colors != nil {
+	if color.Indexed != nil && styles.Colors != nil && *color.Indexed > 0 && *color.Indexed <= len(styles.Colors.IndexedColors) {
 		return styles.Colors.indexedColor(*color.Indexed, color.Tint)
 	}
 	return color.RGB
@@ -280,25 +288,31 @@ func getBuiltinNumberFormat(numFmtId int) string {
 func (styles *xlsxStyleSheet) getNumberFormat(styleIndex int) (string, *parsedNumberFormat) {
 	var numberFormat string = "general"
 	if styles.CellXfs.Xf != nil {
-		if styleIndex > -1 && styleIndex < styles.CellXfs.Count {
+		if styleIndex > -1 && styleIndex < len(styles.CellXfs.Xf) {
 			xf := styles.CellXfs.Xf[styleIndex]
 			if builtin := getBuiltinNumberFormat(xf.NumFmtId); builtin != "" {
 				numberFormat = builtin
 			} else {
+				styles.RLock()
 				if styles.numFmtRefTable != nil {
 					numFmt := styles.numFmtRefTable[xf.NumFmtId]
 					numberFormat = numFmt.FormatCode
 				}
+				styles.RUnlock()
 			}
 		}
 	}
+	styles.RLock()
 	parsedFmt, ok := styles.parsedNumFmtTable[numberFormat]
+	styles.RUnlock()
 	if !ok {
+		parsedFmt = parseFullNumberFormatString(numberFormat)
+		styles.Lock()
 		if styles.parsedNumFmtTable == nil {
 			styles.parsedNumFmtTable = map[string]*parsedNumberFormat{}
 		}
-		parsedFmt = parseFullNumberFormatString(numberFormat)
 		styles.parsedNumFmtTable[numberFormat] = parsedFmt
+		styles.Unlock()
 	}
 	return numberFormat, parsedFmt
 }
@@ -376,6 +390,19 @@ func (styles *xlsxStyleSheet) addCellXf(xCellXf xlsxXf) (index int) {
 	return
 }
 
+func (styles *xlsxStyleSheet) addDXF(xDXF *xlsxDXF) (index int) {
+	xmarshalled, _ := xDXF.Marshal()
+	for i, dxf := range styles.DXfs.Dxf {
+		if marshalled, _ := dxf.Marshal(); marshalled == xmarshalled {
+			return i
+		}
+	}
+	styles.DXfs.Dxf = append(styles.DXfs.Dxf, xDXF)
+	index = styles.DXfs.Count
+	styles.DXfs.Count++
+	return
+}
+
 // newNumFmt generate a xlsxNumFmt according the format code. When the FormatCode is built in, it will return a xlsxNumFmt with the NumFmtId defined in ECMA document, otherwise it will generate a new NumFmtId greater than 164.
 func (styles *xlsxStyleSheet) newNumFmt(formatCode string) xlsxNumFmt {
 	if compareFormatString(formatCode, "general") {
@@ -486,11 +513,88 @@ func (styles *xlsxStyleSheet) Marshal() (string, error) {
 		result += xcellStyles
 	}
 
+	xdxfs, err := styles.DXfs.Marshal()
+	if err != nil {
+		return "", err
+	}
+	result += xdxfs
+
 	return result + "</styleSheet>", nil
 }
 
+// xlsxDXFs directly maps the dxfs element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
 type xlsxDXFs struct {
-	Count int `xml:"count,attr"`
+	Count int        `xml:"count,attr"`
+	Dxf   []*xlsxDXF `xml:"dxf,omitempty"`
+}
+
+func (dxfs *xlsxDXFs) Marshal() (result string, err error) {
+	if dxfs.Count > 0 {
+		result = fmt.Sprintf(`<dxfs count="%d">`, dxfs.Count)
+		for _, dxf := range dxfs.Dxf {
+			var xdxf string
+			xdxf, err = dxf.Marshal()
+			if err != nil {
+				return
+			}
+			result += xdxf
+		}
+		result += `</dxfs>`
+	}
+	return
+}
+
+// xlsxDXF directly maps the dxf element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.  A dxf describes differential formatting, that is only
+// the properties that are to be changed, as used by conditional
+// formats.
+type xlsxDXF struct {
+	Font   *xlsxFont   `xml:"font,omitempty"`
+	NumFmt *xlsxNumFmt `xml:"numFmt,omitempty"`
+	Fill   *xlsxFill   `xml:"fill,omitempty"`
+	Border *xlsxBorder `xml:"border,omitempty"`
+}
+
+func (dxf *xlsxDXF) Marshal() (result string, err error) {
+	result = "<dxf>"
+	if dxf.Font != nil {
+		var xfont string
+		xfont, err = dxf.Font.Marshal()
+		if err != nil {
+			return
+		}
+		result += xfont
+	}
+	if dxf.NumFmt != nil {
+		var xnumFmt string
+		xnumFmt, err = dxf.NumFmt.Marshal()
+		if err != nil {
+			return
+		}
+		result += xnumFmt
+	}
+	if dxf.Fill != nil {
+		var xfill string
+		xfill, err = dxf.Fill.Marshal()
+		if err != nil {
+			return
+		}
+		result += xfill
+	}
+	if dxf.Border != nil {
+		var xborder string
+		xborder, err = dxf.Border.Marshal()
+		if err != nil {
+			return
+		}
+		result += xborder
+	}
+	return result + "</dxf>", nil
 }
 
 // xlsxNumFmts directly maps the numFmts element in the namespace
@@ -963,18 +1067,19 @@ func (cellXfs *xlsxCellXfs) Marshal(outputBorderMap, outputFillMap, outputFontMa
 // currently I have not checked it for completeness - it does as much
 // as I need.
 type xlsxXf struct {
-	ApplyAlignment    bool          `xml:"applyAlignment,attr"`
-	ApplyBorder       bool          `xml:"applyBorder,attr"`
-	ApplyFont         bool          `xml:"applyFont,attr"`
-	ApplyFill         bool          `xml:"applyFill,attr"`
-	ApplyNumberFormat bool          `xml:"applyNumberFormat,attr"`
-	ApplyProtection   bool          `xml:"applyProtection,attr"`
-	BorderId          int           `xml:"borderId,attr"`
-	FillId            int           `xml:"fillId,attr"`
-	FontId            int           `xml:"fontId,attr"`
-	NumFmtId          int           `xml:"numFmtId,attr"`
-	XfId              *int          `xml:"xfId,attr,omitempty"`
-	Alignment         xlsxAlignment `xml:"alignment"`
+	ApplyAlignment    bool            `xml:"applyAlignment,attr"`
+	ApplyBorder       bool            `xml:"applyBorder,attr"`
+	ApplyFont         bool            `xml:"applyFont,attr"`
+	ApplyFill         bool            `xml:"applyFill,attr"`
+	ApplyNumberFormat bool            `xml:"applyNumberFormat,attr"`
+	ApplyProtection   bool            `xml:"applyProtection,attr"`
+	BorderId          int             `xml:"borderId,attr"`
+	FillId            int             `xml:"fillId,attr"`
+	FontId            int             `xml:"fontId,attr"`
+	NumFmtId          int             `xml:"numFmtId,attr"`
+	XfId              *int            `xml:"xfId,attr,omitempty"`
+	Alignment         xlsxAlignment   `xml:"alignment"`
+	Protection        *xlsxProtection `xml:"protection"`
 }
 
 func (xf *xlsxXf) Equals(other xlsxXf) bool {
@@ -990,7 +1095,10 @@ func (xf *xlsxXf) Equals(other xlsxXf) bool {
 		(xf.XfId == other.XfId ||
 			((xf.XfId != nil && other.XfId != nil) &&
 				*xf.XfId == *other.XfId)) &&
-		xf.Alignment.Equals(other.Alignment)
+		xf.Alignment.Equals(other.Alignment) &&
+		((xf.Protection == nil && other.Protection == nil) ||
+			((xf.Protection != nil && other.Protection != nil) &&
+				xf.Protection.Equals(*other.Protection)))
 }
 
 func (xf *xlsxXf) Marshal(outputBorderMap, outputFillMap, outputFontMap map[int]int) (result string, err error) {
@@ -1003,7 +1111,11 @@ func (xf *xlsxXf) Marshal(outputBorderMap, outputFillMap, outputFontMap map[int]
 	if err != nil {
 		return result, err
 	}
-	return result + xAlignment + "</xf>", nil
+	result += xAlignment
+	if xf.Protection != nil {
+		result += xf.Protection.Marshal()
+	}
+	return result + "</xf>", nil
 }
 
 type xlsxAlignment struct {
@@ -1034,6 +1146,30 @@ func (alignment *xlsxAlignment) Marshal() (result string, err error) {
 	return fmt.Sprintf(`<alignment horizontal="%s" indent="%d" shrinkToFit="%b" textRotation="%d" vertical="%s" wrapText="%b"/>`, alignment.Horizontal, alignment.Indent, bool2Int(alignment.ShrinkToFit), alignment.TextRotation, alignment.Vertical, bool2Int(alignment.WrapText)), nil
 }
 
+// xlsxProtection directly maps the protection element in the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main
+// - currently I have not checked it for completeness - it does as
+// much as I need.
+type xlsxProtection struct {
+	// Locked is a pointer because cells are locked unless the
+	// attribute says otherwise.
+	Locked *bool `xml:"locked,attr"`
+	Hidden bool  `xml:"hidden,attr"`
+}
+
+func (protection *xlsxProtection) isLocked() bool {
+	return protection.Locked == nil || *protection.Locked
+}
+
+func (protection *xlsxProtection) Equals(other xlsxProtection) bool {
+	return protection.isLocked() == other.isLocked() &&
+		protection.Hidden == other.Hidden
+}
+
+func (protection *xlsxProtection) Marshal() string {
+	return fmt.Sprintf(`<protection locked="%b" hidden="%b"/>`, bool2Int(protection.isLocked()), bool2Int(protection.Hidden))
+}
+
 func bool2Int(b bool) int {
 	if b {
 		return 1
@@ -0,0 +1,57 @@
+package xlsx
+
+import (
+	"encoding/xml"
+)
+
+// xlsxTable directly maps the table element from the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxTable struct {
+	XMLName        xml.Name            `xml:"http://schemas.openxmlformats.org/spreadsheetml/2006/main table"`
+	Id             int                 `xml:"id,attr"`
+	Name           string              `xml:"name,attr"`
+	DisplayName    string              `xml:"displayName,attr"`
+	Ref            string              `xml:"ref,attr"`
+	HeaderRowCount *int                `xml:"headerRowCount,attr"`
+	TotalsRowCount int                 `xml:"totalsRowCount,attr,omitempty"`
+	TotalsRowShown *bool               `xml:"totalsRowShown,attr"`
+	AutoFilter     *xlsxAutoFilter     `xml:"autoFilter"`
+	TableColumns   xlsxTableColumns    `xml:"tableColumns"`
+	TableStyleInfo *xlsxTableStyleInfo `xml:"tableStyleInfo"`
+}
+
+// xlsxTableColumns directly maps the tableColumns element from the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main
+// - currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxTableColumns struct {
+	Count       int               `xml:"count,attr"`
+	TableColumn []xlsxTableColumn `xml:"tableColumn"`
+}
+
+// xlsxTableColumn directly maps the tableColumn element from the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main
+// - currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxTableColumn struct {
+	Id                      int    `xml:"id,attr"`
+	Name                    string `xml:"name,attr"`
+	TotalsRowLabel          string `xml:"totalsRowLabel,attr,omitempty"`
+	TotalsRowFunction       string `xml:"totalsRowFunction,attr,omitempty"`
+	CalculatedColumnFormula string `xml:"calculatedColumnFormula,omitempty"`
+}
+
+// xlsxTableStyleInfo directly maps the tableStyleInfo element from
+// the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked this for completeness - it does as
+// much as I need.
+type xlsxTableStyleInfo struct {
+	Name              string `xml:"name,attr,omitempty"`
+	ShowFirstColumn   bool   `xml:"showFirstColumn,attr"`
+	ShowLastColumn    bool   `xml:"showLastColumn,attr"`
+	ShowRowStripes    bool   `xml:"showRowStripes,attr"`
+	ShowColumnStripes bool   `xml:"showColumnStripes,attr"`
+}
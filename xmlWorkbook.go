@@ -24,9 +24,10 @@ type xlsxWorkbookRels struct {
 
 // xmlxWorkbookRelation maps sheet id and xl/worksheets/sheet%d.xml
 type xlsxWorkbookRelation struct {
-	Id     string `xml:",attr"`
-	Target string `xml:",attr"`
-	Type   string `xml:",attr"`
+	Id         string `xml:",attr"`
+	Target     string `xml:",attr"`
+	Type       string `xml:",attr"`
+	TargetMode string `xml:",attr,omitempty"`
 }
 
 // xlsxWorkbook directly maps the workbook element from the namespace
@@ -49,7 +50,13 @@ type xlsxWorkbook struct {
 // - currently I have not checked it for completeness - it does as
 // much as I need.
 type xlsxWorkbookProtection struct {
-	// We don't need this, yet.
+	WorkbookAlgorithmName string `xml:"workbookAlgorithmName,attr,omitempty"`
+	WorkbookHashValue     string `xml:"workbookHashValue,attr,omitempty"`
+	WorkbookSaltValue     string `xml:"workbookSaltValue,attr,omitempty"`
+	WorkbookSpinCount     int    `xml:"workbookSpinCount,attr,omitempty"`
+	WorkbookPassword      string `xml:"workbookPassword,attr,omitempty"`
+	LockStructure         bool   `xml:"lockStructure,attr,omitempty"`
+	LockWindows           bool   `xml:"lockWindows,attr,omitempty"`
 }
 
 // xlsxFileVersion directly maps the fileVersion element from the
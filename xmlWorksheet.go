@@ -2,13 +2,20 @@ package xlsx
 
 import (
 	"encoding/xml"
+	"fmt"
 	"strings"
 )
 
 type RelationshipType string
 
 const (
-	RelationshipTypeHyperlink RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	RelationshipTypeHyperlink  RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	RelationshipTypeComments   RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+	RelationshipTypeVMLDrawing RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/vmlDrawing"
+	RelationshipTypeDrawing    RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing"
+	RelationshipTypeImage      RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	RelationshipTypeChart      RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart"
+	RelationshipTypeTable      RelationshipType = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/table"
 )
 
 type RelationshipTargetMode string
@@ -27,7 +34,7 @@ type xlsxWorksheetRelation struct {
 	Id         string                 `xml:"Id,attr"`
 	Type       RelationshipType       `xml:"Type,attr"`
 	Target     string                 `xml:"Target,attr"`
-	TargetMode RelationshipTargetMode `xml:"TargetMode,attr"`
+	TargetMode RelationshipTargetMode `xml:"TargetMode,attr,omitempty"`
 }
 
 // xlsxWorksheet directly maps the worksheet element in the namespace
@@ -35,21 +42,55 @@ type xlsxWorksheetRelation struct {
 // currently I have not checked it for completeness - it does as much
 // as I need.
 type xlsxWorksheet struct {
-	XMLName         xml.Name             `xml:"http://schemas.openxmlformats.org/spreadsheetml/2006/main worksheet"`
-	SheetPr         xlsxSheetPr          `xml:"sheetPr"`
-	Dimension       xlsxDimension        `xml:"dimension"`
-	SheetViews      xlsxSheetViews       `xml:"sheetViews"`
-	SheetFormatPr   xlsxSheetFormatPr    `xml:"sheetFormatPr"`
-	Cols            *xlsxCols            `xml:"cols,omitempty"`
-	SheetData       xlsxSheetData        `xml:"sheetData"`
-	Hyperlinks      *xlsxHyperlinks      `xml:"hyperlinks,omitempty"`
-	DataValidations *xlsxDataValidations `xml:"dataValidations"`
-	AutoFilter      *xlsxAutoFilter      `xml:"autoFilter,omitempty"`
-	MergeCells      *xlsxMergeCells      `xml:"mergeCells,omitempty"`
-	PrintOptions    xlsxPrintOptions     `xml:"printOptions"`
-	PageMargins     xlsxPageMargins      `xml:"pageMargins"`
-	PageSetUp       xlsxPageSetUp        `xml:"pageSetup"`
-	HeaderFooter    xlsxHeaderFooter     `xml:"headerFooter"`
+	XMLName               xml.Name                     `xml:"http://schemas.openxmlformats.org/spreadsheetml/2006/main worksheet"`
+	SheetPr               xlsxSheetPr                  `xml:"sheetPr"`
+	Dimension             xlsxDimension                `xml:"dimension"`
+	SheetViews            xlsxSheetViews               `xml:"sheetViews"`
+	SheetFormatPr         xlsxSheetFormatPr            `xml:"sheetFormatPr"`
+	Cols                  *xlsxCols                    `xml:"cols,omitempty"`
+	SheetData             xlsxSheetData                `xml:"sheetData"`
+	SheetProtection       *xlsxSheetProtection         `xml:"sheetProtection,omitempty"`
+	AutoFilter            *xlsxAutoFilter              `xml:"autoFilter,omitempty"`
+	MergeCells            *xlsxMergeCells              `xml:"mergeCells,omitempty"`
+	ConditionalFormatting []*xlsxConditionalFormatting `xml:"conditionalFormatting,omitempty"`
+	DataValidations       *xlsxDataValidations         `xml:"dataValidations"`
+	Hyperlinks            *xlsxHyperlinks              `xml:"hyperlinks,omitempty"`
+	PrintOptions          xlsxPrintOptions             `xml:"printOptions"`
+	PageMargins           xlsxPageMargins              `xml:"pageMargins"`
+	PageSetUp             xlsxPageSetUp                `xml:"pageSetup"`
+	HeaderFooter          xlsxHeaderFooter             `xml:"headerFooter"`
+	Drawing               *xlsxDrawing                 `xml:"drawing,omitempty"`
+	LegacyDrawing         *xlsxLegacyDrawing           `xml:"legacyDrawing,omitempty"`
+	TableParts            *xlsxTableParts              `xml:"tableParts,omitempty"`
+}
+
+// xlsxSheetProtection directly maps the sheetProtection element in
+// the namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main
+// - currently I have not checked it for completeness - it does as
+// much as I need.  The flags are pointers because their defaults
+// differ, and each one says that something is protected.
+type xlsxSheetProtection struct {
+	AlgorithmName       string `xml:"algorithmName,attr,omitempty"`
+	HashValue           string `xml:"hashValue,attr,omitempty"`
+	SaltValue           string `xml:"saltValue,attr,omitempty"`
+	SpinCount           int    `xml:"spinCount,attr,omitempty"`
+	Password            string `xml:"password,attr,omitempty"`
+	Sheet               bool   `xml:"sheet,attr"`
+	Objects             *bool  `xml:"objects,attr"`
+	Scenarios           *bool  `xml:"scenarios,attr"`
+	FormatCells         *bool  `xml:"formatCells,attr"`
+	FormatColumns       *bool  `xml:"formatColumns,attr"`
+	FormatRows          *bool  `xml:"formatRows,attr"`
+	InsertColumns       *bool  `xml:"insertColumns,attr"`
+	InsertRows          *bool  `xml:"insertRows,attr"`
+	InsertHyperlinks    *bool  `xml:"insertHyperlinks,attr"`
+	DeleteColumns       *bool  `xml:"deleteColumns,attr"`
+	DeleteRows          *bool  `xml:"deleteRows,attr"`
+	SelectLockedCells   *bool  `xml:"selectLockedCells,attr"`
+	Sort                *bool  `xml:"sort,attr"`
+	AutoFilter          *bool  `xml:"autoFilter,attr"`
+	PivotTables         *bool  `xml:"pivotTables,attr"`
+	SelectUnlockedCells *bool  `xml:"selectUnlockedCells,attr"`
 }
 
 // xlsxHeaderFooter directly maps the headerFooter element in the namespace
@@ -339,6 +380,39 @@ func (mc *xlsxMergeCells) addCell(cell xlsxMergeCell) {
 	mc.CellsMap[cellRefs[0]] = cell
 }
 
+// xlsxDrawing directly maps the drawing element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxDrawing struct {
+	RelationshipId string `xml:"id,attr"`
+}
+
+// xlsxLegacyDrawing directly maps the legacyDrawing element in the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxLegacyDrawing struct {
+	RelationshipId string `xml:"id,attr"`
+}
+
+// xlsxTableParts directly maps the tableParts element in the
+// namespace http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxTableParts struct {
+	Count     int             `xml:"count,attr"`
+	TablePart []xlsxTablePart `xml:"tablePart"`
+}
+
+// xlsxTablePart directly maps the tablePart element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxTablePart struct {
+	RelationshipId string `xml:"id,attr"`
+}
+
 type xlsxHyperlinks struct {
 	HyperLinks []xlsxHyperlink `xml:"hyperlink"`
 }
@@ -358,6 +432,9 @@ func (mc *xlsxMergeCells) getExtent(cellRef string) (int, int, error) {
 	}
 	if cell, ok := mc.CellsMap[cellRef]; ok {
 		parts := strings.Split(cell.Ref, ":")
+		if len(parts) != 2 {
+			return -1, -1, fmt.Errorf("invalid range %q", cell.Ref)
+		}
 		startx, starty, err := GetCoordsFromCellIDString(parts[0])
 		if err != nil {
 			return -1, -1, err
@@ -371,6 +448,83 @@ func (mc *xlsxMergeCells) getExtent(cellRef string) (int, int, error) {
 	return 0, 0, nil
 }
 
+// xlsxConditionalFormatting directly maps the conditionalFormatting
+// element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxConditionalFormatting struct {
+	SQRef  string        `xml:"sqref,attr"`
+	CfRule []*xlsxCfRule `xml:"cfRule"`
+}
+
+// xlsxCfRule directly maps the cfRule element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxCfRule struct {
+	Type         string          `xml:"type,attr,omitempty"`
+	DxfID        *int            `xml:"dxfId,attr"`
+	Priority     int             `xml:"priority,attr"`
+	StopIfTrue   bool            `xml:"stopIfTrue,attr,omitempty"`
+	AboveAverage *bool           `xml:"aboveAverage,attr"`
+	Percent      bool            `xml:"percent,attr,omitempty"`
+	Bottom       bool            `xml:"bottom,attr,omitempty"`
+	Operator     string          `xml:"operator,attr,omitempty"`
+	Text         string          `xml:"text,attr,omitempty"`
+	TimePeriod   string          `xml:"timePeriod,attr,omitempty"`
+	Rank         int             `xml:"rank,attr,omitempty"`
+	StdDev       int             `xml:"stdDev,attr,omitempty"`
+	EqualAverage bool            `xml:"equalAverage,attr,omitempty"`
+	Formula      []string        `xml:"formula,omitempty"`
+	ColorScale   *xlsxColorScale `xml:"colorScale"`
+	DataBar      *xlsxDataBar    `xml:"dataBar"`
+	IconSet      *xlsxIconSet    `xml:"iconSet"`
+}
+
+// xlsxCfvo directly maps the cfvo element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxCfvo struct {
+	Type string `xml:"type,attr"`
+	Val  string `xml:"val,attr,omitempty"`
+	Gte  *bool  `xml:"gte,attr"`
+}
+
+// xlsxColorScale directly maps the colorScale element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxColorScale struct {
+	Cfvo  []xlsxCfvo  `xml:"cfvo"`
+	Color []xlsxColor `xml:"color"`
+}
+
+// xlsxDataBar directly maps the dataBar element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxDataBar struct {
+	MinLength *int        `xml:"minLength,attr"`
+	MaxLength *int        `xml:"maxLength,attr"`
+	ShowValue *bool       `xml:"showValue,attr"`
+	Cfvo      []xlsxCfvo  `xml:"cfvo"`
+	Color     []xlsxColor `xml:"color"`
+}
+
+// xlsxIconSet directly maps the iconSet element in the namespace
+// http://schemas.openxmlformats.org/spreadsheetml/2006/main -
+// currently I have not checked it for completeness - it does as much
+// as I need.
+type xlsxIconSet struct {
+	IconSet   string     `xml:"iconSet,attr,omitempty"`
+	ShowValue *bool      `xml:"showValue,attr"`
+	Percent   *bool      `xml:"percent,attr"`
+	Reverse   bool       `xml:"reverse,attr,omitempty"`
+	Cfvo      []xlsxCfvo `xml:"cfvo"`
+}
+
 // xlsxC directly maps the c element in the namespace
 // http://schemas.openxmlformats.org/spreadsheetml/2006/main -
 // currently I have not checked it for completeness - it does as much